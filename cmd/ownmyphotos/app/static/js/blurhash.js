@@ -0,0 +1,128 @@
+/*
+ * Minimal BlurHash decoder. See https://blurha.sh for the algorithm.
+ */
+const characters = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~";
+
+function decode83(str) {
+   let value = 0;
+
+   for (const c of str) {
+      value = value * 83 + characters.indexOf(c);
+   }
+
+   return value;
+}
+
+function sRGBToLinear(value) {
+   const v = value / 255;
+   return v <= 0.04045 ? v / 12.92 : Math.pow((v + 0.055) / 1.055, 2.4);
+}
+
+function linearTosRGB(value) {
+   const v = Math.max(0, Math.min(1, value));
+   return v <= 0.0031308 ? Math.round(v * 12.92 * 255) : Math.round((1.055 * Math.pow(v, 1 / 2.4) - 0.055) * 255);
+}
+
+function signPow(value, exp) {
+   return Math.sign(value) * Math.pow(Math.abs(value), exp);
+}
+
+/**
+ * Decodes a BlurHash string into RGBA pixel data of the requested size.
+ * Returns null if the hash is malformed.
+ */
+export function decodeBlurHash(hash, width, height) {
+   if (!hash || hash.length < 6) {
+      return null;
+   }
+
+   const sizeFlag = decode83(hash[0]);
+   const numY = Math.floor(sizeFlag / 9) + 1;
+   const numX = (sizeFlag % 9) + 1;
+
+   if (hash.length !== 4 + 2 * numX * numY) {
+      return null;
+   }
+
+   const maximumValue = (decode83(hash[1]) + 1) / 166;
+   const colors = [];
+
+   for (let i = 0; i < numX * numY; i++) {
+      if (i === 0) {
+         const value = decode83(hash.substring(2, 6));
+         colors.push([sRGBToLinear(value >> 16), sRGBToLinear((value >> 8) & 255), sRGBToLinear(value & 255)]);
+      } else {
+         const value = decode83(hash.substring(4 + i * 2, 6 + i * 2));
+         const quantR = Math.floor(value / (19 * 19));
+         const quantG = Math.floor(value / 19) % 19;
+         const quantB = value % 19;
+
+         colors.push([
+            signPow((quantR - 9) / 9, 2.0) * maximumValue,
+            signPow((quantG - 9) / 9, 2.0) * maximumValue,
+            signPow((quantB - 9) / 9, 2.0) * maximumValue,
+         ]);
+      }
+   }
+
+   const pixels = new Uint8ClampedArray(width * height * 4);
+
+   for (let y = 0; y < height; y++) {
+      for (let x = 0; x < width; x++) {
+         let r = 0;
+         let g = 0;
+         let b = 0;
+
+         for (let j = 0; j < numY; j++) {
+            for (let i = 0; i < numX; i++) {
+               const basis = Math.cos((Math.PI * x * i) / width) * Math.cos((Math.PI * y * j) / height);
+               const color = colors[i + j * numX];
+
+               r += color[0] * basis;
+               g += color[1] * basis;
+               b += color[2] * basis;
+            }
+         }
+
+         const index = 4 * (x + y * width);
+         pixels[index] = linearTosRGB(r);
+         pixels[index + 1] = linearTosRGB(g);
+         pixels[index + 2] = linearTosRGB(b);
+         pixels[index + 3] = 255;
+      }
+   }
+
+   return pixels;
+}
+
+/**
+ * Paints BlurHash placeholders as the background of every image with a
+ * data-blurhash attribute that hasn't finished loading yet.
+ */
+export function renderBlurHashPlaceholders(root = document) {
+   const size = 32;
+   const canvas = document.createElement("canvas");
+   canvas.width = size;
+   canvas.height = size;
+
+   const context = canvas.getContext("2d");
+
+   root.querySelectorAll("img[data-blurhash]").forEach((img) => {
+      if (img.complete && img.naturalWidth > 0) {
+         return;
+      }
+
+      const pixels = decodeBlurHash(img.dataset.blurhash, size, size);
+
+      if (!pixels) {
+         return;
+      }
+
+      context.putImageData(new ImageData(pixels, size, size), 0, 0);
+      img.style.backgroundImage = `url(${canvas.toDataURL()})`;
+
+      img.addEventListener("load", () => {
+         img.style.backgroundImage = "";
+      }, { once: true });
+   });
+}
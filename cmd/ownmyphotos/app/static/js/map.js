@@ -0,0 +1,378 @@
+/*
+ * A small slippy map for showing where photos were taken. It draws
+ * {z}/{x}/{y} raster tiles in Web Mercator, supports dragging and
+ * zooming, and shows photo clusters from /api/photos.geojson.
+ */
+const tileSize = 256;
+const minZoom = 1;
+const maxZoom = 19;
+
+function clamp(value, min, max) {
+   return Math.max(min, Math.min(max, value));
+}
+
+/**
+ * Converts a latitude/longitude to pixel coordinates in the whole
+ * world at a zoom level.
+ */
+function project(lat, lon, zoom) {
+   const size = tileSize * Math.pow(2, zoom);
+   const sin = Math.sin(clamp(lat, -85.0511, 85.0511) * Math.PI / 180);
+
+   return {
+      x: (lon + 180) / 360 * size,
+      y: (0.5 - Math.log((1 + sin) / (1 - sin)) / (4 * Math.PI)) * size,
+   };
+}
+
+/**
+ * Converts world pixel coordinates at a zoom level back to a
+ * latitude/longitude.
+ */
+function unproject(x, y, zoom) {
+   const size = tileSize * Math.pow(2, zoom);
+   const n = Math.PI - 2 * Math.PI * y / size;
+
+   return {
+      lat: 180 / Math.PI * Math.atan(0.5 * (Math.exp(n) - Math.exp(-n))),
+      lon: x / size * 360 - 180,
+   };
+}
+
+function tileURL(template, z, x, y) {
+   return template.replace("{z}", z).replace("{x}", x).replace("{y}", y).replace("{s}", "a");
+}
+
+/**
+ * Creates a map inside an element. The element's data attributes
+ * configure it:
+ *   data-tile-template tile URL template
+ *   data-photos-target selector of the element cluster photos load into
+ *   data-lat, data-lon optional point to center on and mark with a pin
+ *   data-zoom          optional zoom level for the point
+ */
+export function createMap(element) {
+   const tileLayer = document.createElement("div");
+   const markerLayer = document.createElement("div");
+
+   tileLayer.className = "map-tiles";
+   markerLayer.className = "map-markers";
+   element.append(tileLayer, markerLayer);
+
+   const state = {
+      lat: 20,
+      lon: 0,
+      zoom: 2,
+      features: [],
+      pin: null,
+      tiles: new Map(),
+      fetchTimer: null,
+      fetchController: null,
+   };
+
+   function size() {
+      return { width: element.clientWidth, height: element.clientHeight };
+   }
+
+   function topLeft() {
+      const center = project(state.lat, state.lon, state.zoom);
+      const { width, height } = size();
+
+      return { x: center.x - width / 2, y: center.y - height / 2 };
+   }
+
+   /**
+    * Returns the visible area as west,south,east,north.
+    */
+   function bounds() {
+      const origin = topLeft();
+      const { width, height } = size();
+      const northWest = unproject(origin.x, origin.y, state.zoom);
+      const southEast = unproject(origin.x + width, origin.y + height, state.zoom);
+
+      return [northWest.lon, southEast.lat, southEast.lon, northWest.lat];
+   }
+
+   function renderTiles() {
+      const origin = topLeft();
+      const { width, height } = size();
+      const tileCount = Math.pow(2, state.zoom);
+      const wanted = new Set();
+
+      const firstX = Math.floor(origin.x / tileSize);
+      const lastX = Math.floor((origin.x + width) / tileSize);
+      const firstY = Math.max(0, Math.floor(origin.y / tileSize));
+      const lastY = Math.min(tileCount - 1, Math.floor((origin.y + height) / tileSize));
+
+      for (let x = firstX; x <= lastX; x++) {
+         for (let y = firstY; y <= lastY; y++) {
+            const key = `${state.zoom}/${x}/${y}`;
+            const wrappedX = ((x % tileCount) + tileCount) % tileCount;
+            let img = state.tiles.get(key);
+
+            wanted.add(key);
+
+            if (!img) {
+               img = document.createElement("img");
+               img.alt = "";
+               img.draggable = false;
+               img.src = tileURL(element.dataset.tileTemplate, state.zoom, wrappedX, y);
+               tileLayer.append(img);
+               state.tiles.set(key, img);
+            }
+
+            img.style.transform = `translate(${x * tileSize - origin.x}px, ${y * tileSize - origin.y}px)`;
+         }
+      }
+
+      for (const [key, img] of state.tiles) {
+         if (!wanted.has(key)) {
+            img.remove();
+            state.tiles.delete(key);
+         }
+      }
+   }
+
+   function renderMarkers() {
+      const origin = topLeft();
+      const worldWidth = tileSize * Math.pow(2, state.zoom);
+      const { width } = size();
+
+      markerLayer.replaceChildren();
+
+      for (const feature of state.features) {
+         const [lon, lat] = feature.geometry.coordinates;
+         const point = project(lat, lon, state.zoom);
+         let x = point.x - origin.x;
+
+         /*
+          * Markers are drawn on the copy of the world that is in view.
+          */
+         while (x < 0) x += worldWidth;
+         while (x > width && x - worldWidth >= 0) x -= worldWidth;
+
+         const marker = document.createElement("button");
+         marker.type = "button";
+         marker.className = "map-cluster";
+         marker.style.transform = `translate(${x}px, ${point.y - origin.y}px)`;
+         marker.style.backgroundImage = `url("${feature.properties.thumbnailUrl}")`;
+         marker.title = `${feature.properties.count} photos`;
+         marker.innerHTML = feature.properties.count > 1 ? `<span>${feature.properties.count}</span>` : "";
+         marker.addEventListener("click", (e) => {
+            e.stopPropagation();
+            showPhotos(`bbox=${feature.properties.bbox.join(",")}`);
+         });
+
+         markerLayer.append(marker);
+      }
+
+      if (state.pin) {
+         const point = project(state.pin.lat, state.pin.lon, state.zoom);
+         const pin = document.createElement("div");
+
+         pin.className = "map-pin";
+         pin.style.transform = `translate(${point.x - origin.x}px, ${point.y - origin.y}px)`;
+         markerLayer.append(pin);
+      }
+   }
+
+   function render() {
+      renderTiles();
+      renderMarkers();
+   }
+
+   /**
+    * Loads the clusters for the visible area after the map stops moving.
+    */
+   function scheduleFetch() {
+      clearTimeout(state.fetchTimer);
+      state.fetchTimer = setTimeout(fetchClusters, 250);
+   }
+
+   async function fetchClusters() {
+      if (state.fetchController) {
+         state.fetchController.abort();
+      }
+
+      state.fetchController = new AbortController();
+
+      try {
+         const response = await fetch(`/api/photos.geojson?bbox=${bounds().join(",")}&zoom=${state.zoom}`, {
+            signal: state.fetchController.signal,
+         });
+
+         if (!response.ok) {
+            throw new Error(`status ${response.status}`);
+         }
+
+         state.features = (await response.json()).features;
+         renderMarkers();
+      } catch (e) {
+         if (e.name !== "AbortError") {
+            console.error("error loading photo clusters", e);
+         }
+      }
+   }
+
+   function showPhotos(query) {
+      const target = element.dataset.photosTarget;
+
+      if (target) {
+         htmx.ajax("GET", `/map/photos?${query}`, { target, swap: "innerHTML" });
+      }
+   }
+
+   function moveBy(dx, dy) {
+      const center = project(state.lat, state.lon, state.zoom);
+      const moved = unproject(center.x + dx, center.y + dy, state.zoom);
+
+      state.lat = clamp(moved.lat, -85, 85);
+      state.lon = ((moved.lon + 540) % 360) - 180;
+      render();
+   }
+
+   /**
+    * Zooms while keeping the point under the cursor in place.
+    */
+   function zoomTo(zoom, pivotX, pivotY) {
+      zoom = clamp(zoom, minZoom, maxZoom);
+
+      if (zoom === state.zoom) {
+         return;
+      }
+
+      const { width, height } = size();
+      pivotX = pivotX ?? width / 2;
+      pivotY = pivotY ?? height / 2;
+
+      const origin = topLeft();
+      const pivot = unproject(origin.x + pivotX, origin.y + pivotY, state.zoom);
+      const pivotPoint = project(pivot.lat, pivot.lon, zoom);
+      const center = unproject(pivotPoint.x - pivotX + width / 2, pivotPoint.y - pivotY + height / 2, zoom);
+
+      state.zoom = zoom;
+      state.lat = center.lat;
+      state.lon = center.lon;
+      render();
+      scheduleFetch();
+   }
+
+   /**
+    * Centers and zooms the map so a west,south,east,north box is in view.
+    */
+   function fitBounds([west, south, east, north]) {
+      const { width, height } = size();
+      let zoom = maxZoom;
+
+      for (; zoom > minZoom; zoom--) {
+         const northWest = project(north, west, zoom);
+         const southEast = project(south, east, zoom);
+
+         if (southEast.x - northWest.x <= width * 0.8 && southEast.y - northWest.y <= height * 0.8) {
+            break;
+         }
+      }
+
+      const northWest = project(north, west, zoom);
+      const southEast = project(south, east, zoom);
+      const center = unproject((northWest.x + southEast.x) / 2, (northWest.y + southEast.y) / 2, zoom);
+
+      state.zoom = Math.min(zoom, 15);
+      state.lat = center.lat;
+      state.lon = center.lon;
+      render();
+      scheduleFetch();
+   }
+
+   /*
+    * Dragging
+    */
+   let drag = null;
+
+   element.addEventListener("pointerdown", (e) => {
+      if (e.target.closest(".map-cluster, .map-controls")) {
+         return;
+      }
+
+      drag = { x: e.clientX, y: e.clientY };
+      element.setPointerCapture(e.pointerId);
+      element.classList.add("dragging");
+   });
+
+   element.addEventListener("pointermove", (e) => {
+      if (!drag) {
+         return;
+      }
+
+      moveBy(drag.x - e.clientX, drag.y - e.clientY);
+      drag = { x: e.clientX, y: e.clientY };
+   });
+
+   element.addEventListener("pointerup", () => {
+      if (drag) {
+         drag = null;
+         element.classList.remove("dragging");
+         scheduleFetch();
+      }
+   });
+
+   /*
+    * Zooming
+    */
+   element.addEventListener("wheel", (e) => {
+      e.preventDefault();
+
+      const rect = element.getBoundingClientRect();
+      zoomTo(state.zoom + (e.deltaY < 0 ? 1 : -1), e.clientX - rect.left, e.clientY - rect.top);
+   }, { passive: false });
+
+   element.addEventListener("dblclick", (e) => {
+      const rect = element.getBoundingClientRect();
+      zoomTo(state.zoom + 1, e.clientX - rect.left, e.clientY - rect.top);
+   });
+
+   element.querySelector("[data-map-zoom-in]")?.addEventListener("click", () => zoomTo(state.zoom + 1));
+   element.querySelector("[data-map-zoom-out]")?.addEventListener("click", () => zoomTo(state.zoom - 1));
+
+   new ResizeObserver(() => render()).observe(element);
+
+   /*
+    * Start centered on the pin, or by fitting every photo in view.
+    */
+   (async () => {
+      if (element.dataset.lat && element.dataset.lon) {
+         state.pin = { lat: parseFloat(element.dataset.lat), lon: parseFloat(element.dataset.lon) };
+         state.lat = state.pin.lat;
+         state.lon = state.pin.lon;
+         state.zoom = clamp(parseInt(element.dataset.zoom || "13", 10), minZoom, maxZoom);
+         render();
+         scheduleFetch();
+         return;
+      }
+
+      render();
+
+      try {
+         const response = await fetch("/api/photos.geojson?bbox=-180,-90,180,90&zoom=0");
+         const data = await response.json();
+
+         if (data.bbox) {
+            fitBounds(data.bbox);
+            return;
+         }
+      } catch (e) {
+         console.error("error loading photo locations", e);
+      }
+
+      scheduleFetch();
+   })();
+
+   return {
+      /**
+       * Loads photos within radiusKm of the center of the map.
+       */
+      showPhotosNearCenter(radiusKm) {
+         showPhotos(`lat=${state.lat}&lon=${state.lon}&radius=${radiusKm}`);
+      },
+   };
+}
@@ -1,6 +1,10 @@
+import { renderBlurHashPlaceholders } from "/static/js/blurhash.js";
+
 document.addEventListener("DOMContentLoaded", () => {
    const slideshowTime = 5000;
 
+   renderBlurHashPlaceholders();
+
    if (fsLightbox) {
       fsLightbox.props.slideshowTime = slideshowTime;
       fsLightbox.props.disableBackgroundClose = true;
@@ -8,6 +12,7 @@ document.addEventListener("DOMContentLoaded", () => {
 
    htmx.on("htmx:afterSettle", () => {
       refreshFsLightbox();
+      renderBlurHashPlaceholders();
 
       if (fsLightbox) {
          fsLightbox.props.slideshowTime = slideshowTime;
@@ -15,4 +20,3 @@ document.addEventListener("DOMContentLoaded", () => {
       }
    });
 });
-
@@ -55,3 +55,65 @@ document.addEventListener('DOMContentLoaded', () => {
    window.addEventListener('popstate', updateRootFromURL);
 });
 
+
+/*
+ * Photos are selected with the boxes shown while the selection toolbar
+ * is open. Shift-clicking a box selects every box since the last one.
+ */
+document.addEventListener("DOMContentLoaded", () => {
+   let lastSelected = null;
+
+   function updateSelectionCount() {
+      const count = document.querySelector(".selection-count");
+
+      if (!count) {
+         return;
+      }
+
+      const selected = document.querySelectorAll(".batch-select:checked").length;
+
+      if (selected === 0) {
+         count.textContent = "No photos selected";
+      } else if (selected === 1) {
+         count.textContent = "1 photo selected";
+      } else {
+         count.textContent = `${selected} photos selected`;
+      }
+   }
+
+   document.addEventListener("click", (e) => {
+      const selectionButton = e.target.closest("[data-selection]");
+
+      if (selectionButton) {
+         const checked = selectionButton.dataset.selection === "all";
+
+         document.querySelectorAll(".batch-select").forEach((box) => {
+            box.checked = checked;
+         });
+
+         lastSelected = null;
+         updateSelectionCount();
+         return;
+      }
+
+      if (!e.target.matches(".batch-select")) {
+         return;
+      }
+
+      const boxes = Array.from(document.querySelectorAll(".batch-select"));
+
+      if (e.shiftKey && boxes.includes(lastSelected)) {
+         const start = Math.min(boxes.indexOf(lastSelected), boxes.indexOf(e.target));
+         const end = Math.max(boxes.indexOf(lastSelected), boxes.indexOf(e.target));
+
+         boxes.slice(start, end + 1).forEach((box) => {
+            box.checked = e.target.checked;
+         });
+      }
+
+      lastSelected = e.target;
+      updateSelectionCount();
+   });
+
+   document.body.addEventListener("htmx:afterSettle", updateSelectionCount);
+});
@@ -0,0 +1,667 @@
+package albums
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/adampresley/adamgokit/httphelpers"
+	"github.com/adampresley/adamgokit/rendering"
+	"github.com/adampresley/ownmyphotos/cmd/ownmyphotos/internal/viewmodels"
+	"github.com/adampresley/ownmyphotos/pkg/models"
+	"github.com/adampresley/ownmyphotos/pkg/query"
+	"github.com/adampresley/ownmyphotos/pkg/services"
+)
+
+type AlbumsHandlers interface {
+	AlbumsPage(w http.ResponseWriter, r *http.Request)
+	AlbumNav(w http.ResponseWriter, r *http.Request)
+	CreateAlbumAction(w http.ResponseWriter, r *http.Request)
+	CreateSmartAlbumAction(w http.ResponseWriter, r *http.Request)
+	UpdateSearchAction(w http.ResponseWriter, r *http.Request)
+	ConvertAlbumAction(w http.ResponseWriter, r *http.Request)
+	RenameAlbumAction(w http.ResponseWriter, r *http.Request)
+	MoveAlbumAction(w http.ResponseWriter, r *http.Request)
+	DeleteAlbumAction(w http.ResponseWriter, r *http.Request)
+	AlbumPage(w http.ResponseWriter, r *http.Request)
+	AlbumPicker(w http.ResponseWriter, r *http.Request)
+	AddPhotosAction(w http.ResponseWriter, r *http.Request)
+	RemovePhotoAction(w http.ResponseWriter, r *http.Request)
+	SetCoverAction(w http.ResponseWriter, r *http.Request)
+}
+
+type AlbumsControllerConfig struct {
+	AlbumService    services.AlbumServicer
+	PhotoService    services.PhotoServicer
+	Renderer        rendering.TemplateRenderer
+	SettingsService services.SettingsServicer
+}
+
+type AlbumsController struct {
+	albumService    services.AlbumServicer
+	photoService    services.PhotoServicer
+	renderer        rendering.TemplateRenderer
+	settingsService services.SettingsServicer
+}
+
+func NewAlbumsController(config AlbumsControllerConfig) AlbumsController {
+	return AlbumsController{
+		albumService:    config.AlbumService,
+		photoService:    config.PhotoService,
+		renderer:        config.Renderer,
+		settingsService: config.SettingsService,
+	}
+}
+
+/*
+GET /albums
+*/
+func (c AlbumsController) AlbumsPage(w http.ResponseWriter, r *http.Request) {
+	viewData := viewmodels.Albums{
+		BaseViewModel: viewmodels.BaseViewModel{
+			IsHtmx: httphelpers.IsHtmx(r),
+		},
+	}
+
+	c.renderAlbums(viewData, w)
+}
+
+/*
+GET /albums/nav
+
+Returns the list of albums shown in the sidebar. It is reloaded
+whenever an album changes, so smart album counts stay current.
+*/
+func (c AlbumsController) AlbumNav(w http.ResponseWriter, r *http.Request) {
+	var (
+		err error
+	)
+
+	pageName := "pages/fragments/album-nav"
+
+	viewData := viewmodels.Albums{
+		BaseViewModel: viewmodels.BaseViewModel{
+			IsHtmx: httphelpers.IsHtmx(r),
+		},
+	}
+
+	if viewData.Albums, err = c.albumService.All(); err != nil {
+		slog.Error("error getting albums", "error", err)
+		viewData.Albums = []*models.Album{}
+	}
+
+	c.renderer.Render(pageName, viewData, w)
+}
+
+/*
+POST /albums
+
+When a photo is posted along with the name, it is added to the new
+album and the album picker for that photo is returned instead.
+*/
+func (c AlbumsController) CreateAlbumAction(w http.ResponseWriter, r *http.Request) {
+	var (
+		err   error
+		album *models.Album
+	)
+
+	name := httphelpers.GetFromRequest[string](r, "name")
+	photoID := httphelpers.GetFromRequest[string](r, "photo")
+
+	viewData := viewmodels.Albums{
+		BaseViewModel: viewmodels.BaseViewModel{
+			IsHtmx: httphelpers.IsHtmx(r),
+		},
+	}
+
+	if album, err = c.albumService.Create(name); err != nil {
+		slog.Error("error creating album", "error", err, "name", name)
+		viewData.Message = "There was an error creating the album."
+		viewData.IsError = true
+
+		if errors.Is(err, services.ErrAlbumNameRequired) {
+			viewData.Message = "Please give the album a name."
+		}
+
+		if photoID != "" {
+			c.renderPicker(photoID, viewData.BaseViewModel, w)
+			return
+		}
+
+		c.renderAlbums(viewData, w)
+		return
+	}
+
+	albumsChanged(w)
+
+	if photoID != "" {
+		c.addPhotos(album, []string{photoID}, w)
+		return
+	}
+
+	viewData.Message = fmt.Sprintf("Album '%s' created.", album.Name)
+	c.renderAlbums(viewData, w)
+}
+
+/*
+POST /albums/smart
+*/
+func (c AlbumsController) CreateSmartAlbumAction(w http.ResponseWriter, r *http.Request) {
+	var (
+		err   error
+		album *models.Album
+	)
+
+	name := httphelpers.GetFromRequest[string](r, "name")
+
+	criteria := models.PhotoSearch{
+		SearchTerm: httphelpers.GetFromRequest[string](r, "term"),
+		Match:      models.NewSearchMatch(httphelpers.GetFromRequest[string](r, "match")),
+		Keywords:   httphelpers.GetFromRequest[[]string](r, "keyword"),
+		People:     httphelpers.GetFromRequest[[]string](r, "person"),
+	}
+
+	viewData := viewmodels.Albums{
+		BaseViewModel: viewmodels.BaseViewModel{
+			IsHtmx: httphelpers.IsHtmx(r),
+		},
+	}
+
+	if album, err = c.albumService.CreateSmart(name, criteria); err != nil {
+		slog.Error("error creating smart album", "error", err, "name", name, "term", criteria.SearchTerm)
+		viewData.Message = smartAlbumErrorMessage(err)
+		viewData.IsError = true
+		viewData.SmartAlbumName = name
+		viewData.SmartAlbumTerm = criteria.SearchTerm
+
+		c.renderAlbums(viewData, w)
+		return
+	}
+
+	albumsChanged(w)
+
+	viewData.Message = fmt.Sprintf("Smart album '%s' created with %d photos.", album.Name, album.NumPhotos)
+	c.renderAlbums(viewData, w)
+}
+
+/*
+POST /albums/{id}/search
+*/
+func (c AlbumsController) UpdateSearchAction(w http.ResponseWriter, r *http.Request) {
+	var (
+		err error
+	)
+
+	id := httphelpers.GetFromRequest[int64](r, "id")
+
+	criteria := models.PhotoSearch{
+		SearchTerm: httphelpers.GetFromRequest[string](r, "term"),
+		Match:      models.NewSearchMatch(httphelpers.GetFromRequest[string](r, "match")),
+		Keywords:   httphelpers.GetFromRequest[[]string](r, "keyword"),
+		People:     httphelpers.GetFromRequest[[]string](r, "person"),
+	}
+
+	viewData := viewmodels.Album{
+		BaseViewModel: viewmodels.BaseViewModel{
+			IsHtmx: httphelpers.IsHtmx(r),
+		},
+	}
+
+	if err = c.albumService.UpdateSearch(id, criteria); err != nil {
+		slog.Error("error updating smart album search", "error", err, "id", id, "term", criteria.SearchTerm)
+		viewData.Message = smartAlbumErrorMessage(err)
+		viewData.IsError = true
+	} else {
+		albumsChanged(w)
+		viewData.Message = "Search updated."
+	}
+
+	c.renderAlbum(id, 1, viewData, w)
+}
+
+/*
+POST /albums/{id}/convert
+
+Turns a smart album into a regular album holding a snapshot of the
+photos its search matches.
+*/
+func (c AlbumsController) ConvertAlbumAction(w http.ResponseWriter, r *http.Request) {
+	var (
+		err   error
+		added int
+	)
+
+	id := httphelpers.GetFromRequest[int64](r, "id")
+
+	viewData := viewmodels.Album{
+		BaseViewModel: viewmodels.BaseViewModel{
+			IsHtmx: httphelpers.IsHtmx(r),
+		},
+	}
+
+	if added, err = c.albumService.ConvertToStatic(id); err != nil {
+		slog.Error("error converting smart album", "error", err, "id", id)
+		viewData.Message = "There was an error converting the album."
+		viewData.IsError = true
+	} else {
+		albumsChanged(w)
+		viewData.Message = fmt.Sprintf("This is now a regular album with %d photos. New photos matching the search won't be added.", added)
+	}
+
+	c.renderAlbum(id, 1, viewData, w)
+}
+
+/*
+POST /albums/{id}/rename
+*/
+func (c AlbumsController) RenameAlbumAction(w http.ResponseWriter, r *http.Request) {
+	var (
+		err error
+	)
+
+	id := httphelpers.GetFromRequest[int64](r, "id")
+	name := httphelpers.GetFromRequest[string](r, "name")
+
+	viewData := viewmodels.Albums{
+		BaseViewModel: viewmodels.BaseViewModel{
+			IsHtmx: httphelpers.IsHtmx(r),
+		},
+	}
+
+	if err = c.albumService.Rename(id, name); err != nil {
+		slog.Error("error renaming album", "error", err, "id", id, "name", name)
+		viewData.Message = "There was an error renaming the album."
+		viewData.IsError = true
+
+		if errors.Is(err, services.ErrAlbumNameRequired) {
+			viewData.Message = "Please give the album a name."
+		}
+
+		c.renderAlbums(viewData, w)
+		return
+	}
+
+	albumsChanged(w)
+
+	viewData.Message = "Album renamed."
+	c.renderAlbums(viewData, w)
+}
+
+/*
+POST /albums/{id}/move?direction=up
+POST /albums/{id}/move?direction=down
+*/
+func (c AlbumsController) MoveAlbumAction(w http.ResponseWriter, r *http.Request) {
+	var (
+		err error
+	)
+
+	id := httphelpers.GetFromRequest[int64](r, "id")
+	offset := 1
+
+	if httphelpers.GetFromRequest[string](r, "direction") == "up" {
+		offset = -1
+	}
+
+	viewData := viewmodels.Albums{
+		BaseViewModel: viewmodels.BaseViewModel{
+			IsHtmx: httphelpers.IsHtmx(r),
+		},
+	}
+
+	if err = c.albumService.Move(id, offset); err != nil {
+		slog.Error("error moving album", "error", err, "id", id, "offset", offset)
+		viewData.Message = "There was an error moving the album."
+		viewData.IsError = true
+	} else {
+		albumsChanged(w)
+	}
+
+	c.renderAlbums(viewData, w)
+}
+
+/*
+DELETE /albums/{id}
+*/
+func (c AlbumsController) DeleteAlbumAction(w http.ResponseWriter, r *http.Request) {
+	var (
+		err error
+	)
+
+	id := httphelpers.GetFromRequest[int64](r, "id")
+
+	viewData := viewmodels.Albums{
+		BaseViewModel: viewmodels.BaseViewModel{
+			IsHtmx: httphelpers.IsHtmx(r),
+		},
+	}
+
+	if err = c.albumService.Delete(id); err != nil {
+		slog.Error("error deleting album", "error", err, "id", id)
+		viewData.Message = "There was an error deleting the album."
+		viewData.IsError = true
+
+		c.renderAlbums(viewData, w)
+		return
+	}
+
+	albumsChanged(w)
+
+	viewData.Message = "Album deleted. The photos in it are still in your library."
+	c.renderAlbums(viewData, w)
+}
+
+/*
+GET /albums/{id}
+*/
+func (c AlbumsController) AlbumPage(w http.ResponseWriter, r *http.Request) {
+	viewData := viewmodels.Album{
+		BaseViewModel: viewmodels.BaseViewModel{
+			IsHtmx: httphelpers.IsHtmx(r),
+		},
+	}
+
+	id := httphelpers.GetFromRequest[int64](r, "id")
+	page := max(1, httphelpers.GetFromRequest[int](r, "page"))
+
+	c.renderAlbum(id, page, viewData, w)
+}
+
+/*
+GET /albums/picker?photo={photoID}
+
+Returns the list of albums a photo can be added to.
+*/
+func (c AlbumsController) AlbumPicker(w http.ResponseWriter, r *http.Request) {
+	photoID := httphelpers.GetFromRequest[string](r, "photo")
+
+	c.renderPicker(photoID, viewmodels.BaseViewModel{IsHtmx: httphelpers.IsHtmx(r)}, w)
+}
+
+/*
+POST /albums/{id}/photos
+
+Adds every posted photo to the album, and returns the album picker
+for the first of them.
+*/
+func (c AlbumsController) AddPhotosAction(w http.ResponseWriter, r *http.Request) {
+	var (
+		err   error
+		album *models.Album
+	)
+
+	id := httphelpers.GetFromRequest[int64](r, "id")
+	photoIDs := httphelpers.GetFromRequest[[]string](r, "photo")
+
+	if album, err = c.albumService.Get(id); err != nil || album.ID == 0 {
+		slog.Error("error retrieving album", "error", err, "id", id)
+
+		c.renderPicker(firstOrEmpty(photoIDs), viewmodels.BaseViewModel{
+			IsHtmx:  httphelpers.IsHtmx(r),
+			Message: "This album could not be found.",
+			IsError: true,
+		}, w)
+
+		return
+	}
+
+	c.addPhotos(album, photoIDs, w)
+}
+
+/*
+DELETE /albums/{id}/photos/{photoID}
+*/
+func (c AlbumsController) RemovePhotoAction(w http.ResponseWriter, r *http.Request) {
+	var (
+		err error
+	)
+
+	id := httphelpers.GetFromRequest[int64](r, "id")
+	photoID := httphelpers.GetFromRequest[string](r, "photoID")
+
+	viewData := viewmodels.Album{
+		BaseViewModel: viewmodels.BaseViewModel{
+			IsHtmx: httphelpers.IsHtmx(r),
+		},
+	}
+
+	if err = c.albumService.RemovePhotos(id, []string{photoID}); err != nil {
+		slog.Error("error removing photo from album", "error", err, "id", id, "photoID", photoID)
+		viewData.Message = "There was an error removing the photo from the album."
+		viewData.IsError = true
+	} else {
+		albumsChanged(w)
+		viewData.Message = "Photo removed from the album."
+	}
+
+	c.renderAlbum(id, 1, viewData, w)
+}
+
+/*
+PUT /albums/{id}/cover/{photoID}
+*/
+func (c AlbumsController) SetCoverAction(w http.ResponseWriter, r *http.Request) {
+	var (
+		err error
+	)
+
+	id := httphelpers.GetFromRequest[int64](r, "id")
+	photoID := httphelpers.GetFromRequest[string](r, "photoID")
+
+	viewData := viewmodels.Album{
+		BaseViewModel: viewmodels.BaseViewModel{
+			IsHtmx: httphelpers.IsHtmx(r),
+		},
+	}
+
+	if err = c.albumService.SetCover(id, photoID); err != nil {
+		slog.Error("error setting album cover", "error", err, "id", id, "photoID", photoID)
+		viewData.Message = "There was an error setting the album's cover."
+		viewData.IsError = true
+	} else {
+		albumsChanged(w)
+		viewData.Message = "Cover photo updated."
+	}
+
+	c.renderAlbum(id, 1, viewData, w)
+}
+
+func (c AlbumsController) addPhotos(album *models.Album, photoIDs []string, w http.ResponseWriter) {
+	var (
+		err   error
+		added int
+	)
+
+	viewData := viewmodels.BaseViewModel{IsHtmx: true}
+
+	added, err = c.albumService.AddPhotos(album.ID, photoIDs)
+
+	switch {
+	case errors.Is(err, services.ErrSmartAlbum):
+		viewData.Message = fmt.Sprintf("'%s' is a smart album. It shows the photos matching its search.", album.Name)
+		viewData.IsWarning = true
+
+	case err != nil:
+		slog.Error("error adding photos to album", "error", err, "id", album.ID, "photoIDs", photoIDs)
+		viewData.Message = "There was an error adding to the album."
+		viewData.IsError = true
+
+	case added == 0:
+		viewData.Message = fmt.Sprintf("Already in '%s'.", album.Name)
+		viewData.IsWarning = true
+
+	case added == 1:
+		albumsChanged(w)
+		viewData.Message = fmt.Sprintf("Added to '%s'.", album.Name)
+
+	default:
+		albumsChanged(w)
+		viewData.Message = fmt.Sprintf("Added %d photos to '%s'.", added, album.Name)
+	}
+
+	c.renderPicker(firstOrEmpty(photoIDs), viewData, w)
+}
+
+func (c AlbumsController) renderAlbums(viewData viewmodels.Albums, w http.ResponseWriter) {
+	var (
+		err error
+	)
+
+	pageName := "pages/albums"
+
+	if viewData.Albums, err = c.albumService.All(); err != nil {
+		slog.Error("error getting albums", "error", err)
+		viewData.Message = "There was an error retrieving your albums."
+		viewData.IsError = true
+	}
+
+	c.renderer.Render(pageName, viewData, w)
+}
+
+func (c AlbumsController) renderAlbum(id int64, page int, viewData viewmodels.Album, w http.ResponseWriter) {
+	var (
+		err      error
+		settings *models.Settings
+		photos   []*models.Photo
+	)
+
+	pageName := "pages/album"
+	viewData.JavascriptIncludes = []rendering.JavascriptInclude{
+		{Src: "/static/js/fslightbox.js", Type: "text/javascript"},
+		{Src: "/static/js/pages/home.js", Type: "module"},
+	}
+	viewData.Album = &models.Album{}
+	viewData.Images = []viewmodels.ImageModel{}
+
+	/*
+	 * Pages after the first are requested by infinite scroll, and
+	 * only need the next set of photos.
+	 */
+	if page > 1 && viewData.IsHtmx {
+		pageName = "pages/fragments/album-photos"
+	}
+
+	if settings, err = c.settingsService.Read(); err != nil {
+		slog.Error("error reading settings", "error", err)
+		viewData.Message = "Error reading settings"
+		viewData.IsError = true
+
+		c.renderer.Render(pageName, viewData, w)
+		return
+	}
+
+	if viewData.Album, err = c.albumService.Get(id); err != nil || viewData.Album.ID == 0 {
+		slog.Error("error retrieving album", "error", err, "id", id)
+		viewData.Album = &models.Album{}
+		viewData.Message = "This album could not be found."
+		viewData.IsError = true
+
+		c.renderer.Render(pageName, viewData, w)
+		return
+	}
+
+	/*
+	 * Smart albums run their saved search each time, so photos that
+	 * match are shown as soon as they're collected.
+	 */
+	if viewData.Album.IsSmart {
+		var results models.SearchPhotosResult
+
+		if results, err = c.photoService.Search(viewData.Album.Criteria(page)); err != nil {
+			slog.Error("error searching for photos in smart album", "error", err, "id", id)
+			viewData.Message = smartAlbumErrorMessage(err)
+			viewData.IsError = true
+
+			c.renderer.Render(pageName, viewData, w)
+			return
+		}
+
+		photos, viewData.Paging = results.PhotoMatches, results.PhotoPaging
+	} else if photos, viewData.Paging, err = c.photoService.GetPhotosInAlbum(id, page); err != nil {
+		slog.Error("error getting photos in album", "error", err, "id", id)
+		viewData.Message = "There was an error retrieving the photos in this album."
+		viewData.IsError = true
+
+		c.renderer.Render(pageName, viewData, w)
+		return
+	}
+
+	viewData.Images = viewmodels.NewImageModelCollectionFromPhotos(photos, []*models.Folder{}, settings.LibraryPath)
+	c.renderer.Render(pageName, viewData, w)
+}
+
+func (c AlbumsController) renderPicker(photoID string, base viewmodels.BaseViewModel, w http.ResponseWriter) {
+	var (
+		err    error
+		albums []*models.Album
+	)
+
+	pageName := "pages/fragments/album-picker"
+
+	viewData := viewmodels.AlbumPicker{
+		BaseViewModel: base,
+		PhotoID:       photoID,
+		Albums:        []*models.Album{},
+		AlbumIDs:      []int64{},
+	}
+
+	if viewData.Albums, err = c.albumService.All(); err != nil {
+		slog.Error("error getting albums", "error", err)
+		viewData.Message = "There was an error retrieving your albums."
+		viewData.IsError = true
+
+		c.renderer.Render(pageName, viewData, w)
+		return
+	}
+
+	if albums, err = c.albumService.GetAlbumsForPhoto(photoID); err != nil {
+		slog.Error("error getting albums for photo", "error", err, "photoID", photoID)
+	}
+
+	for _, album := range albums {
+		viewData.AlbumIDs = append(viewData.AlbumIDs, album.ID)
+	}
+
+	c.renderer.Render(pageName, viewData, w)
+}
+
+func firstOrEmpty(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+
+	return values[0]
+}
+
+/*
+albumsChanged tells the page that albums were added, changed, or
+removed, so the album list in the sidebar reloads.
+*/
+func albumsChanged(w http.ResponseWriter) {
+	w.Header().Set("HX-Trigger", "albumsChanged")
+}
+
+/*
+smartAlbumErrorMessage returns the message shown when a smart album's
+search can't be saved or run.
+*/
+func smartAlbumErrorMessage(err error) string {
+	var (
+		queryError *query.Error
+	)
+
+	switch {
+	case errors.Is(err, services.ErrAlbumNameRequired):
+		return "Please give the album a name."
+
+	case errors.Is(err, services.ErrAlbumSearchRequired):
+		return "Please enter a search, keywords, or people for the album to match."
+
+	case errors.As(err, &queryError):
+		return "The album's search couldn't be understood: " + queryError.Error() + "."
+
+	default:
+		return "There was an error with the smart album."
+	}
+}
@@ -13,9 +13,16 @@ type Config struct {
 	CacheDirectory   string `flag:"ccd" env:"CACHE_DIRECTORY" default:"../../cache" description:"Cache directory"`
 	DataMigrationDir string `flag:"dmd" env:"DATA_MIGRATION_DIR" default:"../../sql-migrations" description:"Migration folder"`
 	DSN              string `flag:"dsn" env:"DSN" default:"file:./data/ownmyphotos.db" description:"Database connection"`
+	GazetteerDir     string `flag:"gazetteer" env:"GAZETTEER_DIRECTORY" default:"" description:"Directory with GeoNames cities, admin1CodesASCII.txt, and countryInfo.txt files, imported to name the places photos were taken"`
 	Host             string `flag:"host" env:"HOST" default:"localhost:8080" description:"The address and port to bind the HTTP server to"`
 	LogLevel         string `flag:"loglevel" env:"LOG_LEVEL" default:"debug" description:"The log level to use. Valid values are 'debug', 'info', 'warn', and 'error'"`
 	MaxCacheWorkers  int    `flag:"mcw" env:"MAX_CACHE_WORKERS" default:"5" description:"Number of concurrent cache workers"`
+	BaseURL          string `flag:"baseurl" env:"BASE_URL" default:"http://localhost:8080" description:"The public URL of this server, used for links in emails"`
+	SMTPHost         string `flag:"smtphost" env:"SMTP_HOST" default:"" description:"SMTP server used to send emails. Leave empty to disable email"`
+	SMTPPort         int    `flag:"smtpport" env:"SMTP_PORT" default:"587" description:"SMTP server port"`
+	SMTPUser         string `flag:"smtpuser" env:"SMTP_USER" default:"" description:"SMTP user name. Leave empty if the server doesn't need authentication"`
+	SMTPPassword     string `flag:"smtppassword" env:"SMTP_PASSWORD" default:"" description:"SMTP password"`
+	SMTPFrom         string `flag:"smtpfrom" env:"SMTP_FROM" default:"" description:"The address emails are sent from"`
 }
 
 func LoadConfig() Config {
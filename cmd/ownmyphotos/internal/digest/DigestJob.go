@@ -0,0 +1,167 @@
+package digest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adampresley/adamgokit/rendering"
+	"github.com/adampresley/ownmyphotos/cmd/ownmyphotos/internal/configuration"
+	"github.com/adampresley/ownmyphotos/cmd/ownmyphotos/internal/viewmodels"
+	"github.com/adampresley/ownmyphotos/pkg/models"
+	"github.com/adampresley/ownmyphotos/pkg/services"
+)
+
+/*
+DigestJob builds an "on this day" digest of photos taken on today's
+date in past years. The digest is saved as an HTML file, emailed, or
+both, depending on settings.
+*/
+type DigestJob struct {
+	config          *configuration.Config
+	photoService    services.PhotoServicer
+	renderer        rendering.TemplateRenderer
+	settingsService services.SettingsServicer
+}
+
+type DigestJobConfig struct {
+	Config          *configuration.Config
+	PhotoService    services.PhotoServicer
+	Renderer        rendering.TemplateRenderer
+	SettingsService services.SettingsServicer
+}
+
+func NewDigestJob(config DigestJobConfig) DigestJob {
+	return DigestJob{
+		config:          config.Config,
+		photoService:    config.PhotoService,
+		renderer:        config.Renderer,
+		settingsService: config.SettingsService,
+	}
+}
+
+/*
+Run builds today's digest. Nothing is sent or saved when there are
+no photos for today.
+*/
+func (j DigestJob) Run() error {
+	var (
+		err      error
+		settings *models.Settings
+		photos   []*models.Photo
+		errs     []error
+	)
+
+	day := time.Now()
+
+	if settings, err = j.settingsService.Read(); err != nil {
+		return fmt.Errorf("error reading settings: %w", err)
+	}
+
+	if photos, err = j.photoService.GetPhotosOnThisDay(day); err != nil {
+		return fmt.Errorf("error getting photos for the digest: %w", err)
+	}
+
+	if len(photos) == 0 {
+		slog.Info("no memories for today. skipping the digest", "day", day.Format(time.DateOnly))
+		return nil
+	}
+
+	viewData := viewmodels.Memories{
+		Day:     day,
+		Years:   viewmodels.NewMemoryYears(photos, day, settings.LibraryPath),
+		BaseURL: strings.TrimSuffix(j.config.BaseURL, "/"),
+	}
+
+	content := &bytes.Buffer{}
+	j.renderer.Render("pages/digest", viewData, content)
+
+	subject := fmt.Sprintf("On this day: %d photos from %s", len(photos), day.Format("January 2"))
+
+	if settings.DigestOutputPath != "" {
+		if err = j.save(settings.DigestOutputPath, day, content.Bytes()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if settings.DigestEmailTo != "" {
+		if err = j.email(settings.DigestEmailTo, subject, content.Bytes()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (j DigestJob) save(outputPath string, day time.Time, content []byte) error {
+	var (
+		err error
+	)
+
+	if err = os.MkdirAll(outputPath, 0755); err != nil {
+		return fmt.Errorf("error creating digest directory '%s': %w", outputPath, err)
+	}
+
+	fileName := filepath.Join(outputPath, "memories-"+day.Format(time.DateOnly)+".html")
+
+	if err = os.WriteFile(fileName, content, 0644); err != nil {
+		return fmt.Errorf("error writing digest file '%s': %w", fileName, err)
+	}
+
+	slog.Info("saved memories digest", "file", fileName)
+	return nil
+}
+
+/*
+email sends the digest to a comma separated list of addresses using
+the SMTP server from the configuration.
+*/
+func (j DigestJob) email(to, subject string, content []byte) error {
+	var (
+		err  error
+		auth smtp.Auth
+	)
+
+	if j.config.SMTPHost == "" || j.config.SMTPFrom == "" {
+		return fmt.Errorf("cannot email the digest, as SMTP_HOST and SMTP_FROM are not configured")
+	}
+
+	recipients := []string{}
+
+	for _, address := range strings.Split(to, ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			recipients = append(recipients, address)
+		}
+	}
+
+	if j.config.SMTPUser != "" {
+		auth = smtp.PlainAuth("", j.config.SMTPUser, j.config.SMTPPassword, j.config.SMTPHost)
+	}
+
+	message := &bytes.Buffer{}
+	message.WriteString("From: " + j.config.SMTPFrom + "\r\n")
+	message.WriteString("To: " + strings.Join(recipients, ", ") + "\r\n")
+	message.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", subject) + "\r\n")
+	message.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	message.WriteString("MIME-Version: 1.0\r\n")
+	message.WriteString("Content-Type: text/html; charset=UTF-8\r\n")
+	message.WriteString("\r\n")
+	message.Write(content)
+
+	address := j.config.SMTPHost + ":" + strconv.Itoa(j.config.SMTPPort)
+
+	if err = smtp.SendMail(address, auth, j.config.SMTPFrom, recipients, message.Bytes()); err != nil {
+		return fmt.Errorf("error emailing the digest to %s: %w", to, err)
+	}
+
+	slog.Info("emailed memories digest", "to", recipients)
+	return nil
+}
@@ -0,0 +1,239 @@
+package events
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/adampresley/adamgokit/httphelpers"
+	"github.com/adampresley/adamgokit/rendering"
+	"github.com/adampresley/ownmyphotos/cmd/ownmyphotos/internal/viewmodels"
+	"github.com/adampresley/ownmyphotos/pkg/models"
+	"github.com/adampresley/ownmyphotos/pkg/services"
+)
+
+type EventsHandlers interface {
+	EventsPage(w http.ResponseWriter, r *http.Request)
+	EventPage(w http.ResponseWriter, r *http.Request)
+	RenameEventAction(w http.ResponseWriter, r *http.Request)
+	RebuildEventsAction(w http.ResponseWriter, r *http.Request)
+}
+
+type EventsControllerConfig struct {
+	EventService    services.EventServicer
+	PhotoService    services.PhotoServicer
+	Renderer        rendering.TemplateRenderer
+	SettingsService services.SettingsServicer
+}
+
+type EventsController struct {
+	eventService    services.EventServicer
+	photoService    services.PhotoServicer
+	renderer        rendering.TemplateRenderer
+	settingsService services.SettingsServicer
+}
+
+func NewEventsController(config EventsControllerConfig) EventsController {
+	return EventsController{
+		eventService:    config.EventService,
+		photoService:    config.PhotoService,
+		renderer:        config.Renderer,
+		settingsService: config.SettingsService,
+	}
+}
+
+/*
+GET /events
+GET /events?year=2023
+*/
+func (c EventsController) EventsPage(w http.ResponseWriter, r *http.Request) {
+	viewData := viewmodels.Events{
+		BaseViewModel: viewmodels.BaseViewModel{
+			IsHtmx: httphelpers.IsHtmx(r),
+		},
+		Year: httphelpers.GetFromRequest[string](r, "year"),
+	}
+
+	c.renderEvents(viewData, w)
+}
+
+/*
+POST /events/rebuild
+
+Groups photos into events again, such as after a library is first
+scanned.
+*/
+func (c EventsController) RebuildEventsAction(w http.ResponseWriter, r *http.Request) {
+	var (
+		err       error
+		numEvents int
+	)
+
+	viewData := viewmodels.Events{
+		BaseViewModel: viewmodels.BaseViewModel{
+			IsHtmx: httphelpers.IsHtmx(r),
+		},
+		Year: httphelpers.GetFromRequest[string](r, "year"),
+	}
+
+	if numEvents, err = c.eventService.Rebuild(); err != nil {
+		slog.Error("error grouping photos into events", "error", err)
+		viewData.Message = "There was an error grouping your photos into events."
+		viewData.IsError = true
+
+		c.renderEvents(viewData, w)
+		return
+	}
+
+	viewData.Message = fmt.Sprintf("Your photos were grouped into %d events.", numEvents)
+	c.renderEvents(viewData, w)
+}
+
+/*
+GET /events/{id}
+*/
+func (c EventsController) EventPage(w http.ResponseWriter, r *http.Request) {
+	viewData := viewmodels.Event{
+		BaseViewModel: viewmodels.BaseViewModel{
+			IsHtmx: httphelpers.IsHtmx(r),
+		},
+	}
+
+	page := max(1, httphelpers.GetFromRequest[int](r, "page"))
+	c.renderEvent(httphelpers.GetFromRequest[int64](r, "id"), page, viewData, w)
+}
+
+/*
+POST /events/{id}/rename
+*/
+func (c EventsController) RenameEventAction(w http.ResponseWriter, r *http.Request) {
+	var (
+		err error
+	)
+
+	id := httphelpers.GetFromRequest[int64](r, "id")
+	name := httphelpers.GetFromRequest[string](r, "name")
+
+	viewData := viewmodels.Event{
+		BaseViewModel: viewmodels.BaseViewModel{
+			IsHtmx: httphelpers.IsHtmx(r),
+		},
+	}
+
+	if err = c.eventService.Rename(id, name); err != nil {
+		slog.Error("error renaming event", "error", err, "id", id, "name", name)
+		viewData.Message = "There was an error renaming this event."
+		viewData.IsError = true
+
+		if errors.Is(err, services.ErrEventNotFound) {
+			viewData.Message = "This event could not be found. Your photos may have been grouped again."
+		}
+
+		c.renderEvent(id, 1, viewData, w)
+		return
+	}
+
+	viewData.Message = "Renamed."
+	c.renderEvent(id, 1, viewData, w)
+}
+
+func (c EventsController) renderEvents(viewData viewmodels.Events, w http.ResponseWriter) {
+	var (
+		err  error
+		year int
+	)
+
+	viewData.Years = []*models.DateBucket{}
+	viewData.Events = []*models.Event{}
+
+	if viewData.Years, err = c.eventService.GetYears(); err != nil {
+		slog.Error("error getting years of events", "error", err)
+		viewData.Message = "There was an error retrieving your events."
+		viewData.IsError = true
+
+		c.renderer.Render("pages/events", viewData, w)
+		return
+	}
+
+	/*
+	 * Without a year, the most recent year with events is shown.
+	 */
+	if year, err = strconv.Atoi(viewData.Year); err != nil || len(viewData.Year) != 4 {
+		viewData.Year = ""
+
+		if len(viewData.Years) > 0 {
+			viewData.Year = viewData.Years[len(viewData.Years)-1].Period
+			year, _ = strconv.Atoi(viewData.Year)
+		}
+	}
+
+	if viewData.Year == "" {
+		c.renderer.Render("pages/events", viewData, w)
+		return
+	}
+
+	if viewData.Events, err = c.eventService.GetEvents(year); err != nil {
+		slog.Error("error getting events", "error", err, "year", year)
+		viewData.Message = fmt.Sprintf("There was an error retrieving your events from %d.", year)
+		viewData.IsError = true
+	}
+
+	c.renderer.Render("pages/events", viewData, w)
+}
+
+func (c EventsController) renderEvent(id int64, page int, viewData viewmodels.Event, w http.ResponseWriter) {
+	var (
+		err      error
+		settings *models.Settings
+		photos   []*models.Photo
+	)
+
+	pageName := "pages/event"
+	viewData.JavascriptIncludes = []rendering.JavascriptInclude{
+		{Src: "/static/js/fslightbox.js", Type: "text/javascript"},
+		{Src: "/static/js/pages/home.js", Type: "module"},
+	}
+	viewData.Event = &models.Event{}
+	viewData.Images = []viewmodels.ImageModel{}
+
+	/*
+	 * Pages after the first are requested by infinite scroll, and
+	 * only need the next set of photos.
+	 */
+	if page > 1 && viewData.IsHtmx {
+		pageName = "pages/fragments/event-photos"
+	}
+
+	if settings, err = c.settingsService.Read(); err != nil {
+		slog.Error("error reading settings", "error", err)
+		viewData.Message = "Error reading settings"
+		viewData.IsError = true
+
+		c.renderer.Render(pageName, viewData, w)
+		return
+	}
+
+	if viewData.Event, err = c.eventService.Get(id); err != nil || viewData.Event.ID == 0 {
+		slog.Error("error retrieving event", "error", err, "id", id)
+		viewData.Event = &models.Event{}
+		viewData.Message = "This event could not be found. Your photos may have been grouped again."
+		viewData.IsError = true
+
+		c.renderer.Render(pageName, viewData, w)
+		return
+	}
+
+	if photos, viewData.Paging, err = c.photoService.GetPhotosInEvent(id, page); err != nil {
+		slog.Error("error getting photos in event", "error", err, "id", id)
+		viewData.Message = "There was an error retrieving the photos in this event."
+		viewData.IsError = true
+
+		c.renderer.Render(pageName, viewData, w)
+		return
+	}
+
+	viewData.Images = viewmodels.NewImageModelCollectionFromPhotos(photos, []*models.Folder{}, settings.LibraryPath)
+	c.renderer.Render(pageName, viewData, w)
+}
@@ -0,0 +1,326 @@
+package facedetection
+
+import (
+	"fmt"
+	"image"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync/atomic"
+
+	"github.com/adampresley/ownmyphotos/pkg/faces"
+	"github.com/adampresley/ownmyphotos/pkg/models"
+	"github.com/adampresley/ownmyphotos/pkg/services"
+	"github.com/nfnt/resize"
+)
+
+const (
+	// batchSize is how many photos are read from the database at a time
+	batchSize = 100
+
+	// maxImageSize is the longest edge originals are scaled down to when there is no thumbnail
+	maxImageSize = 1024
+)
+
+/*
+FaceDetectionJob looks for faces in photos face detection hasn't
+looked at yet, using their thumbnails. It then groups the unnamed
+faces that look like the same person, and suggests who each group
+looks like, for the review queue on the people page. Only the CPU is
+used, so it runs anywhere, if slowly on a large library.
+*/
+type FaceDetectionJob struct {
+	detector        *faces.Detector
+	faceService     services.FaceServicer
+	photoCache      services.PhotoCacher
+	running         *atomic.Bool
+	settingsService services.SettingsServicer
+}
+
+type FaceDetectionJobConfig struct {
+	FaceService     services.FaceServicer
+	PhotoCache      services.PhotoCacher
+	SettingsService services.SettingsServicer
+}
+
+func NewFaceDetectionJob(config FaceDetectionJobConfig) (FaceDetectionJob, error) {
+	detector, err := faces.NewDetector()
+
+	if err != nil {
+		return FaceDetectionJob{}, err
+	}
+
+	return FaceDetectionJob{
+		detector:        detector,
+		faceService:     config.FaceService,
+		photoCache:      config.PhotoCache,
+		running:         &atomic.Bool{},
+		settingsService: config.SettingsService,
+	}, nil
+}
+
+/*
+IsRunning returns true while the job is running.
+*/
+func (j FaceDetectionJob) IsRunning() bool {
+	return j.running.Load()
+}
+
+/*
+RunInBackground starts the job without waiting for it to finish.
+Returns false if the job is already running.
+*/
+func (j FaceDetectionJob) RunInBackground() bool {
+	if j.running.Load() {
+		return false
+	}
+
+	go func() {
+		if err := j.Run(); err != nil {
+			slog.Error("error detecting faces", "error", err)
+			return
+		}
+
+		slog.Info("face detection completed")
+	}()
+
+	return true
+}
+
+/*
+Run detects faces in every photo not looked at yet, then groups the
+unnamed faces. Only one run happens at a time, so a run started
+while another is going does nothing.
+*/
+func (j FaceDetectionJob) Run() error {
+	var (
+		err      error
+		settings *models.Settings
+		photos   []*models.Photo
+		numFaces int
+	)
+
+	if !j.running.CompareAndSwap(false, true) {
+		slog.Info("face detection is already running")
+		return nil
+	}
+
+	defer j.running.Store(false)
+
+	if settings, err = j.settingsService.Read(); err != nil {
+		return fmt.Errorf("error reading settings: %w", err)
+	}
+
+	numPhotos := 0
+
+	for {
+		if photos, err = j.faceService.GetPhotosPendingDetection(batchSize); err != nil {
+			return err
+		}
+
+		if len(photos) == 0 {
+			break
+		}
+
+		saved := 0
+
+		for _, photo := range photos {
+			found, err := j.detect(settings, photo)
+
+			if err != nil {
+				slog.Error("error detecting faces in photo", "error", err, "id", photo.ID)
+				continue
+			}
+
+			numFaces += found
+			saved++
+		}
+
+		numPhotos += saved
+
+		/*
+		 * Photos are marked as looked at even when they can't be read,
+		 * so a batch with none saved means the database itself is
+		 * failing, and trying again would go on forever.
+		 */
+		if saved == 0 {
+			return fmt.Errorf("no photos could be saved in a batch of %d", len(photos))
+		}
+	}
+
+	slog.Info("faces detected", "photos", numPhotos, "faces", numFaces)
+	return j.cluster()
+}
+
+/*
+detect finds the faces in a photo that aren't already there, and
+describes them along with the faces the photo had, from its
+metadata. Returns how many new faces were found.
+*/
+func (j FaceDetectionJob) detect(settings *models.Settings, photo *models.Photo) (int, error) {
+	var (
+		err      error
+		img      image.Image
+		existing []*models.Face
+	)
+
+	detected := []*models.Face{}
+	described := []*models.Face{}
+
+	if existing, err = j.faceService.GetFacesInPhoto(photo.ID); err != nil {
+		return 0, err
+	}
+
+	/*
+	 * A photo that can't be read is still marked as looked at, so it
+	 * isn't tried again on every run. Changing the file gives it a new
+	 * chance, as collecting it again clears the mark.
+	 */
+	if img, err = j.readImage(settings, photo); err != nil {
+		slog.Error("error reading photo for face detection", "error", err, "id", photo.ID)
+		return 0, j.faceService.SaveDetectedFaces(photo.ID, detected, described)
+	}
+
+	for _, detection := range j.detector.Detect(img) {
+		face := &models.Face{
+			X:      detection.X,
+			Y:      detection.Y,
+			Width:  detection.Width,
+			Height: detection.Height,
+		}
+
+		if slices.ContainsFunc(existing, face.Overlaps) {
+			continue
+		}
+
+		descriptor := faces.Describe(img, face.X, face.Y, face.Width, face.Height)
+
+		if descriptor == nil {
+			continue
+		}
+
+		face.Descriptor = descriptor.Bytes()
+		detected = append(detected, face)
+	}
+
+	for _, face := range existing {
+		if descriptor := faces.Describe(img, face.X, face.Y, face.Width, face.Height); descriptor != nil {
+			face.Descriptor = descriptor.Bytes()
+			described = append(described, face)
+		}
+	}
+
+	if err = j.faceService.SaveDetectedFaces(photo.ID, detected, described); err != nil {
+		return 0, err
+	}
+
+	return len(detected), nil
+}
+
+/*
+readImage decodes a photo's thumbnail, or the original scaled down
+when there isn't one yet.
+*/
+func (j FaceDetectionJob) readImage(settings *models.Settings, photo *models.Photo) (image.Image, error) {
+	var (
+		err error
+		f   *os.File
+		img image.Image
+	)
+
+	sourcePath := filepath.Join(photo.FullPath, photo.FileName+photo.Ext)
+	hasThumbnail := j.photoCache.Exists(settings, photo)
+
+	if hasThumbnail {
+		sourcePath = j.photoCache.GetFullCachePath(settings, photo)
+	}
+
+	if f, err = os.Open(sourcePath); err != nil {
+		return nil, fmt.Errorf("error opening image file %s: %w", sourcePath, err)
+	}
+
+	defer f.Close()
+
+	if img, _, err = image.Decode(f); err != nil {
+		return nil, fmt.Errorf("error decoding image file %s: %w", sourcePath, err)
+	}
+
+	if !hasThumbnail {
+		img = resize.Thumbnail(maxImageSize, maxImageSize, img, resize.Bilinear)
+	}
+
+	return img, nil
+}
+
+/*
+cluster groups the unnamed faces that look like the same person. Each
+group is compared with the average face of every named person, and
+the closest, if they're close enough, is suggested for the group.
+*/
+func (j FaceDetectionJob) cluster() error {
+	var (
+		err       error
+		described []*models.Face
+	)
+
+	if described, err = j.faceService.GetDescribedFaces(); err != nil {
+		return err
+	}
+
+	unnamed := []*models.Face{}
+	descriptors := []faces.Descriptor{}
+	personFaces := map[uint][]faces.Descriptor{}
+
+	for _, face := range described {
+		descriptor := faces.DescriptorFromBytes(face.Descriptor)
+
+		switch {
+		case face.PersonID != 0:
+			personFaces[face.PersonID] = append(personFaces[face.PersonID], descriptor)
+
+		case face.Name == "":
+			unnamed = append(unnamed, face)
+			descriptors = append(descriptors, descriptor)
+		}
+	}
+
+	personIDs := []uint{}
+	personMeans := []faces.Descriptor{}
+
+	for personID, descriptors := range personFaces {
+		personIDs = append(personIDs, personID)
+		personMeans = append(personMeans, faces.Mean(descriptors))
+	}
+
+	clustered := []*models.Face{}
+	groups := faces.Cluster(descriptors)
+
+	for index, group := range groups {
+		var suggestedPersonID uint
+
+		members := make([]faces.Descriptor, 0, len(group))
+
+		for _, member := range group {
+			members = append(members, descriptors[member])
+		}
+
+		if closest, distance := faces.Closest(faces.Mean(members), personMeans); closest >= 0 && distance <= faces.MatchThreshold {
+			suggestedPersonID = personIDs[closest]
+		}
+
+		for _, member := range group {
+			face := unnamed[member]
+			face.ClusterID = int64(index + 1)
+			face.SuggestedPersonID = suggestedPersonID
+			clustered = append(clustered, face)
+		}
+	}
+
+	if err = j.faceService.SaveClusters(clustered); err != nil {
+		return err
+	}
+
+	slog.Info("faces grouped", "faces", len(clustered), "groups", len(groups))
+	return nil
+}
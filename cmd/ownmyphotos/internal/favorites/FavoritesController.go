@@ -0,0 +1,91 @@
+package favorites
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/adampresley/adamgokit/httphelpers"
+	"github.com/adampresley/adamgokit/rendering"
+	"github.com/adampresley/ownmyphotos/cmd/ownmyphotos/internal/viewmodels"
+	"github.com/adampresley/ownmyphotos/pkg/models"
+	"github.com/adampresley/ownmyphotos/pkg/services"
+)
+
+type FavoritesHandlers interface {
+	FavoritesPage(w http.ResponseWriter, r *http.Request)
+}
+
+type FavoritesControllerConfig struct {
+	PhotoService    services.PhotoServicer
+	Renderer        rendering.TemplateRenderer
+	SettingsService services.SettingsServicer
+}
+
+type FavoritesController struct {
+	photoService    services.PhotoServicer
+	renderer        rendering.TemplateRenderer
+	settingsService services.SettingsServicer
+}
+
+func NewFavoritesController(config FavoritesControllerConfig) FavoritesController {
+	return FavoritesController{
+		photoService:    config.PhotoService,
+		renderer:        config.Renderer,
+		settingsService: config.SettingsService,
+	}
+}
+
+/*
+GET /favorites
+*/
+func (c FavoritesController) FavoritesPage(w http.ResponseWriter, r *http.Request) {
+	var (
+		err      error
+		settings *models.Settings
+		photos   []*models.Photo
+	)
+
+	pageName := "pages/favorites"
+
+	viewData := viewmodels.Favorites{
+		BaseViewModel: viewmodels.BaseViewModel{
+			IsHtmx: httphelpers.IsHtmx(r),
+			JavascriptIncludes: []rendering.JavascriptInclude{
+				{Src: "/static/js/fslightbox.js", Type: "text/javascript"},
+				{Src: "/static/js/pages/home.js", Type: "module"},
+			},
+		},
+		Images: []viewmodels.ImageModel{},
+	}
+
+	/*
+	 * Pages after the first are requested by infinite scroll, and
+	 * only need the next set of photos.
+	 */
+	page := max(1, httphelpers.GetFromRequest[int](r, "page"))
+
+	if page > 1 && viewData.IsHtmx {
+		pageName = "pages/fragments/favorites-photos"
+	}
+
+	if settings, err = c.settingsService.Read(); err != nil {
+		slog.Error("error reading settings", "error", err)
+		viewData.Message = "Error reading settings"
+		viewData.IsError = true
+
+		c.renderer.Render(pageName, viewData, w)
+		return
+	}
+
+	if photos, viewData.Paging, err = c.photoService.GetFavoritePhotos(page); err != nil {
+		slog.Error("error getting favorite photos", "error", err)
+		viewData.Message = "There was an error retrieving your favorite photos."
+		viewData.IsError = true
+
+		c.renderer.Render(pageName, viewData, w)
+		return
+	}
+
+	viewData.Images = viewmodels.NewImageModelCollectionFromPhotos(photos, []*models.Folder{}, settings.LibraryPath)
+	c.renderer.Render(pageName, viewData, w)
+}
@@ -0,0 +1,144 @@
+package gear
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/adampresley/adamgokit/httphelpers"
+	"github.com/adampresley/adamgokit/rendering"
+	"github.com/adampresley/ownmyphotos/cmd/ownmyphotos/internal/viewmodels"
+	"github.com/adampresley/ownmyphotos/pkg/models"
+	"github.com/adampresley/ownmyphotos/pkg/services"
+)
+
+type GearHandlers interface {
+	GearPage(w http.ResponseWriter, r *http.Request)
+}
+
+type GearControllerConfig struct {
+	PhotoService    services.PhotoServicer
+	Renderer        rendering.TemplateRenderer
+	SettingsService services.SettingsServicer
+}
+
+type GearController struct {
+	photoService    services.PhotoServicer
+	renderer        rendering.TemplateRenderer
+	settingsService services.SettingsServicer
+}
+
+func NewGearController(config GearControllerConfig) GearController {
+	return GearController{
+		photoService:    config.PhotoService,
+		renderer:        config.Renderer,
+		settingsService: config.SettingsService,
+	}
+}
+
+/*
+GET /gear
+GET /gear?camera=fujifilm|x100v
+GET /gear?lens=ef50mmf/1.8stm
+*/
+func (c GearController) GearPage(w http.ResponseWriter, r *http.Request) {
+	var (
+		err      error
+		settings *models.Settings
+		photos   []*models.Photo
+	)
+
+	pageName := "pages/gear"
+
+	viewData := viewmodels.Gear{
+		BaseViewModel: viewmodels.BaseViewModel{
+			IsHtmx: httphelpers.IsHtmx(r),
+			JavascriptIncludes: []rendering.JavascriptInclude{
+				{Src: "/static/js/fslightbox.js", Type: "text/javascript"},
+				{Src: "/static/js/pages/home.js", Type: "module"},
+			},
+		},
+		Cameras: []*models.Equipment{},
+		Lenses:  []*models.Equipment{},
+		Images:  []viewmodels.ImageModel{},
+	}
+
+	if id := httphelpers.GetFromRequest[string](r, "camera"); id != "" {
+		viewData.Selected = &models.Equipment{Kind: models.EquipmentCamera, ID: id}
+	} else if id := httphelpers.GetFromRequest[string](r, "lens"); id != "" {
+		viewData.Selected = &models.Equipment{Kind: models.EquipmentLens, ID: id}
+	}
+
+	/*
+	 * Pages after the first are requested by infinite scroll, and
+	 * only need the next set of photos.
+	 */
+	page := max(1, httphelpers.GetFromRequest[int](r, "page"))
+
+	if page > 1 && viewData.IsHtmx {
+		pageName = "pages/fragments/gear-photos"
+	}
+
+	if settings, err = c.settingsService.Read(); err != nil {
+		slog.Error("error reading settings", "error", err)
+		viewData.Message = "Error reading settings"
+		viewData.IsError = true
+
+		c.renderer.Render(pageName, viewData, w)
+		return
+	}
+
+	if viewData.Selected != nil {
+		if photos, viewData.Paging, err = c.photoService.GetPhotosWithEquipment(viewData.Selected.Kind, viewData.Selected.ID, page); err != nil {
+			slog.Error("error getting photos for equipment", "error", err, "kind", viewData.Selected.Kind, "id", viewData.Selected.ID)
+			viewData.Message = "There was an error retrieving photos taken with this " + string(viewData.Selected.Kind) + "."
+			viewData.IsError = true
+
+			c.renderer.Render(pageName, viewData, w)
+			return
+		}
+
+		viewData.Images = viewmodels.NewImageModelCollectionFromPhotos(photos, []*models.Folder{}, settings.LibraryPath)
+	}
+
+	if page > 1 && viewData.IsHtmx {
+		c.renderer.Render(pageName, viewData, w)
+		return
+	}
+
+	if viewData.Cameras, err = c.photoService.GetEquipment(models.EquipmentCamera); err != nil {
+		slog.Error("error getting cameras", "error", err)
+		viewData.Message = "There was an error retrieving your cameras."
+		viewData.IsError = true
+
+		c.renderer.Render(pageName, viewData, w)
+		return
+	}
+
+	if viewData.Lenses, err = c.photoService.GetEquipment(models.EquipmentLens); err != nil {
+		slog.Error("error getting lenses", "error", err)
+		viewData.Message = "There was an error retrieving your lenses."
+		viewData.IsError = true
+
+		c.renderer.Render(pageName, viewData, w)
+		return
+	}
+
+	/*
+	 * Use the full details of the selected equipment for the heading.
+	 */
+	if viewData.Selected != nil {
+		for _, equipment := range append(viewData.Cameras, viewData.Lenses...) {
+			if equipment.Kind == viewData.Selected.Kind && equipment.ID == viewData.Selected.ID {
+				viewData.Selected = equipment
+				break
+			}
+		}
+
+		if viewData.Selected.Name == "" {
+			viewData.Message = "No photos were taken with this " + string(viewData.Selected.Kind) + "."
+			viewData.IsWarning = true
+		}
+	}
+
+	c.renderer.Render(pageName, viewData, w)
+}
@@ -0,0 +1,188 @@
+package geo
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/adampresley/adamgokit/httphelpers"
+	"github.com/adampresley/adamgokit/rendering"
+	"github.com/adampresley/ownmyphotos/cmd/ownmyphotos/internal/viewmodels"
+	"github.com/adampresley/ownmyphotos/pkg/models"
+	"github.com/adampresley/ownmyphotos/pkg/services"
+)
+
+const (
+	defaultRadiusKm = 1.0
+	maxRadiusKm     = 500.0
+)
+
+type GeoHandlers interface {
+	MapPage(w http.ResponseWriter, r *http.Request)
+	MapPhotos(w http.ResponseWriter, r *http.Request)
+	PhotosGeoJSON(w http.ResponseWriter, r *http.Request)
+}
+
+type GeoControllerConfig struct {
+	PhotoService    services.PhotoServicer
+	Renderer        rendering.TemplateRenderer
+	SettingsService services.SettingsServicer
+}
+
+type GeoController struct {
+	photoService    services.PhotoServicer
+	renderer        rendering.TemplateRenderer
+	settingsService services.SettingsServicer
+}
+
+func NewGeoController(config GeoControllerConfig) GeoController {
+	return GeoController{
+		photoService:    config.PhotoService,
+		renderer:        config.Renderer,
+		settingsService: config.SettingsService,
+	}
+}
+
+/*
+GET /map
+*/
+func (c GeoController) MapPage(w http.ResponseWriter, r *http.Request) {
+	var (
+		err      error
+		settings *models.Settings
+	)
+
+	pageName := "pages/map"
+
+	viewData := viewmodels.MapPage{
+		BaseViewModel: viewmodels.BaseViewModel{
+			IsHtmx: httphelpers.IsHtmx(r),
+			JavascriptIncludes: []rendering.JavascriptInclude{
+				{Src: "/static/js/fslightbox.js", Type: "text/javascript"},
+				{Src: "/static/js/pages/home.js", Type: "module"},
+			},
+		},
+	}
+
+	if settings, err = c.settingsService.Read(); err != nil {
+		slog.Error("error reading settings", "error", err)
+		viewData.Message = "Error reading settings"
+		viewData.IsError = true
+
+		c.renderer.Render(pageName, viewData, w)
+		return
+	}
+
+	viewData.TileURL = settings.MapTileURL
+	viewData.TileAttribution = settings.MapTileAttribution
+
+	c.renderer.Render(pageName, viewData, w)
+}
+
+/*
+GET /map/photos?bbox=west,south,east,north
+GET /map/photos?lat=38.9&lon=-77.03&radius=2
+*/
+func (c GeoController) MapPhotos(w http.ResponseWriter, r *http.Request) {
+	var (
+		err      error
+		settings *models.Settings
+		photos   []*models.Photo
+		bounds   models.GeoBounds
+	)
+
+	pageName := "pages/fragments/map-photos"
+
+	viewData := viewmodels.MapPhotos{
+		BaseViewModel: viewmodels.BaseViewModel{
+			IsHtmx: httphelpers.IsHtmx(r),
+		},
+		Bounds:    strings.TrimSpace(httphelpers.GetFromRequest[string](r, "bbox")),
+		Latitude:  httphelpers.GetFromRequest[float64](r, "lat"),
+		Longitude: httphelpers.GetFromRequest[float64](r, "lon"),
+		RadiusKm:  httphelpers.GetFromRequest[float64](r, "radius"),
+		Images:    []viewmodels.ImageModel{},
+	}
+
+	/*
+	 * Pages after the first are requested by infinite scroll, and
+	 * only need the next set of photos.
+	 */
+	page := max(1, httphelpers.GetFromRequest[int](r, "page"))
+
+	if page > 1 {
+		pageName = "pages/fragments/map-photos-page"
+	}
+
+	if settings, err = c.settingsService.Read(); err != nil {
+		slog.Error("error reading settings", "error", err)
+		viewData.Message = "Error reading settings"
+		viewData.IsError = true
+
+		c.renderer.Render(pageName, viewData, w)
+		return
+	}
+
+	if viewData.Bounds != "" {
+		if bounds, err = models.ParseGeoBounds(viewData.Bounds); err != nil {
+			viewData.Message = err.Error()
+			viewData.IsError = true
+
+			c.renderer.Render(pageName, viewData, w)
+			return
+		}
+
+		viewData.Bounds = bounds.String()
+		viewData.Heading = "Photos in this area"
+		photos, viewData.Paging, err = c.photoService.GetPhotosInBounds(bounds, page)
+	} else {
+		if viewData.RadiusKm <= 0 {
+			viewData.RadiusKm = defaultRadiusKm
+		}
+
+		viewData.RadiusKm = min(viewData.RadiusKm, maxRadiusKm)
+		viewData.Heading = fmt.Sprintf("Photos within %g km", viewData.RadiusKm)
+		photos, viewData.Paging, err = c.photoService.GetPhotosNear(viewData.Latitude, viewData.Longitude, viewData.RadiusKm, page)
+	}
+
+	if err != nil {
+		slog.Error("error getting photos for the map", "error", err, "bbox", viewData.Bounds, "lat", viewData.Latitude, "lon", viewData.Longitude)
+		viewData.Message = "There was an error retrieving photos for this area."
+		viewData.IsError = true
+
+		c.renderer.Render(pageName, viewData, w)
+		return
+	}
+
+	viewData.Images = viewmodels.NewImageModelCollectionFromPhotos(photos, []*models.Folder{}, settings.LibraryPath)
+	c.renderer.Render(pageName, viewData, w)
+}
+
+/*
+GET /api/photos.geojson?bbox=west,south,east,north&zoom=4
+*/
+func (c GeoController) PhotosGeoJSON(w http.ResponseWriter, r *http.Request) {
+	var (
+		err      error
+		bounds   = models.GeoBounds{West: -180, South: -90, East: 180, North: 90}
+		clusters []*models.GeoCluster
+	)
+
+	if bbox := strings.TrimSpace(httphelpers.GetFromRequest[string](r, "bbox")); bbox != "" {
+		if bounds, err = models.ParseGeoBounds(bbox); err != nil {
+			httphelpers.JsonErrorMessage(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	zoom := httphelpers.GetFromRequest[int](r, "zoom")
+
+	if clusters, err = c.photoService.GetGeoClusters(bounds, zoom); err != nil {
+		slog.Error("error getting photo clusters", "error", err, "bbox", bounds.String(), "zoom", zoom)
+		httphelpers.JsonErrorMessage(w, http.StatusInternalServerError, "There was an error retrieving photo locations.")
+		return
+	}
+
+	httphelpers.JsonOK(w, viewmodels.NewGeoJSONFeatureCollection(clusters))
+}
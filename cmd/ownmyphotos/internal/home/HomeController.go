@@ -1,6 +1,7 @@
 package home
 
 import (
+	"errors"
 	"log/slog"
 	"net/http"
 	"os"
@@ -22,6 +23,7 @@ type HomeHandlers interface {
 }
 
 type HomeControllerConfig struct {
+	AlbumService    services.AlbumServicer
 	Config          *configuration.Config
 	FolderService   services.FolderServicer
 	PhotoService    services.PhotoServicer
@@ -30,6 +32,7 @@ type HomeControllerConfig struct {
 }
 
 type HomeController struct {
+	albumService    services.AlbumServicer
 	config          *configuration.Config
 	folderService   services.FolderServicer
 	photoService    services.PhotoServicer
@@ -39,6 +42,7 @@ type HomeController struct {
 
 func NewHomeController(config HomeControllerConfig) HomeController {
 	return HomeController{
+		albumService:    config.AlbumService,
 		config:          config.Config,
 		folderService:   config.FolderService,
 		photoService:    config.PhotoService,
@@ -78,6 +82,17 @@ func (c HomeController) HomePage(w http.ResponseWriter, r *http.Request) {
 		Root:   strings.TrimSpace(httphelpers.GetFromRequest[string](r, "root")),
 		Images: []viewmodels.ImageModel{},
 		Parent: "root",
+		Sort:   models.NewPhotoSort(httphelpers.GetFromRequest[string](r, "sort")),
+	}
+
+	/*
+	 * Pages after the first are requested by infinite scroll, and
+	 * only need the next set of photos.
+	 */
+	page := max(1, httphelpers.GetFromRequest[int](r, "page"))
+
+	if page > 1 && viewData.IsHtmx {
+		pageName = "pages/fragments/home-photos"
 	}
 
 	if settings, err = c.settingsService.Read(); err != nil {
@@ -98,6 +113,18 @@ func (c HomeController) HomePage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if page > 1 && viewData.IsHtmx {
+		if photos, viewData.Paging, err = c.photoService.GetPhotosInFolder(cleanRoot, viewData.Sort, page); err != nil {
+			slog.Error("error getting photos", "error", err, "root", cleanRoot, "page", page)
+			http.Error(w, "There was an error retrieving more photos", http.StatusInternalServerError)
+			return
+		}
+
+		viewData.Images = viewmodels.NewImageModelCollectionFromPhotos(photos, []*models.Folder{}, settings.LibraryPath)
+		c.renderer.Render(pageName, viewData, w)
+		return
+	}
+
 	if viewData.Root != "" {
 		pathParts := strings.Split(filepath.ToSlash(viewData.Root), "/")
 
@@ -122,7 +149,7 @@ func (c HomeController) HomePage(w http.ResponseWriter, r *http.Request) {
 	/*
 	 * Get photos for this path.
 	 */
-	if photos, err = c.photoService.GetPhotosInFolder(cleanRoot); err != nil {
+	if photos, viewData.Paging, err = c.photoService.GetPhotosInFolder(cleanRoot, viewData.Sort, page); err != nil {
 		slog.Error("error getting photos", "error", err, "root", cleanRoot)
 		viewData.Message = "There was an error retrieving photos for the path '" + cleanRoot + "'."
 		viewData.IsError = true
@@ -141,6 +168,7 @@ func (c HomeController) HomePage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	viewData.Images = viewmodels.NewImageModelCollectionFromPhotos(photos, folders, settings.LibraryPath)
+	viewData.Selection = viewmodels.NewSelection(viewData.SortURL(viewData.Sort), c.getAlbums())
 	c.renderer.Render(pageName, viewData, w)
 }
 
@@ -161,9 +189,21 @@ func (c HomeController) SimpleSearchPage(w http.ResponseWriter, r *http.Request)
 		Root:        httphelpers.GetFromRequest[string](r, "root"),
 		Results:     models.SearchPhotosResult{},
 		LibraryPath: "",
+		Keywords:    httphelpers.GetFromRequest[[]string](r, "keyword"),
+		People:      httphelpers.GetFromRequest[[]string](r, "person"),
+		Match:       models.NewSearchMatch(httphelpers.GetFromRequest[string](r, "match")),
+		Page:        max(1, httphelpers.GetFromRequest[int](r, "page")),
+	}
+
+	if viewData.Page > 1 && viewData.IsHtmx {
+		pageName = "pages/fragments/simple-search-photos"
 	}
 
 	criteria := models.PhotoSearch{
+		Keywords:   viewData.Keywords,
+		Match:      viewData.Match,
+		Page:       viewData.Page,
+		People:     viewData.People,
 		SearchTerm: viewData.SearchTerm,
 	}
 
@@ -179,6 +219,15 @@ func (c HomeController) SimpleSearchPage(w http.ResponseWriter, r *http.Request)
 	viewData.LibraryPath = settings.LibraryPath
 
 	if viewData.Results, err = c.photoService.Search(criteria); err != nil {
+		/*
+		 * Problems with the query itself are the user's to fix, so
+		 * show them where the query went wrong.
+		 */
+		if errors.As(err, &viewData.QueryError) {
+			c.renderer.Render(pageName, viewData, w)
+			return
+		}
+
 		slog.Error("error searching for photos", "error", err, "term", viewData.SearchTerm)
 		viewData.Message = "There was an error searching for photos with the term '" + viewData.SearchTerm + "'."
 		viewData.IsError = true
@@ -187,6 +236,7 @@ func (c HomeController) SimpleSearchPage(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	viewData.Selection = viewmodels.NewSelection(viewData.CurrentURL(), c.getAlbums())
 	c.renderer.Render(pageName, viewData, w)
 }
 
@@ -204,6 +254,22 @@ func (c HomeController) AboutPage(w http.ResponseWriter, r *http.Request) {
 	c.renderer.Render(pageName, viewData, w)
 }
 
+/*
+getAlbums returns the albums selected photos can be added to. The
+page is still useful without them, so an error only leaves the list
+empty.
+*/
+func (c HomeController) getAlbums() []*models.Album {
+	albums, err := c.albumService.All()
+
+	if err != nil {
+		slog.Error("error getting albums", "error", err)
+		return []*models.Album{}
+	}
+
+	return albums
+}
+
 // BuildFolderTree builds a hierarchical folder structure
 func BuildFolderTree(libraryPath string, folders []*models.Folder, currentPath string) *models.FolderNode {
 	// Create a map of paths to folder nodes
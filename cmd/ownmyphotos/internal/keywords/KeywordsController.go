@@ -0,0 +1,230 @@
+package keywords
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/adampresley/adamgokit/httphelpers"
+	"github.com/adampresley/adamgokit/rendering"
+	"github.com/adampresley/ownmyphotos/cmd/ownmyphotos/internal/viewmodels"
+	"github.com/adampresley/ownmyphotos/pkg/models"
+	"github.com/adampresley/ownmyphotos/pkg/services"
+)
+
+type KeywordsHandlers interface {
+	KeywordsPage(w http.ResponseWriter, r *http.Request)
+	RenameKeywordAction(w http.ResponseWriter, r *http.Request)
+	MergeKeywordsAction(w http.ResponseWriter, r *http.Request)
+	DeleteKeywordAction(w http.ResponseWriter, r *http.Request)
+}
+
+type KeywordsControllerConfig struct {
+	KeywordService  services.KeywordServicer
+	PhotoService    services.PhotoServicer
+	Renderer        rendering.TemplateRenderer
+	SettingsService services.SettingsServicer
+}
+
+type KeywordsController struct {
+	keywordService  services.KeywordServicer
+	photoService    services.PhotoServicer
+	renderer        rendering.TemplateRenderer
+	settingsService services.SettingsServicer
+}
+
+func NewKeywordsController(config KeywordsControllerConfig) KeywordsController {
+	return KeywordsController{
+		keywordService:  config.KeywordService,
+		photoService:    config.PhotoService,
+		renderer:        config.Renderer,
+		settingsService: config.SettingsService,
+	}
+}
+
+/*
+GET /keywords
+*/
+func (c KeywordsController) KeywordsPage(w http.ResponseWriter, r *http.Request) {
+	viewData := viewmodels.Keywords{
+		BaseViewModel: viewmodels.BaseViewModel{
+			IsHtmx: httphelpers.IsHtmx(r),
+		},
+	}
+
+	c.renderKeywords(viewData, w)
+}
+
+/*
+POST /keywords/rename
+*/
+func (c KeywordsController) RenameKeywordAction(w http.ResponseWriter, r *http.Request) {
+	var (
+		err error
+		ids []string
+	)
+
+	keyword := httphelpers.GetFromRequest[string](r, "keyword")
+	newName := strings.TrimSpace(httphelpers.GetFromRequest[string](r, "newName"))
+
+	viewData := viewmodels.Keywords{
+		BaseViewModel: viewmodels.BaseViewModel{
+			IsHtmx: httphelpers.IsHtmx(r),
+		},
+		WriteBack: httphelpers.GetFromRequest[bool](r, "writeBack"),
+	}
+
+	if ids, err = c.keywordService.Rename(keyword, newName); err != nil {
+		slog.Error("error renaming keyword", "error", err, "keyword", keyword, "newName", newName)
+		viewData.Message = "There was an error renaming the keyword."
+		viewData.IsError = true
+
+		if errors.Is(err, services.ErrKeywordNameRequired) {
+			viewData.Message = "Please give the keyword a name."
+		}
+
+		c.renderKeywords(viewData, w)
+		return
+	}
+
+	viewData.Message = fmt.Sprintf("Renamed '%s' to '%s' on %d photos.", keyword, newName, len(ids))
+	c.writeBack(ids, &viewData)
+	c.renderKeywords(viewData, w)
+}
+
+/*
+POST /keywords/merge
+*/
+func (c KeywordsController) MergeKeywordsAction(w http.ResponseWriter, r *http.Request) {
+	var (
+		err error
+		ids []string
+	)
+
+	if err = r.ParseForm(); err != nil {
+		slog.Error("error parsing form when merging keywords", "error", err)
+	}
+
+	keywords := r.Form["keyword"]
+	into := strings.TrimSpace(httphelpers.GetFromRequest[string](r, "into"))
+
+	viewData := viewmodels.Keywords{
+		BaseViewModel: viewmodels.BaseViewModel{
+			IsHtmx: httphelpers.IsHtmx(r),
+		},
+		WriteBack: httphelpers.GetFromRequest[bool](r, "writeBack"),
+	}
+
+	if len(keywords) == 0 {
+		viewData.Message = "Please select the keywords to merge."
+		viewData.IsError = true
+
+		c.renderKeywords(viewData, w)
+		return
+	}
+
+	if ids, err = c.keywordService.Merge(keywords, into); err != nil {
+		slog.Error("error merging keywords", "error", err, "keywords", keywords, "into", into)
+		viewData.Message = "There was an error merging the keywords."
+		viewData.IsError = true
+
+		if errors.Is(err, services.ErrKeywordNameRequired) {
+			viewData.Message = "Please enter the keyword to merge them into."
+		}
+
+		c.renderKeywords(viewData, w)
+		return
+	}
+
+	viewData.Message = fmt.Sprintf("Merged %d keywords into '%s' on %d photos.", len(keywords), into, len(ids))
+	c.writeBack(ids, &viewData)
+	c.renderKeywords(viewData, w)
+}
+
+/*
+POST /keywords/delete
+*/
+func (c KeywordsController) DeleteKeywordAction(w http.ResponseWriter, r *http.Request) {
+	var (
+		err error
+		ids []string
+	)
+
+	keyword := httphelpers.GetFromRequest[string](r, "keyword")
+
+	viewData := viewmodels.Keywords{
+		BaseViewModel: viewmodels.BaseViewModel{
+			IsHtmx: httphelpers.IsHtmx(r),
+		},
+		WriteBack: httphelpers.GetFromRequest[bool](r, "writeBack"),
+	}
+
+	if ids, err = c.keywordService.Delete(keyword); err != nil {
+		slog.Error("error deleting keyword", "error", err, "keyword", keyword)
+		viewData.Message = "There was an error deleting the keyword."
+		viewData.IsError = true
+
+		c.renderKeywords(viewData, w)
+		return
+	}
+
+	viewData.Message = fmt.Sprintf("Removed '%s' from %d photos.", keyword, len(ids))
+	c.writeBack(ids, &viewData)
+	c.renderKeywords(viewData, w)
+}
+
+/*
+writeBack writes the keywords of photos changed in the database back
+to their files, when asked to. Otherwise the change stays in the
+database until the files themselves change.
+*/
+func (c KeywordsController) writeBack(ids []string, viewData *viewmodels.Keywords) {
+	var (
+		err      error
+		settings *models.Settings
+		written  int
+	)
+
+	if !viewData.WriteBack || len(ids) == 0 {
+		return
+	}
+
+	if settings, err = c.settingsService.Read(); err != nil {
+		slog.Error("error reading settings", "error", err)
+		viewData.Message += " Error reading settings, so the change wasn't written to your files."
+		viewData.IsWarning = true
+		return
+	}
+
+	if written, err = c.photoService.RewriteMetadata(ids, settings.MetadataWriteTarget); err != nil {
+		slog.Error("error writing keywords back to photos", "error", err)
+		viewData.Message += fmt.Sprintf(" Wrote the change to %d of %d files. The rest couldn't be written.", written, len(ids))
+		viewData.IsWarning = true
+		return
+	}
+
+	viewData.Message += fmt.Sprintf(" Wrote the change to %d files.", written)
+}
+
+func (c KeywordsController) renderKeywords(viewData viewmodels.Keywords, w http.ResponseWriter) {
+	var (
+		err      error
+		keywords []*models.KeywordSearchResult
+	)
+
+	pageName := "pages/keywords"
+
+	if keywords, err = c.keywordService.All(); err != nil {
+		slog.Error("error getting keywords", "error", err)
+		viewData.Message = "There was an error retrieving your keywords."
+		viewData.IsError = true
+	}
+
+	viewData.NumKeywords = len(keywords)
+	viewData.Tree = models.BuildKeywordTree(keywords)
+	viewData.CaseDuplicates = models.FindCaseDuplicates(keywords)
+
+	c.renderer.Render(pageName, viewData, w)
+}
@@ -2,32 +2,57 @@ package library
 
 import (
 	"fmt"
+	"image"
+	"image/jpeg"
 	"io/fs"
 	"log/slog"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/adampresley/adamgokit/httphelpers"
+	"github.com/adampresley/adamgokit/rendering"
+	"github.com/adampresley/ownmyphotos/cmd/ownmyphotos/internal/viewmodels"
+	"github.com/adampresley/ownmyphotos/pkg/cache"
 	"github.com/adampresley/ownmyphotos/pkg/models"
 	"github.com/adampresley/ownmyphotos/pkg/services"
 )
 
+const (
+	cacheControlImmutable  = "public, max-age=31536000, immutable"
+	cacheControlRevalidate = "no-cache"
+
+	renditionFace     = "face"
+	renditionOriginal = "original"
+
+	faceCropSize = 256
+)
+
 type LibraryHandlers interface {
+	PhotoDetails(w http.ResponseWriter, r *http.Request)
+	ServeFaceCrop(w http.ResponseWriter, r *http.Request)
 	ServeImage(w http.ResponseWriter, r *http.Request)
 	ServeThumbnail(w http.ResponseWriter, r *http.Request)
+	SetLabelAction(w http.ResponseWriter, r *http.Request)
+	SetRatingAction(w http.ResponseWriter, r *http.Request)
+	ToggleFavoriteAction(w http.ResponseWriter, r *http.Request)
 }
 
 type LibraryControllerConfig struct {
 	PhotoCache      services.PhotoCacher
 	PhotoService    services.PhotoServicer
+	PlaceService    services.PlaceServicer
+	Renderer        rendering.TemplateRenderer
 	SettingsService services.SettingsServicer
 }
 
 type LibraryController struct {
 	photoCache      services.PhotoCacher
 	photoService    services.PhotoServicer
+	placeService    services.PlaceServicer
+	renderer        rendering.TemplateRenderer
 	settingsService services.SettingsServicer
 }
 
@@ -35,10 +60,159 @@ func NewLibraryController(config LibraryControllerConfig) LibraryController {
 	return LibraryController{
 		photoCache:      config.PhotoCache,
 		photoService:    config.PhotoService,
+		placeService:    config.PlaceService,
+		renderer:        config.Renderer,
 		settingsService: config.SettingsService,
 	}
 }
 
+/*
+GET /library/{id}/details
+*/
+func (c LibraryController) PhotoDetails(w http.ResponseWriter, r *http.Request) {
+	var (
+		err       error
+		photo     *models.Photo
+		placePath models.PlacePath
+	)
+
+	pageName := "pages/fragments/photo-details"
+	id := httphelpers.GetFromRequest[string](r, "id")
+
+	viewData := viewmodels.PhotoDetails{
+		BaseViewModel: viewmodels.BaseViewModel{
+			IsHtmx: httphelpers.IsHtmx(r),
+		},
+		Places: []*models.Place{},
+	}
+
+	if photo, err = c.photoService.GetPhotoByID(id); err != nil || photo.ID == "" {
+		slog.Error("error retrieving photo", "error", err, "id", id)
+		viewData.Message = "This photo could not be found."
+		viewData.IsError = true
+
+		c.renderer.Render(pageName, viewData, w)
+		return
+	}
+
+	viewData.Photo = photo
+
+	if photo.PlaceID != nil {
+		if placePath, err = c.placeService.GetCityPlacePath(*photo.PlaceID); err == nil {
+			viewData.Places, err = c.placeService.GetPlaceHierarchy(placePath)
+		}
+
+		/*
+		 * The place is a nicety, so the rest of the details are still
+		 * shown without it.
+		 */
+		if err != nil {
+			slog.Error("error getting the place of a photo", "error", err, "id", id)
+		}
+	}
+
+	c.renderer.Render(pageName, viewData, w)
+}
+
+/*
+PUT /library/{id}/favorite
+
+Returns the favorite button with the photo's new state.
+*/
+func (c LibraryController) ToggleFavoriteAction(w http.ResponseWriter, r *http.Request) {
+	var (
+		err   error
+		photo *models.Photo
+	)
+
+	pageName := "pages/fragments/favorite-toggle"
+	id := httphelpers.GetFromRequest[string](r, "id")
+
+	if photo, err = c.photoService.GetPhotoByID(id); err != nil || photo.ID == "" {
+		slog.Error("error retrieving photo", "error", err, "id", id)
+		http.Error(w, "Error retrieving photo", http.StatusNotFound)
+		return
+	}
+
+	viewData := viewmodels.FavoriteToggle{
+		BaseViewModel: viewmodels.BaseViewModel{
+			IsHtmx: httphelpers.IsHtmx(r),
+		},
+		Photo:      photo,
+		IsFavorite: photo.IsFavorite,
+	}
+
+	if viewData.IsFavorite, err = c.photoService.ToggleFavorite(id); err != nil {
+		slog.Error("error toggling favorite", "error", err, "id", id)
+		viewData.IsFavorite = photo.IsFavorite
+	}
+
+	c.renderer.Render(pageName, viewData, w)
+}
+
+/*
+PUT /library/{id}/rating
+
+Returns the rating controls with the photo's new rating.
+*/
+func (c LibraryController) SetRatingAction(w http.ResponseWriter, r *http.Request) {
+	id := httphelpers.GetFromRequest[string](r, "id")
+	rating := httphelpers.GetFromRequest[int](r, "rating")
+
+	c.renderRating(w, r, id, func() (*models.Photo, error) {
+		return c.photoService.SetRating(id, rating)
+	})
+}
+
+/*
+PUT /library/{id}/label
+
+Returns the rating controls with the photo's new color label.
+*/
+func (c LibraryController) SetLabelAction(w http.ResponseWriter, r *http.Request) {
+	id := httphelpers.GetFromRequest[string](r, "id")
+	label := models.NewColorLabel(httphelpers.GetFromRequest[string](r, "label"))
+
+	c.renderRating(w, r, id, func() (*models.Photo, error) {
+		return c.photoService.SetLabel(id, label)
+	})
+}
+
+/*
+renderRating applies a rating change and renders the rating controls.
+If the change fails, the controls are rendered as they were.
+*/
+func (c LibraryController) renderRating(w http.ResponseWriter, r *http.Request, id string, change func() (*models.Photo, error)) {
+	var (
+		err     error
+		photo   *models.Photo
+		updated *models.Photo
+	)
+
+	pageName := "pages/fragments/photo-rating"
+
+	if photo, err = c.photoService.GetPhotoByID(id); err != nil || photo.ID == "" {
+		slog.Error("error retrieving photo", "error", err, "id", id)
+		http.Error(w, "Error retrieving photo", http.StatusNotFound)
+		return
+	}
+
+	viewData := viewmodels.PhotoRating{
+		BaseViewModel: viewmodels.BaseViewModel{
+			IsHtmx: httphelpers.IsHtmx(r),
+		},
+		Photo: photo,
+	}
+
+	if updated, err = change(); err != nil {
+		slog.Error("error changing the rating of a photo", "error", err, "id", id)
+	} else {
+		viewData.Photo = updated
+	}
+
+	c.renderer.Render(pageName, viewData, w)
+}
+
 func (c LibraryController) ServeImage(w http.ResponseWriter, r *http.Request) {
 	var (
 		err      error
@@ -54,13 +228,13 @@ func (c LibraryController) ServeImage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if photo, err = c.photoService.GetPhotoByID(id); err != nil {
+	if photo, err = c.photoService.GetPhotoByID(id); err != nil || photo.ID == "" {
 		slog.Error("Error retrieving photo", "error", err, "id", id)
 		http.Error(w, "Error retrieving photo", http.StatusNotFound)
 		return
 	}
 
-	c.serveFullImage(w, r, settings, photo)
+	c.serveFullImage(w, r, settings, photo, cacheControlFor(r, photo.MetadataHash))
 }
 
 func (c LibraryController) ServeThumbnail(w http.ResponseWriter, r *http.Request) {
@@ -78,7 +252,7 @@ func (c LibraryController) ServeThumbnail(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	if photo, err = c.photoService.GetPhotoByID(id); err != nil {
+	if photo, err = c.photoService.GetPhotoByID(id); err != nil || photo.ID == "" {
 		slog.Error("Error retrieving photo", "error", err, "id", id)
 		http.Error(w, "Error retrieving photo", http.StatusNotFound)
 		return
@@ -89,57 +263,176 @@ func (c LibraryController) ServeThumbnail(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	c.serveFullImage(w, r, settings, photo)
+	/*
+	 * The thumbnail hasn't been created yet, so send the original. It must
+	 * be revalidated, otherwise the browser would keep the full size image
+	 * for this URL even after the thumbnail exists.
+	 */
+	c.serveFullImage(w, r, settings, photo, cacheControlRevalidate)
 }
 
-func (c LibraryController) serveFullImage(w http.ResponseWriter, r *http.Request, settings *models.Settings, photo *models.Photo) {
+/*
+GET /faces/{id}
+
+Serves a square crop around a face, used as a person's avatar. The
+crop is made from the photo's thumbnail when it has one, which is
+plenty for an avatar and much quicker to decode than the original.
+*/
+func (c LibraryController) ServeFaceCrop(w http.ResponseWriter, r *http.Request) {
 	var (
-		err  error
-		f    *os.File
-		info fs.FileInfo
+		err      error
+		settings *models.Settings
+		face     *models.Face
+		photo    *models.Photo
+		f        *os.File
+		img      image.Image
 	)
 
-	fullPath := filepath.Join(photo.FullPath, photo.FileName+photo.Ext)
+	id := httphelpers.GetFromRequest[int64](r, "id")
 
-	if f, err = os.Open(fullPath); err != nil {
-		slog.Error("Error opening image file", "error", err, "path", fullPath)
+	if settings, err = c.settingsService.Read(); err != nil {
+		slog.Error("Error reading settings in ServeFaceCrop", "error", err)
+		http.Error(w, "Error reading settings", http.StatusInternalServerError)
+		return
+	}
+
+	if face, err = c.photoService.GetFace(id); err != nil || face.ID == 0 {
+		slog.Error("Error retrieving face", "error", err, "id", id)
+		http.Error(w, "Error retrieving face", http.StatusNotFound)
+		return
+	}
+
+	if photo, err = c.photoService.GetPhotoByID(face.PhotoID); err != nil || photo.ID == "" {
+		slog.Error("Error retrieving photo", "error", err, "id", face.PhotoID)
+		http.Error(w, "Error retrieving photo", http.StatusNotFound)
+		return
+	}
+
+	/*
+	 * A face's rectangle is part of the photo's metadata hash, so the
+	 * photo's ETag changes along with it.
+	 */
+	etag := photo.ETag(fmt.Sprintf("%s-%d", renditionFace, face.ID))
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", cacheControlRevalidate)
+
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	sourcePath := filepath.Join(photo.FullPath, photo.FileName+photo.Ext)
+
+	if c.photoCache.Exists(settings, photo) {
+		sourcePath = c.photoCache.GetFullCachePath(settings, photo)
+	}
+
+	if f, err = os.Open(sourcePath); err != nil {
+		slog.Error("Error opening image file", "error", err, "path", sourcePath)
+		w.Header().Del("ETag")
 		http.Error(w, "Error retrieving image", http.StatusInternalServerError)
 		return
 	}
 
 	defer f.Close()
 
-	modTime := time.Now()
+	if img, _, err = image.Decode(f); err != nil {
+		slog.Error("Error decoding image file", "error", err, "path", sourcePath)
+		w.Header().Del("ETag")
+		http.Error(w, "Error retrieving image", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/jpeg")
 
-	if info, err = os.Stat(fullPath); err == nil {
-		modTime = info.ModTime()
+	if err = jpeg.Encode(w, cache.CropFace(img, face.X, face.Y, face.Width, face.Height, faceCropSize), &jpeg.Options{Quality: 85}); err != nil {
+		slog.Error("Error encoding face crop", "error", err, "id", id)
 	}
+}
 
-	http.ServeContent(w, r, fmt.Sprintf("%s%s", photo.FileName, photo.Ext), modTime, f)
+func (c LibraryController) serveFullImage(w http.ResponseWriter, r *http.Request, settings *models.Settings, photo *models.Photo, cacheControl string) {
+	fullPath := filepath.Join(photo.FullPath, photo.FileName+photo.Ext)
+	serveFile(w, r, fullPath, photo, photo.ETag(renditionOriginal), cacheControl)
 }
 
 func (c LibraryController) serveThumbnail(w http.ResponseWriter, r *http.Request, settings *models.Settings, photo *models.Photo) {
+	fullPath := c.photoCache.GetFullCachePath(settings, photo)
+	serveFile(w, r, fullPath, photo, photo.ETag(models.RenditionThumbnail), cacheControlFor(r, photo.ThumbnailVersion()))
+}
+
+/*
+cacheControlFor returns the Cache-Control header for a request. Only
+requests for the current version of a rendition may be cached forever.
+Unversioned or stale URLs must be revalidated.
+*/
+func cacheControlFor(r *http.Request, current string) string {
+	version := r.URL.Query().Get("v")
+
+	if version != "" && version == current {
+		return cacheControlImmutable
+	}
+
+	return cacheControlRevalidate
+}
+
+/*
+serveFile writes a file with caching headers. Conditional requests that
+match the ETag get a 304 without the file ever being opened.
+*/
+func serveFile(w http.ResponseWriter, r *http.Request, fullPath string, photo *models.Photo, etag, cacheControl string) {
 	var (
 		err  error
 		f    *os.File
 		info fs.FileInfo
 	)
 
-	fullPath := c.photoCache.GetFullCachePath(settings, photo)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", cacheControl)
+
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
 
 	if f, err = os.Open(fullPath); err != nil {
-		slog.Error("Error opening cached image file", "error", err, "path", fullPath)
-		http.Error(w, "Error retrieving cached image", http.StatusInternalServerError)
+		slog.Error("Error opening image file", "error", err, "path", fullPath)
+		w.Header().Del("ETag")
+		w.Header().Del("Cache-Control")
+		http.Error(w, "Error retrieving image", http.StatusInternalServerError)
 		return
 	}
 
 	defer f.Close()
 
-	modTime := time.Now()
+	/*
+	 * A zero modification time tells ServeContent not to send
+	 * Last-Modified, which is better than inventing one.
+	 */
+	modTime := time.Time{}
 
-	if info, err = os.Stat(fullPath); err == nil {
+	if info, err = f.Stat(); err == nil {
 		modTime = info.ModTime()
 	}
 
 	http.ServeContent(w, r, fmt.Sprintf("%s%s", photo.FileName, photo.Ext), modTime, f)
 }
+
+/*
+etagMatches reports if an If-None-Match header matches the given ETag.
+*/
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,572 @@
+package people
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/adampresley/adamgokit/httphelpers"
+	"github.com/adampresley/adamgokit/rendering"
+	"github.com/adampresley/ownmyphotos/cmd/ownmyphotos/internal/facedetection"
+	"github.com/adampresley/ownmyphotos/cmd/ownmyphotos/internal/viewmodels"
+	"github.com/adampresley/ownmyphotos/pkg/models"
+	"github.com/adampresley/ownmyphotos/pkg/services"
+)
+
+type PeopleHandlers interface {
+	PeoplePage(w http.ResponseWriter, r *http.Request)
+	PersonPage(w http.ResponseWriter, r *http.Request)
+	RenamePersonAction(w http.ResponseWriter, r *http.Request)
+	MergePeopleAction(w http.ResponseWriter, r *http.Request)
+	AddAliasAction(w http.ResponseWriter, r *http.Request)
+	RemoveAliasAction(w http.ResponseWriter, r *http.Request)
+	SetCoverAction(w http.ResponseWriter, r *http.Request)
+	ReviewFacesPage(w http.ResponseWriter, r *http.Request)
+	NameFacesAction(w http.ResponseWriter, r *http.Request)
+	IgnoreFacesAction(w http.ResponseWriter, r *http.Request)
+	DetectFacesAction(w http.ResponseWriter, r *http.Request)
+}
+
+const (
+	// reviewClusters is how many groups of faces the review queue shows at once
+	reviewClusters = 20
+
+	// reviewFacesPerCluster is how many faces of each group the review queue shows
+	reviewFacesPerCluster = 12
+)
+
+type PeopleControllerConfig struct {
+	FaceDetection   facedetection.FaceDetectionJob
+	FaceService     services.FaceServicer
+	PeopleService   services.PeopleServicer
+	PhotoService    services.PhotoServicer
+	Renderer        rendering.TemplateRenderer
+	SettingsService services.SettingsServicer
+}
+
+type PeopleController struct {
+	faceDetection   facedetection.FaceDetectionJob
+	faceService     services.FaceServicer
+	peopleService   services.PeopleServicer
+	photoService    services.PhotoServicer
+	renderer        rendering.TemplateRenderer
+	settingsService services.SettingsServicer
+}
+
+func NewPeopleController(config PeopleControllerConfig) PeopleController {
+	return PeopleController{
+		faceDetection:   config.FaceDetection,
+		faceService:     config.FaceService,
+		peopleService:   config.PeopleService,
+		photoService:    config.PhotoService,
+		renderer:        config.Renderer,
+		settingsService: config.SettingsService,
+	}
+}
+
+/*
+GET /people
+*/
+func (c PeopleController) PeoplePage(w http.ResponseWriter, r *http.Request) {
+	var (
+		err error
+	)
+
+	viewData := viewmodels.People{
+		BaseViewModel: viewmodels.BaseViewModel{
+			IsHtmx: httphelpers.IsHtmx(r),
+		},
+	}
+
+	if viewData.People, err = c.peopleService.All(); err != nil {
+		slog.Error("error getting people", "error", err)
+		viewData.Message = "There was an error retrieving the people in your photos."
+		viewData.IsError = true
+	}
+
+	if viewData.NumFacesToReview, err = c.faceService.CountFacesToReview(); err != nil {
+		slog.Error("error counting faces to review", "error", err)
+	}
+
+	c.renderer.Render("pages/people", viewData, w)
+}
+
+/*
+GET /people/{id}
+GET /people/{id}?year=2021
+*/
+func (c PeopleController) PersonPage(w http.ResponseWriter, r *http.Request) {
+	viewData := viewmodels.Person{
+		BaseViewModel: viewmodels.BaseViewModel{
+			IsHtmx: httphelpers.IsHtmx(r),
+		},
+		Year: httphelpers.GetFromRequest[string](r, "year"),
+	}
+
+	page := max(1, httphelpers.GetFromRequest[int](r, "page"))
+	c.renderPerson(httphelpers.GetFromRequest[int64](r, "id"), page, viewData, w)
+}
+
+/*
+POST /people/{id}/rename
+*/
+func (c PeopleController) RenamePersonAction(w http.ResponseWriter, r *http.Request) {
+	var (
+		err      error
+		personID int64
+		ids      []string
+	)
+
+	id := httphelpers.GetFromRequest[int64](r, "id")
+	name := httphelpers.GetFromRequest[string](r, "name")
+
+	viewData := viewmodels.Person{
+		BaseViewModel: viewmodels.BaseViewModel{
+			IsHtmx: httphelpers.IsHtmx(r),
+		},
+		WriteBack: httphelpers.GetFromRequest[bool](r, "writeBack"),
+	}
+
+	if personID, ids, err = c.peopleService.Rename(id, name); err != nil {
+		slog.Error("error renaming person", "error", err, "id", id, "name", name)
+		viewData.Message = "There was an error renaming this person."
+		viewData.IsError = true
+
+		if errors.Is(err, services.ErrPersonNameRequired) {
+			viewData.Message = "Please give this person a name."
+		}
+
+		c.renderPerson(id, 1, viewData, w)
+		return
+	}
+
+	viewData.Message = "Renamed. Photos with the old name will still find this person."
+
+	/*
+	 * Renaming someone to another person's name merges them, and their
+	 * page is gone.
+	 */
+	if personID != id {
+		viewData.Message = fmt.Sprintf("'%s' is already someone in your photos, so this person was merged with them.", name)
+		w.Header().Set("HX-Push-Url", viewmodels.PersonURL(uint(personID)))
+	}
+
+	c.writeBack(ids, &viewData.BaseViewModel, viewData.WriteBack)
+	c.renderPerson(personID, 1, viewData, w)
+}
+
+/*
+POST /people/merge
+
+Merges the selected people into the one chosen, then shows them.
+*/
+func (c PeopleController) MergePeopleAction(w http.ResponseWriter, r *http.Request) {
+	var (
+		err      error
+		ids      []int64
+		photoIDs []string
+	)
+
+	if err = r.ParseForm(); err != nil {
+		slog.Error("error parsing form when merging people", "error", err)
+	}
+
+	for _, value := range r.Form["id"] {
+		if id, err := strconv.ParseInt(value, 10, 64); err == nil {
+			ids = append(ids, id)
+		}
+	}
+
+	into := httphelpers.GetFromRequest[int64](r, "into")
+
+	viewData := viewmodels.Person{
+		BaseViewModel: viewmodels.BaseViewModel{
+			IsHtmx: httphelpers.IsHtmx(r),
+		},
+		WriteBack: httphelpers.GetFromRequest[bool](r, "writeBack"),
+	}
+
+	w.Header().Set("HX-Push-Url", viewmodels.PersonURL(uint(into)))
+
+	if len(ids) == 0 {
+		viewData.Message = "Please select the people to merge."
+		viewData.IsError = true
+
+		c.renderPerson(into, 1, viewData, w)
+		return
+	}
+
+	if photoIDs, err = c.peopleService.Merge(ids, into); err != nil {
+		slog.Error("error merging people", "error", err, "ids", ids, "into", into)
+		viewData.Message = "There was an error merging these people."
+		viewData.IsError = true
+
+		c.renderPerson(into, 1, viewData, w)
+		return
+	}
+
+	viewData.Message = "Merged. Photos with their names will find this person from now on."
+	c.writeBack(photoIDs, &viewData.BaseViewModel, viewData.WriteBack)
+	c.renderPerson(into, 1, viewData, w)
+}
+
+/*
+POST /people/{id}/aliases
+*/
+func (c PeopleController) AddAliasAction(w http.ResponseWriter, r *http.Request) {
+	var (
+		err error
+	)
+
+	id := httphelpers.GetFromRequest[int64](r, "id")
+	alias := httphelpers.GetFromRequest[string](r, "alias")
+
+	viewData := viewmodels.Person{
+		BaseViewModel: viewmodels.BaseViewModel{
+			IsHtmx: httphelpers.IsHtmx(r),
+		},
+	}
+
+	if err = c.peopleService.AddAlias(id, alias); err != nil {
+		slog.Error("error adding alias", "error", err, "id", id, "alias", alias)
+		viewData.Message = "There was an error adding the alias."
+		viewData.IsError = true
+
+		if errors.Is(err, services.ErrPersonNameRequired) {
+			viewData.Message = "Please enter the other name this person is known by."
+		}
+
+		if errors.Is(err, services.ErrAliasIsPerson) {
+			viewData.Message = fmt.Sprintf("Someone is already named '%s'. Merge them with this person instead.", alias)
+		}
+
+		c.renderPerson(id, 1, viewData, w)
+		return
+	}
+
+	viewData.Message = fmt.Sprintf("Photos with '%s' in them will find this person when they're next scanned.", alias)
+	c.renderPerson(id, 1, viewData, w)
+}
+
+/*
+POST /people/{id}/aliases/delete
+*/
+func (c PeopleController) RemoveAliasAction(w http.ResponseWriter, r *http.Request) {
+	var (
+		err error
+	)
+
+	id := httphelpers.GetFromRequest[int64](r, "id")
+	alias := httphelpers.GetFromRequest[string](r, "alias")
+
+	viewData := viewmodels.Person{
+		BaseViewModel: viewmodels.BaseViewModel{
+			IsHtmx: httphelpers.IsHtmx(r),
+		},
+	}
+
+	if err = c.peopleService.RemoveAlias(id, alias); err != nil {
+		slog.Error("error removing alias", "error", err, "id", id, "alias", alias)
+		viewData.Message = "There was an error removing the alias."
+		viewData.IsError = true
+	}
+
+	c.renderPerson(id, 1, viewData, w)
+}
+
+/*
+PUT /people/{id}/cover/{photoID}
+*/
+func (c PeopleController) SetCoverAction(w http.ResponseWriter, r *http.Request) {
+	var (
+		err error
+	)
+
+	id := httphelpers.GetFromRequest[int64](r, "id")
+	photoID := httphelpers.GetFromRequest[string](r, "photoID")
+
+	viewData := viewmodels.Person{
+		BaseViewModel: viewmodels.BaseViewModel{
+			IsHtmx: httphelpers.IsHtmx(r),
+		},
+		Year: httphelpers.GetFromRequest[string](r, "year"),
+	}
+
+	if err = c.peopleService.SetCover(id, photoID); err != nil {
+		slog.Error("error setting person cover", "error", err, "id", id, "photoID", photoID)
+		viewData.Message = "There was an error setting the cover photo."
+		viewData.IsError = true
+	}
+
+	c.renderPerson(id, 1, viewData, w)
+}
+
+/*
+GET /people/review
+*/
+func (c PeopleController) ReviewFacesPage(w http.ResponseWriter, r *http.Request) {
+	viewData := viewmodels.FaceReview{
+		BaseViewModel: viewmodels.BaseViewModel{
+			IsHtmx: httphelpers.IsHtmx(r),
+		},
+	}
+
+	c.renderReview(viewData, w)
+}
+
+/*
+POST /people/review/name
+
+Names the selected faces of a group, adding the person to their
+photos.
+*/
+func (c PeopleController) NameFacesAction(w http.ResponseWriter, r *http.Request) {
+	var (
+		err      error
+		photoIDs []string
+	)
+
+	ids := faceIDs(r)
+	name := httphelpers.GetFromRequest[string](r, "name")
+
+	viewData := viewmodels.FaceReview{
+		BaseViewModel: viewmodels.BaseViewModel{
+			IsHtmx: httphelpers.IsHtmx(r),
+		},
+		WriteBack: httphelpers.GetFromRequest[bool](r, "writeBack"),
+	}
+
+	if len(ids) == 0 {
+		viewData.Message = "Please select the faces to name."
+		viewData.IsError = true
+
+		c.renderReview(viewData, w)
+		return
+	}
+
+	if _, photoIDs, err = c.faceService.NameFaces(ids, name); err != nil {
+		slog.Error("error naming faces", "error", err, "ids", ids, "name", name)
+		viewData.Message = "There was an error naming these faces."
+		viewData.IsError = true
+
+		if errors.Is(err, services.ErrPersonNameRequired) {
+			viewData.Message = "Please enter who this is."
+		}
+
+		c.renderReview(viewData, w)
+		return
+	}
+
+	viewData.Message = fmt.Sprintf("Added %s to %d photos.", name, len(photoIDs))
+	c.writeBack(photoIDs, &viewData.BaseViewModel, viewData.WriteBack)
+	c.renderReview(viewData, w)
+}
+
+/*
+POST /people/review/ignore
+
+Takes the selected faces out of the review queue.
+*/
+func (c PeopleController) IgnoreFacesAction(w http.ResponseWriter, r *http.Request) {
+	var (
+		err error
+	)
+
+	ids := faceIDs(r)
+
+	viewData := viewmodels.FaceReview{
+		BaseViewModel: viewmodels.BaseViewModel{
+			IsHtmx: httphelpers.IsHtmx(r),
+		},
+	}
+
+	if err = c.faceService.IgnoreFaces(ids); err != nil {
+		slog.Error("error ignoring faces", "error", err, "ids", ids)
+		viewData.Message = "There was an error ignoring these faces."
+		viewData.IsError = true
+	}
+
+	c.renderReview(viewData, w)
+}
+
+/*
+POST /people/review/detect
+
+Starts looking for faces in photos that haven't been looked at yet.
+*/
+func (c PeopleController) DetectFacesAction(w http.ResponseWriter, r *http.Request) {
+	viewData := viewmodels.FaceReview{
+		BaseViewModel: viewmodels.BaseViewModel{
+			IsHtmx: httphelpers.IsHtmx(r),
+		},
+	}
+
+	viewData.Message = "Looking for faces in your photos. Come back in a while to see who was found."
+
+	if !c.faceDetection.RunInBackground() {
+		viewData.Message = "Already looking for faces in your photos."
+	}
+
+	c.renderReview(viewData, w)
+}
+
+/*
+faceIDs returns the IDs of the faces selected in a form.
+*/
+func faceIDs(r *http.Request) []int64 {
+	result := []int64{}
+
+	if err := r.ParseForm(); err != nil {
+		slog.Error("error parsing form of faces", "error", err)
+	}
+
+	for _, value := range r.Form["id"] {
+		if id, err := strconv.ParseInt(value, 10, 64); err == nil {
+			result = append(result, id)
+		}
+	}
+
+	return result
+}
+
+func (c PeopleController) renderReview(viewData viewmodels.FaceReview, w http.ResponseWriter) {
+	var (
+		err error
+	)
+
+	pageName := "pages/review-faces"
+	viewData.IsDetecting = c.faceDetection.IsRunning()
+
+	if viewData.Clusters, err = c.faceService.GetClusters(reviewClusters, reviewFacesPerCluster); err != nil {
+		slog.Error("error getting faces to review", "error", err)
+		viewData.Message = "There was an error retrieving the faces to review."
+		viewData.IsError = true
+
+		c.renderer.Render(pageName, viewData, w)
+		return
+	}
+
+	if viewData.NumFaces, err = c.faceService.CountFacesToReview(); err != nil {
+		slog.Error("error counting faces to review", "error", err)
+	}
+
+	if viewData.People, err = c.peopleService.All(); err != nil {
+		slog.Error("error getting people", "error", err)
+	}
+
+	c.renderer.Render(pageName, viewData, w)
+}
+
+/*
+writeBack writes the people of photos changed in the database back to
+their files, when asked to. Otherwise the change stays in the database,
+and the old names in the files are matched through aliases.
+*/
+func (c PeopleController) writeBack(ids []string, viewData *viewmodels.BaseViewModel, writeBack bool) {
+	var (
+		err      error
+		settings *models.Settings
+		written  int
+	)
+
+	if !writeBack || len(ids) == 0 {
+		return
+	}
+
+	if settings, err = c.settingsService.Read(); err != nil {
+		slog.Error("error reading settings", "error", err)
+		viewData.Message += " Error reading settings, so the change wasn't written to your files."
+		viewData.IsWarning = true
+		return
+	}
+
+	if written, err = c.photoService.RewriteMetadata(ids, settings.MetadataWriteTarget); err != nil {
+		slog.Error("error writing people back to photos", "error", err)
+		viewData.Message += fmt.Sprintf(" Wrote the change to %d of %d files. The rest couldn't be written.", written, len(ids))
+		viewData.IsWarning = true
+		return
+	}
+
+	viewData.Message += fmt.Sprintf(" Wrote the change to %d files.", written)
+}
+
+func (c PeopleController) renderPerson(id int64, page int, viewData viewmodels.Person, w http.ResponseWriter) {
+	var (
+		err      error
+		settings *models.Settings
+		photos   []*models.Photo
+	)
+
+	pageName := "pages/person"
+	viewData.JavascriptIncludes = []rendering.JavascriptInclude{
+		{Src: "/static/js/fslightbox.js", Type: "text/javascript"},
+		{Src: "/static/js/pages/home.js", Type: "module"},
+	}
+	viewData.Person = &models.Person{}
+	viewData.Images = []viewmodels.ImageModel{}
+
+	/*
+	 * Pages after the first are requested by infinite scroll, and
+	 * only need the next set of photos.
+	 */
+	if page > 1 && viewData.IsHtmx {
+		pageName = "pages/fragments/person-photos"
+	}
+
+	if settings, err = c.settingsService.Read(); err != nil {
+		slog.Error("error reading settings", "error", err)
+		viewData.Message = "Error reading settings"
+		viewData.IsError = true
+
+		c.renderer.Render(pageName, viewData, w)
+		return
+	}
+
+	if viewData.Person, err = c.peopleService.Get(id); err != nil || viewData.Person.ID == 0 {
+		slog.Error("error retrieving person", "error", err, "id", id)
+		viewData.Person = &models.Person{}
+		viewData.Message = "This person could not be found."
+		viewData.IsError = true
+
+		c.renderer.Render(pageName, viewData, w)
+		return
+	}
+
+	if page == 1 {
+		if viewData.Years, err = c.peopleService.GetYears(id); err != nil {
+			slog.Error("error getting years of photos of person", "error", err, "id", id)
+		}
+
+		if viewData.People, err = c.peopleService.All(); err != nil {
+			slog.Error("error getting people", "error", err)
+		}
+	}
+
+	/*
+	 * A year shows only the photos taken that year. Anything else
+	 * shows every photo.
+	 */
+	start := models.DateBucket{Period: viewData.Year}.Start()
+	end := time.Time{}
+
+	if start.IsZero() || len(viewData.Year) != 4 {
+		start, viewData.Year = time.Time{}, ""
+	} else {
+		end = start.AddDate(1, 0, 0)
+	}
+
+	if photos, viewData.Paging, err = c.photoService.GetPhotosOfPerson(id, start, end, page); err != nil {
+		slog.Error("error getting photos of person", "error", err, "id", id)
+		viewData.Message = "There was an error retrieving the photos of this person."
+		viewData.IsError = true
+
+		c.renderer.Render(pageName, viewData, w)
+		return
+	}
+
+	viewData.Images = viewmodels.NewImageModelCollectionFromPhotos(photos, []*models.Folder{}, settings.LibraryPath)
+	c.renderer.Render(pageName, viewData, w)
+}
@@ -0,0 +1,533 @@
+package photos
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/adampresley/adamgokit/httphelpers"
+	"github.com/adampresley/adamgokit/rendering"
+	"github.com/adampresley/ownmyphotos/cmd/ownmyphotos/internal/viewmodels"
+	"github.com/adampresley/ownmyphotos/pkg/metadata"
+	"github.com/adampresley/ownmyphotos/pkg/models"
+	"github.com/adampresley/ownmyphotos/pkg/query"
+	"github.com/adampresley/ownmyphotos/pkg/services"
+)
+
+type PhotosHandlers interface {
+	PhotoPage(w http.ResponseWriter, r *http.Request)
+	EditMetadataAction(w http.ResponseWriter, r *http.Request)
+	BatchEditAction(w http.ResponseWriter, r *http.Request)
+	BatchAlbumAction(w http.ResponseWriter, r *http.Request)
+	BatchFavoriteAction(w http.ResponseWriter, r *http.Request)
+	BatchDownloadAction(w http.ResponseWriter, r *http.Request)
+	BatchTrashAction(w http.ResponseWriter, r *http.Request)
+}
+
+type PhotosControllerConfig struct {
+	AlbumService    services.AlbumServicer
+	PhotoCache      services.PhotoCacher
+	PhotoService    services.PhotoServicer
+	PlaceService    services.PlaceServicer
+	Renderer        rendering.TemplateRenderer
+	SettingsService services.SettingsServicer
+}
+
+type PhotosController struct {
+	albumService    services.AlbumServicer
+	photoCache      services.PhotoCacher
+	photoService    services.PhotoServicer
+	placeService    services.PlaceServicer
+	renderer        rendering.TemplateRenderer
+	settingsService services.SettingsServicer
+}
+
+func NewPhotosController(config PhotosControllerConfig) PhotosController {
+	return PhotosController{
+		albumService:    config.AlbumService,
+		photoCache:      config.PhotoCache,
+		photoService:    config.PhotoService,
+		placeService:    config.PlaceService,
+		renderer:        config.Renderer,
+		settingsService: config.SettingsService,
+	}
+}
+
+/*
+GET /photos/{id}
+GET /photos/{id}?term=beach&keyword=summer&person=Sam&match=any
+
+Without search parameters, previous and next move through the photo's
+folder. With them, they move through the search results.
+*/
+func (c PhotosController) PhotoPage(w http.ResponseWriter, r *http.Request) {
+	id := httphelpers.GetFromRequest[string](r, "id")
+	c.renderPhotoPage(w, r, id, "", false)
+}
+
+/*
+POST /photos/{id}/metadata
+
+Saves the title, caption, keywords, and people from the photo page's
+edit form, then shows the photo page again. Search parameters in the
+URL are kept, as they are for the photo page.
+*/
+func (c PhotosController) EditMetadataAction(w http.ResponseWriter, r *http.Request) {
+	var (
+		err      error
+		settings *models.Settings
+		photo    *models.Photo
+	)
+
+	id := httphelpers.GetFromRequest[string](r, "id")
+
+	edit := models.PhotoEdit{
+		Title:    httphelpers.GetFromRequest[string](r, "title"),
+		Caption:  httphelpers.GetFromRequest[string](r, "caption"),
+		Keywords: models.SplitNames(httphelpers.GetFromRequest[string](r, "keywords")),
+		People:   models.SplitNames(httphelpers.GetFromRequest[string](r, "people")),
+	}
+
+	if settings, err = c.settingsService.Read(); err != nil {
+		slog.Error("error reading settings", "error", err)
+		c.renderPhotoPage(w, r, id, "Error reading settings", true)
+		return
+	}
+
+	if photo, err = c.photoService.UpdateMetadata(id, edit, settings.MetadataWriteTarget); err != nil {
+		slog.Error("error updating photo metadata", "error", err, "id", id)
+		c.renderPhotoPage(w, r, id, "Your changes could not be saved. Please review logs for more details.", true)
+		return
+	}
+
+	/*
+	 * Writing to the file can give the photo a new ID, so the address
+	 * bar is pointed at it.
+	 */
+	photoURL := "/photos/" + photo.ID
+
+	if r.URL.RawQuery != "" {
+		photoURL += "?" + r.URL.RawQuery
+	}
+
+	w.Header().Set("HX-Push-Url", photoURL)
+	c.renderPhotoPage(w, r, photo.ID, "Your changes were saved.", false)
+}
+
+/*
+POST /photos/batch/edit
+
+Applies the selection toolbar's edit form to the selected photos. When
+every photo is updated, the page the toolbar was on is loaded again,
+as photos can get new IDs when their files are rewritten.
+*/
+func (c PhotosController) BatchEditAction(w http.ResponseWriter, r *http.Request) {
+	var (
+		err      error
+		settings *models.Settings
+		updated  int
+	)
+
+	ids := httphelpers.GetFromRequest[[]string](r, "id")
+
+	edit := models.BatchPhotoEdit{
+		Title:          httphelpers.GetFromRequest[string](r, "title"),
+		Caption:        httphelpers.GetFromRequest[string](r, "caption"),
+		AddKeywords:    models.SplitNames(httphelpers.GetFromRequest[string](r, "addKeywords")),
+		RemoveKeywords: models.SplitNames(httphelpers.GetFromRequest[string](r, "removeKeywords")),
+		AddPeople:      models.SplitNames(httphelpers.GetFromRequest[string](r, "addPeople")),
+		RemovePeople:   models.SplitNames(httphelpers.GetFromRequest[string](r, "removePeople")),
+	}
+
+	if len(ids) == 0 {
+		c.renderBatchResult(w, r, "Select the photos to edit first.", false, true)
+		return
+	}
+
+	if edit.IsEmpty() {
+		c.renderBatchResult(w, r, "Enter the changes to make to the selected photos.", false, true)
+		return
+	}
+
+	if settings, err = c.settingsService.Read(); err != nil {
+		slog.Error("error reading settings", "error", err)
+		c.renderBatchResult(w, r, "Error reading settings", true, false)
+		return
+	}
+
+	if updated, err = c.photoService.BatchUpdateMetadata(ids, edit, settings.MetadataWriteTarget); err != nil {
+		slog.Error("error updating the metadata of photos", "error", err, "updated", updated, "selected", len(ids))
+		c.renderBatchResult(w, r, fmt.Sprintf("%d of %d photos were updated. The rest could not be, please review logs for more details.", updated, len(ids)), true, false)
+		return
+	}
+
+	c.reloadSelectionPage(w, r)
+	c.renderBatchResult(w, r, fmt.Sprintf("%d photos were updated.", updated), false, false)
+}
+
+/*
+POST /photos/batch/album
+
+Adds the selected photos to an album. A new album is created when
+a name is given instead of an existing album.
+*/
+func (c PhotosController) BatchAlbumAction(w http.ResponseWriter, r *http.Request) {
+	var (
+		err   error
+		album *models.Album
+		added int
+	)
+
+	ids := httphelpers.GetFromRequest[[]string](r, "id")
+	albumID := httphelpers.GetFromRequest[int64](r, "album")
+	albumName := strings.TrimSpace(httphelpers.GetFromRequest[string](r, "albumName"))
+
+	if len(ids) == 0 {
+		c.renderBatchResult(w, r, "Select the photos to add to an album first.", false, true)
+		return
+	}
+
+	if albumID == 0 && albumName == "" {
+		c.renderBatchResult(w, r, "Choose an album, or enter a name for a new one.", false, true)
+		return
+	}
+
+	if albumName != "" {
+		album, err = c.albumService.Create(albumName)
+	} else {
+		album, err = c.albumService.Get(albumID)
+	}
+
+	if err != nil || album.ID == 0 {
+		slog.Error("error getting the album to add photos to", "error", err, "album", albumID, "name", albumName)
+		c.renderBatchResult(w, r, "The album could not be found or created.", true, false)
+		return
+	}
+
+	if added, err = c.albumService.AddPhotos(album.ID, ids); err != nil {
+		if errors.Is(err, services.ErrSmartAlbum) {
+			c.renderBatchResult(w, r, "Smart albums show the photos matching their search, so photos can't be added to them.", false, true)
+			return
+		}
+
+		slog.Error("error adding photos to album", "error", err, "album", album.ID, "selected", len(ids))
+		c.renderBatchResult(w, r, "There was an error adding the photos to the album.", true, false)
+		return
+	}
+
+	w.Header().Set("HX-Trigger", "albumsChanged")
+	c.renderBatchResult(w, r, fmt.Sprintf("%d photos were added to %s.", added, album.Name), false, false)
+}
+
+/*
+POST /photos/batch/favorite?favorite=true
+
+Favorites, or un-favorites, the selected photos.
+*/
+func (c PhotosController) BatchFavoriteAction(w http.ResponseWriter, r *http.Request) {
+	var (
+		err     error
+		changed int
+	)
+
+	ids := httphelpers.GetFromRequest[[]string](r, "id")
+	favorite := httphelpers.GetFromRequest[bool](r, "favorite")
+
+	if len(ids) == 0 {
+		c.renderBatchResult(w, r, "Select the photos to change first.", false, true)
+		return
+	}
+
+	if changed, err = c.photoService.SetFavorites(ids, favorite); err != nil {
+		slog.Error("error changing favorites", "error", err, "favorite", favorite, "selected", len(ids))
+		c.renderBatchResult(w, r, "There was an error changing the favorites. No photos were changed.", true, false)
+		return
+	}
+
+	c.reloadSelectionPage(w, r)
+	c.renderBatchResult(w, r, fmt.Sprintf("%d photos were changed.", changed), false, false)
+}
+
+/*
+POST /photos/batch/download
+
+Downloads the selected photos as a zip file. This is a regular form
+post, rather than an htmx request, so the browser saves the file.
+*/
+func (c PhotosController) BatchDownloadAction(w http.ResponseWriter, r *http.Request) {
+	var (
+		err      error
+		settings *models.Settings
+		photos   []*models.Photo
+	)
+
+	ids := httphelpers.GetFromRequest[[]string](r, "id")
+
+	if len(ids) == 0 {
+		http.Error(w, "Select the photos to download first.", http.StatusBadRequest)
+		return
+	}
+
+	if settings, err = c.settingsService.Read(); err != nil {
+		slog.Error("error reading settings", "error", err)
+		http.Error(w, "Error reading settings", http.StatusInternalServerError)
+		return
+	}
+
+	if photos, err = c.photoService.GetPhotosByIDs(ids); err != nil || len(photos) == 0 {
+		slog.Error("error getting photos to download", "error", err, "selected", len(ids))
+		http.Error(w, "The selected photos could not be found.", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="photos.zip"`)
+
+	/*
+	 * The response has started by now, so an error can only cut the
+	 * download short.
+	 */
+	if err = c.photoService.WriteArchive(w, settings.LibraryPath, photos); err != nil {
+		slog.Error("error writing photo archive", "error", err, "selected", len(ids))
+	}
+}
+
+/*
+POST /photos/batch/trash
+
+Moves the selected photos to the trash folder in the library, then
+loads the page again without them.
+*/
+func (c PhotosController) BatchTrashAction(w http.ResponseWriter, r *http.Request) {
+	var (
+		err      error
+		settings *models.Settings
+		photos   []*models.Photo
+	)
+
+	ids := httphelpers.GetFromRequest[[]string](r, "id")
+
+	if len(ids) == 0 {
+		c.renderBatchResult(w, r, "Select the photos to move to the trash first.", false, true)
+		return
+	}
+
+	if settings, err = c.settingsService.Read(); err != nil {
+		slog.Error("error reading settings", "error", err)
+		c.renderBatchResult(w, r, "Error reading settings", true, false)
+		return
+	}
+
+	if photos, err = c.photoService.MoveToTrash(settings.LibraryPath, ids); err != nil {
+		slog.Error("error moving photos to the trash", "error", err, "selected", len(ids))
+		c.renderBatchResult(w, r, "There was an error moving the photos to the trash. No photos were moved.", true, false)
+		return
+	}
+
+	for _, photo := range photos {
+		if err = c.photoCache.Remove(settings, photo); err != nil {
+			slog.Error("error removing thumbnail of trashed photo", "error", err, "id", photo.ID)
+		}
+	}
+
+	c.reloadSelectionPage(w, r)
+	c.renderBatchResult(w, r, fmt.Sprintf("%d photos were moved to the trash.", len(photos)), false, false)
+}
+
+/*
+renderBatchResult shows the outcome of acting on selected photos in
+the selection toolbar.
+*/
+func (c PhotosController) renderBatchResult(w http.ResponseWriter, r *http.Request, message string, isError, isWarning bool) {
+	viewData := viewmodels.BatchResult{
+		BaseViewModel: viewmodels.BaseViewModel{
+			IsHtmx:    httphelpers.IsHtmx(r),
+			Message:   message,
+			IsError:   isError,
+			IsWarning: isWarning,
+		},
+	}
+
+	c.renderer.Render("pages/fragments/selection-result", viewData, w)
+}
+
+/*
+reloadSelectionPage has htmx load the page the selection toolbar was
+on again, once the selected photos have changed. Only links within
+this app are followed.
+*/
+func (c PhotosController) reloadSelectionPage(w http.ResponseWriter, r *http.Request) {
+	returnURL := httphelpers.GetFromRequest[string](r, "returnURL")
+
+	if !strings.HasPrefix(returnURL, "/") || strings.HasPrefix(returnURL, "//") {
+		return
+	}
+
+	location, _ := json.Marshal(map[string]string{"path": returnURL, "target": "#mainContent"})
+	w.Header().Set("HX-Location", string(location))
+}
+
+/*
+renderPhotoPage renders the photo page for a photo, with a message
+shown above it if there is one.
+*/
+func (c PhotosController) renderPhotoPage(w http.ResponseWriter, r *http.Request, id, message string, isError bool) {
+	var (
+		err       error
+		settings  *models.Settings
+		photo     *models.Photo
+		placePath models.PlacePath
+		info      os.FileInfo
+		f         *os.File
+	)
+
+	pageName := "pages/photo"
+
+	viewData := viewmodels.PhotoPage{
+		PhotoDetails: viewmodels.PhotoDetails{
+			BaseViewModel: viewmodels.BaseViewModel{
+				Message: message,
+				IsError: isError,
+				IsHtmx:  httphelpers.IsHtmx(r),
+				JavascriptIncludes: []rendering.JavascriptInclude{
+					{Src: "/static/js/fslightbox.js", Type: "text/javascript"},
+					{Src: "/static/js/pages/home.js", Type: "module"},
+				},
+			},
+			Places: []*models.Place{},
+		},
+		Albums:  []*models.Album{},
+		Faces:   []*models.Face{},
+		Context: url.Values{},
+	}
+
+	/*
+	 * Reading the term first parses the form, which the keyword and
+	 * person lists are read from.
+	 */
+	criteria := models.PhotoSearch{
+		SearchTerm: httphelpers.GetFromRequest[string](r, "term"),
+		Match:      models.NewSearchMatch(httphelpers.GetFromRequest[string](r, "match")),
+		Keywords:   httphelpers.GetFromRequest[[]string](r, "keyword"),
+		People:     httphelpers.GetFromRequest[[]string](r, "person"),
+	}
+
+	if settings, err = c.settingsService.Read(); err != nil {
+		slog.Error("error reading settings", "error", err)
+		viewData.Message = "Error reading settings"
+		viewData.IsError = true
+
+		c.renderer.Render(pageName, viewData, w)
+		return
+	}
+
+	if photo, err = c.photoService.GetPhotoByID(id); err != nil || photo.ID == "" {
+		slog.Error("error retrieving photo", "error", err, "id", id)
+		viewData.Message = "This photo could not be found."
+		viewData.IsError = true
+
+		c.renderer.Render(pageName, viewData, w)
+		return
+	}
+
+	viewData.Photo = photo
+	viewData.AlbumPath = photo.GetAlbumPath(settings.LibraryPath)
+	viewData.TileURL = settings.MapTileURL
+	viewData.TileAttribution = settings.MapTileAttribution
+	viewData.MetadataWriteTarget = settings.MetadataWriteTarget
+
+	/*
+	 * Previous and next follow the search the photo was opened from,
+	 * falling back to its folder.
+	 */
+	if criteria.SearchTerm != "" || len(criteria.Keywords) > 0 || len(criteria.People) > 0 {
+		viewData.Context = searchContext(criteria)
+		viewData.ContextName = "search results"
+		viewData.Adjacent, err = c.photoService.GetAdjacentSearchResults(photo.ID, criteria)
+	} else {
+		viewData.ContextName = "folder"
+		viewData.Adjacent, err = c.photoService.GetAdjacentPhotosInFolder(photo.ID, photo.FullPath)
+	}
+
+	if err != nil {
+		var queryError *query.Error
+
+		if !errors.As(err, &queryError) {
+			slog.Error("error finding adjacent photos", "error", err, "id", photo.ID)
+		}
+	}
+
+	if photo.PlaceID != nil {
+		if placePath, err = c.placeService.GetCityPlacePath(*photo.PlaceID); err == nil {
+			viewData.Places, err = c.placeService.GetPlaceHierarchy(placePath)
+		}
+
+		if err != nil {
+			slog.Error("error getting the place of a photo", "error", err, "id", photo.ID)
+		}
+	}
+
+	if viewData.Albums, err = c.albumService.GetAlbumsForPhoto(photo.ID); err != nil {
+		slog.Error("error getting the albums a photo is in", "error", err, "id", photo.ID)
+	}
+
+	if viewData.Faces, err = c.photoService.GetFaces(photo.ID); err != nil {
+		slog.Error("error getting the faces in a photo", "error", err, "id", photo.ID)
+	}
+
+	/*
+	 * The file is read for its size and raw metadata. If it can't be,
+	 * the page still shows what the database knows.
+	 */
+	if f, err = os.Open(photo.GetFullPath()); err != nil {
+		slog.Error("error opening photo", "error", err, "path", photo.GetFullPath())
+		viewData.Message = "The photo's file could not be read, so only the details from your library are shown."
+		viewData.IsWarning = true
+
+		c.renderer.Render(pageName, viewData, w)
+		return
+	}
+
+	defer f.Close()
+
+	if info, err = f.Stat(); err == nil {
+		viewData.FileSize = info.Size()
+	}
+
+	if viewData.RawMetadata, err = metadata.ReadRaw(f); err != nil {
+		slog.Error("error reading raw metadata", "error", err, "path", photo.GetFullPath())
+	}
+
+	c.renderer.Render(pageName, viewData, w)
+}
+
+/*
+searchContext returns the query string parameters that carry a search
+from photo to photo.
+*/
+func searchContext(criteria models.PhotoSearch) url.Values {
+	result := url.Values{}
+
+	if criteria.SearchTerm != "" {
+		result.Set("term", criteria.SearchTerm)
+	}
+
+	for _, keyword := range criteria.Keywords {
+		result.Add("keyword", keyword)
+	}
+
+	for _, person := range criteria.People {
+		result.Add("person", person)
+	}
+
+	if criteria.Match == models.MatchAny {
+		result.Set("match", string(criteria.Match))
+	}
+
+	return result
+}
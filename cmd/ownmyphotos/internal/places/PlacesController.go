@@ -0,0 +1,132 @@
+package places
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/adampresley/adamgokit/httphelpers"
+	"github.com/adampresley/adamgokit/rendering"
+	"github.com/adampresley/ownmyphotos/cmd/ownmyphotos/internal/viewmodels"
+	"github.com/adampresley/ownmyphotos/pkg/models"
+	"github.com/adampresley/ownmyphotos/pkg/services"
+)
+
+type PlacesHandlers interface {
+	PlacesPage(w http.ResponseWriter, r *http.Request)
+}
+
+type PlacesControllerConfig struct {
+	PhotoService    services.PhotoServicer
+	PlaceService    services.PlaceServicer
+	Renderer        rendering.TemplateRenderer
+	SettingsService services.SettingsServicer
+}
+
+type PlacesController struct {
+	photoService    services.PhotoServicer
+	placeService    services.PlaceServicer
+	renderer        rendering.TemplateRenderer
+	settingsService services.SettingsServicer
+}
+
+func NewPlacesController(config PlacesControllerConfig) PlacesController {
+	return PlacesController{
+		photoService:    config.PhotoService,
+		placeService:    config.PlaceService,
+		renderer:        config.Renderer,
+		settingsService: config.SettingsService,
+	}
+}
+
+/*
+GET /places?place=IT/07/3169070
+*/
+func (c PlacesController) PlacesPage(w http.ResponseWriter, r *http.Request) {
+	var (
+		err      error
+		settings *models.Settings
+		photos   []*models.Photo
+	)
+
+	pageName := "pages/places"
+
+	viewData := viewmodels.Places{
+		BaseViewModel: viewmodels.BaseViewModel{
+			IsHtmx: httphelpers.IsHtmx(r),
+			JavascriptIncludes: []rendering.JavascriptInclude{
+				{Src: "/static/js/fslightbox.js", Type: "text/javascript"},
+				{Src: "/static/js/pages/home.js", Type: "module"},
+			},
+		},
+		Place:       models.ParsePlacePath(httphelpers.GetFromRequest[string](r, "place")),
+		Breadcrumbs: []*models.Place{},
+		Places:      []*models.Place{},
+		Images:      []viewmodels.ImageModel{},
+	}
+
+	/*
+	 * Pages after the first are requested by infinite scroll, and
+	 * only need the next set of photos.
+	 */
+	page := max(1, httphelpers.GetFromRequest[int](r, "page"))
+
+	if page > 1 && viewData.IsHtmx {
+		pageName = "pages/fragments/places-photos"
+	}
+
+	if settings, err = c.settingsService.Read(); err != nil {
+		slog.Error("error reading settings", "error", err)
+		viewData.Message = "Error reading settings"
+		viewData.IsError = true
+
+		c.renderer.Render(pageName, viewData, w)
+		return
+	}
+
+	if !viewData.Place.IsEmpty() {
+		if photos, viewData.Paging, err = c.photoService.GetPhotosInPlace(viewData.Place, page); err != nil {
+			slog.Error("error getting photos for place", "error", err, "place", viewData.Place.String())
+			viewData.Message = "There was an error retrieving photos for this place."
+			viewData.IsError = true
+
+			c.renderer.Render(pageName, viewData, w)
+			return
+		}
+
+		viewData.Images = viewmodels.NewImageModelCollectionFromPhotos(photos, []*models.Folder{}, settings.LibraryPath)
+	}
+
+	if page > 1 && viewData.IsHtmx {
+		c.renderer.Render(pageName, viewData, w)
+		return
+	}
+
+	if viewData.HasGazetteer, err = c.placeService.HasGazetteer(); err != nil {
+		slog.Error("error checking for a gazetteer", "error", err)
+		viewData.Message = "There was an error reading places."
+		viewData.IsError = true
+
+		c.renderer.Render(pageName, viewData, w)
+		return
+	}
+
+	if viewData.Breadcrumbs, err = c.placeService.GetPlaceHierarchy(viewData.Place); err != nil {
+		slog.Error("error getting place hierarchy", "error", err, "place", viewData.Place.String())
+		viewData.Message = "There was an error reading places."
+		viewData.IsError = true
+
+		c.renderer.Render(pageName, viewData, w)
+		return
+	}
+
+	if viewData.Places, err = c.photoService.GetPlaces(viewData.Place); err != nil {
+		slog.Error("error getting places", "error", err, "place", viewData.Place.String())
+		viewData.Message = "There was an error reading places."
+		viewData.IsError = true
+
+		c.renderer.Render(pageName, viewData, w)
+		return
+	}
+
+	c.renderer.Render(pageName, viewData, w)
+}
@@ -3,6 +3,7 @@ package settings
 import (
 	"log/slog"
 	"net/http"
+	"strings"
 
 	"github.com/adampresley/adamgokit/httphelpers"
 	"github.com/adampresley/adamgokit/rendering"
@@ -85,10 +86,17 @@ func (c SettingsController) SettingsAction(w http.ResponseWriter, r *http.Reques
 	}
 
 	settings = models.Settings{
-		CollectorSchedule: httphelpers.GetFromRequest[string](r, "collectorSchedule"),
-		MaxWorkers:        httphelpers.GetFromRequest[int](r, "maxWorkers"),
-		LibraryPath:       httphelpers.GetFromRequest[string](r, "libraryPath"),
-		ThumbnailSize:     httphelpers.GetFromRequest[int](r, "thumbnailSize"),
+		CollectorSchedule:     httphelpers.GetFromRequest[string](r, "collectorSchedule"),
+		MaxWorkers:            httphelpers.GetFromRequest[int](r, "maxWorkers"),
+		LibraryPath:           httphelpers.GetFromRequest[string](r, "libraryPath"),
+		ThumbnailSize:         httphelpers.GetFromRequest[int](r, "thumbnailSize"),
+		DigestSchedule:        strings.TrimSpace(httphelpers.GetFromRequest[string](r, "digestSchedule")),
+		DigestOutputPath:      strings.TrimSpace(httphelpers.GetFromRequest[string](r, "digestOutputPath")),
+		DigestEmailTo:         strings.TrimSpace(httphelpers.GetFromRequest[string](r, "digestEmailTo")),
+		MapTileURL:            strings.TrimSpace(httphelpers.GetFromRequest[string](r, "mapTileURL")),
+		MapTileAttribution:    strings.TrimSpace(httphelpers.GetFromRequest[string](r, "mapTileAttribution")),
+		MetadataWriteTarget:   models.NewMetadataWriteTarget(httphelpers.GetFromRequest[string](r, "metadataWriteTarget")),
+		FaceDetectionSchedule: strings.TrimSpace(httphelpers.GetFromRequest[string](r, "faceDetectionSchedule")),
 	}
 
 	// Save the settings
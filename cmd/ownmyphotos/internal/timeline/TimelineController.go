@@ -0,0 +1,219 @@
+package timeline
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/adampresley/adamgokit/httphelpers"
+	"github.com/adampresley/adamgokit/rendering"
+	"github.com/adampresley/ownmyphotos/cmd/ownmyphotos/internal/viewmodels"
+	"github.com/adampresley/ownmyphotos/pkg/models"
+	"github.com/adampresley/ownmyphotos/pkg/services"
+)
+
+type TimelineHandlers interface {
+	MemoriesPage(w http.ResponseWriter, r *http.Request)
+	TimelinePage(w http.ResponseWriter, r *http.Request)
+}
+
+type TimelineControllerConfig struct {
+	PhotoService    services.PhotoServicer
+	Renderer        rendering.TemplateRenderer
+	SettingsService services.SettingsServicer
+}
+
+type TimelineController struct {
+	photoService    services.PhotoServicer
+	renderer        rendering.TemplateRenderer
+	settingsService services.SettingsServicer
+}
+
+func NewTimelineController(config TimelineControllerConfig) TimelineController {
+	return TimelineController{
+		photoService:    config.PhotoService,
+		renderer:        config.Renderer,
+		settingsService: config.SettingsService,
+	}
+}
+
+/*
+GET /timeline?date=2019-07
+*/
+func (c TimelineController) TimelinePage(w http.ResponseWriter, r *http.Request) {
+	var (
+		err      error
+		settings *models.Settings
+		photos   []*models.Photo
+	)
+
+	pageName := "pages/timeline"
+
+	viewData := viewmodels.Timeline{
+		BaseViewModel: viewmodels.BaseViewModel{
+			IsHtmx: httphelpers.IsHtmx(r),
+			JavascriptIncludes: []rendering.JavascriptInclude{
+				{Src: "/static/js/fslightbox.js", Type: "text/javascript"},
+				{Src: "/static/js/pages/home.js", Type: "module"},
+			},
+		},
+		Period:  models.DateBucket{Period: strings.TrimSpace(httphelpers.GetFromRequest[string](r, "date"))},
+		Buckets: []*models.DateBucket{},
+		Years:   []*models.DateBucket{},
+		Images:  []viewmodels.ImageModel{},
+	}
+
+	/*
+	 * Pages after the first are requested by infinite scroll, and
+	 * only need the next set of photos.
+	 */
+	page := max(1, httphelpers.GetFromRequest[int](r, "page"))
+
+	if page > 1 && viewData.IsHtmx {
+		pageName = "pages/fragments/timeline-photos"
+	}
+
+	start, end, grouping, ok := periodRange(viewData.Period)
+
+	if !ok {
+		viewData.Message = "'" + viewData.Period.Period + "' is not a valid date. Use YYYY, YYYY-MM, or YYYY-MM-DD."
+		viewData.IsError = true
+		viewData.Period = models.DateBucket{}
+		start, end, grouping = time.Time{}, time.Time{}, models.GroupByYear
+	}
+
+	if settings, err = c.settingsService.Read(); err != nil {
+		slog.Error("error reading settings", "error", err)
+		viewData.Message = "Error reading settings"
+		viewData.IsError = true
+
+		c.renderer.Render(pageName, viewData, w)
+		return
+	}
+
+	/*
+	 * Photos are listed once a year, month, or day is picked. The
+	 * whole library is too much to scroll through.
+	 */
+	if viewData.Period.Period != "" {
+		if photos, viewData.Paging, err = c.photoService.GetPhotosInDateRange(start, end, page); err != nil {
+			slog.Error("error getting photos for timeline", "error", err, "date", viewData.Period.Period)
+			viewData.Message = "There was an error retrieving photos for " + viewData.Period.Period + "."
+			viewData.IsError = true
+
+			c.renderer.Render(pageName, viewData, w)
+			return
+		}
+
+		viewData.Images = viewmodels.NewImageModelCollectionFromPhotos(photos, []*models.Folder{}, settings.LibraryPath)
+	}
+
+	if page > 1 && viewData.IsHtmx {
+		c.renderer.Render(pageName, viewData, w)
+		return
+	}
+
+	if grouping != "" {
+		if viewData.Buckets, err = c.photoService.GetDateBuckets(grouping, start, end); err != nil {
+			slog.Error("error getting timeline buckets", "error", err, "date", viewData.Period.Period)
+			viewData.Message = "There was an error building the timeline."
+			viewData.IsError = true
+
+			c.renderer.Render(pageName, viewData, w)
+			return
+		}
+	}
+
+	if viewData.Years, err = c.photoService.GetDateBuckets(models.GroupByYear, time.Time{}, time.Time{}); err != nil {
+		slog.Error("error getting timeline years", "error", err)
+		viewData.Message = "There was an error building the timeline."
+		viewData.IsError = true
+
+		c.renderer.Render(pageName, viewData, w)
+		return
+	}
+
+	c.renderer.Render(pageName, viewData, w)
+}
+
+/*
+GET /memories?date=2025-07-14
+*/
+func (c TimelineController) MemoriesPage(w http.ResponseWriter, r *http.Request) {
+	var (
+		err      error
+		settings *models.Settings
+		photos   []*models.Photo
+	)
+
+	pageName := "pages/memories"
+
+	viewData := viewmodels.Memories{
+		BaseViewModel: viewmodels.BaseViewModel{
+			IsHtmx: httphelpers.IsHtmx(r),
+			JavascriptIncludes: []rendering.JavascriptInclude{
+				{Src: "/static/js/fslightbox.js", Type: "text/javascript"},
+				{Src: "/static/js/pages/home.js", Type: "module"},
+			},
+		},
+		Day:   time.Now(),
+		Years: []viewmodels.MemoryYear{},
+	}
+
+	if date := httphelpers.GetFromRequest[string](r, "date"); date != "" {
+		if viewData.Day, err = time.Parse(time.DateOnly, date); err != nil {
+			viewData.Day = time.Now()
+			viewData.Message = "'" + date + "' is not a valid date. Showing today instead."
+			viewData.IsWarning = true
+		}
+	}
+
+	if settings, err = c.settingsService.Read(); err != nil {
+		slog.Error("error reading settings", "error", err)
+		viewData.Message = "Error reading settings"
+		viewData.IsError = true
+
+		c.renderer.Render(pageName, viewData, w)
+		return
+	}
+
+	if photos, err = c.photoService.GetPhotosOnThisDay(viewData.Day); err != nil {
+		slog.Error("error getting memories", "error", err, "day", viewData.Day)
+		viewData.Message = "There was an error retrieving photos for this day."
+		viewData.IsError = true
+
+		c.renderer.Render(pageName, viewData, w)
+		return
+	}
+
+	viewData.Years = viewmodels.NewMemoryYears(photos, viewData.Day, settings.LibraryPath)
+	c.renderer.Render(pageName, viewData, w)
+}
+
+/*
+periodRange returns the date range covered by a period, and how the
+photos in it should be grouped. An empty period is the whole timeline,
+grouped by year. Days are not grouped any further.
+*/
+func periodRange(period models.DateBucket) (time.Time, time.Time, models.DateGrouping, bool) {
+	if period.Period == "" {
+		return time.Time{}, time.Time{}, models.GroupByYear, true
+	}
+
+	start := period.Start()
+
+	if start.IsZero() {
+		return time.Time{}, time.Time{}, "", false
+	}
+
+	switch period.Grouping() {
+	case models.GroupByYear:
+		return start, start.AddDate(1, 0, 0), models.GroupByMonth, true
+
+	case models.GroupByMonth:
+		return start, start.AddDate(0, 1, 0), models.GroupByDay, true
+	}
+
+	return start, start.AddDate(0, 0, 1), "", true
+}
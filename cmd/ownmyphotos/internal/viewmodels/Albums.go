@@ -0,0 +1,71 @@
+package viewmodels
+
+import (
+	"net/url"
+	"slices"
+	"strconv"
+
+	"github.com/adampresley/adamgokit/paging"
+	"github.com/adampresley/ownmyphotos/pkg/models"
+)
+
+type Albums struct {
+	BaseViewModel
+	Albums         []*models.Album
+	SmartAlbumName string
+	SmartAlbumTerm string
+}
+
+/*
+IsFirst returns true if an album is at the top of the list, and so
+can't be moved up.
+*/
+func (a Albums) IsFirst(album *models.Album) bool {
+	return len(a.Albums) > 0 && a.Albums[0].ID == album.ID
+}
+
+/*
+IsLast returns true if an album is at the bottom of the list, and so
+can't be moved down.
+*/
+func (a Albums) IsLast(album *models.Album) bool {
+	return len(a.Albums) > 0 && a.Albums[len(a.Albums)-1].ID == album.ID
+}
+
+type Album struct {
+	BaseViewModel
+	Album  *models.Album
+	Images []ImageModel
+	Paging paging.Paging
+}
+
+/*
+IsCover returns true if a photo is the album's cover.
+*/
+func (a Album) IsCover(photo *models.Photo) bool {
+	return a.Album.KeyPhotoID == photo.ID
+}
+
+/*
+NextPageURL returns the link infinite scroll uses to load more photos.
+*/
+func (a Album) NextPageURL() string {
+	values := url.Values{}
+	values.Set("page", strconv.Itoa(a.Paging.NextPage))
+
+	return "/albums/" + strconv.FormatInt(a.Album.ID, 10) + "?" + values.Encode()
+}
+
+type AlbumPicker struct {
+	BaseViewModel
+	PhotoID  string
+	Albums   []*models.Album
+	AlbumIDs []int64
+}
+
+/*
+HasPhoto returns true if the photo is already in an album.
+*/
+func (a AlbumPicker) HasPhoto(album *models.Album) bool {
+	return slices.Contains(a.AlbumIDs, album.ID)
+}
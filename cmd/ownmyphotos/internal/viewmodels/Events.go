@@ -0,0 +1,58 @@
+package viewmodels
+
+import (
+	"net/url"
+	"strconv"
+
+	"github.com/adampresley/adamgokit/paging"
+	"github.com/adampresley/ownmyphotos/pkg/models"
+)
+
+type Events struct {
+	BaseViewModel
+	Year   string
+	Years  []*models.DateBucket
+	Events []*models.Event
+}
+
+/*
+YearPercent returns the number of photos in events in a year relative
+to the busiest year, for sizing the bars.
+*/
+func (e Events) YearPercent(year *models.DateBucket) int {
+	most := 0
+
+	for _, y := range e.Years {
+		most = max(most, y.NumPhotos)
+	}
+
+	if most == 0 {
+		return 0
+	}
+
+	return max(1, year.NumPhotos*100/most)
+}
+
+type Event struct {
+	BaseViewModel
+	Event  *models.Event
+	Images []ImageModel
+	Paging paging.Paging
+}
+
+/*
+NextPageURL returns the link infinite scroll uses to load more photos.
+*/
+func (e Event) NextPageURL() string {
+	values := url.Values{}
+	values.Set("page", strconv.Itoa(e.Paging.NextPage))
+
+	return EventURL(e.Event.ID) + "?" + values.Encode()
+}
+
+/*
+EventURL returns the link to an event's page.
+*/
+func EventURL(id int64) string {
+	return "/events/" + strconv.FormatInt(id, 10)
+}
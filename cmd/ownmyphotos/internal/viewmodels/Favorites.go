@@ -0,0 +1,31 @@
+package viewmodels
+
+import (
+	"net/url"
+	"strconv"
+
+	"github.com/adampresley/adamgokit/paging"
+	"github.com/adampresley/ownmyphotos/pkg/models"
+)
+
+type Favorites struct {
+	BaseViewModel
+	Images []ImageModel
+	Paging paging.Paging
+}
+
+/*
+NextPageURL returns the link infinite scroll uses to load more photos.
+*/
+func (f Favorites) NextPageURL() string {
+	values := url.Values{}
+	values.Set("page", strconv.Itoa(f.Paging.NextPage))
+
+	return "/favorites?" + values.Encode()
+}
+
+type FavoriteToggle struct {
+	BaseViewModel
+	Photo      *models.Photo
+	IsFavorite bool
+}
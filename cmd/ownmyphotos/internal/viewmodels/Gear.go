@@ -0,0 +1,68 @@
+package viewmodels
+
+import (
+	"net/url"
+	"strconv"
+
+	"github.com/adampresley/adamgokit/paging"
+	"github.com/adampresley/ownmyphotos/pkg/models"
+)
+
+type Gear struct {
+	BaseViewModel
+	Cameras  []*models.Equipment
+	Lenses   []*models.Equipment
+	Selected *models.Equipment
+	Images   []ImageModel
+	Paging   paging.Paging
+}
+
+/*
+EquipmentURL returns the link to the photos taken with a camera or
+lens.
+*/
+func (g Gear) EquipmentURL(equipment *models.Equipment) string {
+	values := url.Values{}
+	values.Set(string(equipment.Kind), equipment.ID)
+
+	return "/gear?" + values.Encode()
+}
+
+/*
+NextPageURL returns the link infinite scroll uses to load more photos.
+*/
+func (g Gear) NextPageURL() string {
+	values := url.Values{}
+
+	if g.Selected != nil {
+		values.Set(string(g.Selected.Kind), g.Selected.ID)
+	}
+
+	values.Set("page", strconv.Itoa(g.Paging.NextPage))
+
+	return "/gear?" + values.Encode()
+}
+
+/*
+Percent returns the number of photos taken with a camera or lens
+relative to the most used one of the same kind, for sizing bars.
+*/
+func (g Gear) Percent(equipment *models.Equipment) int {
+	list := g.Cameras
+
+	if equipment.Kind == models.EquipmentLens {
+		list = g.Lenses
+	}
+
+	most := 0
+
+	for _, e := range list {
+		most = max(most, e.NumPhotos)
+	}
+
+	if most == 0 {
+		return 0
+	}
+
+	return max(1, equipment.NumPhotos*100/most)
+}
@@ -2,17 +2,52 @@ package viewmodels
 
 import (
 	"html/template"
+	"net/url"
+	"strconv"
 
+	"github.com/adampresley/adamgokit/paging"
 	"github.com/adampresley/ownmyphotos/pkg/models"
 	"github.com/adampresley/ownmyphotos/pkg/services"
 )
 
 type Home struct {
 	BaseViewModel
-	Images  []ImageModel
-	Root    string
-	Parent  string
-	Folders *models.FolderNode
+	Images    []ImageModel
+	Root      string
+	Parent    string
+	Folders   *models.FolderNode
+	Paging    paging.Paging
+	Sort      models.PhotoSort
+	Selection Selection
+}
+
+/*
+NextPageURL returns the link infinite scroll uses to load more photos.
+*/
+func (h Home) NextPageURL() string {
+	values := url.Values{}
+	values.Set("root", h.Root)
+	values.Set("page", strconv.Itoa(h.Paging.NextPage))
+
+	if h.Sort != models.SortByName {
+		values.Set("sort", string(h.Sort))
+	}
+
+	return "/?" + values.Encode()
+}
+
+/*
+SortURL returns the link that shows this folder in another order.
+*/
+func (h Home) SortURL(sort models.PhotoSort) string {
+	values := url.Values{}
+	values.Set("root", h.Root)
+
+	if sort != models.SortByName {
+		values.Set("sort", string(sort))
+	}
+
+	return "/?" + values.Encode()
 }
 
 type ImageModel struct {
@@ -24,6 +59,10 @@ type ImageModel struct {
 	Photo        *models.Photo
 	IsFavorite   bool
 	Caption      string
+	BlurHash     string
+	Width        int
+	Height       int
+	IsEstimated  bool
 }
 
 func NewImageModelCollectionFromPhotos(photos []*models.Photo, childFolders []*models.Folder, libraryPath string) []ImageModel {
@@ -53,6 +92,11 @@ func NewImageModelCollectionFromPhotos(photos []*models.Photo, childFolders []*m
 			Name:         template.HTML(photo.FileName),
 			Photo:        photo,
 			Caption:      photo.FileName,
+			BlurHash:     photo.BlurHash,
+			Width:        photo.Width,
+			Height:       photo.Height,
+			IsEstimated:  photo.DateIsEstimated,
+			IsFavorite:   photo.IsFavorite,
 		}
 
 		if photo.Caption != "" {
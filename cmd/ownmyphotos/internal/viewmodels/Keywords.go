@@ -0,0 +1,33 @@
+package viewmodels
+
+import (
+	"github.com/adampresley/ownmyphotos/pkg/models"
+)
+
+type Keywords struct {
+	BaseViewModel
+	Tree           []*models.KeywordNode
+	CaseDuplicates [][]*models.KeywordSearchResult
+	NumKeywords    int
+	WriteBack      bool
+}
+
+/*
+MergeTarget returns the keyword a group of case duplicates should be
+merged into, which is the one on the most photos.
+*/
+func (k Keywords) MergeTarget(group []*models.KeywordSearchResult) string {
+	var target *models.KeywordSearchResult
+
+	for _, keyword := range group {
+		if target == nil || keyword.NumMatches > target.NumMatches {
+			target = keyword
+		}
+	}
+
+	if target == nil {
+		return ""
+	}
+
+	return target.Keyword
+}
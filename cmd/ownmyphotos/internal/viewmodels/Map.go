@@ -0,0 +1,116 @@
+package viewmodels
+
+import (
+	"net/url"
+	"strconv"
+
+	"github.com/adampresley/adamgokit/paging"
+	"github.com/adampresley/ownmyphotos/pkg/models"
+)
+
+type MapPage struct {
+	BaseViewModel
+	TileURL         string
+	TileAttribution string
+}
+
+type MapPhotos struct {
+	BaseViewModel
+	Heading   string
+	Bounds    string
+	Latitude  float64
+	Longitude float64
+	RadiusKm  float64
+	Images    []ImageModel
+	Paging    paging.Paging
+}
+
+/*
+NextPageURL returns the link infinite scroll uses to load more photos.
+*/
+func (m MapPhotos) NextPageURL() string {
+	values := url.Values{}
+	values.Set("page", strconv.Itoa(m.Paging.NextPage))
+
+	if m.Bounds != "" {
+		values.Set("bbox", m.Bounds)
+	} else {
+		values.Set("lat", strconv.FormatFloat(m.Latitude, 'f', -1, 64))
+		values.Set("lon", strconv.FormatFloat(m.Longitude, 'f', -1, 64))
+		values.Set("radius", strconv.FormatFloat(m.RadiusKm, 'f', -1, 64))
+	}
+
+	return "/map/photos?" + values.Encode()
+}
+
+/*
+GeoJSONFeatureCollection is the response for /api/photos.geojson. BBox
+covers every cluster, and is left out when there are none.
+*/
+type GeoJSONFeatureCollection struct {
+	Type     string            `json:"type"`
+	BBox     []float64         `json:"bbox,omitempty"`
+	Features []*GeoJSONFeature `json:"features"`
+}
+
+type GeoJSONFeature struct {
+	Type       string                   `json:"type"`
+	Geometry   GeoJSONPoint             `json:"geometry"`
+	Properties GeoJSONClusterProperties `json:"properties"`
+}
+
+type GeoJSONPoint struct {
+	Type        string    `json:"type"`
+	Coordinates []float64 `json:"coordinates"`
+}
+
+type GeoJSONClusterProperties struct {
+	Count        int       `json:"count"`
+	PhotoID      string    `json:"photoId"`
+	ThumbnailURL string    `json:"thumbnailUrl"`
+	BBox         []float64 `json:"bbox"`
+}
+
+/*
+NewGeoJSONFeatureCollection turns photo clusters into a GeoJSON
+feature collection of points. Each point's bbox covers the photos in
+the cluster, so it can be used to search for them.
+*/
+func NewGeoJSONFeatureCollection(clusters []*models.GeoCluster) GeoJSONFeatureCollection {
+	result := GeoJSONFeatureCollection{
+		Type:     "FeatureCollection",
+		Features: []*GeoJSONFeature{},
+	}
+
+	for index, cluster := range clusters {
+		result.Features = append(result.Features, &GeoJSONFeature{
+			Type: "Feature",
+			Geometry: GeoJSONPoint{
+				Type:        "Point",
+				Coordinates: []float64{cluster.Longitude, cluster.Latitude},
+			},
+			Properties: GeoJSONClusterProperties{
+				Count:        cluster.NumPhotos,
+				PhotoID:      cluster.KeyPhotoID,
+				ThumbnailURL: "/library/" + cluster.KeyPhotoID + "/thumbnail",
+				BBox:         geoJSONBBox(cluster.Bounds),
+			},
+		})
+
+		if index == 0 {
+			result.BBox = geoJSONBBox(cluster.Bounds)
+			continue
+		}
+
+		result.BBox[0] = min(result.BBox[0], cluster.Bounds.West)
+		result.BBox[1] = min(result.BBox[1], cluster.Bounds.South)
+		result.BBox[2] = max(result.BBox[2], cluster.Bounds.East)
+		result.BBox[3] = max(result.BBox[3], cluster.Bounds.North)
+	}
+
+	return result
+}
+
+func geoJSONBBox(bounds models.GeoBounds) []float64 {
+	return []float64{bounds.West, bounds.South, bounds.East, bounds.North}
+}
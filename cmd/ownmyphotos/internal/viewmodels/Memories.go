@@ -0,0 +1,74 @@
+package viewmodels
+
+import (
+	"time"
+
+	"github.com/adampresley/ownmyphotos/pkg/models"
+)
+
+type Memories struct {
+	BaseViewModel
+	Day     time.Time
+	Years   []MemoryYear
+	BaseURL string
+}
+
+/*
+MemoryYear is the photos taken on a day in one past year.
+*/
+type MemoryYear struct {
+	Year     int
+	YearsAgo int
+	Images   []ImageModel
+}
+
+/*
+NewMemoryYears groups photos, sorted newest first, by the year they
+were taken.
+*/
+func NewMemoryYears(photos []*models.Photo, day time.Time, libraryPath string) []MemoryYear {
+	result := []MemoryYear{}
+
+	for _, image := range NewImageModelCollectionFromPhotos(photos, []*models.Folder{}, libraryPath) {
+		year := image.Photo.CreationDateTime.Year()
+
+		if len(result) == 0 || result[len(result)-1].Year != year {
+			result = append(result, MemoryYear{
+				Year:     year,
+				YearsAgo: day.Year() - year,
+				Images:   []ImageModel{},
+			})
+		}
+
+		result[len(result)-1].Images = append(result[len(result)-1].Images, image)
+	}
+
+	return result
+}
+
+/*
+PreviousDay returns the day before, formatted for the memories page.
+*/
+func (m Memories) PreviousDay() string {
+	return m.Day.AddDate(0, 0, -1).Format(time.DateOnly)
+}
+
+/*
+NextDay returns the day after, formatted for the memories page.
+*/
+func (m Memories) NextDay() string {
+	return m.Day.AddDate(0, 0, 1).Format(time.DateOnly)
+}
+
+/*
+NumPhotos returns the total number of photos across all years.
+*/
+func (m Memories) NumPhotos() int {
+	result := 0
+
+	for _, year := range m.Years {
+		result += len(year.Images)
+	}
+
+	return result
+}
@@ -0,0 +1,98 @@
+package viewmodels
+
+import (
+	"net/url"
+	"strconv"
+
+	"github.com/adampresley/adamgokit/paging"
+	"github.com/adampresley/ownmyphotos/pkg/models"
+)
+
+type People struct {
+	BaseViewModel
+	People           []*models.Person
+	NumFacesToReview int
+	WriteBack        bool
+}
+
+type FaceReview struct {
+	BaseViewModel
+	Clusters    []*models.FaceCluster
+	People      []*models.Person
+	NumFaces    int
+	IsDetecting bool
+	WriteBack   bool
+}
+
+type Person struct {
+	BaseViewModel
+	Person    *models.Person
+	People    []*models.Person
+	Years     []*models.DateBucket
+	Year      string
+	Images    []ImageModel
+	Paging    paging.Paging
+	WriteBack bool
+}
+
+/*
+IsCover returns true if a photo is the person's cover.
+*/
+func (p Person) IsCover(photo *models.Photo) bool {
+	return p.Person.KeyPhotoID == photo.ID
+}
+
+/*
+NextPageURL returns the link infinite scroll uses to load more photos.
+*/
+func (p Person) NextPageURL() string {
+	values := url.Values{}
+	values.Set("page", strconv.Itoa(p.Paging.NextPage))
+
+	if p.Year != "" {
+		values.Set("year", p.Year)
+	}
+
+	return PersonURL(p.Person.ID) + "?" + values.Encode()
+}
+
+/*
+OtherPeople returns everyone but this person, to choose who to merge
+into them.
+*/
+func (p Person) OtherPeople() []*models.Person {
+	result := []*models.Person{}
+
+	for _, person := range p.People {
+		if person.ID != p.Person.ID {
+			result = append(result, person)
+		}
+	}
+
+	return result
+}
+
+/*
+YearPercent returns the number of photos in a year relative to the
+person's busiest year, for sizing the bars.
+*/
+func (p Person) YearPercent(year *models.DateBucket) int {
+	most := 0
+
+	for _, y := range p.Years {
+		most = max(most, y.NumPhotos)
+	}
+
+	if most == 0 {
+		return 0
+	}
+
+	return max(1, year.NumPhotos*100/most)
+}
+
+/*
+PersonURL returns the link to a person's page.
+*/
+func PersonURL(id uint) string {
+	return "/people/" + strconv.FormatUint(uint64(id), 10)
+}
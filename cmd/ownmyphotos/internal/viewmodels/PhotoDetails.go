@@ -0,0 +1,47 @@
+package viewmodels
+
+import (
+	"net/url"
+
+	"github.com/adampresley/ownmyphotos/pkg/models"
+)
+
+type PhotoDetails struct {
+	BaseViewModel
+	Photo  *models.Photo
+	Places []*models.Place
+}
+
+/*
+CameraName returns the name of the camera the photo was taken with.
+*/
+func (p PhotoDetails) CameraName() string {
+	return models.EquipmentName(p.Photo.Make, p.Photo.Model)
+}
+
+/*
+CameraURL returns the link to every photo taken with the same camera.
+*/
+func (p PhotoDetails) CameraURL() string {
+	values := url.Values{}
+	values.Set(string(models.EquipmentCamera), models.CameraID(p.Photo.Make, p.Photo.Model))
+
+	return "/gear?" + values.Encode()
+}
+
+/*
+LensName returns the name of the lens the photo was taken with.
+*/
+func (p PhotoDetails) LensName() string {
+	return models.EquipmentName(p.Photo.LensMake, p.Photo.LensModel)
+}
+
+/*
+LensURL returns the link to every photo taken with the same lens.
+*/
+func (p PhotoDetails) LensURL() string {
+	values := url.Values{}
+	values.Set(string(models.EquipmentLens), p.Photo.LensID)
+
+	return "/gear?" + values.Encode()
+}
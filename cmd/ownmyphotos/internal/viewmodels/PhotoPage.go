@@ -0,0 +1,170 @@
+package viewmodels
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/adampresley/ownmyphotos/pkg/metadata"
+	"github.com/adampresley/ownmyphotos/pkg/models"
+)
+
+type PhotoPage struct {
+	PhotoDetails
+	AlbumPath           string
+	Albums              []*models.Album
+	Faces               []*models.Face
+	FileSize            int64
+	Adjacent            models.AdjacentPhotos
+	Context             url.Values
+	ContextName         string
+	RawMetadata         metadata.RawMetadata
+	TileURL             string
+	TileAttribution     string
+	MetadataWriteTarget models.MetadataWriteTarget
+}
+
+/*
+IsFavorite returns true if the photo is one of the user's favorites.
+*/
+func (p PhotoPage) IsFavorite() bool {
+	return p.Photo.IsFavorite
+}
+
+/*
+HasLocation returns true if the photo is geotagged.
+*/
+func (p PhotoPage) HasLocation() bool {
+	return p.Photo.Latitude != 0 || p.Photo.Longitude != 0
+}
+
+/*
+FolderURL returns the link to the folder the photo is in.
+*/
+func (p PhotoPage) FolderURL() string {
+	values := url.Values{}
+	values.Set("root", p.AlbumPath)
+
+	return "/?" + values.Encode()
+}
+
+/*
+KeywordURL returns the link to every photo with a keyword.
+*/
+func (p PhotoPage) KeywordURL(keyword string) string {
+	values := url.Values{}
+	values.Set("keyword", keyword)
+
+	return "/search/simple?" + values.Encode()
+}
+
+/*
+ColorURL returns the link to every photo with a color close to one of
+this photo's.
+*/
+func (p PhotoPage) ColorURL(color *models.Color) string {
+	values := url.Values{}
+	values.Set("term", "color:"+color.Hex)
+
+	return "/search/simple?" + values.Encode()
+}
+
+/*
+PersonURL returns the link to a person's page.
+*/
+func (p PhotoPage) PersonURL(person *models.Person) string {
+	return PersonURL(person.ID)
+}
+
+/*
+FacePersonURL returns the link to the page of the person a face is
+linked to.
+*/
+func (p PhotoPage) FacePersonURL(face *models.Face) string {
+	return PersonURL(face.PersonID)
+}
+
+/*
+PreviousURL returns the link to the photo before this one, keeping the
+folder or search the photo was opened from.
+*/
+func (p PhotoPage) PreviousURL() string {
+	return p.photoURL(p.Adjacent.PreviousID)
+}
+
+/*
+NextURL returns the link to the photo after this one, keeping the
+folder or search the photo was opened from.
+*/
+func (p PhotoPage) NextURL() string {
+	return p.photoURL(p.Adjacent.NextID)
+}
+
+/*
+EditURL returns the address the metadata edit form is posted to,
+keeping the folder or search the photo was opened from.
+*/
+func (p PhotoPage) EditURL() string {
+	if len(p.Context) == 0 {
+		return "/photos/" + p.Photo.ID + "/metadata"
+	}
+
+	return "/photos/" + p.Photo.ID + "/metadata?" + p.Context.Encode()
+}
+
+/*
+KeywordList returns the photo's keywords as the comma separated list
+the edit form uses.
+*/
+func (p PhotoPage) KeywordList() string {
+	return strings.Join(models.NewPhotoEdit(p.Photo).Keywords, ", ")
+}
+
+/*
+PeopleList returns the people in the photo as the comma separated list
+the edit form uses.
+*/
+func (p PhotoPage) PeopleList() string {
+	return strings.Join(models.NewPhotoEdit(p.Photo).People, ", ")
+}
+
+/*
+WritesToFile returns true if edits are written into the photo's file,
+rather than a sidecar.
+*/
+func (p PhotoPage) WritesToFile() bool {
+	return p.MetadataWriteTarget == models.WriteToFile
+}
+
+/*
+FileSizeLabel returns the size of the photo's file, such as "4.2 MB".
+*/
+func (p PhotoPage) FileSizeLabel() string {
+	const unit = 1024
+
+	if p.FileSize < unit {
+		return fmt.Sprintf("%d bytes", p.FileSize)
+	}
+
+	size := float64(p.FileSize) / unit
+	units := []string{"KB", "MB", "GB"}
+	i := 0
+
+	for ; size >= unit && i < len(units)-1; i++ {
+		size /= unit
+	}
+
+	return fmt.Sprintf("%.1f %s", size, units[i])
+}
+
+func (p PhotoPage) photoURL(id string) string {
+	if id == "" {
+		return ""
+	}
+
+	if len(p.Context) == 0 {
+		return "/photos/" + id
+	}
+
+	return "/photos/" + id + "?" + p.Context.Encode()
+}
@@ -0,0 +1,10 @@
+package viewmodels
+
+import (
+	"github.com/adampresley/ownmyphotos/pkg/models"
+)
+
+type PhotoRating struct {
+	BaseViewModel
+	Photo *models.Photo
+}
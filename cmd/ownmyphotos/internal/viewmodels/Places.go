@@ -0,0 +1,42 @@
+package viewmodels
+
+import (
+	"net/url"
+	"strconv"
+
+	"github.com/adampresley/adamgokit/paging"
+	"github.com/adampresley/ownmyphotos/pkg/models"
+)
+
+type Places struct {
+	BaseViewModel
+	Place        models.PlacePath
+	Breadcrumbs  []*models.Place
+	Places       []*models.Place
+	Images       []ImageModel
+	Paging       paging.Paging
+	HasGazetteer bool
+}
+
+/*
+Name returns the name of the current place, or an empty string when
+browsing the whole world.
+*/
+func (p Places) Name() string {
+	if len(p.Breadcrumbs) == 0 {
+		return ""
+	}
+
+	return p.Breadcrumbs[len(p.Breadcrumbs)-1].Name
+}
+
+/*
+NextPageURL returns the link infinite scroll uses to load more photos.
+*/
+func (p Places) NextPageURL() string {
+	values := url.Values{}
+	values.Set("place", p.Place.String())
+	values.Set("page", strconv.Itoa(p.Paging.NextPage))
+
+	return "/places?" + values.Encode()
+}
@@ -0,0 +1,36 @@
+package viewmodels
+
+import (
+	"github.com/adampresley/adamgokit/slices"
+	"github.com/adampresley/ownmyphotos/pkg/models"
+)
+
+/*
+Selection is what the gallery's selection toolbar needs: the page to
+show again once the selected photos change, and the albums they can
+be added to.
+*/
+type Selection struct {
+	ReturnURL string
+	Albums    []*models.Album
+}
+
+/*
+NewSelection creates the selection toolbar for a page. Smart albums
+are left out, as photos can't be added to them.
+*/
+func NewSelection(returnURL string, albums []*models.Album) Selection {
+	return Selection{
+		ReturnURL: returnURL,
+		Albums: slices.Filter(albums, func(album *models.Album) bool {
+			return !album.IsSmart
+		}),
+	}
+}
+
+/*
+BatchResult is the message shown after acting on selected photos.
+*/
+type BatchResult struct {
+	BaseViewModel
+}
@@ -1,6 +1,13 @@
 package viewmodels
 
-import "github.com/adampresley/ownmyphotos/pkg/models"
+import (
+	"net/url"
+	"slices"
+	"strconv"
+
+	"github.com/adampresley/ownmyphotos/pkg/models"
+	"github.com/adampresley/ownmyphotos/pkg/query"
+)
 
 type SimpleSearch struct {
 	BaseViewModel
@@ -8,4 +15,102 @@ type SimpleSearch struct {
 	Root        string
 	Results     models.SearchPhotosResult
 	LibraryPath string
+	QueryError  *query.Error
+	Keywords    []string
+	People      []string
+	Match       models.SearchMatch
+	Page        int
+	Selection   Selection
+}
+
+/*
+HasFilters returns true when the search is narrowed by keywords
+or people.
+*/
+func (s SimpleSearch) HasFilters() bool {
+	return len(s.Keywords) > 0 || len(s.People) > 0
+}
+
+/*
+CanChangeMatch returns true when there are enough filters that
+matching all or any of them gives different results.
+*/
+func (s SimpleSearch) CanChangeMatch() bool {
+	return len(s.Keywords)+len(s.People) > 1
+}
+
+/*
+MatchURL returns a link to this search using a different match mode.
+*/
+func (s SimpleSearch) MatchURL(match string) string {
+	return s.searchURL(s.Keywords, s.People, models.NewSearchMatch(match)).String()
+}
+
+/*
+CurrentURL returns a link to the first page of this search.
+*/
+func (s SimpleSearch) CurrentURL() string {
+	return s.searchURL(s.Keywords, s.People, s.Match).String()
+}
+
+/*
+PageURL returns a link to another page of photos for this search.
+*/
+func (s SimpleSearch) PageURL(page int) string {
+	u := s.searchURL(s.Keywords, s.People, s.Match)
+
+	values := u.Query()
+	values.Set("page", strconv.Itoa(page))
+	u.RawQuery = values.Encode()
+
+	return u.String()
+}
+
+/*
+WithoutKeywordURL returns a link to this search with a keyword removed.
+*/
+func (s SimpleSearch) WithoutKeywordURL(keyword string) string {
+	keywords := slices.DeleteFunc(slices.Clone(s.Keywords), func(k string) bool { return k == keyword })
+	return s.searchURL(keywords, s.People, s.Match).String()
+}
+
+/*
+WithoutPersonURL returns a link to this search with a person removed.
+*/
+func (s SimpleSearch) WithoutPersonURL(person string) string {
+	people := slices.DeleteFunc(slices.Clone(s.People), func(p string) bool { return p == person })
+	return s.searchURL(s.Keywords, people, s.Match).String()
+}
+
+/*
+PhotoURL returns the link to a photo's page that moves through these
+search results.
+*/
+func (s SimpleSearch) PhotoURL(photo *models.Photo) string {
+	u := s.searchURL(s.Keywords, s.People, s.Match)
+	u.Path = "/photos/" + photo.ID
+
+	return u.String()
+}
+
+func (s SimpleSearch) searchURL(keywords, people []string, match models.SearchMatch) *url.URL {
+	values := url.Values{}
+
+	if s.SearchTerm != "" {
+		values.Set("term", s.SearchTerm)
+	}
+
+	for _, keyword := range keywords {
+		values.Add("keyword", keyword)
+	}
+
+	for _, person := range people {
+		values.Add("person", person)
+	}
+
+	if match == models.MatchAny {
+		values.Set("match", string(match))
+	}
+
+	return &url.URL{Path: "/search/simple", RawQuery: values.Encode()}
 }
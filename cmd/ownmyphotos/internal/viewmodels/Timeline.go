@@ -0,0 +1,63 @@
+package viewmodels
+
+import (
+	"net/url"
+	"strconv"
+
+	"github.com/adampresley/adamgokit/paging"
+	"github.com/adampresley/ownmyphotos/pkg/models"
+)
+
+type Timeline struct {
+	BaseViewModel
+	Period  models.DateBucket
+	Buckets []*models.DateBucket
+	Years   []*models.DateBucket
+	Images  []ImageModel
+	Paging  paging.Paging
+}
+
+/*
+Breadcrumbs returns the year and month containing the current period,
+followed by the period itself.
+*/
+func (t Timeline) Breadcrumbs() []models.DateBucket {
+	result := []models.DateBucket{}
+
+	for _, length := range []int{4, 7, 10} {
+		if len(t.Period.Period) >= length {
+			result = append(result, models.DateBucket{Period: t.Period.Period[:length]})
+		}
+	}
+
+	return result
+}
+
+/*
+NextPageURL returns the link infinite scroll uses to load more photos.
+*/
+func (t Timeline) NextPageURL() string {
+	values := url.Values{}
+	values.Set("date", t.Period.Period)
+	values.Set("page", strconv.Itoa(t.Paging.NextPage))
+
+	return "/timeline?" + values.Encode()
+}
+
+/*
+YearPercent returns the number of photos in a year relative to the
+busiest year, for sizing the bars on the scrubber.
+*/
+func (t Timeline) YearPercent(year *models.DateBucket) int {
+	most := 0
+
+	for _, y := range t.Years {
+		most = max(most, y.NumPhotos)
+	}
+
+	if most == 0 {
+		return 0
+	}
+
+	return max(1, year.NumPhotos*100/most)
+}
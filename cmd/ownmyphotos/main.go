@@ -14,10 +14,22 @@ import (
 	"github.com/adampresley/adamgokit/httphelpers"
 	"github.com/adampresley/adamgokit/mux"
 	"github.com/adampresley/adamgokit/rendering"
+	"github.com/adampresley/ownmyphotos/cmd/ownmyphotos/internal/albums"
 	"github.com/adampresley/ownmyphotos/cmd/ownmyphotos/internal/configuration"
+	"github.com/adampresley/ownmyphotos/cmd/ownmyphotos/internal/digest"
+	"github.com/adampresley/ownmyphotos/cmd/ownmyphotos/internal/events"
+	"github.com/adampresley/ownmyphotos/cmd/ownmyphotos/internal/facedetection"
+	"github.com/adampresley/ownmyphotos/cmd/ownmyphotos/internal/favorites"
+	"github.com/adampresley/ownmyphotos/cmd/ownmyphotos/internal/gear"
+	"github.com/adampresley/ownmyphotos/cmd/ownmyphotos/internal/geo"
 	"github.com/adampresley/ownmyphotos/cmd/ownmyphotos/internal/home"
+	"github.com/adampresley/ownmyphotos/cmd/ownmyphotos/internal/keywords"
 	"github.com/adampresley/ownmyphotos/cmd/ownmyphotos/internal/library"
+	"github.com/adampresley/ownmyphotos/cmd/ownmyphotos/internal/people"
+	"github.com/adampresley/ownmyphotos/cmd/ownmyphotos/internal/photos"
+	"github.com/adampresley/ownmyphotos/cmd/ownmyphotos/internal/places"
 	"github.com/adampresley/ownmyphotos/cmd/ownmyphotos/internal/settings"
+	"github.com/adampresley/ownmyphotos/cmd/ownmyphotos/internal/timeline"
 	"github.com/adampresley/ownmyphotos/pkg/cache"
 	"github.com/adampresley/ownmyphotos/pkg/collector"
 	"github.com/adampresley/ownmyphotos/pkg/models"
@@ -38,18 +50,35 @@ var (
 
 	/* Services */
 	db               *sqlz.DB
+	albumService     services.AlbumServicer
+	eventService     services.EventServicer
+	faceDetection    facedetection.FaceDetectionJob
+	faceService      services.FaceServicer
 	folderService    services.FolderServicer
 	jpegCollector    collector.Collector
 	jpegCacheCreator cache.CacheCreator
+	keywordService   services.KeywordServicer
+	peopleService    services.PeopleServicer
 	photoCache       services.PhotoCacher
 	photoService     services.PhotoServicer
+	placeService     services.PlaceServicer
 	renderer         rendering.TemplateRenderer
 	settingsService  services.SettingsServicer
 
 	/* Controllers */
-	homeController     home.HomeHandlers
-	libraryController  library.LibraryHandlers
-	settingsController settings.SettingsHandlers
+	albumsController    albums.AlbumsHandlers
+	eventsController    events.EventsHandlers
+	favoritesController favorites.FavoritesHandlers
+	gearController      gear.GearHandlers
+	geoController       geo.GeoHandlers
+	homeController      home.HomeHandlers
+	keywordsController  keywords.KeywordsHandlers
+	libraryController   library.LibraryHandlers
+	peopleController    people.PeopleHandlers
+	photosController    photos.PhotosHandlers
+	placesController    places.PlacesHandlers
+	settingsController  settings.SettingsHandlers
+	timelineController  timeline.TimelineHandlers
 )
 
 func main() {
@@ -105,10 +134,34 @@ func main() {
 		DB: db,
 	})
 
+	placeService = services.NewPlaceService(services.PlaceServiceConfig{
+		DB: db,
+	})
+
+	albumService = services.NewAlbumService(services.AlbumServiceConfig{
+		DB: db,
+	})
+
 	folderService = services.NewFolderService(services.FolderServiceConfig{
 		DB: db,
 	})
 
+	keywordService = services.NewKeywordService(services.KeywordServiceConfig{
+		DB: db,
+	})
+
+	peopleService = services.NewPeopleService(services.PeopleServiceConfig{
+		DB: db,
+	})
+
+	faceService = services.NewFaceService(services.FaceServiceConfig{
+		DB: db,
+	})
+
+	eventService = services.NewEventService(services.EventServiceConfig{
+		DB: db,
+	})
+
 	jpegCacheCreator = cache.NewJpegCacheCreator(uint(userSettings.ThumbnailSize))
 
 	jpegCollector, err = collector.NewJpegCollector(collector.JpegCollectorConfig{
@@ -117,6 +170,7 @@ func main() {
 		FolderService: folderService,
 		PhotoCache:    photoCache,
 		PhotoService:  photoService,
+		PlaceService:  placeService,
 	})
 
 	if err != nil {
@@ -124,10 +178,22 @@ func main() {
 		os.Exit(1)
 	}
 
+	faceDetection, err = facedetection.NewFaceDetectionJob(facedetection.FaceDetectionJobConfig{
+		FaceService:     faceService,
+		PhotoCache:      photoCache,
+		SettingsService: settingsService,
+	})
+
+	if err != nil {
+		slog.Error("error setting up face detection", "error", err.Error())
+		os.Exit(1)
+	}
+
 	/*
 	 * Setup controllers
 	 */
 	homeController = home.NewHomeController(home.HomeControllerConfig{
+		AlbumService:    albumService,
 		Config:          &config,
 		FolderService:   folderService,
 		PhotoService:    photoService,
@@ -138,6 +204,24 @@ func main() {
 	libraryController = library.NewLibraryController(library.LibraryControllerConfig{
 		PhotoCache:      photoCache,
 		PhotoService:    photoService,
+		PlaceService:    placeService,
+		Renderer:        renderer,
+		SettingsService: settingsService,
+	})
+
+	photosController = photos.NewPhotosController(photos.PhotosControllerConfig{
+		AlbumService:    albumService,
+		PhotoCache:      photoCache,
+		PhotoService:    photoService,
+		PlaceService:    placeService,
+		Renderer:        renderer,
+		SettingsService: settingsService,
+	})
+
+	placesController = places.NewPlacesController(places.PlacesControllerConfig{
+		PhotoService:    photoService,
+		PlaceService:    placeService,
+		Renderer:        renderer,
 		SettingsService: settingsService,
 	})
 
@@ -147,6 +231,60 @@ func main() {
 		SettingsService: settingsService,
 	})
 
+	albumsController = albums.NewAlbumsController(albums.AlbumsControllerConfig{
+		AlbumService:    albumService,
+		PhotoService:    photoService,
+		Renderer:        renderer,
+		SettingsService: settingsService,
+	})
+
+	favoritesController = favorites.NewFavoritesController(favorites.FavoritesControllerConfig{
+		PhotoService:    photoService,
+		Renderer:        renderer,
+		SettingsService: settingsService,
+	})
+
+	gearController = gear.NewGearController(gear.GearControllerConfig{
+		PhotoService:    photoService,
+		Renderer:        renderer,
+		SettingsService: settingsService,
+	})
+
+	keywordsController = keywords.NewKeywordsController(keywords.KeywordsControllerConfig{
+		KeywordService:  keywordService,
+		PhotoService:    photoService,
+		Renderer:        renderer,
+		SettingsService: settingsService,
+	})
+
+	peopleController = people.NewPeopleController(people.PeopleControllerConfig{
+		FaceDetection:   faceDetection,
+		FaceService:     faceService,
+		PeopleService:   peopleService,
+		PhotoService:    photoService,
+		Renderer:        renderer,
+		SettingsService: settingsService,
+	})
+
+	geoController = geo.NewGeoController(geo.GeoControllerConfig{
+		PhotoService:    photoService,
+		Renderer:        renderer,
+		SettingsService: settingsService,
+	})
+
+	timelineController = timeline.NewTimelineController(timeline.TimelineControllerConfig{
+		PhotoService:    photoService,
+		Renderer:        renderer,
+		SettingsService: settingsService,
+	})
+
+	eventsController = events.NewEventsController(events.EventsControllerConfig{
+		EventService:    eventService,
+		PhotoService:    photoService,
+		Renderer:        renderer,
+		SettingsService: settingsService,
+	})
+
 	/*
 	 * Setup router and http server
 	 */
@@ -156,11 +294,65 @@ func main() {
 		{Path: "GET /heartbeat", HandlerFunc: heartbeat},
 		{Path: "GET /", HandlerFunc: homeController.HomePage},
 		{Path: "GET /about", HandlerFunc: homeController.AboutPage},
+		{Path: "GET /timeline", HandlerFunc: timelineController.TimelinePage},
+		{Path: "GET /memories", HandlerFunc: timelineController.MemoriesPage},
+		{Path: "GET /events", HandlerFunc: eventsController.EventsPage},
+		{Path: "POST /events/rebuild", HandlerFunc: eventsController.RebuildEventsAction},
+		{Path: "GET /events/{id}", HandlerFunc: eventsController.EventPage},
+		{Path: "POST /events/{id}/rename", HandlerFunc: eventsController.RenameEventAction},
+		{Path: "GET /gear", HandlerFunc: gearController.GearPage},
+		{Path: "GET /favorites", HandlerFunc: favoritesController.FavoritesPage},
+		{Path: "GET /photos/{id}", HandlerFunc: photosController.PhotoPage},
+		{Path: "POST /photos/{id}/metadata", HandlerFunc: photosController.EditMetadataAction},
+		{Path: "POST /photos/batch/edit", HandlerFunc: photosController.BatchEditAction},
+		{Path: "POST /photos/batch/album", HandlerFunc: photosController.BatchAlbumAction},
+		{Path: "POST /photos/batch/favorite", HandlerFunc: photosController.BatchFavoriteAction},
+		{Path: "POST /photos/batch/download", HandlerFunc: photosController.BatchDownloadAction},
+		{Path: "POST /photos/batch/trash", HandlerFunc: photosController.BatchTrashAction},
+		{Path: "GET /places", HandlerFunc: placesController.PlacesPage},
+		{Path: "GET /people", HandlerFunc: peopleController.PeoplePage},
+		{Path: "POST /people/merge", HandlerFunc: peopleController.MergePeopleAction},
+		{Path: "GET /people/review", HandlerFunc: peopleController.ReviewFacesPage},
+		{Path: "POST /people/review/name", HandlerFunc: peopleController.NameFacesAction},
+		{Path: "POST /people/review/ignore", HandlerFunc: peopleController.IgnoreFacesAction},
+		{Path: "POST /people/review/detect", HandlerFunc: peopleController.DetectFacesAction},
+		{Path: "GET /people/{id}", HandlerFunc: peopleController.PersonPage},
+		{Path: "POST /people/{id}/rename", HandlerFunc: peopleController.RenamePersonAction},
+		{Path: "POST /people/{id}/aliases", HandlerFunc: peopleController.AddAliasAction},
+		{Path: "POST /people/{id}/aliases/delete", HandlerFunc: peopleController.RemoveAliasAction},
+		{Path: "PUT /people/{id}/cover/{photoID}", HandlerFunc: peopleController.SetCoverAction},
+		{Path: "GET /keywords", HandlerFunc: keywordsController.KeywordsPage},
+		{Path: "POST /keywords/rename", HandlerFunc: keywordsController.RenameKeywordAction},
+		{Path: "POST /keywords/merge", HandlerFunc: keywordsController.MergeKeywordsAction},
+		{Path: "POST /keywords/delete", HandlerFunc: keywordsController.DeleteKeywordAction},
+		{Path: "GET /albums", HandlerFunc: albumsController.AlbumsPage},
+		{Path: "POST /albums", HandlerFunc: albumsController.CreateAlbumAction},
+		{Path: "POST /albums/smart", HandlerFunc: albumsController.CreateSmartAlbumAction},
+		{Path: "GET /albums/nav", HandlerFunc: albumsController.AlbumNav},
+		{Path: "GET /albums/picker", HandlerFunc: albumsController.AlbumPicker},
+		{Path: "GET /albums/{id}", HandlerFunc: albumsController.AlbumPage},
+		{Path: "DELETE /albums/{id}", HandlerFunc: albumsController.DeleteAlbumAction},
+		{Path: "POST /albums/{id}/rename", HandlerFunc: albumsController.RenameAlbumAction},
+		{Path: "POST /albums/{id}/move", HandlerFunc: albumsController.MoveAlbumAction},
+		{Path: "POST /albums/{id}/search", HandlerFunc: albumsController.UpdateSearchAction},
+		{Path: "POST /albums/{id}/convert", HandlerFunc: albumsController.ConvertAlbumAction},
+		{Path: "POST /albums/{id}/photos", HandlerFunc: albumsController.AddPhotosAction},
+		{Path: "DELETE /albums/{id}/photos/{photoID}", HandlerFunc: albumsController.RemovePhotoAction},
+		{Path: "PUT /albums/{id}/cover/{photoID}", HandlerFunc: albumsController.SetCoverAction},
+		{Path: "GET /map", HandlerFunc: geoController.MapPage},
+		{Path: "GET /map/photos", HandlerFunc: geoController.MapPhotos},
+		{Path: "GET /api/photos.geojson", HandlerFunc: geoController.PhotosGeoJSON},
 		{Path: "GET /settings", HandlerFunc: settingsController.SettingsPage},
 		{Path: "POST /settings", HandlerFunc: settingsController.SettingsAction},
+		{Path: "GET /search/simple", HandlerFunc: homeController.SimpleSearchPage},
 		{Path: "POST /search/simple", HandlerFunc: homeController.SimpleSearchPage},
 		{Path: "GET /library/{id}", HandlerFunc: libraryController.ServeImage},
 		{Path: "GET /library/{id}/thumbnail", HandlerFunc: libraryController.ServeThumbnail},
+		{Path: "GET /library/{id}/details", HandlerFunc: libraryController.PhotoDetails},
+		{Path: "GET /faces/{id}", HandlerFunc: libraryController.ServeFaceCrop},
+		{Path: "PUT /library/{id}/favorite", HandlerFunc: libraryController.ToggleFavoriteAction},
+		{Path: "PUT /library/{id}/rating", HandlerFunc: libraryController.SetRatingAction},
+		{Path: "PUT /library/{id}/label", HandlerFunc: libraryController.SetLabelAction},
 	}
 
 	routerConfig := mux.RouterConfig{
@@ -179,7 +371,10 @@ func main() {
 	 * Setup cache creator
 	 */
 	// setupCacheCreator()
+	setupGazetteer()
 	setupCollectors(userSettings)
+	setupDigest(userSettings)
+	setupFaceDetection(userSettings)
 
 	/*
 	 * Start cron jobs
@@ -274,5 +469,89 @@ func setupCollectors(settings *models.Settings) {
 		}
 
 		slog.Info("photo collection completed")
+
+		if _, err = eventService.Rebuild(); err != nil {
+			slog.Error("error grouping photos into events", "error", err)
+		}
+	})
+}
+
+/*
+setupGazetteer imports the GeoNames files in the gazetteer directory
+the first time they're configured, then places the photos already
+collected. This runs in the background, as a large cities file can
+take a while to import.
+*/
+func setupGazetteer() {
+	if config.GazetteerDir == "" {
+		return
+	}
+
+	go func() {
+		hasGazetteer, err := placeService.HasGazetteer()
+
+		if err != nil {
+			slog.Error("error checking for a gazetteer", "error", err)
+			return
+		}
+
+		if !hasGazetteer {
+			slog.Info("importing gazetteer", "directory", config.GazetteerDir)
+
+			numCities, err := placeService.ImportGazetteer(config.GazetteerDir)
+
+			if err != nil {
+				slog.Error("error importing gazetteer", "error", err, "directory", config.GazetteerDir)
+				return
+			}
+
+			slog.Info("gazetteer imported", "cities", numCities)
+		}
+
+		if _, err = placeService.ResolveMissingPlaces(); err != nil {
+			slog.Error("error resolving photo places", "error", err)
+		}
+	}()
+}
+
+func setupDigest(settings *models.Settings) {
+	if settings.DigestSchedule == "" {
+		return
+	}
+
+	digestJob := digest.NewDigestJob(digest.DigestJobConfig{
+		Config:          &config,
+		PhotoService:    photoService,
+		Renderer:        renderer,
+		SettingsService: settingsService,
+	})
+
+	cron.Add(settings.DigestSchedule, func() {
+		if err := digestJob.Run(); err != nil {
+			slog.Error("error creating the memories digest", "error", err)
+			return
+		}
+
+		slog.Info("memories digest completed")
+	})
+}
+
+/*
+setupFaceDetection schedules looking for faces in new photos. Face
+detection is optional, so nothing is scheduled without a schedule,
+though it can still be started from the people page.
+*/
+func setupFaceDetection(settings *models.Settings) {
+	if settings.FaceDetectionSchedule == "" {
+		return
+	}
+
+	cron.Add(settings.FaceDetectionSchedule, func() {
+		if err := faceDetection.Run(); err != nil {
+			slog.Error("error detecting faces", "error", err)
+			return
+		}
+
+		slog.Info("face detection completed")
 	})
 }
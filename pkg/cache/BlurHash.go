@@ -0,0 +1,169 @@
+package cache
+
+import (
+	"image"
+	"math"
+	"strings"
+
+	"github.com/nfnt/resize"
+)
+
+const (
+	blurHashCharacters   = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+	blurHashSampleSize   = 32
+	blurHashXComponents  = 4
+	blurHashYComponents  = 3
+	blurHashMaxComponent = 9
+)
+
+/*
+encodeBlurHash computes a BlurHash string for an image. The image is
+downsampled before encoding, as the hash only captures low frequency
+color information anyway. See https://blurha.sh for the algorithm.
+*/
+func encodeBlurHash(img image.Image, xComponents, yComponents int) string {
+	if xComponents < 1 || xComponents > blurHashMaxComponent || yComponents < 1 || yComponents > blurHashMaxComponent {
+		return ""
+	}
+
+	bounds := img.Bounds()
+
+	if bounds.Dx() == 0 || bounds.Dy() == 0 {
+		return ""
+	}
+
+	sample := resize.Thumbnail(blurHashSampleSize, blurHashSampleSize, img, resize.Bilinear)
+	bounds = sample.Bounds()
+	width := bounds.Dx()
+	height := bounds.Dy()
+
+	/*
+	 * Convert the sample to linear RGB once up front.
+	 */
+	linear := make([][3]float64, width*height)
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			r, g, b, _ := sample.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+
+			linear[y*width+x] = [3]float64{
+				srgbToLinear(int(r >> 8)),
+				srgbToLinear(int(g >> 8)),
+				srgbToLinear(int(b >> 8)),
+			}
+		}
+	}
+
+	factors := make([][3]float64, 0, xComponents*yComponents)
+
+	for j := 0; j < yComponents; j++ {
+		for i := 0; i < xComponents; i++ {
+			factors = append(factors, blurHashFactor(linear, width, height, i, j))
+		}
+	}
+
+	result := &strings.Builder{}
+	result.WriteString(encodeBase83((xComponents-1)+(yComponents-1)*9, 1))
+
+	maximumValue := 1.0
+	ac := factors[1:]
+
+	if len(ac) > 0 {
+		actualMaximum := 0.0
+
+		for _, f := range ac {
+			actualMaximum = math.Max(actualMaximum, math.Max(math.Abs(f[0]), math.Max(math.Abs(f[1]), math.Abs(f[2]))))
+		}
+
+		quantisedMaximum := int(math.Max(0, math.Min(82, math.Floor(actualMaximum*166-0.5))))
+		maximumValue = float64(quantisedMaximum+1) / 166
+		result.WriteString(encodeBase83(quantisedMaximum, 1))
+	} else {
+		result.WriteString(encodeBase83(0, 1))
+	}
+
+	dc := factors[0]
+	result.WriteString(encodeBase83((linearToSrgb(dc[0])<<16)+(linearToSrgb(dc[1])<<8)+linearToSrgb(dc[2]), 4))
+
+	for _, f := range ac {
+		quantR := quantiseAC(f[0], maximumValue)
+		quantG := quantiseAC(f[1], maximumValue)
+		quantB := quantiseAC(f[2], maximumValue)
+
+		result.WriteString(encodeBase83(quantR*19*19+quantG*19+quantB, 2))
+	}
+
+	return result.String()
+}
+
+func blurHashFactor(linear [][3]float64, width, height, i, j int) [3]float64 {
+	var (
+		result [3]float64
+	)
+
+	normalisation := 2.0
+
+	if i == 0 && j == 0 {
+		normalisation = 1.0
+	}
+
+	for y := 0; y < height; y++ {
+		basisY := math.Cos(math.Pi * float64(j) * float64(y) / float64(height))
+
+		for x := 0; x < width; x++ {
+			basis := math.Cos(math.Pi*float64(i)*float64(x)/float64(width)) * basisY
+			pixel := linear[y*width+x]
+
+			result[0] += basis * pixel[0]
+			result[1] += basis * pixel[1]
+			result[2] += basis * pixel[2]
+		}
+	}
+
+	scale := normalisation / float64(width*height)
+
+	result[0] *= scale
+	result[1] *= scale
+	result[2] *= scale
+
+	return result
+}
+
+func quantiseAC(value, maximumValue float64) int {
+	return int(math.Max(0, math.Min(18, math.Floor(signPow(value/maximumValue, 0.5)*9+9.5))))
+}
+
+func encodeBase83(value, length int) string {
+	result := make([]byte, length)
+
+	for i := 1; i <= length; i++ {
+		digit := (value / int(math.Pow(83, float64(length-i)))) % 83
+		result[i-1] = blurHashCharacters[digit]
+	}
+
+	return string(result)
+}
+
+func srgbToLinear(value int) float64 {
+	v := float64(value) / 255
+
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+func linearToSrgb(value float64) int {
+	v := math.Max(0, math.Min(1, value))
+
+	if v <= 0.0031308 {
+		return int(v*12.92*255 + 0.5)
+	}
+
+	return int((1.055*math.Pow(v, 1/2.4)-0.055)*255 + 0.5)
+}
+
+func signPow(value, exp float64) float64 {
+	return math.Copysign(math.Pow(math.Abs(value), exp), value)
+}
@@ -1,6 +1,21 @@
 package cache
 
+import (
+	"github.com/adampresley/ownmyphotos/pkg/models"
+)
+
 type CacheCreator interface {
 	DoesExist(cacheFilePath string) bool
-	CreateCacheFile(originalFilePath string, cacheFilePath string) error
+	CreateCacheFile(originalFilePath string, cacheFilePath string) (CacheFileInfo, error)
+	ThumbnailSize() uint
+}
+
+/*
+CacheFileInfo describes details about an image gathered while
+its cache file was being created.
+*/
+type CacheFileInfo struct {
+	BlurHash      string
+	Colors        models.DbColorSlice
+	ThumbnailSize uint
 }
@@ -0,0 +1,56 @@
+package cache
+
+import (
+	"image"
+	"image/draw"
+	"math"
+
+	"github.com/nfnt/resize"
+)
+
+/*
+faceCropMargin is how much larger than the face a crop is, so it shows
+the whole head rather than just the face.
+*/
+const faceCropMargin = 1.6
+
+/*
+CropFace returns a square crop of an image around a face, resized to
+size pixels. The face's rectangle is given as fractions of the image's
+width and height, measured from its top left corner. Crops that would
+go past an edge of the image are moved inside it.
+*/
+func CropFace(img image.Image, x, y, width, height float64, size uint) image.Image {
+	bounds := img.Bounds()
+	imageWidth, imageHeight := float64(bounds.Dx()), float64(bounds.Dy())
+
+	side := math.Max(width*imageWidth, height*imageHeight) * faceCropMargin
+	side = math.Max(math.Min(side, math.Min(imageWidth, imageHeight)), 1)
+
+	centerX := (x + width/2) * imageWidth
+	centerY := (y + height/2) * imageHeight
+
+	left := math.Min(math.Max(centerX-side/2, 0), imageWidth-side)
+	top := math.Min(math.Max(centerY-side/2, 0), imageHeight-side)
+
+	crop := image.Rect(
+		bounds.Min.X+int(left),
+		bounds.Min.Y+int(top),
+		bounds.Min.X+int(left+side),
+		bounds.Min.Y+int(top+side),
+	).Intersect(bounds)
+
+	var cropped image.Image
+
+	if subImager, ok := img.(interface {
+		SubImage(r image.Rectangle) image.Image
+	}); ok {
+		cropped = subImager.SubImage(crop)
+	} else {
+		rgba := image.NewRGBA(image.Rect(0, 0, crop.Dx(), crop.Dy()))
+		draw.Draw(rgba, rgba.Bounds(), img, crop.Min, draw.Src)
+		cropped = rgba
+	}
+
+	return resize.Resize(size, size, cropped, resize.Lanczos3)
+}
@@ -21,6 +21,14 @@ func NewJpegCacheCreator(thumnailSize uint) JpegCacheCreator {
 	}
 }
 
+/*
+ThumbnailSize returns the size, along the longest edge, thumbnails
+are made at.
+*/
+func (c JpegCacheCreator) ThumbnailSize() uint {
+	return c.thumnailSize
+}
+
 func (c JpegCacheCreator) DoesExist(cacheFilePath string) bool {
 	if _, err := os.Stat(cacheFilePath); err == nil {
 		return true
@@ -29,33 +37,34 @@ func (c JpegCacheCreator) DoesExist(cacheFilePath string) bool {
 	return false
 }
 
-func (c JpegCacheCreator) CreateCacheFile(originalFilePath string, cacheFilePath string) error {
+func (c JpegCacheCreator) CreateCacheFile(originalFilePath string, cacheFilePath string) (CacheFileInfo, error) {
 	var (
-		err error
-		f   *os.File
-		out *os.File
-		img image.Image
+		err    error
+		f      *os.File
+		out    *os.File
+		img    image.Image
+		result = CacheFileInfo{}
 	)
 
 	if f, err = os.Open(originalFilePath); err != nil {
-		return fmt.Errorf("error opening source image %s: %w", originalFilePath, err)
+		return result, fmt.Errorf("error opening source image %s: %w", originalFilePath, err)
 	}
 
 	defer f.Close()
 
 	if img, _, err = image.Decode(f); err != nil {
-		return fmt.Errorf("error decoding image %s: %w", originalFilePath, err)
+		return result, fmt.Errorf("error decoding image %s: %w", originalFilePath, err)
 	}
 
 	/*
 	 * Create the output file and save the resized image
 	 */
 	if err = os.MkdirAll(filepath.Dir(cacheFilePath), 0755); err != nil {
-		return fmt.Errorf("error creating cache directory %s: %w", filepath.Dir(cacheFilePath), err)
+		return result, fmt.Errorf("error creating cache directory %s: %w", filepath.Dir(cacheFilePath), err)
 	}
 
 	if out, err = os.Create(cacheFilePath); err != nil {
-		return fmt.Errorf("error creating cache file %s: %w", cacheFilePath, err)
+		return result, fmt.Errorf("error creating cache file %s: %w", cacheFilePath, err)
 	}
 
 	defer out.Close()
@@ -66,13 +75,20 @@ func (c JpegCacheCreator) CreateCacheFile(originalFilePath string, cacheFilePath
 	switch ext {
 	case ".jpg", ".jpeg":
 		if err = jpeg.Encode(out, resizedImage, &jpeg.Options{Quality: 85}); err != nil {
-			return fmt.Errorf("error encoding JPEG image %s: %w", cacheFilePath, err)
+			return result, fmt.Errorf("error encoding JPEG image %s: %w", cacheFilePath, err)
 		}
 	default:
-		return fmt.Errorf("unsupported image format: %s", ext)
+		return result, fmt.Errorf("unsupported image format: %s", ext)
 	}
 
-	return nil
+	/*
+	 * The thumbnail is already in memory, so compute the placeholder
+	 * hash and palette from it rather than the much larger original.
+	 */
+	result.BlurHash = encodeBlurHash(resizedImage, blurHashXComponents, blurHashYComponents)
+	result.Colors = extractPalette(resizedImage)
+	result.ThumbnailSize = c.thumnailSize
+	return result, nil
 }
 
 func (c JpegCacheCreator) resize(img image.Image, maxSize uint) image.Image {
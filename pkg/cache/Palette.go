@@ -0,0 +1,190 @@
+package cache
+
+import (
+	"image"
+	"math"
+	"sort"
+
+	"github.com/adampresley/ownmyphotos/pkg/models"
+)
+
+const (
+	// paletteSamples is roughly how many pixels are looked at to find a palette
+	paletteSamples = 4096
+
+	// paletteBoxes is how many groups of similar pixels the palette starts from
+	paletteBoxes = 8
+
+	// paletteSize is the most colors a palette has
+	paletteSize = 6
+
+	// paletteMergeDistance is how close two colors must be to count as one
+	paletteMergeDistance = 40
+
+	// paletteMinWeight is the smallest part of a photo a color must cover to be in its palette
+	paletteMinWeight = 0.03
+)
+
+type paletteBox struct {
+	pixels [][3]uint8
+}
+
+/*
+extractPalette finds the dominant colors of an image, most dominant
+first, by median cut. A sample of the pixels is split into boxes of
+similar colors, each time cutting the box with the widest spread in
+two along its widest channel. Each box's average is a color of the
+palette, and boxes too close to tell apart are merged. The palette is
+never nil, so an image with no dominant colors isn't looked at again.
+*/
+func extractPalette(img image.Image) models.DbColorSlice {
+	bounds := img.Bounds()
+	result := models.DbColorSlice{}
+
+	if bounds.Empty() {
+		return result
+	}
+
+	step := max(1, int(math.Sqrt(float64(bounds.Dx()*bounds.Dy())/paletteSamples)))
+	pixels := make([][3]uint8, 0, paletteSamples)
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += step {
+		for x := bounds.Min.X; x < bounds.Max.X; x += step {
+			r, g, b, _ := img.At(x, y).RGBA()
+			pixels = append(pixels, [3]uint8{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8)})
+		}
+	}
+
+	boxes := []*paletteBox{{pixels: pixels}}
+
+	for len(boxes) < paletteBoxes {
+		widest, channel, spread := -1, 0, 0
+
+		for index, box := range boxes {
+			if len(box.pixels) < 2 {
+				continue
+			}
+
+			if c, s := box.widestChannel(); s*len(box.pixels) > spread {
+				widest, channel, spread = index, c, s*len(box.pixels)
+			}
+		}
+
+		if widest < 0 {
+			break
+		}
+
+		box := boxes[widest]
+
+		sort.Slice(box.pixels, func(i, j int) bool {
+			return box.pixels[i][channel] < box.pixels[j][channel]
+		})
+
+		middle := len(box.pixels) / 2
+		boxes[widest] = &paletteBox{pixels: box.pixels[:middle]}
+		boxes = append(boxes, &paletteBox{pixels: box.pixels[middle:]})
+	}
+
+	/*
+	 * Averages are kept as sums weighted by pixel count, so merging
+	 * two colors gives the average of all their pixels.
+	 */
+	type paletteColor struct {
+		sum   [3]int
+		count int
+	}
+
+	colors := []*paletteColor{}
+
+	for _, box := range boxes {
+		color := &paletteColor{count: len(box.pixels)}
+
+		for _, pixel := range box.pixels {
+			for channel := range pixel {
+				color.sum[channel] += int(pixel[channel])
+			}
+		}
+
+		colors = append(colors, color)
+	}
+
+	sort.SliceStable(colors, func(i, j int) bool {
+		return colors[i].count > colors[j].count
+	})
+
+	average := func(c *paletteColor) [3]int {
+		return [3]int{c.sum[0] / c.count, c.sum[1] / c.count, c.sum[2] / c.count}
+	}
+
+	merged := []*paletteColor{}
+
+	for _, color := range colors {
+		if color.count == 0 {
+			continue
+		}
+
+		a := average(color)
+		isMerged := false
+
+		for _, m := range merged {
+			b := average(m)
+			distance := (a[0]-b[0])*(a[0]-b[0]) + (a[1]-b[1])*(a[1]-b[1]) + (a[2]-b[2])*(a[2]-b[2])
+
+			if distance <= paletteMergeDistance*paletteMergeDistance {
+				for channel := range m.sum {
+					m.sum[channel] += color.sum[channel]
+				}
+
+				m.count += color.count
+				isMerged = true
+				break
+			}
+		}
+
+		if !isMerged {
+			merged = append(merged, color)
+		}
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].count > merged[j].count
+	})
+
+	for _, color := range merged {
+		weight := float64(color.count) / float64(len(pixels))
+
+		if len(result) == paletteSize || weight < paletteMinWeight {
+			break
+		}
+
+		rgb := average(color)
+		result = append(result, models.NewColor(uint8(rgb[0]), uint8(rgb[1]), uint8(rgb[2]), weight))
+	}
+
+	return result
+}
+
+/*
+widestChannel returns the channel whose values are most spread out in
+a box, and how far apart they are.
+*/
+func (b *paletteBox) widestChannel() (int, int) {
+	lowest, highest := [3]uint8{255, 255, 255}, [3]uint8{}
+
+	for _, pixel := range b.pixels {
+		for channel := range pixel {
+			lowest[channel] = min(lowest[channel], pixel[channel])
+			highest[channel] = max(highest[channel], pixel[channel])
+		}
+	}
+
+	channel, spread := 0, 0
+
+	for c := range highest {
+		if s := int(highest[c]) - int(lowest[c]); s > spread {
+			channel, spread = c, s
+		}
+	}
+
+	return channel, spread
+}
@@ -1,6 +1,7 @@
 package collector
 
 import (
+	"database/sql"
 	"errors"
 	"fmt"
 	"io"
@@ -15,6 +16,8 @@ import (
 	"github.com/adampresley/imagemetadata"
 	"github.com/adampresley/imagemetadata/imagemodel"
 	"github.com/adampresley/ownmyphotos/pkg/cache"
+	"github.com/adampresley/ownmyphotos/pkg/exposure"
+	"github.com/adampresley/ownmyphotos/pkg/metadata"
 	"github.com/adampresley/ownmyphotos/pkg/models"
 	"github.com/adampresley/ownmyphotos/pkg/services"
 	"github.com/alitto/pond/v2"
@@ -26,6 +29,7 @@ type JpegCollectorConfig struct {
 	FolderService services.FolderServicer
 	PhotoCache    services.PhotoCacher
 	PhotoService  services.PhotoServicer
+	PlaceService  services.PlaceServicer
 }
 
 type JpegCollector struct {
@@ -34,6 +38,7 @@ type JpegCollector struct {
 	folderService services.FolderServicer
 	photoCache    services.PhotoCacher
 	photoService  services.PhotoServicer
+	placeService  services.PlaceServicer
 
 	pool    pond.Pool
 	running bool
@@ -57,6 +62,7 @@ func NewJpegCollector(config JpegCollectorConfig) (*JpegCollector, error) {
 		folderService: config.FolderService,
 		photoCache:    config.PhotoCache,
 		photoService:  config.PhotoService,
+		placeService:  config.PlaceService,
 		running:       false,
 	}, nil
 }
@@ -67,6 +73,7 @@ func (c *JpegCollector) Run(settingsService services.SettingsServicer) ([]error,
 		processErrors []error
 		allPhotos     []*models.Photo
 		settings      *models.Settings
+		hasGazetteer  bool
 	)
 
 	if c.running {
@@ -97,11 +104,23 @@ func (c *JpegCollector) Run(settingsService services.SettingsServicer) ([]error,
 
 	slog.Info("retrieved all database photos", "count", len(allPhotos))
 
+	if hasGazetteer, err = c.placeService.HasGazetteer(); err != nil {
+		return []error{}, fmt.Errorf("error checking for a gazetteer: %w", err)
+	}
+
 	/*
 	 * Clean removed photos from the library and the database.
 	 */
 	processErrors = append(processErrors, c.cleanRemovedPhotos(settings, allPhotos)...)
-	processErrors = append(processErrors, c.syncPhotos(settings, allPhotos)...)
+	processErrors = append(processErrors, c.syncPhotos(settings, allPhotos, hasGazetteer)...)
+
+	/*
+	 * Photos collected before the gazetteer was imported are placed
+	 * now. Anything saved above was already placed.
+	 */
+	if _, err = c.placeService.ResolveMissingPlaces(); err != nil {
+		processErrors = append(processErrors, fmt.Errorf("could not resolve photo places: %w", err))
+	}
 
 	return processErrors, nil
 }
@@ -152,7 +171,7 @@ func (c *JpegCollector) cleanRemovedPhotos(settings *models.Settings, allPhotos
 	return errs
 }
 
-func (c *JpegCollector) syncPhotos(settings *models.Settings, allPhotos []*models.Photo) []error {
+func (c *JpegCollector) syncPhotos(settings *models.Settings, allPhotos []*models.Photo, hasGazetteer bool) []error {
 	var (
 		errs []error
 	)
@@ -164,15 +183,27 @@ func (c *JpegCollector) syncPhotos(settings *models.Settings, allPhotos []*model
 
 	filepath.WalkDir(settings.LibraryPath, func(path string, d os.DirEntry, err error) error {
 		var (
-			fileID    string
-			f         *os.File
-			imageData *imagemodel.ImageData
+			fileID         string
+			f              *os.File
+			imageData      *imagemodel.ImageData
+			cameraExposure models.Exposure
+			rating         metadata.Rating
+			description    metadata.Description
+			regions        metadata.FaceRegions
+			cacheInfo      cache.CacheFileInfo
 		)
 
 		if err != nil {
 			return err
 		}
 
+		/*
+		 * Photos in the trash are no longer part of the library.
+		 */
+		if d.IsDir() && path == services.GetTrashPath(settings.LibraryPath) {
+			return filepath.SkipDir
+		}
+
 		if d.IsDir() {
 			folder := strings.TrimPrefix(path, settings.LibraryPath)
 			parentPath := ""
@@ -256,6 +287,58 @@ func (c *JpegCollector) syncPhotos(settings *models.Settings, allPhotos []*model
 				return errs
 			}
 
+			/*
+			 * Shooting settings come from a second pass over the EXIF
+			 * data. Photos without any, such as scans, are still collected.
+			 */
+			if _, err = f.Seek(0, io.SeekStart); err != nil {
+				errs = append(errs, fmt.Errorf("could not rewind file '%s': %w", fullImagePath, err))
+				return errs
+			}
+
+			if cameraExposure, err = exposure.Read(f); err != nil {
+				slog.Debug("no exposure settings in photo", "path", fullImagePath, "error", err)
+			}
+
+			/*
+			 * Ratings and labels come from the XMP data, with the
+			 * sidecar, if any, taking precedence over the file.
+			 */
+			if _, err = f.Seek(0, io.SeekStart); err != nil {
+				errs = append(errs, fmt.Errorf("could not rewind file '%s': %w", fullImagePath, err))
+				return errs
+			}
+
+			if rating, err = metadata.ReadRating(f, fullImagePath); err != nil {
+				slog.Debug("could not read rating from photo", "path", fullImagePath, "error", err)
+			}
+
+			/*
+			 * Titles, captions, keywords, and people in the XMP data win
+			 * over the rest of the file's metadata, as that's where edits
+			 * made in this app are written.
+			 */
+			if _, err = f.Seek(0, io.SeekStart); err != nil {
+				errs = append(errs, fmt.Errorf("could not rewind file '%s': %w", fullImagePath, err))
+				return errs
+			}
+
+			if description, err = metadata.ReadDescription(f, fullImagePath); err != nil {
+				slog.Debug("could not read XMP description from photo", "path", fullImagePath, "error", err)
+			}
+
+			/*
+			 * Named face regions are read the same way.
+			 */
+			if _, err = f.Seek(0, io.SeekStart); err != nil {
+				errs = append(errs, fmt.Errorf("could not rewind file '%s': %w", fullImagePath, err))
+				return errs
+			}
+
+			if regions, err = metadata.ReadFaceRegions(f, fullImagePath); err != nil {
+				slog.Debug("could not read face regions from photo", "path", fullImagePath, "error", err)
+			}
+
 			/*
 			 * Find an existing photo, if any, in the database. This will help
 			 * us determine if we need to create a new record, or update an existing one.
@@ -272,8 +355,18 @@ func (c *JpegCollector) syncPhotos(settings *models.Settings, allPhotos []*model
 			filePhoto := models.NewPhotoFromImageData(
 				fullImagePath,
 				imageData,
+				cameraExposure,
 			)
 
+			applyDescription(filePhoto, description)
+			applyFaceRegions(filePhoto, regions)
+			filePhoto.Rating = models.ClampRating(rating.Stars)
+			filePhoto.Label = models.NewColorLabel(rating.Label)
+
+			if info, err := f.Stat(); err == nil {
+				filePhoto.EstimateCreationDateTime(info.ModTime())
+			}
+
 			if fileID, err = c.photoService.GetFileID(fullImagePath); err != nil {
 				errs = append(errs, fmt.Errorf("could not get file ID for '%s': %w", fullImagePath, err))
 				return errs
@@ -283,16 +376,53 @@ func (c *JpegCollector) syncPhotos(settings *models.Settings, allPhotos []*model
 				existingPhoto = &models.Photo{}
 			}
 
-			if existingPhoto.ID != fileID || existingPhoto.MetadataHash != filePhoto.MetadataHash {
-				action := "creating"
+			remakeThumbnail := c.needsNewThumbnail(existingPhoto, fileID, fullCachePath)
 
-				if err = c.cacheCreator.CreateCacheFile(fullImagePath, fullCachePath); err != nil {
-					errs = append(errs, fmt.Errorf("could not create cache file for '%s': %w", fullImagePath, err))
-					return errs
-				}
+			if remakeThumbnail || hasChanged(existingPhoto, filePhoto) {
+				action := "creating"
 
 				// Determine what we should do with the photo: update or create
 				filePhoto.ID = fileID
+				filePhoto.BlurHash = existingPhoto.BlurHash
+				filePhoto.ThumbnailCreatedAt = existingPhoto.ThumbnailCreatedAt
+				filePhoto.ThumbnailSize = existingPhoto.ThumbnailSize
+				filePhoto.Colors = existingPhoto.Colors
+
+				if remakeThumbnail {
+					if cacheInfo, err = c.cacheCreator.CreateCacheFile(fullImagePath, fullCachePath); err != nil {
+						errs = append(errs, fmt.Errorf("could not create cache file for '%s': %w", fullImagePath, err))
+						return errs
+					}
+
+					filePhoto.BlurHash = cacheInfo.BlurHash
+					filePhoto.ThumbnailCreatedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+					filePhoto.ThumbnailSize = int(cacheInfo.ThumbnailSize)
+					filePhoto.Colors = cacheInfo.Colors
+				}
+
+				/*
+				 * An empty palette is stored as an empty list rather than
+				 * NULL, so a photo with no dominant colors isn't taken for
+				 * one whose palette hasn't been worked out yet.
+				 */
+				if filePhoto.Colors == nil {
+					filePhoto.Colors = models.DbColorSlice{}
+				}
+
+				/*
+				 * Without a gazetteer the place is left empty, so it is
+				 * looked up once one is imported.
+				 */
+				if hasGazetteer {
+					placeID, err := c.placeService.ResolvePlaceID(filePhoto.Latitude, filePhoto.Longitude)
+
+					if err != nil {
+						errs = append(errs, fmt.Errorf("could not resolve the place for '%s': %w", fullImagePath, err))
+						return errs
+					}
+
+					filePhoto.PlaceID = &placeID
+				}
 
 				if existingPhoto.ID == fileID {
 					filePhoto.CreatedAt = existingPhoto.CreatedAt
@@ -307,12 +437,6 @@ func (c *JpegCollector) syncPhotos(settings *models.Settings, allPhotos []*model
 					errs = append(errs, fmt.Errorf("could not save photo '%s': %w", fileName, err))
 					return errs
 				}
-			} else if !c.cacheCreator.DoesExist(fullCachePath) {
-				slog.Info("creating cache file for photo", "path", fullCachePath)
-				if err = c.cacheCreator.CreateCacheFile(fullImagePath, fullCachePath); err != nil {
-					errs = append(errs, fmt.Errorf("could not create cache file for '%s': %w", fullImagePath, err))
-					return errs
-				}
 			}
 
 			return []error{}
@@ -330,6 +454,87 @@ func (c *JpegCollector) syncPhotos(settings *models.Settings, allPhotos []*model
 	return errs
 }
 
+/*
+needsNewThumbnail returns true when a photo's thumbnail, along with
+its placeholder hash and palette, needs to be made. They're only made
+again when the image may have changed or the thumbnail is missing or
+out of date, as decoding the photo is slow and gives the thumbnail a
+new URL.
+*/
+func (c *JpegCollector) needsNewThumbnail(existingPhoto *models.Photo, fileID, cachePath string) bool {
+	// A new file, or one rewritten by another app, has a new file ID
+	return existingPhoto.ID != fileID ||
+		// The thumbnail was removed from the cache
+		!c.cacheCreator.DoesExist(cachePath) ||
+		// Collected before placeholders. Photos without one have a thumbnail creation time
+		(existingPhoto.BlurHash == "" && !existingPhoto.ThumbnailCreatedAt.Valid) ||
+		// Collected before palettes. Photos without dominant colors have an empty one
+		existingPhoto.Colors == nil ||
+		// Made before the thumbnail size was changed. Unrecorded sizes are left alone
+		(existingPhoto.ThumbnailSize > 0 && existingPhoto.ThumbnailSize != int(c.cacheCreator.ThumbnailSize()))
+}
+
+/*
+hasChanged returns true when what was read from a photo's file
+differs from what's in the database, so the photo is saved again.
+*/
+func hasChanged(existingPhoto, filePhoto *models.Photo) bool {
+	// Titles, captions, keywords, people, and the rest of the descriptive metadata
+	return existingPhoto.MetadataHash != filePhoto.MetadataHash ||
+		// Face regions, which aren't part of the metadata hash
+		existingPhoto.FacesHash != filePhoto.FacesHash ||
+		// Collected before lens IDs were worked out
+		existingPhoto.LensID != filePhoto.LensID ||
+		// Collected before exposure settings were read
+		existingPhoto.Exposure != filePhoto.Exposure ||
+		// Ratings and labels can change in a sidecar on their own
+		existingPhoto.Rating != filePhoto.Rating ||
+		existingPhoto.Label != filePhoto.Label ||
+		// Estimated dates come from the file's modification time, which changes when it's touched
+		existingPhoto.DateIsEstimated != filePhoto.DateIsEstimated ||
+		(filePhoto.DateIsEstimated && !existingPhoto.CreationDateTime.Equal(filePhoto.CreationDateTime))
+}
+
+/*
+applyDescription replaces a photo's title, caption, keywords, and
+people with those found in its XMP data, leaving any that weren't
+found as the metadata library read them.
+*/
+func applyDescription(photo *models.Photo, description metadata.Description) {
+	if !description.HasTitle && !description.HasCaption && !description.HasKeywords && !description.HasPeople && !description.HasHierarchy {
+		return
+	}
+
+	edit := models.NewPhotoEdit(photo)
+
+	if description.HasTitle {
+		edit.Title = description.Title
+	}
+
+	if description.HasCaption {
+		edit.Caption = description.Caption
+	}
+
+	if description.HasKeywords {
+		edit.Keywords = description.Keywords
+	}
+
+	if description.HasPeople {
+		edit.People = description.People
+	}
+
+	/*
+	 * Lightroom usually lists every level of a hierarchical keyword
+	 * in the keywords as well, so the hierarchy mostly adds which
+	 * keyword sits under which.
+	 */
+	if description.HasHierarchy {
+		edit.Keywords = append(edit.Keywords, description.Hierarchy...)
+	}
+
+	photo.ApplyEdit(edit)
+}
+
 // cleanEmptyCacheDirectories removes empty cache directories recursively
 // It starts with the thumbnails directory and works its way up to parent directories
 func (c *JpegCollector) cleanEmptyCacheDirectories(libraryPath, thumbnailsDir string) error {
@@ -401,3 +606,24 @@ func isDirEmpty(dirPath string) (bool, error) {
 	}
 	return false, err // Either not empty or error
 }
+
+/*
+applyFaceRegions sets a photo's faces to the regions found in its XMP
+data. The faces are always set, even when there are none, so saving
+the photo replaces any it had before.
+*/
+func applyFaceRegions(photo *models.Photo, regions metadata.FaceRegions) {
+	photo.Faces = make([]*models.Face, 0, len(regions.Regions))
+
+	for _, region := range regions.Regions {
+		photo.Faces = append(photo.Faces, &models.Face{
+			Name:   region.Name,
+			X:      region.X,
+			Y:      region.Y,
+			Width:  region.Width,
+			Height: region.Height,
+		})
+	}
+
+	photo.FacesHash = photo.GenerateFacesHash()
+}
@@ -0,0 +1,214 @@
+package collector
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/adampresley/imagemetadata/imagemodel"
+	"github.com/adampresley/ownmyphotos/pkg/cache"
+	"github.com/adampresley/ownmyphotos/pkg/metadata"
+	"github.com/adampresley/ownmyphotos/pkg/models"
+)
+
+const regionsPacket = `<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about=""
+    xmlns:dc="http://purl.org/dc/elements/1.1/"
+    xmlns:mwg-rs="http://www.metadataworkinggroup.com/schemas/regions/"
+    xmlns:stArea="http://ns.adobe.com/xmp/sType/Area#">
+   <dc:title><rdf:Alt><rdf:li xml:lang="x-default">Picnic</rdf:li></rdf:Alt></dc:title>
+   <mwg-rs:Regions rdf:parseType="Resource">
+    <mwg-rs:RegionList>
+     <rdf:Bag>
+      <rdf:li mwg-rs:Name="Aunt Mary" mwg-rs:Type="Face">
+       <mwg-rs:Area stArea:x="0.5" stArea:y="0.4" stArea:w="0.2" stArea:h="0.3" stArea:unit="normalized"/>
+      </rdf:li>
+     </rdf:Bag>
+    </mwg-rs:RegionList>
+   </mwg-rs:Regions>
+  </rdf:Description>
+ </rdf:RDF>
+</x:xmpmeta>`
+
+/*
+writeTestJPEG writes a JPEG with no image, holding only an XMP packet.
+*/
+func writeTestJPEG(t *testing.T, path, packet string) {
+	t.Helper()
+
+	data := append([]byte("http://ns.adobe.com/xap/1.0/\x00"), packet...)
+	length := len(data) + 2
+
+	b := []byte{0xFF, 0xD8, 0xFF, 0xE1, byte(length >> 8), byte(length)}
+	b = append(b, data...)
+	b = append(b, 0xFF, 0xD9)
+
+	if err := os.WriteFile(path, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+/*
+collectTestPhoto reads a photo the way syncPhotos does, apart from
+the metadata library, whose values the XMP data replaces anyway.
+*/
+func collectTestPhoto(t *testing.T, path string) *models.Photo {
+	t.Helper()
+
+	f, err := os.Open(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+
+	description, err := metadata.ReadDescription(f, path)
+
+	if err != nil {
+		t.Fatalf("error reading the description: %v", err)
+	}
+
+	if _, err = f.Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	regions, err := metadata.ReadFaceRegions(f, path)
+
+	if err != nil {
+		t.Fatalf("error reading the face regions: %v", err)
+	}
+
+	photo := models.NewPhotoFromImageData(path, &imagemodel.ImageData{Width: 640, Height: 480}, models.Exposure{})
+	applyDescription(photo, description)
+	applyFaceRegions(photo, regions)
+
+	return photo
+}
+
+func TestEditedPhotoMatchesCollectedPhoto(t *testing.T) {
+	for _, target := range []models.MetadataWriteTarget{models.WriteToFile, models.WriteToSidecar} {
+		t.Run(string(target), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "picnic.jpg")
+			writeTestJPEG(t, path, regionsPacket)
+
+			collected := collectTestPhoto(t, path)
+
+			if len(collected.Faces) != 1 || collected.FacesHash == "" {
+				t.Fatalf("collected %d faces with hash %q, want 1 face", len(collected.Faces), collected.FacesHash)
+			}
+
+			/*
+			 * Photos loaded from the database don't have their faces.
+			 */
+			stored := *collected
+			stored.Faces = nil
+			stored.ApplyEdit(models.PhotoEdit{
+				Title:    "Picnic at the lake",
+				Keywords: []string{"Lake", "Summer"},
+				People:   []string{"Aunt Mary"},
+			})
+
+			description := metadata.Description{
+				Title:    stored.Title,
+				Keywords: stored.KeywordNames(),
+				People:   []string{"Aunt Mary"},
+			}
+
+			var err error
+
+			if target == models.WriteToFile {
+				err = metadata.WriteDescriptionToJPEG(path, description)
+			} else {
+				err = metadata.WriteDescriptionToSidecar(path, description)
+			}
+
+			if err != nil {
+				t.Fatalf("error writing the description: %v", err)
+			}
+
+			rescanned := collectTestPhoto(t, path)
+
+			if rescanned.MetadataHash != stored.MetadataHash {
+				t.Errorf("metadata hash after the edit = %s, the collector read %s", stored.MetadataHash, rescanned.MetadataHash)
+			}
+
+			if rescanned.FacesHash != collected.FacesHash {
+				t.Errorf("faces hash changed from %s to %s, though the faces didn't", collected.FacesHash, rescanned.FacesHash)
+			}
+		})
+	}
+}
+
+type fakeCacheCreator struct {
+	exists bool
+	size   uint
+}
+
+func (f fakeCacheCreator) DoesExist(cacheFilePath string) bool {
+	return f.exists
+}
+
+func (f fakeCacheCreator) CreateCacheFile(originalFilePath string, cacheFilePath string) (cache.CacheFileInfo, error) {
+	return cache.CacheFileInfo{ThumbnailSize: f.size}, nil
+}
+
+func (f fakeCacheCreator) ThumbnailSize() uint {
+	return f.size
+}
+
+func TestRescanDecisions(t *testing.T) {
+	collected := func() *models.Photo {
+		return &models.Photo{
+			ID:                 "42",
+			MetadataHash:       "1234",
+			BlurHash:           "LEHV6n",
+			Colors:             models.DbColorSlice{},
+			ThumbnailSize:      400,
+			ThumbnailCreatedAt: sql.NullTime{Time: time.Now(), Valid: true},
+			Rating:             2,
+		}
+	}
+
+	tests := []struct {
+		name          string
+		change        func(existing, file *models.Photo)
+		cacheExists   bool
+		wantThumbnail bool
+		wantSave      bool
+	}{
+		{name: "unchanged", change: func(e, f *models.Photo) {}, cacheExists: true},
+		{name: "rating", change: func(e, f *models.Photo) { f.Rating = 5 }, cacheExists: true, wantSave: true},
+		{name: "label", change: func(e, f *models.Photo) { f.Label = models.ColorLabel("red") }, cacheExists: true, wantSave: true},
+		{name: "exposure", change: func(e, f *models.Photo) { f.ISO = 800 }, cacheExists: true, wantSave: true},
+		{name: "faces", change: func(e, f *models.Photo) { f.FacesHash = "99" }, cacheExists: true, wantSave: true},
+		{name: "metadata", change: func(e, f *models.Photo) { f.MetadataHash = "5678" }, cacheExists: true, wantSave: true},
+		{name: "new file", change: func(e, f *models.Photo) { e.ID = "" }, cacheExists: true, wantThumbnail: true},
+		{name: "missing thumbnail", change: func(e, f *models.Photo) {}, wantThumbnail: true},
+		{name: "no palette yet", change: func(e, f *models.Photo) { e.Colors = nil }, cacheExists: true, wantThumbnail: true},
+		{name: "no placeholder yet", change: func(e, f *models.Photo) { e.BlurHash, e.ThumbnailCreatedAt = "", sql.NullTime{} }, cacheExists: true, wantThumbnail: true},
+		{name: "no placeholder possible", change: func(e, f *models.Photo) { e.BlurHash = "" }, cacheExists: true},
+		{name: "thumbnail size changed", change: func(e, f *models.Photo) { e.ThumbnailSize = 300 }, cacheExists: true, wantThumbnail: true},
+		{name: "thumbnail size unknown", change: func(e, f *models.Photo) { e.ThumbnailSize = 0 }, cacheExists: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			existing, file := collected(), collected()
+			tt.change(existing, file)
+
+			c := &JpegCollector{cacheCreator: fakeCacheCreator{exists: tt.cacheExists, size: 400}}
+
+			if got := c.needsNewThumbnail(existing, "42", "thumbnail.jpg"); got != tt.wantThumbnail {
+				t.Errorf("needsNewThumbnail() = %v, want %v", got, tt.wantThumbnail)
+			}
+
+			if got := hasChanged(existing, file); got != tt.wantSave {
+				t.Errorf("hasChanged() = %v, want %v", got, tt.wantSave)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package events
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// MaxGap is the longest time between two photos of the same event
+	MaxGap = 18 * time.Hour
+
+	// TravelGap is the longest time between two photos of the same event taken far apart
+	TravelGap = 2 * time.Hour
+
+	// TravelDistance is how far apart, in kilometers, two photos must be taken to count as far apart
+	TravelDistance = 30.0
+
+	// MaxDuration is how long an event can go on before a night's break starts a new one
+	MaxDuration = 7 * 24 * time.Hour
+
+	// NightGap is the shortest break counted as a night's break
+	NightGap = 6 * time.Hour
+)
+
+/*
+Moment is when, and where, a photo was taken. Photos without a
+location have a latitude and longitude of 0.
+*/
+type Moment struct {
+	Time      time.Time
+	Latitude  float64
+	Longitude float64
+}
+
+/*
+HasLocation returns true when the photo was taken somewhere known.
+*/
+func (m Moment) HasLocation() bool {
+	return m.Latitude != 0 || m.Longitude != 0
+}
+
+/*
+Split groups moments, which must be in the order they were taken,
+into events, returning the indexes of the moments in each. A new
+event starts when:
+
+  - there's a long break between two photos, of more than MaxGap
+  - there's a shorter break, of more than TravelGap, and the next
+    photo was taken more than TravelDistance from the last one with
+    a location
+  - the event has gone on longer than MaxDuration, and there's a
+    night's break, so a long stretch of everyday photos reads as a
+    week at a time rather than one event
+*/
+func Split(moments []Moment) [][]int {
+	var (
+		result   [][]int
+		current  []int
+		start    time.Time
+		previous Moment
+		located  *Moment
+	)
+
+	for index, moment := range moments {
+		if len(current) > 0 && isNewEvent(start, previous, located, moment) {
+			result = append(result, current)
+			current, located = nil, nil
+		}
+
+		if len(current) == 0 {
+			start = moment.Time
+		}
+
+		current = append(current, index)
+		previous = moment
+
+		if moment.HasLocation() {
+			located = &moments[index]
+		}
+	}
+
+	if len(current) > 0 {
+		result = append(result, current)
+	}
+
+	return result
+}
+
+func isNewEvent(start time.Time, previous Moment, located *Moment, next Moment) bool {
+	gap := next.Time.Sub(previous.Time)
+
+	switch {
+	case gap > MaxGap:
+		return true
+
+	case gap > NightGap && next.Time.Sub(start) > MaxDuration:
+		return true
+
+	case gap > TravelGap && located != nil && next.HasLocation():
+		return Distance(*located, next) > TravelDistance
+	}
+
+	return false
+}
+
+/*
+Distance returns how far apart, in kilometers, two moments were taken.
+*/
+func Distance(a, b Moment) float64 {
+	const earthRadius = 6371.0
+
+	lat1, lat2 := a.Latitude*math.Pi/180, b.Latitude*math.Pi/180
+	deltaLat := lat2 - lat1
+	deltaLon := (b.Longitude - a.Longitude) * math.Pi / 180
+
+	h := math.Sin(deltaLat/2)*math.Sin(deltaLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(deltaLon/2)*math.Sin(deltaLon/2)
+
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
@@ -0,0 +1,133 @@
+package events
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var (
+	start      = time.Date(2023, time.July, 1, 9, 0, 0, 0, time.UTC)
+	memphis    = Moment{Latitude: 35.1495, Longitude: -90.0490}
+	germantown = Moment{Latitude: 35.0868, Longitude: -89.8101}
+	nashville  = Moment{Latitude: 36.1627, Longitude: -86.7816}
+)
+
+func at(hours float64, place Moment) Moment {
+	place.Time = start.Add(time.Duration(hours * float64(time.Hour)))
+	return place
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name    string
+		moments []Moment
+		want    [][]int
+	}{
+		{
+			name:    "no photos",
+			moments: []Moment{},
+			want:    nil,
+		},
+		{
+			name:    "one photo",
+			moments: []Moment{at(0, Moment{})},
+			want:    [][]int{{0}},
+		},
+		{
+			name:    "short gaps",
+			moments: []Moment{at(0, Moment{}), at(1, Moment{}), at(12, Moment{})},
+			want:    [][]int{{0, 1, 2}},
+		},
+		{
+			name:    "long gap",
+			moments: []Moment{at(0, Moment{}), at(1, Moment{}), at(20, Moment{})},
+			want:    [][]int{{0, 1}, {2}},
+		},
+		{
+			name:    "travel gap far away",
+			moments: []Moment{at(0, memphis), at(3, nashville)},
+			want:    [][]int{{0}, {1}},
+		},
+		{
+			name:    "travel gap close by",
+			moments: []Moment{at(0, memphis), at(3, germantown)},
+			want:    [][]int{{0, 1}},
+		},
+		{
+			name:    "short gap far away",
+			moments: []Moment{at(0, memphis), at(1, nashville)},
+			want:    [][]int{{0, 1}},
+		},
+		{
+			name:    "travel gap without a location",
+			moments: []Moment{at(0, memphis), at(3, Moment{}), at(6, Moment{})},
+			want:    [][]int{{0, 1, 2}},
+		},
+		{
+			name:    "distance from the last located photo",
+			moments: []Moment{at(0, memphis), at(1, Moment{}), at(4, nashville)},
+			want:    [][]int{{0, 1}, {2}},
+		},
+		{
+			name:    "long event breaks at night",
+			moments: everyHours(12, 16),
+			want:    [][]int{{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14}, {15}},
+		},
+		{
+			name:    "long event without a night's break",
+			moments: everyHours(4, 44),
+			want:    [][]int{indexes(0, 43)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Split(tt.moments); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Split() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b Moment
+		want float64
+	}{
+		{name: "same place", a: memphis, b: memphis, want: 0},
+		{name: "memphis to nashville", a: memphis, b: nashville, want: 316},
+		{name: "nashville to memphis", a: nashville, b: memphis, want: 316},
+		{name: "quarter of the equator", a: Moment{}, b: Moment{Longitude: 90}, want: 10008},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Distance(tt.a, tt.b); math.Abs(got-tt.want) > 1 {
+				t.Errorf("Distance() = %.1f, want %.1f", got, tt.want)
+			}
+		})
+	}
+}
+
+func everyHours(hours float64, count int) []Moment {
+	result := make([]Moment, count)
+
+	for i := range result {
+		result[i] = at(float64(i)*hours, Moment{})
+	}
+
+	return result
+}
+
+func indexes(from, to int) []int {
+	result := []int{}
+
+	for i := from; i <= to; i++ {
+		result = append(result, i)
+	}
+
+	return result
+}
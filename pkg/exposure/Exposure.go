@@ -0,0 +1,81 @@
+package exposure
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/adampresley/ownmyphotos/pkg/models"
+	"github.com/rwcarlsen/goexif/exif"
+	"github.com/rwcarlsen/goexif/tiff"
+)
+
+/*
+Read extracts the shooting settings from a JPEG's EXIF data. Settings
+the camera didn't record are left at zero. An error is returned when
+the image has no EXIF data that can be read.
+*/
+func Read(r io.Reader) (models.Exposure, error) {
+	var (
+		err    error
+		x      *exif.Exif
+		result models.Exposure
+	)
+
+	if x, err = exif.Decode(r); err != nil && exif.IsCriticalError(err) {
+		return result, fmt.Errorf("error decoding EXIF data: %w", err)
+	}
+
+	result.FNumber = readFloat(x, exif.FNumber)
+	result.ExposureTime = readFloat(x, exif.ExposureTime)
+	result.ISO = readInt(x, exif.ISOSpeedRatings)
+	result.FocalLength = readFloat(x, exif.FocalLength)
+	result.FocalLength35mm = readInt(x, exif.FocalLengthIn35mmFilm)
+	result.Flash = readInt(x, exif.Flash)
+	result.ExposureProgram = readInt(x, exif.ExposureProgram)
+
+	return result, nil
+}
+
+/*
+readFloat returns a rational EXIF value as a float, or 0 if the tag is
+missing or malformed.
+*/
+func readFloat(x *exif.Exif, name exif.FieldName) float64 {
+	tag, err := x.Get(name)
+
+	if err != nil {
+		return 0
+	}
+
+	if tag.Format() != tiff.RatVal {
+		value, _ := tag.Float(0)
+		return value
+	}
+
+	num, den, err := tag.Rat2(0)
+
+	if err != nil || den == 0 {
+		return 0
+	}
+
+	return float64(num) / float64(den)
+}
+
+/*
+readInt returns an integer EXIF value, or 0 if the tag is missing or
+malformed.
+*/
+func readInt(x *exif.Exif, name exif.FieldName) int {
+	tag, err := x.Get(name)
+
+	if err != nil {
+		return 0
+	}
+
+	if tag.Format() != tiff.IntVal {
+		return 0
+	}
+
+	value, _ := tag.Int(0)
+	return value
+}
@@ -0,0 +1,92 @@
+package faces
+
+import (
+	"sort"
+)
+
+/*
+MatchThreshold is the largest distance between two descriptors still
+counted as the same person.
+*/
+const MatchThreshold = 0.45
+
+/*
+Cluster groups descriptors that look like the same person, returning
+the indexes of the descriptors in each group, biggest group first.
+Each descriptor joins the group whose average it's closest to, if
+that's within MatchThreshold, or starts a group of its own.
+*/
+func Cluster(descriptors []Descriptor) [][]int {
+	var (
+		groups    [][]int
+		centroids []Descriptor
+	)
+
+	for index, descriptor := range descriptors {
+		if len(descriptor) == 0 {
+			continue
+		}
+
+		closest, distance := Closest(descriptor, centroids)
+
+		if closest < 0 || distance > MatchThreshold {
+			groups = append(groups, []int{index})
+			centroids = append(centroids, append(Descriptor{}, descriptor...))
+			continue
+		}
+
+		/*
+		 * The group's average moves toward each descriptor that joins
+		 * it.
+		 */
+		groups[closest] = append(groups[closest], index)
+		weight := float32(1) / float32(len(groups[closest]))
+
+		for i := range centroids[closest] {
+			centroids[closest][i] += (descriptor[i] - centroids[closest][i]) * weight
+		}
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		return len(groups[i]) > len(groups[j])
+	})
+
+	return groups
+}
+
+/*
+Closest returns the index of the candidate closest to a descriptor,
+and how far away it is. The index is -1 when there are no candidates.
+*/
+func Closest(descriptor Descriptor, candidates []Descriptor) (int, float64) {
+	closest, closestDistance := -1, 0.0
+
+	for index, candidate := range candidates {
+		if distance := descriptor.Distance(candidate); closest < 0 || distance < closestDistance {
+			closest, closestDistance = index, distance
+		}
+	}
+
+	return closest, closestDistance
+}
+
+/*
+Mean returns the average of several descriptors.
+*/
+func Mean(descriptors []Descriptor) Descriptor {
+	if len(descriptors) == 0 {
+		return nil
+	}
+
+	result := make(Descriptor, len(descriptors[0]))
+
+	for _, descriptor := range descriptors {
+		for i := range result {
+			if i < len(descriptor) {
+				result[i] += descriptor[i] / float32(len(descriptors))
+			}
+		}
+	}
+
+	return result
+}
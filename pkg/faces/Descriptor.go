@@ -0,0 +1,230 @@
+package faces
+
+import (
+	"encoding/binary"
+	"image"
+	"math"
+
+	"github.com/nfnt/resize"
+)
+
+const (
+	// descriptorSize is the size, in pixels, faces are scaled to before they're described
+	descriptorSize = 48
+
+	// descriptorGrid is how many cells across and down a face is split into
+	descriptorGrid = 4
+
+	// patternBins is the number of uniform local binary patterns, plus one bin for the rest
+	patternBins = 59
+)
+
+/*
+patternBin maps each local binary pattern to its histogram bin.
+Patterns with at most two changes between 0 and 1 around the circle,
+called uniform patterns, get a bin each. They are the edges, corners,
+and spots that make up most of a face. Every other pattern shares the
+last bin.
+*/
+var patternBin = func() [256]uint8 {
+	var (
+		result [256]uint8
+		next   uint8
+	)
+
+	for pattern := 0; pattern < 256; pattern++ {
+		rotated := (pattern >> 1) | ((pattern & 1) << 7)
+		changes := 0
+
+		for xor := pattern ^ rotated; xor > 0; xor &= xor - 1 {
+			changes++
+		}
+
+		if changes <= 2 {
+			result[pattern] = next
+			next++
+			continue
+		}
+
+		result[pattern] = patternBins - 1
+	}
+
+	return result
+}()
+
+/*
+Descriptor describes a face so it can be compared with others. It is
+made of histograms of local binary patterns, one for each cell of a
+grid over the face, which capture the face's texture and layout
+while ignoring its overall brightness. It's a long way from the
+neural networks dedicated face recognition uses, but it runs anywhere
+and is good enough to group photos of the same person taken in
+similar light.
+*/
+type Descriptor []float32
+
+/*
+Describe returns the descriptor of a face in an image. The face's
+rectangle is given as fractions of the image's width and height,
+measured from its top left corner.
+*/
+func Describe(img image.Image, x, y, width, height float64) Descriptor {
+	bounds := img.Bounds()
+
+	crop := image.Rect(
+		bounds.Min.X+int(x*float64(bounds.Dx())),
+		bounds.Min.Y+int(y*float64(bounds.Dy())),
+		bounds.Min.X+int((x+width)*float64(bounds.Dx())),
+		bounds.Min.Y+int((y+height)*float64(bounds.Dy())),
+	).Intersect(bounds)
+
+	if crop.Empty() {
+		return nil
+	}
+
+	face := image.NewGray(crop)
+
+	for py := crop.Min.Y; py < crop.Max.Y; py++ {
+		for px := crop.Min.X; px < crop.Max.X; px++ {
+			face.Set(px, py, img.At(px, py))
+		}
+	}
+
+	scaled, ok := resize.Resize(descriptorSize, descriptorSize, face, resize.Bilinear).(*image.Gray)
+
+	if !ok {
+		return nil
+	}
+
+	pixels := equalize(scaled.Pix)
+	cellSize := descriptorSize / descriptorGrid
+	result := make(Descriptor, descriptorGrid*descriptorGrid*patternBins)
+
+	/*
+	 * Each pixel's pattern compares it with its eight neighbours, so
+	 * the outermost pixels, which don't have all eight, are skipped.
+	 */
+	for py := 1; py < descriptorSize-1; py++ {
+		for px := 1; px < descriptorSize-1; px++ {
+			center := pixels[py*descriptorSize+px]
+			pattern := 0
+
+			for bit, offset := range [8][2]int{{-1, -1}, {0, -1}, {1, -1}, {1, 0}, {1, 1}, {0, 1}, {-1, 1}, {-1, 0}} {
+				if pixels[(py+offset[1])*descriptorSize+px+offset[0]] >= center {
+					pattern |= 1 << bit
+				}
+			}
+
+			cell := (py/cellSize)*descriptorGrid + px/cellSize
+			result[cell*patternBins+int(patternBin[pattern])]++
+		}
+	}
+
+	/*
+	 * Each cell's histogram is made to add up to one, so cells at the
+	 * edge, which have fewer pixels, count as much as the others.
+	 */
+	for cell := 0; cell < descriptorGrid*descriptorGrid; cell++ {
+		histogram := result[cell*patternBins : (cell+1)*patternBins]
+		total := float32(0)
+
+		for _, count := range histogram {
+			total += count
+		}
+
+		for index := range histogram {
+			histogram[index] /= total
+		}
+	}
+
+	return result
+}
+
+/*
+Distance returns how different two descriptors are, from 0 for the
+same face to 2 for faces with nothing in common. Descriptors of
+different lengths are as different as can be.
+*/
+func (d Descriptor) Distance(other Descriptor) float64 {
+	if len(d) != len(other) || len(d) == 0 {
+		return 2
+	}
+
+	sum := 0.0
+
+	for index := range d {
+		a, b := float64(d[index]), float64(other[index])
+
+		if a+b > 0 {
+			sum += (a - b) * (a - b) / (a + b)
+		}
+	}
+
+	return sum / (descriptorGrid * descriptorGrid)
+}
+
+/*
+Bytes returns the descriptor in the form it's stored in the database.
+*/
+func (d Descriptor) Bytes() []byte {
+	result := make([]byte, 4*len(d))
+
+	for index, value := range d {
+		binary.LittleEndian.PutUint32(result[index*4:], math.Float32bits(value))
+	}
+
+	return result
+}
+
+/*
+DescriptorFromBytes reads a descriptor stored in the database.
+*/
+func DescriptorFromBytes(b []byte) Descriptor {
+	result := make(Descriptor, len(b)/4)
+
+	for index := range result {
+		result[index] = math.Float32frombits(binary.LittleEndian.Uint32(b[index*4:]))
+	}
+
+	return result
+}
+
+/*
+equalize spreads gray levels over the whole range, so faces in dim or
+flat light are described like those in good light.
+*/
+func equalize(pixels []uint8) []uint8 {
+	var (
+		histogram [256]int
+		lowest    int
+	)
+
+	for _, value := range pixels {
+		histogram[value]++
+	}
+
+	cumulative := [256]int{}
+	total := 0
+
+	for level, count := range histogram {
+		total += count
+		cumulative[level] = total
+
+		if lowest == 0 && total > 0 {
+			lowest = total
+		}
+	}
+
+	result := make([]uint8, len(pixels))
+
+	if total == lowest {
+		copy(result, pixels)
+		return result
+	}
+
+	for index, value := range pixels {
+		result[index] = uint8(math.Round(float64(cumulative[value]-lowest) * 255 / float64(total-lowest)))
+	}
+
+	return result
+}
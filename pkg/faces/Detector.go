@@ -0,0 +1,135 @@
+package faces
+
+import (
+	_ "embed"
+	"fmt"
+	"image"
+	"image/color"
+
+	pigo "github.com/esimov/pigo/core"
+)
+
+/*
+facefinder is the frontal face cascade from pigo
+(https://github.com/esimov/pigo), which is MIT licensed, as found in
+LICENSE-pigo. It is bundled with the binary, so finding faces needs
+nothing but the CPU.
+*/
+//go:embed facefinder
+var facefinder []byte
+
+const (
+	// minFaceSize is the smallest face looked for, in pixels
+	minFaceSize = 20
+
+	// minScore is the lowest detection score kept. Lower scores are mostly things that aren't faces
+	minScore = 5.0
+
+	// overlapThreshold is how much detections must overlap to be counted as the same face
+	overlapThreshold = 0.2
+)
+
+/*
+Detection is a face found in an image. X, Y, Width, and Height are
+fractions of the image's width and height, measured from its top left
+corner. Score is how sure the detector is that it's a face.
+*/
+type Detection struct {
+	X      float64
+	Y      float64
+	Width  float64
+	Height float64
+	Score  float64
+}
+
+/*
+Detector finds faces in images. One detector can be used by several
+goroutines at once.
+*/
+type Detector struct {
+	classifier *pigo.Pigo
+}
+
+func NewDetector() (*Detector, error) {
+	classifier, err := pigo.NewPigo().Unpack(facefinder)
+
+	if err != nil {
+		return nil, fmt.Errorf("error reading the face detection cascade: %w", err)
+	}
+
+	return &Detector{
+		classifier: classifier,
+	}, nil
+}
+
+/*
+Detect returns the faces in an image. Only faces looking toward the
+camera are found, and faces smaller than 20 pixels are missed, so a
+thumbnail of a few hundred pixels finds most of the faces worth
+naming.
+*/
+func (d *Detector) Detect(img image.Image) []Detection {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	result := []Detection{}
+
+	if min(width, height) < minFaceSize {
+		return result
+	}
+
+	params := pigo.CascadeParams{
+		MinSize:     minFaceSize,
+		MaxSize:     min(width, height),
+		ShiftFactor: 0.1,
+		ScaleFactor: 1.1,
+		ImageParams: pigo.ImageParams{
+			Pixels: grayscale(img),
+			Rows:   height,
+			Cols:   width,
+			Dim:    width,
+		},
+	}
+
+	detections := d.classifier.ClusterDetections(d.classifier.RunCascade(params, 0), overlapThreshold)
+
+	for _, detection := range detections {
+		if detection.Q < minScore {
+			continue
+		}
+
+		/*
+		 * Detections are squares given by their center.
+		 */
+		size := float64(detection.Scale)
+		left := max(float64(detection.Col)-size/2, 0)
+		top := max(float64(detection.Row)-size/2, 0)
+		right := min(float64(detection.Col)+size/2, float64(width))
+		bottom := min(float64(detection.Row)+size/2, float64(height))
+
+		result = append(result, Detection{
+			X:      left / float64(width),
+			Y:      top / float64(height),
+			Width:  (right - left) / float64(width),
+			Height: (bottom - top) / float64(height),
+			Score:  float64(detection.Q),
+		})
+	}
+
+	return result
+}
+
+/*
+grayscale returns an image's pixels as 8 bit gray levels, row by row.
+*/
+func grayscale(img image.Image) []uint8 {
+	bounds := img.Bounds()
+	result := make([]uint8, 0, bounds.Dx()*bounds.Dy())
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			result = append(result, color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,190 @@
+/*
+Package gazetteer reads the GeoNames dump files used for offline
+reverse geocoding. The files are available from
+https://download.geonames.org/export/dump/. A directory holding a
+cities file (such as cities15000.txt), admin1CodesASCII.txt, and
+countryInfo.txt is enough to name the places photos were taken.
+*/
+package gazetteer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	Admin1FileName  = "admin1CodesASCII.txt"
+	CountryFileName = "countryInfo.txt"
+)
+
+type Country struct {
+	CountryCode string
+	Name        string
+}
+
+type Region struct {
+	CountryCode string
+	Admin1Code  string
+	Name        string
+}
+
+type City struct {
+	GeonameID   int64
+	Name        string
+	Latitude    float64
+	Longitude   float64
+	CountryCode string
+	Admin1Code  string
+	Population  int64
+}
+
+/*
+FindCitiesFile returns the path to the GeoNames cities file in a
+directory. When there are several, such as cities500.txt and
+cities15000.txt, the one with the most detail is used.
+*/
+func FindCitiesFile(dir string) (string, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "cities*.txt"))
+
+	if err != nil {
+		return "", fmt.Errorf("error looking for a cities file in '%s': %w", dir, err)
+	}
+
+	if len(matches) == 0 {
+		return "", fmt.Errorf("no GeoNames cities file (cities*.txt) found in '%s'", dir)
+	}
+
+	/*
+	 * The number in the file name is the minimum population, so the
+	 * smallest number has the most cities.
+	 */
+	sort.Slice(matches, func(i, j int) bool {
+		return minimumPopulation(matches[i]) < minimumPopulation(matches[j])
+	})
+
+	return matches[0], nil
+}
+
+/*
+ReadCountries reads countryInfo.txt, calling fn for each country.
+*/
+func ReadCountries(path string, fn func(country Country) error) error {
+	return readFile(path, 5, func(fields []string) error {
+		return fn(Country{
+			CountryCode: fields[0],
+			Name:        fields[4],
+		})
+	})
+}
+
+/*
+ReadRegions reads admin1CodesASCII.txt, calling fn for each first
+level administrative division, such as a state or province.
+*/
+func ReadRegions(path string, fn func(region Region) error) error {
+	return readFile(path, 2, func(fields []string) error {
+		countryCode, admin1Code, ok := strings.Cut(fields[0], ".")
+
+		if !ok {
+			return nil
+		}
+
+		return fn(Region{
+			CountryCode: countryCode,
+			Admin1Code:  admin1Code,
+			Name:        fields[1],
+		})
+	})
+}
+
+/*
+ReadCities reads a GeoNames cities file, calling fn for each city.
+*/
+func ReadCities(path string, fn func(city City) error) error {
+	return readFile(path, 15, func(fields []string) error {
+		var (
+			err  error
+			city City
+		)
+
+		if city.GeonameID, err = strconv.ParseInt(fields[0], 10, 64); err != nil {
+			return fmt.Errorf("invalid geoname ID '%s': %w", fields[0], err)
+		}
+
+		if city.Latitude, err = strconv.ParseFloat(fields[4], 64); err != nil {
+			return fmt.Errorf("invalid latitude '%s' for geoname %d: %w", fields[4], city.GeonameID, err)
+		}
+
+		if city.Longitude, err = strconv.ParseFloat(fields[5], 64); err != nil {
+			return fmt.Errorf("invalid longitude '%s' for geoname %d: %w", fields[5], city.GeonameID, err)
+		}
+
+		city.Name = fields[1]
+		city.CountryCode = fields[8]
+		city.Admin1Code = fields[10]
+		city.Population, _ = strconv.ParseInt(fields[14], 10, 64)
+
+		return fn(city)
+	})
+}
+
+/*
+readFile calls fn with the tab separated fields of each line in a
+GeoNames file. Comments and lines with fewer than minFields fields
+are skipped.
+*/
+func readFile(path string, minFields int, fn func(fields []string) error) error {
+	var (
+		err error
+		f   *os.File
+	)
+
+	if f, err = os.Open(path); err != nil {
+		return fmt.Errorf("error opening '%s': %w", path, err)
+	}
+
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	lineNumber := 0
+
+	for {
+		line, readErr := reader.ReadString('\n')
+		lineNumber++
+
+		if readErr != nil && readErr != io.EOF {
+			return fmt.Errorf("error reading '%s': %w", path, readErr)
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+
+		if line != "" && !strings.HasPrefix(line, "#") {
+			if fields := strings.Split(line, "\t"); len(fields) >= minFields {
+				if err = fn(fields); err != nil {
+					return fmt.Errorf("error on line %d of '%s': %w", lineNumber, path, err)
+				}
+			}
+		}
+
+		if readErr == io.EOF {
+			return nil
+		}
+	}
+}
+
+func minimumPopulation(path string) int {
+	name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), "cities"), ".txt")
+	result, err := strconv.Atoi(name)
+
+	if err != nil {
+		return 0
+	}
+
+	return result
+}
@@ -0,0 +1,542 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const (
+	dcNamespace         = "http://purl.org/dc/elements/1.1/"
+	iptcExtNamespace    = "http://iptc.org/std/Iptc4xmpExt/2008-02-29/"
+	lightroomNamespace  = "http://ns.adobe.com/lightroom/1.0/"
+	rdfNamespace        = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	newXMPPacketHeader  = "<?xpacket begin=\"\uFEFF\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n"
+	newXMPPacketTrailer = "<?xpacket end=\"w\"?>"
+)
+
+var (
+	ErrSegmentTooLarge  = errors.New("the metadata is too large to fit in a JPEG segment")
+	ErrDefaultNamespace = errors.New("the XMP data declares a property namespace as the default, which can't be updated")
+
+	namespaceDeclarationPattern = regexp.MustCompile(`\sxmlns(?::([\w.-]+))?\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+)
+
+/*
+Description is the descriptive metadata in a photo's XMP data: its
+title (dc:title), caption (dc:description), keywords (dc:subject), the
+people in it (Iptc4xmpExt:PersonInImage), and Lightroom's hierarchical
+keywords (lr:hierarchicalSubject), such as "Places|Italy|Rome". The
+Has fields are false when the XMP didn't include a value, which is
+different from including an empty one.
+*/
+type Description struct {
+	Title        string
+	Caption      string
+	Keywords     []string
+	People       []string
+	Hierarchy    []string
+	HasTitle     bool
+	HasCaption   bool
+	HasKeywords  bool
+	HasPeople    bool
+	HasHierarchy bool
+}
+
+/*
+merge returns this description with any values set in other taking
+their place.
+*/
+func (d Description) merge(other Description) Description {
+	if other.HasTitle {
+		d.Title, d.HasTitle = other.Title, true
+	}
+
+	if other.HasCaption {
+		d.Caption, d.HasCaption = other.Caption, true
+	}
+
+	if other.HasKeywords {
+		d.Keywords, d.HasKeywords = other.Keywords, true
+	}
+
+	if other.HasPeople {
+		d.People, d.HasPeople = other.People, true
+	}
+
+	if other.HasHierarchy {
+		d.Hierarchy, d.HasHierarchy = other.Hierarchy, true
+	}
+
+	return d
+}
+
+/*
+ReadDescription reads the title, caption, keywords, and people from
+the XMP packet in a JPEG, then from the photo's sidecar, if it has
+one. As with ratings, values in the sidecar win.
+*/
+func ReadDescription(jpeg io.Reader, photoPath string) (Description, error) {
+	var (
+		err    error
+		b      []byte
+		result Description
+	)
+
+	err = readSegments(jpeg, func(marker byte, data []byte) {
+		if marker == markerAPP1 && bytes.HasPrefix(data, xmpHeader) {
+			result = result.merge(parseDescription(data[len(xmpHeader):]))
+		}
+	})
+
+	if err != nil {
+		return result, err
+	}
+
+	if sidecarPath := FindSidecar(photoPath); sidecarPath != "" {
+		if b, err = os.ReadFile(sidecarPath); err != nil {
+			return result, fmt.Errorf("error reading XMP sidecar '%s': %w", sidecarPath, err)
+		}
+
+		result = result.merge(parseDescription(b))
+	}
+
+	return result, nil
+}
+
+/*
+WriteDescriptionToSidecar stores a photo's title, caption, keywords,
+and people in its XMP sidecar, leaving the JPEG untouched. The sidecar
+is created if the photo doesn't have one.
+*/
+func WriteDescriptionToSidecar(photoPath string, description Description) error {
+	var (
+		err     error
+		b       []byte
+		content string
+	)
+
+	sidecarPath, exists := sidecarPathFor(photoPath)
+	content = newSidecar
+
+	if exists {
+		if b, err = os.ReadFile(sidecarPath); err != nil {
+			return fmt.Errorf("error reading XMP sidecar '%s': %w", sidecarPath, err)
+		}
+
+		content = string(b)
+	}
+
+	if content, err = setDescription(content, description); err != nil {
+		return fmt.Errorf("error updating XMP sidecar '%s': %w", sidecarPath, err)
+	}
+
+	return writeFileAtomically(sidecarPath, []byte(content))
+}
+
+/*
+WriteDescriptionToJPEG stores a photo's title, caption, keywords, and
+people in the JPEG's XMP packet and IPTC data, keeping every other
+segment as it is. The file is replaced by a rewritten copy, so it gets
+a new file ID. A sidecar the photo already has is updated too, as its
+values win when the photo is read.
+*/
+func WriteDescriptionToJPEG(photoPath string, description Description) error {
+	var (
+		err       error
+		b         []byte
+		segments  []segment
+		imageData []byte
+		packet    string
+	)
+
+	if b, err = os.ReadFile(photoPath); err != nil {
+		return fmt.Errorf("error reading '%s': %w", photoPath, err)
+	}
+
+	if segments, imageData, err = splitJPEG(b); err != nil {
+		return fmt.Errorf("error reading '%s': %w", photoPath, err)
+	}
+
+	xmpIndex, photoshopIndex := -1, -1
+
+	for index, s := range segments {
+		switch {
+		case xmpIndex < 0 && s.marker == markerAPP1 && bytes.HasPrefix(s.data, xmpHeader):
+			xmpIndex = index
+
+		case photoshopIndex < 0 && s.marker == markerAPP13 && bytes.HasPrefix(s.data, photoshopHeader):
+			photoshopIndex = index
+		}
+	}
+
+	packet = newXMPPacketHeader + newSidecar + newXMPPacketTrailer
+
+	if xmpIndex >= 0 {
+		packet = string(bytes.TrimRight(segments[xmpIndex].data[len(xmpHeader):], "\x00"))
+	}
+
+	if packet, err = setDescription(packet, description); err != nil {
+		return fmt.Errorf("error updating the XMP data in '%s': %w", photoPath, err)
+	}
+
+	resources := []byte{}
+
+	if photoshopIndex >= 0 {
+		resources = segments[photoshopIndex].data[len(photoshopHeader):]
+	}
+
+	xmpSegment := segment{marker: markerAPP1, data: concatBytes(xmpHeader, []byte(packet))}
+	photoshopSegment := segment{marker: markerAPP13, data: concatBytes(photoshopHeader, setIPTC(resources, description))}
+
+	/*
+	 * New segments go after the JFIF and EXIF segments, which readers
+	 * expect to come first.
+	 */
+	if xmpIndex < 0 {
+		xmpIndex = 0
+
+		for xmpIndex < len(segments) && (segments[xmpIndex].marker == markerAPP0 || segments[xmpIndex].marker == markerAPP1) {
+			xmpIndex++
+		}
+
+		segments = insertSegment(segments, xmpIndex, xmpSegment)
+
+		if photoshopIndex >= xmpIndex {
+			photoshopIndex++
+		}
+	}
+
+	segments[xmpIndex] = xmpSegment
+
+	if photoshopIndex < 0 {
+		photoshopIndex = xmpIndex + 1
+		segments = insertSegment(segments, photoshopIndex, photoshopSegment)
+	}
+
+	segments[photoshopIndex] = photoshopSegment
+
+	if b, err = joinJPEG(segments, imageData); err != nil {
+		return fmt.Errorf("error writing '%s': %w", photoPath, err)
+	}
+
+	if err = writeFileAtomically(photoPath, b); err != nil {
+		return err
+	}
+
+	if FindSidecar(photoPath) != "" {
+		return WriteDescriptionToSidecar(photoPath, description)
+	}
+
+	return nil
+}
+
+/*
+parseDescription finds the title, caption, keywords, and people in an
+XMP packet. Titles and captions are language alternatives, and the
+default language is used when there's more than one. Packets that
+can't be parsed are treated as having none of them.
+*/
+func parseDescription(packet []byte) Description {
+	var (
+		result     Description
+		property   string
+		inItem     bool
+		isDefault  bool
+		text       strings.Builder
+		items      []string
+		preferred  string
+		hasDefault bool
+	)
+
+	decoder := xml.NewDecoder(bytes.NewReader(bytes.TrimRight(packet, "\x00")))
+
+	for {
+		token, err := decoder.Token()
+
+		if err != nil {
+			return result
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if property == "" {
+				/*
+				 * Some tools write a single title or caption as an
+				 * attribute of the rdf:Description.
+				 */
+				for _, attr := range t.Attr {
+					if attr.Name.Space == dcNamespace {
+						result.set(descriptionProperty(attr.Name), []string{strings.TrimSpace(attr.Value)})
+					}
+				}
+
+				if property = descriptionProperty(t.Name); property != "" {
+					items, preferred, hasDefault = []string{}, "", false
+				}
+
+				continue
+			}
+
+			if t.Name.Space == rdfNamespace && t.Name.Local == "li" {
+				inItem, isDefault = true, false
+				text.Reset()
+
+				for _, attr := range t.Attr {
+					if attr.Name.Local == "lang" && attr.Value == "x-default" {
+						isDefault = true
+					}
+				}
+			}
+
+		case xml.CharData:
+			if inItem {
+				text.Write(t)
+			}
+
+		case xml.EndElement:
+			if inItem && t.Name.Space == rdfNamespace && t.Name.Local == "li" {
+				inItem = false
+				value := strings.TrimSpace(text.String())
+
+				if isDefault && !hasDefault {
+					preferred, hasDefault = value, true
+				}
+
+				items = append(items, value)
+				continue
+			}
+
+			if property != "" && descriptionProperty(t.Name) == property {
+				if hasDefault {
+					items = append([]string{preferred}, items...)
+				}
+
+				result.set(property, items)
+				property = ""
+			}
+		}
+	}
+}
+
+/*
+descriptionProperty returns the name of the description property an
+XMP element or attribute holds, or an empty string for anything else.
+*/
+func descriptionProperty(name xml.Name) string {
+	switch {
+	case name.Space == dcNamespace && (name.Local == "title" || name.Local == "description" || name.Local == "subject"):
+		return name.Local
+
+	case name.Space == iptcExtNamespace && name.Local == "PersonInImage":
+		return name.Local
+
+	case name.Space == lightroomNamespace && name.Local == "hierarchicalSubject":
+		return name.Local
+	}
+
+	return ""
+}
+
+/*
+set stores the values found for a description property. Titles and
+captions take the first value, which is the preferred language.
+Keywords, people, and hierarchical keywords take every value that
+isn't empty.
+*/
+func (d *Description) set(property string, values []string) {
+	first := ""
+	nonEmpty := []string{}
+
+	for _, value := range values {
+		if value != "" {
+			nonEmpty = append(nonEmpty, value)
+		}
+	}
+
+	if len(values) > 0 {
+		first = values[0]
+	}
+
+	switch property {
+	case "title":
+		d.Title, d.HasTitle = first, true
+
+	case "description":
+		d.Caption, d.HasCaption = first, true
+
+	case "subject":
+		d.Keywords, d.HasKeywords = nonEmpty, true
+
+	case "PersonInImage":
+		d.People, d.HasPeople = nonEmpty, true
+
+	case "hierarchicalSubject":
+		d.Hierarchy, d.HasHierarchy = nonEmpty, true
+	}
+}
+
+/*
+setDescription writes every description property into an XMP
+document, replacing any values already there. Empty values are written
+too, so they hide values from the JPEG when written to a sidecar. The
+exception is hierarchical keywords, which are only written when there
+are some, as most photos never have them.
+*/
+func setDescription(content string, description Description) (string, error) {
+	var (
+		err error
+	)
+
+	hierarchy := ""
+
+	if len(description.Hierarchy) > 0 {
+		hierarchy = xmpBag(description.Hierarchy)
+	}
+
+	properties := []struct {
+		prefix    string
+		namespace string
+		name      string
+		value     string
+	}{
+		{"dc", dcNamespace, "title", xmpAlt(description.Title)},
+		{"dc", dcNamespace, "description", xmpAlt(description.Caption)},
+		{"dc", dcNamespace, "subject", xmpBag(description.Keywords)},
+		{"Iptc4xmpExt", iptcExtNamespace, "PersonInImage", xmpBag(description.People)},
+		{"lr", lightroomNamespace, "hierarchicalSubject", hierarchy},
+	}
+
+	for _, property := range properties {
+		if content, err = setXMPElement(content, property.prefix, property.namespace, property.name, property.value); err != nil {
+			return content, err
+		}
+	}
+
+	return content, nil
+}
+
+/*
+setXMPElement replaces a property in an XMP document with an element
+holding value, removing it wherever it already is, as an element or an
+attribute, under whichever prefixes the document binds its namespace
+to. The new element is added to the first rdf:Description and declares
+its own namespace, so it is valid wherever it lands. An empty value
+only removes the property.
+*/
+func setXMPElement(content, prefix, namespace, name, value string) (string, error) {
+	prefixes, err := namespacePrefixes(content, prefix, namespace)
+
+	if err != nil {
+		return content, err
+	}
+
+	for _, p := range prefixes {
+		qualifiedName := regexp.QuoteMeta(p + ":" + name)
+
+		element := regexp.MustCompile(`(?s)<` + qualifiedName + `(?:\s[^>]*?)?(?:/>|>.*?</` + qualifiedName + `\s*>)`)
+		attribute := regexp.MustCompile(`\s` + qualifiedName + `\s*=\s*("[^"]*"|'[^']*')`)
+
+		content = element.ReplaceAllLiteralString(content, "")
+		content = attribute.ReplaceAllLiteralString(content, "")
+	}
+
+	if value == "" {
+		return content, nil
+	}
+
+	location := descriptionPattern.FindStringIndex(content)
+
+	if location == nil {
+		return content, ErrNoDescription
+	}
+
+	end := strings.IndexByte(content[location[1]:], '>')
+
+	if end < 0 {
+		return content, ErrNoDescription
+	}
+
+	end += location[1]
+
+	newElement := "\n   <" + prefix + ":" + name + ` xmlns:` + prefix + `="` + namespace + `">` + value + "</" + prefix + ":" + name + ">"
+
+	/*
+	 * A description without any elements is written as an empty
+	 * element, which has to be opened up first.
+	 */
+	if content[end-1] == '/' {
+		return content[:end-1] + ">" + newElement + "\n  </rdf:Description" + content[end:], nil
+	}
+
+	return content[:end+1] + newElement + content[end+1:], nil
+}
+
+/*
+namespacePrefixes returns the prefixes an XMP document binds to a
+namespace. The prefix it is usually written with is included too,
+unless the document binds that prefix to another namespace, so that a
+property written without a declaration is still found. Properties in
+a default namespace have no prefix to find them by, so documents that
+declare one are refused.
+*/
+func namespacePrefixes(content, prefix, namespace string) ([]string, error) {
+	result := []string{}
+	prefixIsTaken := false
+
+	for _, match := range namespaceDeclarationPattern.FindAllStringSubmatch(content, -1) {
+		declared, uri := match[1], match[2]+match[3]
+
+		switch {
+		case uri == namespace && declared == "":
+			return nil, ErrDefaultNamespace
+
+		case uri == namespace:
+			if !slices.Contains(result, declared) {
+				result = append(result, declared)
+			}
+
+		case declared == prefix:
+			prefixIsTaken = true
+		}
+	}
+
+	if !prefixIsTaken && !slices.Contains(result, prefix) {
+		result = append(result, prefix)
+	}
+
+	return result, nil
+}
+
+func xmpAlt(value string) string {
+	return `<rdf:Alt><rdf:li xml:lang="x-default">` + xmlEscape(value) + `</rdf:li></rdf:Alt>`
+}
+
+func xmpBag(values []string) string {
+	if len(values) == 0 {
+		return "<rdf:Bag/>"
+	}
+
+	result := strings.Builder{}
+	result.WriteString("<rdf:Bag>")
+
+	for _, value := range values {
+		result.WriteString("<rdf:li>" + xmlEscape(value) + "</rdf:li>")
+	}
+
+	result.WriteString("</rdf:Bag>")
+	return result.String()
+}
+
+func concatBytes(a, b []byte) []byte {
+	result := make([]byte, 0, len(a)+len(b))
+	result = append(result, a...)
+
+	return append(result, b...)
+}
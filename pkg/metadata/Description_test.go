@@ -0,0 +1,149 @@
+package metadata
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+/*
+renamedPacket is written by a tool that binds Dublin Core to its own
+prefix, and uses dc for something else.
+*/
+const renamedPacket = `<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about=""
+    xmlns:purl="http://purl.org/dc/elements/1.1/"
+    xmlns:dc="http://example.com/darkroom/"
+    xmlns:people="http://iptc.org/std/Iptc4xmpExt/2008-02-29/"
+    purl:description="Old caption">
+   <purl:title><rdf:Alt><rdf:li xml:lang="x-default">Old title</rdf:li></rdf:Alt></purl:title>
+   <purl:subject><rdf:Bag><rdf:li>Old</rdf:li></rdf:Bag></purl:subject>
+   <people:PersonInImage><rdf:Bag><rdf:li>Bob</rdf:li></rdf:Bag></people:PersonInImage>
+   <dc:title>Darkroom print 12</dc:title>
+  </rdf:Description>
+ </rdf:RDF>
+</x:xmpmeta>`
+
+func TestSetDescriptionFindsPropertiesByNamespace(t *testing.T) {
+	content, err := setDescription(renamedPacket, Description{
+		Title:    "Picnic",
+		Caption:  "At the lake",
+		Keywords: []string{"Lake"},
+		People:   []string{"Aunt Mary"},
+	})
+
+	if err != nil {
+		t.Fatalf("setDescription returned error %v", err)
+	}
+
+	for _, old := range []string{"purl:title", "purl:description", "purl:subject", "people:PersonInImage", "Old", "Bob"} {
+		if strings.Contains(content, old) {
+			t.Errorf("%q is still in the packet:\n%s", old, content)
+		}
+	}
+
+	if !strings.Contains(content, "<dc:title>Darkroom print 12</dc:title>") {
+		t.Errorf("the other namespace's dc:title was removed:\n%s", content)
+	}
+
+	got := parseDescription([]byte(content))
+
+	if got.Title != "Picnic" || got.Caption != "At the lake" || !slices.Equal(got.Keywords, []string{"Lake"}) || !slices.Equal(got.People, []string{"Aunt Mary"}) {
+		t.Errorf("the packet reads back as %+v", got)
+	}
+}
+
+func TestSetDescriptionRefusesDefaultNamespace(t *testing.T) {
+	packet := strings.Replace(renamedPacket, `xmlns:purl="http://purl.org/dc/elements/1.1/"`, `xmlns="http://purl.org/dc/elements/1.1/"`, 1)
+
+	if _, err := setDescription(packet, Description{Title: "Picnic"}); !errors.Is(err, ErrDefaultNamespace) {
+		t.Errorf("setDescription returned error %v, want ErrDefaultNamespace", err)
+	}
+}
+
+func TestDescriptionRoundTripsThroughJPEG(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lake.jpg")
+	imageData := []byte{0xFF, markerSOS, 0x00, 0x03, 0x2A, 0x01, 0x02, 0x03, 0xFF, markerEOI}
+	exif := []byte{0xFF, markerAPP1, 0x00, 0x08, 'E', 'x', 'i', 'f', 0x00, 0x00}
+
+	if err := os.WriteFile(path, concat([]byte{0xFF, markerSOI}, exif, imageData), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	read := func() Description {
+		t.Helper()
+
+		f, err := os.Open(path)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer f.Close()
+
+		description, err := ReadDescription(f, path)
+
+		if err != nil {
+			t.Fatalf("ReadDescription returned error %v", err)
+		}
+
+		return description
+	}
+
+	/*
+	 * The first write adds the XMP and IPTC segments, the second
+	 * replaces them.
+	 */
+	first := Description{
+		Title:     "Lake <at> dawn & dusk",
+		Caption:   "Taken from the dock",
+		Keywords:  []string{"Lake", "Rome"},
+		People:    []string{"Aunt Mary", "Bob"},
+		Hierarchy: []string{"Places|Italy|Rome"},
+	}
+
+	second := Description{Title: "Lake", Keywords: []string{"Summer"}}
+
+	for _, written := range []Description{first, second} {
+		if err := WriteDescriptionToJPEG(path, written); err != nil {
+			t.Fatalf("WriteDescriptionToJPEG returned error %v", err)
+		}
+
+		got := read()
+		got.HasTitle, got.HasCaption, got.HasKeywords, got.HasPeople, got.HasHierarchy = false, false, false, false, false
+
+		if len(got.People) == 0 {
+			got.People = nil
+		}
+
+		if !reflect.DeepEqual(got, written) {
+			t.Errorf("read back %+v, wrote %+v", got, written)
+		}
+	}
+
+	b, err := os.ReadFile(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	segments, rest, err := splitJPEG(b)
+
+	if err != nil {
+		t.Fatalf("the rewritten file can't be split: %v", err)
+	}
+
+	if len(segments) != 3 || !bytes.Equal(segments[0].data, exif[4:]) {
+		t.Errorf("the rewritten file has %d segments, starting with %x, want EXIF, XMP, and IPTC", len(segments), segments[0].data)
+	}
+
+	if !bytes.Equal(rest, imageData) {
+		t.Errorf("the image data changed to %x", rest)
+	}
+}
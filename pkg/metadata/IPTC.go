@@ -0,0 +1,182 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+	"unicode/utf8"
+)
+
+const (
+	iptcDigestResourceID = 0x0425
+
+	iptcRecordVersion  = 0
+	iptcObjectName     = 5
+	iptcKeywords       = 25
+	iptcCaption        = 120
+	iptcCodedCharacter = 90
+
+	/*
+	 * The longest values the IPTC IIM standard allows. XMP has no
+	 * limits, and is written in full.
+	 */
+	maxIPTCObjectNameLength = 64
+	maxIPTCKeywordLength    = 64
+	maxIPTCCaptionLength    = 2000
+)
+
+/*
+iptcUTF8 is the coded character set dataset value marking IPTC text as
+UTF-8.
+*/
+var iptcUTF8 = []byte("\x1b%G")
+
+/*
+setIPTC returns the Photoshop image resources of an APP13 segment with
+the IPTC object name, keywords, and caption replaced by those in the
+description. Other resources and datasets are kept. The IPTC digest,
+which other tools use to notice IPTC changed behind their back, is
+removed, as it no longer matches.
+*/
+func setIPTC(resources []byte, description Description) []byte {
+	result := bytes.Buffer{}
+	existing := []byte{}
+	hasIPTC := false
+
+	for _, resource := range readImageResources(resources) {
+		if resource.id == iptcResourceID && !hasIPTC {
+			existing = resource.data
+			hasIPTC = true
+		}
+	}
+
+	iptc := buildIPTC(existing, description)
+	wroteIPTC := false
+
+	for _, resource := range readImageResources(resources) {
+		switch resource.id {
+		case iptcDigestResourceID:
+			continue
+
+		case iptcResourceID:
+			if wroteIPTC {
+				continue
+			}
+
+			resource.data = iptc
+			wroteIPTC = true
+		}
+
+		writeImageResource(&result, resource)
+	}
+
+	if !wroteIPTC {
+		writeImageResource(&result, imageResource{id: iptcResourceID, data: iptc})
+	}
+
+	return result.Bytes()
+}
+
+/*
+buildIPTC replaces the datasets for the description in an IPTC block,
+keeping the rest in record and dataset order. Text is written as
+UTF-8, which is declared if the block didn't declare a character set.
+*/
+func buildIPTC(existing []byte, description Description) []byte {
+	hasCharacterSet := false
+	hasRecordVersion := false
+	datasets := []iptcDataset{}
+
+	for _, d := range readIPTCDatasets(existing) {
+		switch {
+		case d.record == 2 && (d.dataset == iptcObjectName || d.dataset == iptcKeywords || d.dataset == iptcCaption):
+			continue
+
+		case d.record == 1 && d.dataset == iptcCodedCharacter:
+			hasCharacterSet = true
+
+		case d.record == 2 && d.dataset == iptcRecordVersion:
+			hasRecordVersion = true
+		}
+
+		datasets = append(datasets, d)
+	}
+
+	if !hasCharacterSet {
+		datasets = append(datasets, iptcDataset{record: 1, dataset: iptcCodedCharacter, value: iptcUTF8})
+	}
+
+	if !hasRecordVersion {
+		datasets = append(datasets, iptcDataset{record: 2, dataset: iptcRecordVersion, value: []byte{0, 4}})
+	}
+
+	if description.Title != "" {
+		datasets = append(datasets, iptcDataset{record: 2, dataset: iptcObjectName, value: truncateUTF8(description.Title, maxIPTCObjectNameLength)})
+	}
+
+	for _, keyword := range description.Keywords {
+		datasets = append(datasets, iptcDataset{record: 2, dataset: iptcKeywords, value: truncateUTF8(keyword, maxIPTCKeywordLength)})
+	}
+
+	if description.Caption != "" {
+		datasets = append(datasets, iptcDataset{record: 2, dataset: iptcCaption, value: truncateUTF8(description.Caption, maxIPTCCaptionLength)})
+	}
+
+	sort.SliceStable(datasets, func(i, j int) bool {
+		if datasets[i].record != datasets[j].record {
+			return datasets[i].record < datasets[j].record
+		}
+
+		return datasets[i].dataset < datasets[j].dataset
+	})
+
+	result := bytes.Buffer{}
+
+	for _, d := range datasets {
+		result.Write([]byte{0x1C, d.record, d.dataset})
+		_ = binary.Write(&result, binary.BigEndian, uint16(len(d.value)))
+		result.Write(d.value)
+	}
+
+	return result.Bytes()
+}
+
+/*
+writeImageResource writes a Photoshop image resource block, padding
+its name and data to even lengths.
+*/
+func writeImageResource(w *bytes.Buffer, resource imageResource) {
+	w.WriteString("8BIM")
+	_ = binary.Write(w, binary.BigEndian, resource.id)
+	w.WriteByte(byte(len(resource.name)))
+	w.Write(resource.name)
+
+	if (len(resource.name)+1)%2 != 0 {
+		w.WriteByte(0)
+	}
+
+	_ = binary.Write(w, binary.BigEndian, uint32(len(resource.data)))
+	w.Write(resource.data)
+
+	if len(resource.data)%2 != 0 {
+		w.WriteByte(0)
+	}
+}
+
+/*
+truncateUTF8 cuts value to at most maxLength bytes without splitting a
+character.
+*/
+func truncateUTF8(value string, maxLength int) []byte {
+	if len(value) <= maxLength {
+		return []byte(value)
+	}
+
+	end := maxLength
+
+	for end > 0 && !utf8.RuneStart(value[end]) {
+		end--
+	}
+
+	return []byte(value[:end])
+}
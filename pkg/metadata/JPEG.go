@@ -0,0 +1,106 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	markerAPP0 = 0xE0
+
+	/*
+	 * A segment's length includes the two bytes storing it.
+	 */
+	maxSegmentDataLength = 0xFFFF - 2
+)
+
+/*
+segment is a JPEG segment before the image data, without its marker
+and length bytes.
+*/
+type segment struct {
+	marker byte
+	data   []byte
+}
+
+/*
+splitJPEG splits a JPEG into the segments before the image data and
+everything from the start of the image data on, which is kept as it
+is.
+*/
+func splitJPEG(b []byte) ([]segment, []byte, error) {
+	result := []segment{}
+
+	if len(b) < 2 || b[0] != 0xFF || b[1] != markerSOI {
+		return result, nil, ErrNotJPEG
+	}
+
+	position := 2
+
+	for {
+		if position+2 > len(b) {
+			return result, nil, fmt.Errorf("the JPEG ends before its image data")
+		}
+
+		if b[position] != 0xFF {
+			return result, nil, fmt.Errorf("invalid JPEG segment marker %x", b[position:position+2])
+		}
+
+		/*
+		 * Markers can be padded with any number of 0xFF bytes.
+		 */
+		if b[position+1] == 0xFF {
+			position++
+			continue
+		}
+
+		marker := b[position+1]
+
+		if marker == markerSOS || marker == markerEOI {
+			return result, b[position:], nil
+		}
+
+		if position+4 > len(b) {
+			return result, nil, fmt.Errorf("the JPEG ends in a segment header")
+		}
+
+		length := int(binary.BigEndian.Uint16(b[position+2 : position+4]))
+
+		if length < 2 || position+2+length > len(b) {
+			return result, nil, fmt.Errorf("invalid JPEG segment length %d", length)
+		}
+
+		result = append(result, segment{marker: marker, data: b[position+4 : position+2+length]})
+		position += 2 + length
+	}
+}
+
+/*
+joinJPEG writes segments and the image data back into a JPEG.
+*/
+func joinJPEG(segments []segment, imageData []byte) ([]byte, error) {
+	result := bytes.Buffer{}
+	result.Write([]byte{0xFF, markerSOI})
+
+	for _, s := range segments {
+		if len(s.data) > maxSegmentDataLength {
+			return nil, fmt.Errorf("%w (%d bytes)", ErrSegmentTooLarge, len(s.data))
+		}
+
+		result.Write([]byte{0xFF, s.marker})
+		_ = binary.Write(&result, binary.BigEndian, uint16(len(s.data)+2))
+		result.Write(s.data)
+	}
+
+	result.Write(imageData)
+	return result.Bytes(), nil
+}
+
+func insertSegment(segments []segment, index int, s segment) []segment {
+	segments = append(segments, segment{})
+	copy(segments[index+1:], segments[index:])
+	segments[index] = s
+
+	return segments
+}
@@ -0,0 +1,122 @@
+package metadata
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSplitJPEG(t *testing.T) {
+	imageData := []byte{0xFF, markerSOS, 0x00, 0x02, 0x01, 0x02, 0xFF, markerEOI}
+
+	tests := []struct {
+		name          string
+		input         []byte
+		wantSegments  []segment
+		wantRoundTrip bool
+	}{
+		{
+			name:          "no segments",
+			input:         concat([]byte{0xFF, markerSOI}, imageData),
+			wantSegments:  []segment{},
+			wantRoundTrip: true,
+		},
+		{
+			name: "two segments",
+			input: concat(
+				[]byte{0xFF, markerSOI},
+				[]byte{0xFF, markerAPP0, 0x00, 0x05, 'J', 'F', 'I'},
+				[]byte{0xFF, 0xE1, 0x00, 0x02},
+				imageData,
+			),
+			wantSegments: []segment{
+				{marker: markerAPP0, data: []byte{'J', 'F', 'I'}},
+				{marker: 0xE1, data: []byte{}},
+			},
+			wantRoundTrip: true,
+		},
+		{
+			name: "padded marker",
+			input: concat(
+				[]byte{0xFF, markerSOI},
+				[]byte{0xFF, 0xFF, 0xFF, markerAPP0, 0x00, 0x03, 'J'},
+				imageData,
+			),
+			wantSegments: []segment{
+				{marker: markerAPP0, data: []byte{'J'}},
+			},
+		},
+		{
+			name:          "image without a scan",
+			input:         []byte{0xFF, markerSOI, 0xFF, markerEOI},
+			wantSegments:  []segment{},
+			wantRoundTrip: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			segments, rest, err := splitJPEG(tt.input)
+
+			if err != nil {
+				t.Fatalf("splitJPEG returned error %v", err)
+			}
+
+			if !reflect.DeepEqual(segments, tt.wantSegments) {
+				t.Errorf("splitJPEG segments = %v, want %v", segments, tt.wantSegments)
+			}
+
+			if rest[0] != 0xFF || (rest[1] != markerSOS && rest[1] != markerEOI) {
+				t.Errorf("splitJPEG image data starts with %x", rest[:2])
+			}
+
+			joined, err := joinJPEG(segments, rest)
+
+			if err != nil {
+				t.Fatalf("joinJPEG returned error %v", err)
+			}
+
+			if tt.wantRoundTrip && !bytes.Equal(joined, tt.input) {
+				t.Errorf("joinJPEG = %x, want %x", joined, tt.input)
+			}
+		})
+	}
+}
+
+func TestSplitJPEGErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input []byte
+	}{
+		{name: "empty", input: []byte{}},
+		{name: "not a JPEG", input: []byte{0x89, 'P', 'N', 'G'}},
+		{name: "ends before the image data", input: []byte{0xFF, markerSOI}},
+		{name: "bad marker", input: []byte{0xFF, markerSOI, 0x00, markerAPP0}},
+		{name: "ends in a segment header", input: []byte{0xFF, markerSOI, 0xFF, markerAPP0, 0x00}},
+		{name: "length too short", input: []byte{0xFF, markerSOI, 0xFF, markerAPP0, 0x00, 0x01}},
+		{name: "length past the end", input: []byte{0xFF, markerSOI, 0xFF, markerAPP0, 0x00, 0x10, 'J'}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := splitJPEG(tt.input); err == nil {
+				t.Errorf("splitJPEG(%x) returned no error", tt.input)
+			}
+		})
+	}
+}
+
+func TestJoinJPEGSegmentTooLarge(t *testing.T) {
+	segments := []segment{
+		{marker: markerAPP0, data: make([]byte, maxSegmentDataLength+1)},
+	}
+
+	if _, err := joinJPEG(segments, []byte{0xFF, markerEOI}); !errors.Is(err, ErrSegmentTooLarge) {
+		t.Errorf("joinJPEG error = %v, want %v", err, ErrSegmentTooLarge)
+	}
+}
+
+func concat(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
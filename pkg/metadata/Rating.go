@@ -0,0 +1,287 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	xmpNamespace = "http://ns.adobe.com/xap/1.0/"
+)
+
+/*
+newSidecar is written when a photo doesn't have a sidecar yet. Rating
+and label attributes are added to its description by WriteRating.
+*/
+const newSidecar = `<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about="" xmlns:xmp="http://ns.adobe.com/xap/1.0/"/>
+ </rdf:RDF>
+</x:xmpmeta>
+`
+
+var (
+	descriptionPattern = regexp.MustCompile(`<rdf:Description\b`)
+	xmpPrefixPattern   = regexp.MustCompile(`xmlns:xmp\s*=\s*["']` + regexp.QuoteMeta(xmpNamespace) + `["']`)
+
+	ErrNoDescription = errors.New("the XMP sidecar has no rdf:Description to add to")
+)
+
+/*
+Rating is the star rating and color label an editor, such as
+Lightroom, stored in a photo's XMP data. HasStars and HasLabel are
+false when the XMP didn't include them.
+*/
+type Rating struct {
+	Stars    int
+	Label    string
+	HasStars bool
+	HasLabel bool
+}
+
+/*
+merge returns this rating with any values set in other taking their
+place.
+*/
+func (r Rating) merge(other Rating) Rating {
+	if other.HasStars {
+		r.Stars, r.HasStars = other.Stars, true
+	}
+
+	if other.HasLabel {
+		r.Label, r.HasLabel = other.Label, true
+	}
+
+	return r
+}
+
+/*
+ReadRating reads xmp:Rating and xmp:Label from the XMP packet in a
+JPEG, then from the photo's sidecar, if it has one. Values in the
+sidecar win, as that's where changes made in this app are written.
+*/
+func ReadRating(jpeg io.Reader, photoPath string) (Rating, error) {
+	var (
+		err    error
+		b      []byte
+		result Rating
+	)
+
+	err = readSegments(jpeg, func(marker byte, data []byte) {
+		if marker == markerAPP1 && bytes.HasPrefix(data, xmpHeader) {
+			result = result.merge(parseRating(data[len(xmpHeader):]))
+		}
+	})
+
+	if err != nil {
+		return result, err
+	}
+
+	if sidecarPath := FindSidecar(photoPath); sidecarPath != "" {
+		if b, err = os.ReadFile(sidecarPath); err != nil {
+			return result, fmt.Errorf("error reading XMP sidecar '%s': %w", sidecarPath, err)
+		}
+
+		result = result.merge(parseRating(b))
+	}
+
+	return result, nil
+}
+
+/*
+WriteRating stores a rating and label in the photo's XMP sidecar, so
+other tools see the change. An existing sidecar is updated in place.
+Otherwise one is created next to the photo, named like Lightroom
+names them, with the photo's extension replaced by ".xmp".
+*/
+func WriteRating(photoPath string, stars int, label string) error {
+	var (
+		err     error
+		b       []byte
+		content string
+	)
+
+	sidecarPath, exists := sidecarPathFor(photoPath)
+	content = newSidecar
+
+	if exists {
+		if b, err = os.ReadFile(sidecarPath); err != nil {
+			return fmt.Errorf("error reading XMP sidecar '%s': %w", sidecarPath, err)
+		}
+
+		content = string(b)
+	}
+
+	if content, err = setXMPProperty(content, "Rating", strconv.Itoa(stars)); err != nil {
+		return fmt.Errorf("error setting the rating in '%s': %w", sidecarPath, err)
+	}
+
+	if content, err = setXMPProperty(content, "Label", label); err != nil {
+		return fmt.Errorf("error setting the label in '%s': %w", sidecarPath, err)
+	}
+
+	return writeFileAtomically(sidecarPath, []byte(content))
+}
+
+/*
+FindSidecar returns the path of a photo's XMP sidecar, or an empty
+string if it doesn't have one. Both Lightroom's naming, which replaces
+the extension, and darktable's, which appends to it, are found.
+*/
+func FindSidecar(photoPath string) string {
+	base := strings.TrimSuffix(photoPath, filepath.Ext(photoPath))
+
+	candidates := []string{
+		base + ".xmp",
+		base + ".XMP",
+		photoPath + ".xmp",
+		photoPath + ".XMP",
+	}
+
+	for _, candidate := range candidates {
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate
+		}
+	}
+
+	return ""
+}
+
+/*
+parseRating finds xmp:Rating and xmp:Label in an XMP packet. They can
+be written as attributes of an rdf:Description or as elements inside
+it. Packets that can't be parsed are treated as having neither.
+*/
+func parseRating(packet []byte) Rating {
+	var (
+		result  Rating
+		current string
+	)
+
+	set := func(name, value string) {
+		value = strings.TrimSpace(value)
+
+		switch name {
+		case "Rating":
+			if stars, err := strconv.ParseFloat(value, 64); err == nil {
+				result.Stars, result.HasStars = int(math.Round(stars)), true
+			}
+
+		case "Label":
+			result.Label, result.HasLabel = value, true
+		}
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(bytes.TrimRight(packet, "\x00")))
+
+	for {
+		token, err := decoder.Token()
+
+		if err != nil {
+			return result
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			current = ""
+
+			for _, attr := range t.Attr {
+				if attr.Name.Space == xmpNamespace {
+					set(attr.Name.Local, attr.Value)
+				}
+			}
+
+			if t.Name.Space == xmpNamespace {
+				current = t.Name.Local
+			}
+
+		case xml.CharData:
+			if current != "" {
+				set(current, string(t))
+			}
+
+		case xml.EndElement:
+			current = ""
+		}
+	}
+}
+
+/*
+setXMPProperty sets an xmp: property in an XMP document, keeping the
+rest of it as it is. The property is replaced where it already is,
+whether an attribute or an element, under whichever prefix the
+document binds the xmp namespace to. Otherwise it is added as an
+attribute of the first rdf:Description, along with the xmp namespace
+if the document doesn't declare it.
+*/
+func setXMPProperty(content, name, value string) (string, error) {
+	escaped := xmlEscape(value)
+
+	prefixes, err := namespacePrefixes(content, "xmp", xmpNamespace)
+
+	if err != nil {
+		return content, err
+	}
+
+	for _, prefix := range prefixes {
+		qualifiedName := regexp.QuoteMeta(prefix + ":" + name)
+		attribute := regexp.MustCompile(`\s` + qualifiedName + `\s*=\s*("[^"]*"|'[^']*')`)
+
+		if attribute.MatchString(content) {
+			return attribute.ReplaceAllLiteralString(content, ` `+prefix+`:`+name+`="`+escaped+`"`), nil
+		}
+
+		/*
+		 * Elements can carry attributes of their own, such as a local
+		 * xmlns:xmp declaration, which are kept.
+		 */
+		element := regexp.MustCompile(`<` + qualifiedName + `(\s[^>]*?)?(?:/>|>[^<]*</` + qualifiedName + `\s*>)`)
+
+		if element.MatchString(content) {
+			return element.ReplaceAllStringFunc(content, func(match string) string {
+				attributes := strings.TrimSuffix(element.FindStringSubmatch(match)[1], "/")
+				return `<` + prefix + `:` + name + attributes + `>` + escaped + `</` + prefix + `:` + name + `>`
+			}), nil
+		}
+	}
+
+	location := descriptionPattern.FindStringIndex(content)
+
+	if location == nil {
+		return content, ErrNoDescription
+	}
+
+	insert := ` xmp:` + name + `="` + escaped + `"`
+
+	/*
+	 * The namespace only needs declaring when the description, or an
+	 * element around it, doesn't already.
+	 */
+	startTagEnd := len(content)
+
+	if end := strings.IndexByte(content[location[1]:], '>'); end >= 0 {
+		startTagEnd = location[1] + end
+	}
+
+	if !xmpPrefixPattern.MatchString(content[:startTagEnd]) {
+		insert = ` xmlns:xmp="` + xmpNamespace + `"` + insert
+	}
+
+	return content[:location[1]] + insert + content[location[1]:], nil
+}
+
+func xmlEscape(value string) string {
+	b := &strings.Builder{}
+	_ = xml.EscapeText(b, []byte(value))
+
+	return b.String()
+}
@@ -0,0 +1,34 @@
+package metadata
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSetXMPPropertyUsesTheDeclaredPrefix(t *testing.T) {
+	packet := `<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about="" xmlns:xap="http://ns.adobe.com/xap/1.0/" xap:Rating="2">
+   <xap:Label>Red</xap:Label>
+  </rdf:Description>
+ </rdf:RDF>
+</x:xmpmeta>`
+
+	content, err := setXMPProperty(packet, "Rating", "5")
+
+	if err == nil {
+		content, err = setXMPProperty(content, "Label", "Green")
+	}
+
+	if err != nil {
+		t.Fatalf("setXMPProperty returned error %v", err)
+	}
+
+	if strings.Contains(content, "xmp:") {
+		t.Errorf("a second property was added under the xmp prefix:\n%s", content)
+	}
+
+	if got := parseRating([]byte(content)); got.Stars != 5 || got.Label != "Green" {
+		t.Errorf("the packet reads back as %+v, want 5 stars labelled Green", got)
+	}
+}
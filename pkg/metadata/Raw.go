@@ -0,0 +1,361 @@
+package metadata
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rwcarlsen/goexif/exif"
+	"github.com/rwcarlsen/goexif/tiff"
+)
+
+const (
+	markerSOI   = 0xD8
+	markerSOS   = 0xDA
+	markerEOI   = 0xD9
+	markerAPP1  = 0xE1
+	markerAPP13 = 0xED
+
+	iptcResourceID = 0x0404
+
+	/*
+	 * Values longer than this, such as maker notes, are cut short so
+	 * the dump stays readable.
+	 */
+	maxRawValueLength = 256
+)
+
+var (
+	exifHeader      = []byte("Exif\x00\x00")
+	xmpHeader       = []byte("http://ns.adobe.com/xap/1.0/\x00")
+	photoshopHeader = []byte("Photoshop 3.0\x00")
+
+	ErrNotJPEG = errors.New("not a JPEG image")
+)
+
+/*
+RawTag is a single metadata value, named the way the standard it
+comes from names it.
+*/
+type RawTag struct {
+	Name  string
+	Value string
+}
+
+/*
+RawMetadata is every EXIF tag, IPTC dataset, and the XMP packet found
+in a JPEG, as they are stored in the file.
+*/
+type RawMetadata struct {
+	EXIF []RawTag
+	IPTC []RawTag
+	XMP  string
+}
+
+/*
+IsEmpty returns true if the image has no metadata at all.
+*/
+func (m RawMetadata) IsEmpty() bool {
+	return len(m.EXIF) == 0 && len(m.IPTC) == 0 && m.XMP == ""
+}
+
+/*
+ReadRaw reads the metadata segments of a JPEG. Segments that can't be
+decoded are skipped, so a damaged EXIF block doesn't hide the IPTC
+and XMP data.
+*/
+func ReadRaw(r io.Reader) (RawMetadata, error) {
+	var (
+		err    error
+		result = RawMetadata{
+			EXIF: []RawTag{},
+			IPTC: []RawTag{},
+		}
+	)
+
+	err = readSegments(r, func(marker byte, data []byte) {
+		switch {
+		case marker == markerAPP1 && bytes.HasPrefix(data, exifHeader):
+			result.EXIF = append(result.EXIF, readEXIF(data)...)
+
+		case marker == markerAPP1 && bytes.HasPrefix(data, xmpHeader):
+			result.XMP = strings.TrimSpace(string(bytes.TrimRight(data[len(xmpHeader):], "\x00")))
+
+		case marker == markerAPP13 && bytes.HasPrefix(data, photoshopHeader):
+			result.IPTC = append(result.IPTC, readIPTC(data[len(photoshopHeader):])...)
+		}
+	})
+
+	return result, err
+}
+
+/*
+readSegments calls fn with the marker and contents of every segment
+before the image data starts.
+*/
+func readSegments(r io.Reader, fn func(marker byte, data []byte)) error {
+	var (
+		err    error
+		header [4]byte
+	)
+
+	br := bufio.NewReader(r)
+
+	if _, err = io.ReadFull(br, header[:2]); err != nil || header[0] != 0xFF || header[1] != markerSOI {
+		return ErrNotJPEG
+	}
+
+	for {
+		if _, err = io.ReadFull(br, header[:2]); err != nil {
+			return fmt.Errorf("error reading JPEG segment: %w", err)
+		}
+
+		if header[0] != 0xFF {
+			return fmt.Errorf("invalid JPEG segment marker %x", header[:2])
+		}
+
+		marker := header[1]
+
+		/*
+		 * Markers can be padded with any number of 0xFF bytes.
+		 */
+		if marker == 0xFF {
+			if err = br.UnreadByte(); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if marker == markerSOS || marker == markerEOI {
+			return nil
+		}
+
+		if _, err = io.ReadFull(br, header[2:4]); err != nil {
+			return fmt.Errorf("error reading JPEG segment length: %w", err)
+		}
+
+		length := int(binary.BigEndian.Uint16(header[2:4])) - 2
+
+		if length < 0 {
+			return fmt.Errorf("invalid JPEG segment length %d", length)
+		}
+
+		data := make([]byte, length)
+
+		if _, err = io.ReadFull(br, data); err != nil {
+			return fmt.Errorf("error reading JPEG segment: %w", err)
+		}
+
+		fn(marker, data)
+	}
+}
+
+type exifWalker struct {
+	tags []RawTag
+}
+
+func (w *exifWalker) Walk(name exif.FieldName, tag *tiff.Tag) error {
+	value, err := tag.StringVal()
+
+	/*
+	 * Tags that aren't text are formatted as JSON by the tiff package,
+	 * which quotes single rational values.
+	 */
+	if err != nil {
+		value = strings.Trim(tag.String(), `"`)
+	}
+
+	w.tags = append(w.tags, RawTag{
+		Name:  string(name),
+		Value: truncateRawValue(strings.TrimRight(value, "\x00 ")),
+	})
+
+	return nil
+}
+
+/*
+readEXIF returns the tags in an EXIF segment, sorted by name.
+*/
+func readEXIF(data []byte) []RawTag {
+	x, err := exif.Decode(bytes.NewReader(data))
+
+	if err != nil && exif.IsCriticalError(err) {
+		return []RawTag{}
+	}
+
+	walker := &exifWalker{tags: []RawTag{}}
+	_ = x.Walk(walker)
+
+	sort.Slice(walker.tags, func(i, j int) bool {
+		return walker.tags[i].Name < walker.tags[j].Name
+	})
+
+	return walker.tags
+}
+
+/*
+iptcDatasetNames are the names of the IPTC IIM application record
+(record 2) datasets.
+*/
+var iptcDatasetNames = map[byte]string{
+	0:   "Record Version",
+	5:   "Object Name",
+	7:   "Edit Status",
+	10:  "Urgency",
+	15:  "Category",
+	20:  "Supplemental Category",
+	22:  "Fixture Identifier",
+	25:  "Keywords",
+	26:  "Content Location Code",
+	27:  "Content Location Name",
+	40:  "Special Instructions",
+	55:  "Date Created",
+	60:  "Time Created",
+	62:  "Digital Creation Date",
+	63:  "Digital Creation Time",
+	65:  "Originating Program",
+	70:  "Program Version",
+	80:  "By-line",
+	85:  "By-line Title",
+	90:  "City",
+	92:  "Sub-location",
+	95:  "Province/State",
+	100: "Country Code",
+	101: "Country",
+	103: "Original Transmission Reference",
+	105: "Headline",
+	110: "Credit",
+	115: "Source",
+	116: "Copyright Notice",
+	118: "Contact",
+	120: "Caption/Abstract",
+	122: "Writer/Editor",
+}
+
+/*
+readIPTC returns the IPTC datasets stored in the Photoshop image
+resources of an APP13 segment, in file order.
+*/
+func readIPTC(data []byte) []RawTag {
+	result := []RawTag{}
+
+	for _, resource := range readImageResources(data) {
+		if resource.id != iptcResourceID {
+			continue
+		}
+
+		for _, d := range readIPTCDatasets(resource.data) {
+			if d.record != 2 {
+				continue
+			}
+
+			name, ok := iptcDatasetNames[d.dataset]
+
+			if !ok {
+				name = "2:" + strconv.Itoa(int(d.dataset))
+			}
+
+			if d.dataset == 0 && len(d.value) == 2 {
+				result = append(result, RawTag{Name: name, Value: strconv.Itoa(int(binary.BigEndian.Uint16(d.value)))})
+				continue
+			}
+
+			result = append(result, RawTag{Name: name, Value: truncateRawValue(string(d.value))})
+		}
+	}
+
+	return result
+}
+
+type iptcDataset struct {
+	record  byte
+	dataset byte
+	value   []byte
+}
+
+/*
+readIPTCDatasets splits an IPTC block into its datasets, from every
+record.
+*/
+func readIPTCDatasets(data []byte) []iptcDataset {
+	result := []iptcDataset{}
+
+	for len(data) >= 5 && data[0] == 0x1C {
+		length := int(binary.BigEndian.Uint16(data[3:5]))
+
+		/*
+		 * Extended datasets, longer than 32767 bytes, aren't used
+		 * for text and end the list here.
+		 */
+		if length&0x8000 != 0 || len(data) < 5+length {
+			break
+		}
+
+		result = append(result, iptcDataset{record: data[1], dataset: data[2], value: data[5 : 5+length]})
+		data = data[5+length:]
+	}
+
+	return result
+}
+
+type imageResource struct {
+	id   uint16
+	name []byte
+	data []byte
+}
+
+/*
+readImageResources splits Photoshop image resource blocks, which start
+with "8BIM", a resource ID, a padded Pascal string name, and a padded
+length-prefixed block of data.
+*/
+func readImageResources(data []byte) []imageResource {
+	result := []imageResource{}
+
+	for len(data) >= 12 && bytes.HasPrefix(data, []byte("8BIM")) {
+		id := binary.BigEndian.Uint16(data[4:6])
+		nameLength := int(data[6])
+		offset := 7 + nameLength
+
+		if offset%2 != 0 {
+			offset++
+		}
+
+		if len(data) < offset+4 {
+			break
+		}
+
+		name := data[7 : 7+nameLength]
+		size := int(binary.BigEndian.Uint32(data[offset : offset+4]))
+		offset += 4
+
+		if size < 0 || len(data) < offset+size {
+			break
+		}
+
+		result = append(result, imageResource{id: id, name: name, data: data[offset : offset+size]})
+
+		if size%2 != 0 {
+			size++
+		}
+
+		data = data[min(len(data), offset+size):]
+	}
+
+	return result
+}
+
+func truncateRawValue(value string) string {
+	if len(value) <= maxRawValueLength {
+		return value
+	}
+
+	return strings.ToValidUTF8(value[:maxRawValueLength], "") + "…"
+}
@@ -0,0 +1,294 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	mwgRegionsNamespace          = "http://www.metadataworkinggroup.com/schemas/regions/"
+	areaNamespace                = "http://ns.adobe.com/xmp/sType/Area#"
+	microsoftRegionInfoNamespace = "http://ns.microsoft.com/photo/1.2/t/RegionInfo#"
+	microsoftRegionNamespace     = "http://ns.microsoft.com/photo/1.2/t/Region#"
+
+	regionFormatMWG       = "mwg"
+	regionFormatMicrosoft = "microsoft"
+)
+
+/*
+FaceRegion is a named rectangle around a face. X, Y, Width, and Height
+are fractions of the photo's width and height, measured from its top
+left corner.
+*/
+type FaceRegion struct {
+	Name   string
+	X      float64
+	Y      float64
+	Width  float64
+	Height float64
+}
+
+/*
+FaceRegions are the faces found in a photo's XMP data, from either the
+Metadata Working Group's regions (mwg-rs:Regions), which Lightroom
+and digiKam write, or Microsoft's (MP:RegionInfo), which Picasa and
+Windows Photo Gallery write. HasRegions is false when the XMP had
+neither, which is different from having an empty list.
+*/
+type FaceRegions struct {
+	Regions    []FaceRegion
+	HasRegions bool
+}
+
+/*
+merge returns these regions with other's taking their place, if
+other has any.
+*/
+func (r FaceRegions) merge(other FaceRegions) FaceRegions {
+	if other.HasRegions {
+		return other
+	}
+
+	return r
+}
+
+/*
+ReadFaceRegions reads the face regions from the XMP packet in a JPEG,
+then from the photo's sidecar, if it has one. As with ratings, the
+sidecar's regions win.
+*/
+func ReadFaceRegions(jpeg io.Reader, photoPath string) (FaceRegions, error) {
+	var (
+		err    error
+		b      []byte
+		result FaceRegions
+	)
+
+	err = readSegments(jpeg, func(marker byte, data []byte) {
+		if marker == markerAPP1 && bytes.HasPrefix(data, xmpHeader) {
+			result = result.merge(parseFaceRegions(data[len(xmpHeader):]))
+		}
+	})
+
+	if err != nil {
+		return result, err
+	}
+
+	if sidecarPath := FindSidecar(photoPath); sidecarPath != "" {
+		if b, err = os.ReadFile(sidecarPath); err != nil {
+			return result, fmt.Errorf("error reading XMP sidecar '%s': %w", sidecarPath, err)
+		}
+
+		result = result.merge(parseFaceRegions(b))
+	}
+
+	return result, nil
+}
+
+/*
+parseFaceRegions finds the face regions in an XMP packet. Tools such
+as digiKam write the same faces in both formats, so Microsoft's
+regions are only used when there are no MWG ones. Regions that aren't
+faces, such as MWG's focus areas, are skipped. Packets that can't be
+parsed are treated as having no regions.
+*/
+func parseFaceRegions(packet []byte) FaceRegions {
+	var (
+		mwg       FaceRegions
+		microsoft FaceRegions
+		format    string
+		depth     int
+		listDepth int
+		itemDepth int
+		region    regionFields
+		text      strings.Builder
+	)
+
+	result := func() FaceRegions {
+		if len(mwg.Regions) == 0 && microsoft.HasRegions {
+			return microsoft
+		}
+
+		return mwg
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(bytes.TrimRight(packet, "\x00")))
+
+	for {
+		token, err := decoder.Token()
+
+		if err != nil {
+			return result()
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			depth++
+			text.Reset()
+
+			switch {
+			case format == "" && t.Name.Space == mwgRegionsNamespace && t.Name.Local == "RegionList":
+				format, listDepth = regionFormatMWG, depth
+				mwg.HasRegions = true
+
+			case format == "" && t.Name.Space == microsoftRegionInfoNamespace && t.Name.Local == "Regions":
+				format, listDepth = regionFormatMicrosoft, depth
+				microsoft.HasRegions = true
+
+			case format != "" && itemDepth == 0 && t.Name.Space == rdfNamespace && t.Name.Local == "li":
+				itemDepth = depth
+				region = regionFields{}
+			}
+
+			if itemDepth > 0 {
+				for _, attr := range t.Attr {
+					region.set(attr.Name, attr.Value)
+				}
+			}
+
+		case xml.CharData:
+			if itemDepth > 0 {
+				text.Write(t)
+			}
+
+		case xml.EndElement:
+			if itemDepth > 0 && depth > itemDepth {
+				region.set(t.Name, text.String())
+			}
+
+			if itemDepth > 0 && depth == itemDepth {
+				if face, ok := region.faceRegion(format); ok && format == regionFormatMWG {
+					mwg.Regions = append(mwg.Regions, face)
+				} else if ok {
+					microsoft.Regions = append(microsoft.Regions, face)
+				}
+
+				itemDepth = 0
+			}
+
+			if format != "" && depth == listDepth {
+				format, listDepth = "", 0
+			}
+
+			text.Reset()
+			depth--
+		}
+	}
+}
+
+/*
+regionFields collects the properties of a single region, which can be
+written as attributes or as elements.
+*/
+type regionFields struct {
+	name      string
+	kind      string
+	unit      string
+	area      [4]float64
+	hasArea   [4]bool
+	rectangle string
+}
+
+func (r *regionFields) set(name xml.Name, value string) {
+	value = strings.TrimSpace(value)
+
+	if value == "" {
+		return
+	}
+
+	switch name {
+	case xml.Name{Space: mwgRegionsNamespace, Local: "Name"},
+		xml.Name{Space: microsoftRegionNamespace, Local: "PersonDisplayName"}:
+		r.name = value
+
+	case xml.Name{Space: mwgRegionsNamespace, Local: "Type"}:
+		r.kind = value
+
+	case xml.Name{Space: areaNamespace, Local: "unit"}:
+		r.unit = value
+
+	case xml.Name{Space: microsoftRegionNamespace, Local: "Rectangle"}:
+		r.rectangle = value
+
+	case xml.Name{Space: areaNamespace, Local: "x"},
+		xml.Name{Space: areaNamespace, Local: "y"},
+		xml.Name{Space: areaNamespace, Local: "w"},
+		xml.Name{Space: areaNamespace, Local: "h"}:
+		index := strings.Index("xywh", name.Local)
+
+		if number, err := strconv.ParseFloat(value, 64); err == nil {
+			r.area[index], r.hasArea[index] = number, true
+		}
+	}
+}
+
+/*
+faceRegion returns the region as a face. MWG areas are measured from
+the center of the region, while Microsoft's rectangles are measured
+from their top left corner, like ours. The rectangle is clipped to the
+photo, and regions without one are skipped.
+*/
+func (r *regionFields) faceRegion(format string) (FaceRegion, bool) {
+	result := FaceRegion{Name: r.name}
+
+	switch format {
+	case regionFormatMWG:
+		if r.kind != "" && !strings.EqualFold(r.kind, "Face") {
+			return result, false
+		}
+
+		if r.unit != "" && r.unit != "normalized" {
+			return result, false
+		}
+
+		for _, has := range r.hasArea {
+			if !has {
+				return result, false
+			}
+		}
+
+		result.Width, result.Height = r.area[2], r.area[3]
+		result.X, result.Y = r.area[0]-result.Width/2, r.area[1]-result.Height/2
+
+	case regionFormatMicrosoft:
+		values := strings.Split(r.rectangle, ",")
+
+		if len(values) != 4 {
+			return result, false
+		}
+
+		numbers := [4]float64{}
+
+		for index, value := range values {
+			number, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+
+			if err != nil {
+				return result, false
+			}
+
+			numbers[index] = number
+		}
+
+		result.X, result.Y, result.Width, result.Height = numbers[0], numbers[1], numbers[2], numbers[3]
+
+	default:
+		return result, false
+	}
+
+	right := math.Min(result.X+result.Width, 1)
+	bottom := math.Min(result.Y+result.Height, 1)
+	result.X, result.Y = math.Max(result.X, 0), math.Max(result.Y, 0)
+	result.Width, result.Height = right-result.X, bottom-result.Y
+
+	if result.Width <= 0 || result.Height <= 0 {
+		return result, false
+	}
+
+	return result, true
+}
@@ -0,0 +1,77 @@
+package metadata
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+/*
+sidecarPathFor returns the sidecar a photo's changes are written to:
+its existing sidecar, or a new one named like Lightroom names them,
+with the photo's extension replaced by ".xmp".
+*/
+func sidecarPathFor(photoPath string) (string, bool) {
+	if sidecarPath := FindSidecar(photoPath); sidecarPath != "" {
+		return sidecarPath, true
+	}
+
+	return strings.TrimSuffix(photoPath, filepath.Ext(photoPath)) + ".xmp", false
+}
+
+/*
+writeFileAtomically replaces a file's contents by writing a temporary
+file next to it, then renaming it over the original. A failed write
+never leaves a half written file behind. An existing file's
+permissions are kept.
+*/
+func writeFileAtomically(path string, content []byte) error {
+	var (
+		err  error
+		temp *os.File
+		info fs.FileInfo
+	)
+
+	mode := fs.FileMode(0644)
+
+	if info, err = os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	if temp, err = os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp"); err != nil {
+		return fmt.Errorf("error creating a temporary file for '%s': %w", path, err)
+	}
+
+	tempPath := temp.Name()
+
+	cleanUp := func(err error) error {
+		_ = temp.Close()
+		_ = os.Remove(tempPath)
+		return err
+	}
+
+	if _, err = temp.Write(content); err != nil {
+		return cleanUp(fmt.Errorf("error writing '%s': %w", path, err))
+	}
+
+	if err = temp.Sync(); err != nil {
+		return cleanUp(fmt.Errorf("error writing '%s': %w", path, err))
+	}
+
+	if err = temp.Chmod(mode); err != nil {
+		return cleanUp(fmt.Errorf("error setting permissions on '%s': %w", path, err))
+	}
+
+	if err = temp.Close(); err != nil {
+		return cleanUp(fmt.Errorf("error writing '%s': %w", path, err))
+	}
+
+	if err = os.Rename(tempPath, path); err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("error replacing '%s': %w", path, err)
+	}
+
+	return nil
+}
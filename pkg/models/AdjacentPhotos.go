@@ -0,0 +1,15 @@
+package models
+
+/*
+AdjacentPhotos is where a photo sits in a list of photos, such as a
+folder or search results, for moving to the photos around it.
+PreviousID and NextID are empty at either end of the list. Position
+starts at 1, and is 0 when the photo isn't in the list.
+*/
+type AdjacentPhotos struct {
+	ID         string
+	PreviousID string
+	NextID     string
+	Position   int
+	Total      int
+}
@@ -0,0 +1,37 @@
+package models
+
+/*
+Album is a collection of photos from any folder. KeyPhotoID is the
+cover photo, or the first photo in the album when no cover has been
+chosen, and is empty for an empty album.
+
+Smart albums have no list of photos. They hold a saved search
+instead, which is run whenever the album is viewed or counted.
+*/
+type Album struct {
+	ID             int64
+	Name           string
+	SortOrder      int
+	CoverPhotoID   string
+	NumPhotos      int
+	KeyPhotoID     string
+	IsSmart        bool
+	SearchTerm     string
+	SearchKeywords DbStringSlice
+	SearchPeople   DbStringSlice
+	SearchMatch    SearchMatch
+}
+
+/*
+Criteria returns the saved search of a smart album, for a page of
+results.
+*/
+func (a *Album) Criteria(page int) PhotoSearch {
+	return PhotoSearch{
+		Keywords:   a.SearchKeywords,
+		Match:      NewSearchMatch(string(a.SearchMatch)),
+		Page:       page,
+		People:     a.SearchPeople,
+		SearchTerm: a.SearchTerm,
+	}
+}
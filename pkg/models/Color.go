@@ -0,0 +1,243 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+/*
+ColorNames are the names colors are searched by, such as
+"color:yellow". Every color in a palette is given the one it's
+closest to.
+*/
+var ColorNames = []string{"red", "orange", "yellow", "green", "teal", "blue", "purple", "pink", "brown", "black", "gray", "white"}
+
+/*
+colorNameAliases are other spellings of color names.
+*/
+var colorNameAliases = map[string]string{
+	"grey":    "gray",
+	"cyan":    "teal",
+	"violet":  "purple",
+	"magenta": "pink",
+}
+
+/*
+Color is one of the dominant colors of a photo. Weight is the
+fraction of the photo it covers, and Name is the closest of
+ColorNames.
+*/
+type Color struct {
+	Hex    string  `json:"hex"`
+	Name   string  `json:"name"`
+	Red    uint8   `json:"red"`
+	Green  uint8   `json:"green"`
+	Blue   uint8   `json:"blue"`
+	Weight float64 `json:"weight"`
+}
+
+func NewColor(red, green, blue uint8, weight float64) *Color {
+	return &Color{
+		Hex:    fmt.Sprintf("#%02x%02x%02x", red, green, blue),
+		Name:   nameColor(red, green, blue),
+		Red:    red,
+		Green:  green,
+		Blue:   blue,
+		Weight: weight,
+	}
+}
+
+/*
+ParseHexColor reads a color written as "#rrggbb" or "#rgb". The "#"
+is optional.
+*/
+func ParseHexColor(value string) (*Color, bool) {
+	hex := strings.TrimPrefix(strings.ToLower(value), "#")
+
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+
+	if len(hex) != 6 {
+		return nil, false
+	}
+
+	rgb, err := strconv.ParseUint(hex, 16, 32)
+
+	if err != nil {
+		return nil, false
+	}
+
+	return NewColor(uint8(rgb>>16), uint8(rgb>>8), uint8(rgb), 0), true
+}
+
+/*
+NewColorName returns the color name for a value, or an empty string
+if it isn't one.
+*/
+func NewColorName(value string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+
+	if alias, ok := colorNameAliases[value]; ok {
+		return alias
+	}
+
+	for _, name := range ColorNames {
+		if name == value {
+			return name
+		}
+	}
+
+	return ""
+}
+
+/*
+Percent returns how much of the photo the color covers, rounded to a
+whole percent.
+*/
+func (c *Color) Percent() int {
+	return int(math.Round(c.Weight * 100))
+}
+
+/*
+nameColor finds the name closest to a color by its hue, saturation,
+and lightness. Dull colors are black, gray, or white depending on
+how light they are. Dark oranges and yellows are brown, and light
+reds are pink.
+*/
+func nameColor(red, green, blue uint8) string {
+	r, g, b := float64(red)/255, float64(green)/255, float64(blue)/255
+	highest, lowest := max(r, g, b), min(r, g, b)
+	lightness := (highest + lowest) / 2
+	chroma := highest - lowest
+
+	if lightness < 0.12 {
+		return "black"
+	}
+
+	if lightness > 0.92 {
+		return "white"
+	}
+
+	saturation := chroma / (1 - math.Abs(2*lightness-1))
+
+	if chroma < 0.1 || saturation < 0.12 {
+		if lightness < 0.25 {
+			return "black"
+		}
+
+		if lightness > 0.8 {
+			return "white"
+		}
+
+		return "gray"
+	}
+
+	hue := 0.0
+
+	switch highest {
+	case r:
+		hue = math.Mod((g-b)/chroma+6, 6) * 60
+
+	case g:
+		hue = ((b-r)/chroma + 2) * 60
+
+	default:
+		hue = ((r-g)/chroma + 4) * 60
+	}
+
+	switch {
+	case hue < 15 || hue >= 345:
+		if lightness > 0.75 {
+			return "pink"
+		}
+
+		return "red"
+
+	case hue < 45:
+		if lightness < 0.45 || saturation < 0.45 {
+			return "brown"
+		}
+
+		return "orange"
+
+	case hue < 70:
+		if lightness < 0.3 {
+			return "brown"
+		}
+
+		return "yellow"
+
+	case hue < 165:
+		return "green"
+
+	case hue < 200:
+		return "teal"
+
+	case hue < 260:
+		return "blue"
+
+	case hue < 290:
+		return "purple"
+	}
+
+	if lightness < 0.35 {
+		return "purple"
+	}
+
+	return "pink"
+}
+
+/*
+DbColorSlice is a photo's palette, stored as JSON. A photo whose
+palette hasn't been worked out yet has a nil palette, stored as NULL.
+A photo whose palette was worked out but has no dominant colors has
+an empty one, stored as an empty JSON array.
+*/
+type DbColorSlice []*Color
+
+func (s *DbColorSlice) Scan(src any) error {
+	var (
+		b []byte
+	)
+
+	switch v := src.(type) {
+	case nil:
+		*s = nil
+		return nil
+
+	case string:
+		b = []byte(v)
+
+	case []byte:
+		b = v
+
+	default:
+		return fmt.Errorf("can't scan type %T into DbColorSlice", v)
+	}
+
+	if len(b) == 0 {
+		*s = nil
+		return nil
+	}
+
+	return json.Unmarshal(b, s)
+}
+
+func (s DbColorSlice) Value() (driver.Value, error) {
+	if s == nil {
+		return nil, nil
+	}
+
+	b, err := json.Marshal(s)
+
+	if err != nil {
+		return nil, fmt.Errorf("error converting colors to JSON: %w", err)
+	}
+
+	return string(b), nil
+}
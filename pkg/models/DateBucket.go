@@ -0,0 +1,82 @@
+package models
+
+import (
+	"time"
+)
+
+/*
+DateGrouping is the size of the date buckets photos are grouped into
+on the timeline.
+*/
+type DateGrouping string
+
+const (
+	GroupByYear  DateGrouping = "year"
+	GroupByMonth DateGrouping = "month"
+	GroupByDay   DateGrouping = "day"
+)
+
+/*
+DateBucket is a count of photos taken in a year, month, or day.
+Period is formatted as "2019", "2019-07", or "2019-07-14", depending
+on the grouping.
+*/
+type DateBucket struct {
+	Period       string
+	NumPhotos    int
+	NumEstimated int
+}
+
+/*
+Grouping returns whether this bucket is a year, month, or day.
+*/
+func (b DateBucket) Grouping() DateGrouping {
+	switch len(b.Period) {
+	case 4:
+		return GroupByYear
+
+	case 7:
+		return GroupByMonth
+	}
+
+	return GroupByDay
+}
+
+/*
+Label returns a short, readable name for the bucket's period, such
+as "2019", "July", or "Sun 14".
+*/
+func (b DateBucket) Label() string {
+	start := b.Start()
+
+	if start.IsZero() {
+		return b.Period
+	}
+
+	switch b.Grouping() {
+	case GroupByYear:
+		return start.Format("2006")
+
+	case GroupByMonth:
+		return start.Format("January")
+	}
+
+	return start.Format("Mon 2")
+}
+
+/*
+Start returns the first moment of the bucket's period.
+*/
+func (b DateBucket) Start() time.Time {
+	for _, layout := range []string{"2006-01-02", "2006-01", "2006"} {
+		if len(b.Period) != len(layout) {
+			continue
+		}
+
+		if t, err := time.Parse(layout, b.Period); err == nil {
+			return t
+		}
+	}
+
+	return time.Time{}
+}
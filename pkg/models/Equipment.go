@@ -0,0 +1,92 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+/*
+EquipmentKind is the type of gear a photo was taken with.
+*/
+type EquipmentKind string
+
+const (
+	EquipmentCamera EquipmentKind = "camera"
+	EquipmentLens   EquipmentKind = "lens"
+)
+
+/*
+Equipment is a camera or lens with the number of photos taken with
+it. FirstTaken and LastTaken are dates formatted as "2019-07-14", and
+are empty when none of the photos are dated.
+*/
+type Equipment struct {
+	Kind       EquipmentKind
+	ID         string
+	Name       string
+	NumPhotos  int
+	FirstTaken string
+	LastTaken  string
+	KeyPhotoID string
+}
+
+/*
+FirstTakenTime returns the date of the earliest photo taken with this
+equipment.
+*/
+func (e *Equipment) FirstTakenTime() time.Time {
+	result, _ := time.Parse(time.DateOnly, e.FirstTaken)
+	return result
+}
+
+/*
+LastTakenTime returns the date of the latest photo taken with this
+equipment.
+*/
+func (e *Equipment) LastTakenTime() time.Time {
+	result, _ := time.Parse(time.DateOnly, e.LastTaken)
+	return result
+}
+
+/*
+NormalizeLensID returns an identifier for a lens that is the same no
+matter how the software writing the metadata formatted its name. Case
+and spacing are ignored, and the make is dropped from the front of the
+model, so "Canon EF 50mm f/1.8 STM" and "EF50mm F/1.8 STM" get the
+same ID. An empty string is returned when the lens model isn't known.
+*/
+func NormalizeLensID(lensMake, lensModel string) string {
+	model := strings.ToLower(strings.Join(strings.Fields(lensModel), " "))
+	firstWord, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(lensMake)), " ")
+
+	if firstWord != "" {
+		model = strings.TrimPrefix(model, firstWord+" ")
+	}
+
+	return strings.ReplaceAll(model, " ", "")
+}
+
+/*
+CameraID returns the identifier photos are grouped by on the gear page
+for a camera make and model. Case and surrounding spaces are ignored.
+*/
+func CameraID(maker, model string) string {
+	return strings.ToLower(strings.TrimSpace(maker)) + "|" + strings.ToLower(strings.TrimSpace(model))
+}
+
+/*
+EquipmentName joins a make and model for display. Models often start
+with the make already, as in "NIKON CORPORATION" and "NIKON D850", in
+which case the model is used alone.
+*/
+func EquipmentName(maker, model string) string {
+	maker = strings.TrimSpace(maker)
+	model = strings.TrimSpace(model)
+	firstWord, _, _ := strings.Cut(maker, " ")
+
+	if maker == "" || strings.HasPrefix(strings.ToLower(model), strings.ToLower(firstWord)) {
+		return model
+	}
+
+	return maker + " " + model
+}
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"time"
+)
+
+/*
+Event is a run of photos taken close together, in time and place.
+Events are worked out from the photos, but can be given a name.
+PlaceName is the name of the city most of its photos were taken in,
+and is empty when none of them has a location. KeyPhotoID is the
+cover photo, or the first photo of the event if the cover is gone.
+*/
+type Event struct {
+	ID            int64
+	Name          string
+	StartDateTime time.Time
+	EndDateTime   time.Time
+	NumPhotos     int
+	PlaceID       *int64
+	PlaceName     string
+	Latitude      *float64
+	Longitude     *float64
+	CoverPhotoID  string
+	KeyPhotoID    string
+}
+
+/*
+Title returns the event's name, or where it happened when it hasn't
+been named, such as "Brighton". Events without either are called
+after the day they started, such as "Saturday".
+*/
+func (e *Event) Title() string {
+	if e.Name != "" {
+		return e.Name
+	}
+
+	if e.PlaceName != "" {
+		return e.PlaceName
+	}
+
+	return e.StartDateTime.Format("Monday")
+}
+
+/*
+DateLabel returns when the event happened, as briefly as it reads
+clearly, such as "12 July 2023", "12–15 July 2023", "30 June – 2 July
+2023", or "30 December 2023 – 2 January 2024".
+*/
+func (e *Event) DateLabel() string {
+	start, end := e.StartDateTime, e.EndDateTime
+
+	switch {
+	case start.Year() != end.Year():
+		return start.Format("2 January 2006") + " – " + end.Format("2 January 2006")
+
+	case start.Month() != end.Month():
+		return start.Format("2 January") + " – " + end.Format("2 January 2006")
+
+	case start.Day() != end.Day():
+		return start.Format("2") + "–" + end.Format("2 January 2006")
+	}
+
+	return start.Format("2 January 2006")
+}
+
+/*
+Year returns the year the event started in.
+*/
+func (e *Event) Year() int {
+	return e.StartDateTime.Year()
+}
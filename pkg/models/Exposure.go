@@ -0,0 +1,129 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+/*
+Exposure holds the camera settings a photo was taken with. Values
+missing from the photo's EXIF data are zero. ExposureTime is in
+seconds, and Flash and ExposureProgram are the raw EXIF values.
+*/
+type Exposure struct {
+	FNumber         float64
+	ExposureTime    float64
+	ISO             int
+	FocalLength     float64
+	FocalLength35mm int
+	Flash           int
+	ExposureProgram int
+}
+
+/*
+exposurePrograms are the names of the EXIF ExposureProgram values.
+*/
+var exposurePrograms = map[int]string{
+	1: "Manual",
+	2: "Program",
+	3: "Aperture priority",
+	4: "Shutter priority",
+	5: "Creative",
+	6: "Action",
+	7: "Portrait",
+	8: "Landscape",
+}
+
+/*
+HasExposure returns true if any of the shooting settings are known.
+*/
+func (e Exposure) HasExposure() bool {
+	return e.FNumber > 0 || e.ExposureTime > 0 || e.ISO > 0 || e.FocalLength > 0
+}
+
+/*
+Aperture returns the f-number formatted as "f/2.8".
+*/
+func (e Exposure) Aperture() string {
+	if e.FNumber <= 0 {
+		return ""
+	}
+
+	return "f/" + strconv.FormatFloat(math.Round(e.FNumber*10)/10, 'f', -1, 64)
+}
+
+/*
+ShutterSpeed returns the exposure time formatted the way cameras show
+it, such as "1/250s" or "2.5s".
+*/
+func (e Exposure) ShutterSpeed() string {
+	if e.ExposureTime <= 0 {
+		return ""
+	}
+
+	if e.ExposureTime < 0.5 {
+		return fmt.Sprintf("1/%ds", int(math.Round(1/e.ExposureTime)))
+	}
+
+	return strconv.FormatFloat(math.Round(e.ExposureTime*10)/10, 'f', -1, 64) + "s"
+}
+
+/*
+FocalLengthLabel returns the focal length, with the 35mm equivalent
+when it's different, such as "23mm (35mm equivalent)".
+*/
+func (e Exposure) FocalLengthLabel() string {
+	if e.FocalLength <= 0 {
+		return ""
+	}
+
+	result := strconv.FormatFloat(math.Round(e.FocalLength*10)/10, 'f', -1, 64) + "mm"
+
+	if e.FocalLength35mm > 0 && e.FocalLength35mm != int(math.Round(e.FocalLength)) {
+		result += fmt.Sprintf(" (%dmm equivalent)", e.FocalLength35mm)
+	}
+
+	return result
+}
+
+/*
+FlashFired returns true if the flash fired. This is the lowest bit of
+the EXIF Flash value.
+*/
+func (e Exposure) FlashFired() bool {
+	return e.Flash&1 == 1
+}
+
+/*
+ProgramName returns the name of the exposure program, such as
+"Aperture priority", or an empty string if it isn't known.
+*/
+func (e Exposure) ProgramName() string {
+	return exposurePrograms[e.ExposureProgram]
+}
+
+/*
+Summary returns the main settings on one line, such as
+"f/2.8 · 1/250s · ISO 400 · 35mm".
+*/
+func (e Exposure) Summary() string {
+	parts := []string{}
+
+	for _, part := range []string{e.Aperture(), e.ShutterSpeed(), e.isoLabel(), e.FocalLengthLabel()} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+
+	return strings.Join(parts, " · ")
+}
+
+func (e Exposure) isoLabel() string {
+	if e.ISO <= 0 {
+		return ""
+	}
+
+	return "ISO " + strconv.Itoa(e.ISO)
+}
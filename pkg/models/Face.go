@@ -0,0 +1,122 @@
+package models
+
+import (
+	"strconv"
+)
+
+const (
+	// FaceSourceMetadata is a face read from a photo's metadata
+	FaceSourceMetadata = "metadata"
+
+	// FaceSourceDetected is a face found by face detection
+	FaceSourceDetected = "detected"
+)
+
+/*
+Face is a rectangle around a face in a photo, either a named region
+from its metadata, as tools like Picasa, Lightroom, and digiKam store
+them in XMP data, or one found by face detection. X, Y, Width, and
+Height are fractions of the photo's width and height, measured from
+its top left corner. When read from the database, Name is the linked
+person's current name, if the face is linked to one, and PersonID is
+0 when it isn't.
+
+Descriptor, ClusterID, and SuggestedPersonID are only filled in for
+face detection and the review queue. Descriptor is what faces are
+compared by. ClusterID groups unnamed faces that look like the same
+person, and SuggestedPersonID is the named person they look most like.
+*/
+type Face struct {
+	ID                uint
+	PhotoID           string
+	PersonID          uint
+	Name              string
+	X                 float64
+	Y                 float64
+	Width             float64
+	Height            float64
+	Source            string
+	Descriptor        []byte
+	ClusterID         int64
+	SuggestedPersonID uint
+}
+
+/*
+Overlaps returns true if two faces cover mostly the same part of a
+photo, so they are most likely the same face.
+*/
+func (f *Face) Overlaps(other *Face) bool {
+	overlapWidth := min(f.X+f.Width, other.X+other.Width) - max(f.X, other.X)
+	overlapHeight := min(f.Y+f.Height, other.Y+other.Height) - max(f.Y, other.Y)
+
+	if overlapWidth <= 0 || overlapHeight <= 0 {
+		return false
+	}
+
+	overlap := overlapWidth * overlapHeight
+	union := f.Width*f.Height + other.Width*other.Height - overlap
+
+	return overlap/union > 0.3
+}
+
+/*
+SameRegion returns true if two faces have the same name and cover
+the same rectangle, to the precision face regions are compared at.
+*/
+func (f *Face) SameRegion(other *Face) bool {
+	return f.Name == other.Name &&
+		formatCoordinate(f.X) == formatCoordinate(other.X) &&
+		formatCoordinate(f.Y) == formatCoordinate(other.Y) &&
+		formatCoordinate(f.Width) == formatCoordinate(other.Width) &&
+		formatCoordinate(f.Height) == formatCoordinate(other.Height)
+}
+
+/*
+FaceCluster is a group of unnamed faces that look like the same
+person, waiting to be named in the review queue. NumFaces counts
+every face in the cluster, while Faces holds only the first few.
+SuggestedName is the person they look most like, if anyone.
+*/
+type FaceCluster struct {
+	ID                int64
+	NumFaces          int
+	Faces             []*Face
+	SuggestedPersonID uint
+	SuggestedName     string
+}
+
+/*
+Left, Top, WidthPercent, and HeightPercent return the rectangle as
+percentages, ready to position a box over the photo.
+*/
+func (f *Face) Left() string {
+	return formatPercent(f.X)
+}
+
+func (f *Face) Top() string {
+	return formatPercent(f.Y)
+}
+
+func (f *Face) WidthPercent() string {
+	return formatPercent(f.Width)
+}
+
+func (f *Face) HeightPercent() string {
+	return formatPercent(f.Height)
+}
+
+/*
+CropURL returns the URL of the square crop of this face used as a
+person's avatar.
+*/
+func (f *Face) CropURL() string {
+	return "/faces/" + strconv.FormatUint(uint64(f.ID), 10)
+}
+
+func formatPercent(fraction float64) string {
+	return strconv.FormatFloat(fraction*100, 'f', 2, 64)
+}
+
+func formatCoordinate(fraction float64) string {
+	return strconv.FormatFloat(fraction, 'f', 4, 64)
+}
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/*
+GeoBounds is a latitude/longitude bounding box. West can be greater
+than East when the box crosses the antimeridian.
+*/
+type GeoBounds struct {
+	West  float64
+	South float64
+	East  float64
+	North float64
+}
+
+/*
+ParseGeoBounds parses a bounding box in the GeoJSON order
+"west,south,east,north".
+*/
+func ParseGeoBounds(value string) (GeoBounds, error) {
+	var (
+		err    error
+		result GeoBounds
+	)
+
+	parts := strings.Split(value, ",")
+
+	if len(parts) != 4 {
+		return result, fmt.Errorf("bounding box '%s' must have four values: west,south,east,north", value)
+	}
+
+	values := make([]float64, 4)
+
+	for index, part := range parts {
+		if values[index], err = strconv.ParseFloat(strings.TrimSpace(part), 64); err != nil {
+			return result, fmt.Errorf("bounding box '%s' has an invalid number '%s'", value, part)
+		}
+	}
+
+	result = GeoBounds{West: values[0], South: values[1], East: values[2], North: values[3]}
+
+	if result.South < -90 || result.North > 90 || result.South > result.North {
+		return result, fmt.Errorf("bounding box '%s' has invalid latitudes", value)
+	}
+
+	return result, nil
+}
+
+/*
+String returns the bounds in the GeoJSON order "west,south,east,north".
+*/
+func (b GeoBounds) String() string {
+	return strings.Join([]string{
+		strconv.FormatFloat(b.West, 'f', -1, 64),
+		strconv.FormatFloat(b.South, 'f', -1, 64),
+		strconv.FormatFloat(b.East, 'f', -1, 64),
+		strconv.FormatFloat(b.North, 'f', -1, 64),
+	}, ",")
+}
+
+/*
+GeoCluster is a group of photos taken close to each other. Latitude
+and Longitude are the center of the photos in the cluster, and Bounds
+covers all of them.
+*/
+type GeoCluster struct {
+	Latitude   float64
+	Longitude  float64
+	NumPhotos  int
+	KeyPhotoID string
+	Bounds     GeoBounds
+}
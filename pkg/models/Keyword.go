@@ -1,5 +1,191 @@
 package models
 
+import (
+	"slices"
+	"sort"
+	"strings"
+)
+
+/*
+KeywordPathSeparator separates the levels of a hierarchical keyword,
+as Lightroom writes them, such as "Places|Italy|Rome".
+*/
+const KeywordPathSeparator = "|"
+
+/*
+Keyword is a keyword on a photo. Parent is the keyword above it in
+the keyword hierarchy, and is empty for top level keywords, or when
+the photo was read from somewhere that doesn't say.
+*/
 type Keyword struct {
 	Keyword string
+	Parent  string
+}
+
+/*
+KeywordNode is a keyword in the keyword tree, with the number of
+photos that have it.
+*/
+type KeywordNode struct {
+	Keyword   string
+	Parent    string
+	NumPhotos int
+	Children  []*KeywordNode
+}
+
+/*
+ExpandKeywords turns keyword names into keywords, expanding each
+hierarchical keyword into every level of it, with its parent. Names
+are trimmed, and duplicates are removed ignoring case, keeping the
+first spelling and the first parent found.
+*/
+func ExpandKeywords(names []string) []*Keyword {
+	result := []*Keyword{}
+	seen := map[string]*Keyword{}
+
+	for _, name := range names {
+		for _, keyword := range ParseKeywordPath(name) {
+			key := strings.ToLower(keyword.Keyword)
+
+			if existing, ok := seen[key]; ok {
+				if existing.Parent == "" {
+					existing.Parent = keyword.Parent
+				}
+
+				continue
+			}
+
+			seen[key] = keyword
+			result = append(result, keyword)
+		}
+	}
+
+	return result
+}
+
+/*
+ParseKeywordPath splits a hierarchical keyword into its levels, top
+level first, each with the level above it as its parent. A keyword
+without levels is returned on its own. Empty levels are skipped.
+*/
+func ParseKeywordPath(path string) []*Keyword {
+	result := []*Keyword{}
+	parent := ""
+
+	for _, level := range strings.Split(path, KeywordPathSeparator) {
+		if level = strings.TrimSpace(level); level == "" {
+			continue
+		}
+
+		result = append(result, &Keyword{Keyword: level, Parent: parent})
+		parent = level
+	}
+
+	return result
+}
+
+/*
+BuildKeywordTree arranges keywords under their parents, sorted by
+name. Keywords whose parent isn't in the list are put at the top. So
+are keywords caught in a loop, such as two files each putting one
+keyword under the other, so every keyword appears once.
+*/
+func BuildKeywordTree(keywords []*KeywordSearchResult) []*KeywordNode {
+	nodes := map[string]*KeywordNode{}
+	result := []*KeywordNode{}
+
+	for _, keyword := range keywords {
+		nodes[keyword.Keyword] = &KeywordNode{
+			Keyword:   keyword.Keyword,
+			Parent:    keyword.Parent,
+			NumPhotos: keyword.NumMatches,
+			Children:  []*KeywordNode{},
+		}
+	}
+
+	for _, keyword := range keywords {
+		node := nodes[keyword.Keyword]
+
+		if parent, ok := nodes[node.Parent]; ok && parent != node {
+			parent.Children = append(parent.Children, node)
+		}
+	}
+
+	placed := map[*KeywordNode]bool{}
+
+	var place func(node *KeywordNode)
+
+	place = func(node *KeywordNode) {
+		placed[node] = true
+
+		for _, child := range node.Children {
+			if !placed[child] {
+				place(child)
+			}
+		}
+	}
+
+	for _, keyword := range keywords {
+		node := nodes[keyword.Keyword]
+
+		if parent, ok := nodes[node.Parent]; !ok || parent == node {
+			result = append(result, node)
+			place(node)
+		}
+	}
+
+	for _, keyword := range keywords {
+		if node := nodes[keyword.Keyword]; !placed[node] {
+			parent := nodes[node.Parent]
+			parent.Children = slices.DeleteFunc(parent.Children, func(child *KeywordNode) bool {
+				return child == node
+			})
+
+			node.Parent = ""
+			result = append(result, node)
+			place(node)
+		}
+	}
+
+	sortKeywordNodes(result)
+	return result
+}
+
+/*
+FindCaseDuplicates returns groups of keywords that differ only by
+case, such as "christmas" and "Christmas", which are probably meant
+to be the same keyword.
+*/
+func FindCaseDuplicates(keywords []*KeywordSearchResult) [][]*KeywordSearchResult {
+	result := [][]*KeywordSearchResult{}
+	groups := map[string][]*KeywordSearchResult{}
+	order := []string{}
+
+	for _, keyword := range keywords {
+		key := strings.ToLower(keyword.Keyword)
+
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+
+		groups[key] = append(groups[key], keyword)
+	}
+
+	for _, key := range order {
+		if len(groups[key]) > 1 {
+			result = append(result, groups[key])
+		}
+	}
+
+	return result
+}
+
+func sortKeywordNodes(nodes []*KeywordNode) {
+	sort.Slice(nodes, func(i, j int) bool {
+		return strings.ToLower(nodes[i].Keyword) < strings.ToLower(nodes[j].Keyword)
+	})
+
+	for _, node := range nodes {
+		sortKeywordNodes(node.Children)
+	}
 }
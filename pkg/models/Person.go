@@ -1,6 +1,20 @@
 package models
 
+/*
+Person is someone in a photo. NumPhotos, KeyPhotoID, KeyFaceID, and
+Aliases are only filled in by the people directory and person pages.
+KeyPhotoID is the cover photo, or their most recent photo when no
+cover has been chosen. KeyFaceID is their face in the cover photo, or
+in their most recent photo with a face region for them, and is used
+as their avatar. It's 0 when there's no such face. Aliases are the
+other names they're known by in photos' metadata.
+*/
 type Person struct {
 	BaseModel
-	Name string
+	Name         string
+	CoverPhotoID string
+	KeyPhotoID   string
+	KeyFaceID    uint
+	NumPhotos    int
+	Aliases      DbStringSlice
 }
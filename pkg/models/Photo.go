@@ -1,6 +1,8 @@
 package models
 
 import (
+	"cmp"
+	"database/sql"
 	"hash/fnv"
 	"os"
 	"path/filepath"
@@ -20,6 +22,7 @@ type Photo struct {
 	ID         string
 	mutex      *sync.Mutex `hash:"ignore"`
 	TotalCount int         `hash:"ignore"`
+	IsFavorite bool        `hash:"ignore"`
 
 	FileName         string
 	Ext              string
@@ -41,13 +44,39 @@ type Photo struct {
 	Longitude        float64
 	IptcDigest       string
 	Year             string
+	BlurHash         string
+	Colors           DbColorSlice
+	DateIsEstimated  bool
+	PlaceID          *int64
+	Rating           int
+	Label            ColorLabel
+	Exposure
+
+	/*
+	 * ThumbnailCreatedAt is when the thumbnail, placeholder hash, and
+	 * palette were last made, and ThumbnailSize is the size the
+	 * thumbnail was made at. They're not set for photos collected
+	 * before they were recorded.
+	 */
+	ThumbnailCreatedAt sql.NullTime
+	ThumbnailSize      int
+
+	/*
+	 * Faces are the named face regions read from the photo's
+	 * metadata. They're nil when the photo was loaded from the
+	 * database, in which case saving it keeps the faces it has.
+	 * FacesHash identifies the faces the photo was last saved with.
+	 */
+	Faces     []*Face
+	FacesHash string
 }
 
-func NewPhotoFromImageData(imagePathAndName string, imageData *imagemodel.ImageData) *Photo {
+func NewPhotoFromImageData(imagePathAndName string, imageData *imagemodel.ImageData, exposure Exposure) *Photo {
 	ext := filepath.Ext(imagePathAndName)
 	cleanFileName := strings.TrimSuffix(filepath.Base(imagePathAndName), ext)
 	creationDateTime := determineCreationDateTime(imageData.CreationDateTime)
 
+	lensMake := strings.TrimSpace(imageData.LensMake)
 	caption := imageData.CaptionEXIF
 	title := imageData.TitleXMP
 
@@ -69,18 +98,14 @@ func NewPhotoFromImageData(imagePathAndName string, imageData *imagemodel.ImageD
 		FileName:  cleanFileName,
 		Ext:       ext,
 		FullPath:  filepath.Dir(imagePathAndName),
-		LensMake:  strings.TrimSpace(imageData.LensMake),
+		LensMake:  lensMake,
 		LensModel: strings.TrimSpace(imageData.LensModel),
-		LensID:    "",
+		LensID:    NormalizeLensID(cmp.Or(lensMake, imageData.Make), imageData.LensModel),
 		Make:      strings.TrimSpace(imageData.Make),
 		Model:     strings.TrimSpace(imageData.Model),
-		Keywords: slices.Map(imageData.Keywords, func(input string, index int) *Keyword {
-			return &Keyword{
-				Keyword: input,
-			}
-		}),
-		Caption: strings.TrimSpace(caption),
-		Title:   strings.TrimSpace(caption),
+		Keywords:  ExpandKeywords(imageData.Keywords),
+		Caption:   strings.TrimSpace(caption),
+		Title:     strings.TrimSpace(title),
 		People: slices.Map(imageData.People, func(input string, index int) *Person {
 			return &Person{
 				Name: input,
@@ -93,6 +118,7 @@ func NewPhotoFromImageData(imagePathAndName string, imageData *imagemodel.ImageD
 		Longitude:        imageData.Longitude,
 		IptcDigest:       "",
 		Year:             determineYear(imageData.Keywords, creationDateTime),
+		Exposure:         exposure,
 	}
 
 	result.MetadataHash = result.GenerateMetadataHash()
@@ -135,6 +161,75 @@ func (p *Photo) GenerateMetadataHash() string {
 	return strconv.FormatUint(sum, 10)
 }
 
+/*
+GenerateFacesHash returns a hash of the photo's named face regions.
+It's kept apart from the metadata hash, as photos loaded from the
+database don't have their faces, and is empty when the photo has none.
+*/
+func (p *Photo) GenerateFacesHash() string {
+	if len(p.Faces) == 0 {
+		return ""
+	}
+
+	h := fnv.New64a()
+
+	faces := slices.Map(p.Faces, func(f *Face, index int) string {
+		return f.Name + ":" + formatCoordinate(f.X) + "," + formatCoordinate(f.Y) + "," +
+			formatCoordinate(f.Width) + "," + formatCoordinate(f.Height)
+	})
+
+	sort.Strings(faces)
+	h.Write([]byte(strings.Join(faces, ";")))
+
+	return strconv.FormatUint(h.Sum64(), 10)
+}
+
+/*
+RenditionThumbnail is the rendition name of a photo's thumbnail.
+*/
+const RenditionThumbnail = "thumbnail"
+
+/*
+ETag returns a strong entity tag for a rendition of this photo, such
+as the original or its thumbnail. It changes whenever the photo's
+metadata hash changes. The thumbnail's also changes whenever the
+thumbnail is made again, such as at a new size.
+*/
+func (p *Photo) ETag(rendition string) string {
+	h := fnv.New64a()
+	h.Write([]byte(p.ID + "_" + p.MetadataHash + "_" + rendition))
+
+	if rendition == RenditionThumbnail {
+		h.Write([]byte("_" + strconv.Itoa(p.ThumbnailSize) + "_" + p.ThumbnailCreatedAt.Time.UTC().Format(time.RFC3339Nano)))
+	}
+
+	return `"` + strconv.FormatUint(h.Sum64(), 36) + `"`
+}
+
+/*
+ImageURL returns the versioned URL for the original photo.
+*/
+func (p *Photo) ImageURL() string {
+	return "/library/" + p.ID + "?v=" + p.MetadataHash
+}
+
+/*
+ThumbnailURL returns the versioned URL for the photo's thumbnail. The
+version changes when the thumbnail does, so responses for it can be
+cached indefinitely.
+*/
+func (p *Photo) ThumbnailURL() string {
+	return "/library/" + p.ID + "/thumbnail?v=" + p.ThumbnailVersion()
+}
+
+/*
+ThumbnailVersion returns the version of the photo's thumbnail used in
+its URL, which is its entity tag without the quotes.
+*/
+func (p *Photo) ThumbnailVersion() string {
+	return strings.Trim(p.ETag(RenditionThumbnail), `"`)
+}
+
 func (p *Photo) String() string {
 	r := &strings.Builder{}
 
@@ -166,6 +261,12 @@ func (p *Photo) String() string {
 	r.WriteString("  Width: " + strconv.Itoa(p.Width) + "\n")
 	r.WriteString("  Height: " + strconv.Itoa(p.Height) + "\n")
 	r.WriteString("  Year: " + p.Year + "\n")
+	r.WriteString("  BlurHash: " + p.BlurHash + "\n")
+	r.WriteString("  Colors: " + strings.Join(slices.Map(p.Colors, func(c *Color, index int) string { return c.Hex }), " ") + "\n")
+	r.WriteString("  Date Is Estimated: " + strconv.FormatBool(p.DateIsEstimated) + "\n")
+	r.WriteString("  Exposure: " + p.Exposure.Summary() + "\n")
+	r.WriteString("  Rating: " + strconv.Itoa(p.Rating) + "\n")
+	r.WriteString("  Label: " + string(p.Label) + "\n")
 
 	return r.String()
 }
@@ -179,6 +280,25 @@ func (p *Photo) GetAlbumPath(libraryPath string) string {
 	return pathMinusLibrary
 }
 
+/*
+EstimateCreationDateTime uses modTime, usually the file's modification
+time, as the creation date when the photo has no date in its metadata.
+The photo is flagged so the date can be shown as an estimate.
+*/
+func (p *Photo) EstimateCreationDateTime(modTime time.Time) {
+	if !p.CreationDateTime.IsZero() || modTime.IsZero() {
+		return
+	}
+
+	keywords := slices.Map(p.Keywords, func(k *Keyword, index int) string {
+		return k.Keyword
+	})
+
+	p.CreationDateTime = modTime.UTC()
+	p.DateIsEstimated = true
+	p.Year = determineYear(keywords, p.CreationDateTime)
+}
+
 func (p *Photo) GetFullPath() string {
 	return filepath.Join(p.FullPath, p.FileName+p.Ext)
 }
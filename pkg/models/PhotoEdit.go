@@ -0,0 +1,149 @@
+package models
+
+import (
+	"strings"
+
+	"github.com/adampresley/adamgokit/slices"
+)
+
+/*
+PhotoEdit is the descriptive metadata that can be edited in the app
+and written back to a photo's file.
+*/
+type PhotoEdit struct {
+	Title    string
+	Caption  string
+	Keywords []string
+	People   []string
+}
+
+/*
+NewPhotoEdit returns the photo's current descriptive metadata, ready
+to be changed.
+*/
+func NewPhotoEdit(photo *Photo) PhotoEdit {
+	return PhotoEdit{
+		Title:    photo.Title,
+		Caption:  photo.Caption,
+		Keywords: photo.KeywordNames(),
+		People: slices.Map(photo.People, func(p *Person, index int) string {
+			return p.Name
+		}),
+	}
+}
+
+/*
+KeywordNames returns the names of the photo's keywords.
+*/
+func (p *Photo) KeywordNames() []string {
+	return slices.Map(p.Keywords, func(k *Keyword, index int) string {
+		return k.Keyword
+	})
+}
+
+/*
+ApplyEdit sets the photo's descriptive metadata. Hierarchical
+keywords, such as "Places|Italy|Rome", add every level of the
+hierarchy. The year, which can come from a keyword, and the metadata
+hash are updated to match, the same way they are when a photo is
+collected.
+*/
+func (p *Photo) ApplyEdit(edit PhotoEdit) {
+	p.Title = strings.TrimSpace(edit.Title)
+	p.Caption = strings.TrimSpace(edit.Caption)
+	p.Keywords = ExpandKeywords(edit.Keywords)
+	p.People = slices.Map(UniqueNames(edit.People), func(input string, index int) *Person {
+		return &Person{
+			Name: input,
+		}
+	})
+	p.Year = determineYear(p.KeywordNames(), p.CreationDateTime)
+	p.MetadataHash = p.GenerateMetadataHash()
+}
+
+/*
+BatchPhotoEdit is a change made to several photos at once. Title and
+caption replace the photo's own when they aren't empty. Keywords and
+people are added to, or removed from, what each photo already has.
+*/
+type BatchPhotoEdit struct {
+	Title          string
+	Caption        string
+	AddKeywords    []string
+	RemoveKeywords []string
+	AddPeople      []string
+	RemovePeople   []string
+}
+
+/*
+IsEmpty returns true if the batch edit wouldn't change anything.
+*/
+func (b BatchPhotoEdit) IsEmpty() bool {
+	return strings.TrimSpace(b.Title) == "" &&
+		strings.TrimSpace(b.Caption) == "" &&
+		len(b.AddKeywords) == 0 &&
+		len(b.RemoveKeywords) == 0 &&
+		len(b.AddPeople) == 0 &&
+		len(b.RemovePeople) == 0
+}
+
+/*
+Apply returns a photo's edit with this batch edit's changes made.
+*/
+func (b BatchPhotoEdit) Apply(edit PhotoEdit) PhotoEdit {
+	if title := strings.TrimSpace(b.Title); title != "" {
+		edit.Title = title
+	}
+
+	if caption := strings.TrimSpace(b.Caption); caption != "" {
+		edit.Caption = caption
+	}
+
+	edit.Keywords = UniqueNames(append(removeNames(edit.Keywords, b.RemoveKeywords), b.AddKeywords...))
+	edit.People = UniqueNames(append(removeNames(edit.People, b.RemovePeople), b.AddPeople...))
+
+	return edit
+}
+
+/*
+SplitNames splits a comma separated list of keywords or people, as
+typed into a form, into its names.
+*/
+func SplitNames(value string) []string {
+	return UniqueNames(strings.Split(value, ","))
+}
+
+/*
+UniqueNames trims names and removes empty ones and duplicates, ignoring
+case. The first spelling of a name is kept.
+*/
+func UniqueNames(names []string) []string {
+	result := []string{}
+	seen := map[string]bool{}
+
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		key := strings.ToLower(name)
+
+		if name == "" || seen[key] {
+			continue
+		}
+
+		seen[key] = true
+		result = append(result, name)
+	}
+
+	return result
+}
+
+func removeNames(names, remove []string) []string {
+	removed := map[string]bool{}
+
+	for _, name := range remove {
+		removed[strings.ToLower(strings.TrimSpace(name))] = true
+	}
+
+	return slices.Filter(names, func(name string) bool {
+		return !removed[strings.ToLower(strings.TrimSpace(name))]
+	})
+}
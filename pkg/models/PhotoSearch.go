@@ -1,8 +1,43 @@
 package models
 
+/*
+SearchMatch controls how multiple keywords or people in a search
+are combined.
+*/
+type SearchMatch string
+
+const (
+	// MatchAll requires a photo to have every keyword and person
+	MatchAll SearchMatch = "all"
+
+	// MatchAny requires a photo to have at least one keyword or person
+	MatchAny SearchMatch = "any"
+)
+
 type PhotoSearch struct {
 	Keywords   []string
+	Match      SearchMatch
 	Page       int
 	People     []string
 	SearchTerm string
 }
+
+/*
+NewSearchMatch converts a string into a SearchMatch. Anything that
+isn't "any" is treated as MatchAll.
+*/
+func NewSearchMatch(value string) SearchMatch {
+	if SearchMatch(value) == MatchAny {
+		return MatchAny
+	}
+
+	return MatchAll
+}
+
+/*
+IsEmpty returns true if the search has no term, keywords, or people,
+and so would match every photo.
+*/
+func (s PhotoSearch) IsEmpty() bool {
+	return s.SearchTerm == "" && len(s.Keywords) == 0 && len(s.People) == 0
+}
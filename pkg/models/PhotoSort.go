@@ -0,0 +1,26 @@
+package models
+
+/*
+PhotoSort is the order photos in a folder are shown in.
+*/
+type PhotoSort string
+
+const (
+	// SortByName shows photos by file name
+	SortByName PhotoSort = "name"
+
+	// SortByRating shows the highest rated photos first, then by file name
+	SortByRating PhotoSort = "rating"
+)
+
+/*
+NewPhotoSort converts a string into a PhotoSort. Anything that isn't
+"rating" is treated as SortByName.
+*/
+func NewPhotoSort(value string) PhotoSort {
+	if PhotoSort(value) == SortByRating {
+		return SortByRating
+	}
+
+	return SortByName
+}
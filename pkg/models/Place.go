@@ -0,0 +1,101 @@
+package models
+
+import (
+	"strings"
+)
+
+/*
+PlaceKind is the level of a place in the country, region, city
+hierarchy.
+*/
+type PlaceKind string
+
+const (
+	PlaceCountry PlaceKind = "country"
+	PlaceRegion  PlaceKind = "region"
+	PlaceCity    PlaceKind = "city"
+)
+
+/*
+PlacePath identifies a country, region, or city by its GeoNames codes.
+It is formatted as "IT", "IT/07", or "IT/07/3169070". Regions are
+GeoNames admin1 codes, and cities are GeoNames IDs.
+*/
+type PlacePath struct {
+	CountryCode string
+	RegionCode  string
+	CityID      string
+}
+
+/*
+ParsePlacePath parses a path formatted as "country/region/city". Any
+part can be left off the end.
+*/
+func ParsePlacePath(value string) PlacePath {
+	parts := strings.SplitN(strings.Trim(value, "/"), "/", 3)
+	result := PlacePath{}
+
+	for index, part := range parts {
+		part = strings.TrimSpace(part)
+
+		switch index {
+		case 0:
+			result.CountryCode = strings.ToUpper(part)
+		case 1:
+			result.RegionCode = part
+		case 2:
+			result.CityID = part
+		}
+	}
+
+	return result
+}
+
+/*
+Kind returns whether the path is a country, region, or city. An empty
+path is the whole world, and has no kind.
+*/
+func (p PlacePath) Kind() PlaceKind {
+	switch {
+	case p.CityID != "":
+		return PlaceCity
+	case p.RegionCode != "":
+		return PlaceRegion
+	case p.CountryCode != "":
+		return PlaceCountry
+	}
+
+	return ""
+}
+
+/*
+IsEmpty returns true when the path doesn't name any place.
+*/
+func (p PlacePath) IsEmpty() bool {
+	return p.CountryCode == ""
+}
+
+func (p PlacePath) String() string {
+	parts := []string{}
+
+	for _, part := range []string{p.CountryCode, p.RegionCode, p.CityID} {
+		if part == "" {
+			break
+		}
+
+		parts = append(parts, part)
+	}
+
+	return strings.Join(parts, "/")
+}
+
+/*
+Place is a country, region, or city with the number of photos taken
+in it.
+*/
+type Place struct {
+	Path       PlacePath
+	Name       string
+	NumPhotos  int
+	KeyPhotoID string
+}
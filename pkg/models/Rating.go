@@ -0,0 +1,142 @@
+package models
+
+import (
+	"strings"
+)
+
+/*
+MaxRating is the highest star rating a photo can have. A rating of 0
+means the photo hasn't been rated.
+*/
+const MaxRating = 5
+
+/*
+ColorLabel is the color label given to a photo, using the names
+Lightroom writes to xmp:Label.
+*/
+type ColorLabel string
+
+const (
+	LabelNone   ColorLabel = ""
+	LabelRed    ColorLabel = "Red"
+	LabelYellow ColorLabel = "Yellow"
+	LabelGreen  ColorLabel = "Green"
+	LabelBlue   ColorLabel = "Blue"
+	LabelPurple ColorLabel = "Purple"
+)
+
+/*
+ColorLabels are the labels a photo can be given, in the order they
+are shown.
+*/
+var ColorLabels = []ColorLabel{
+	LabelRed,
+	LabelYellow,
+	LabelGreen,
+	LabelBlue,
+	LabelPurple,
+}
+
+/*
+NewColorLabel returns the label matching value, ignoring case. Labels
+this app doesn't know, such as custom Lightroom label sets, are
+treated as no label.
+*/
+func NewColorLabel(value string) ColorLabel {
+	value = strings.TrimSpace(value)
+
+	for _, label := range ColorLabels {
+		if strings.EqualFold(string(label), value) {
+			return label
+		}
+	}
+
+	return LabelNone
+}
+
+/*
+ClassName returns the CSS class used to color the label.
+*/
+func (l ColorLabel) ClassName() string {
+	return "label-" + strings.ToLower(string(l))
+}
+
+/*
+Shortcut returns the key that sets this label, matching Lightroom's
+6 through 9. Purple has no shortcut.
+*/
+func (l ColorLabel) Shortcut() string {
+	switch l {
+	case LabelRed:
+		return "6"
+
+	case LabelYellow:
+		return "7"
+
+	case LabelGreen:
+		return "8"
+
+	case LabelBlue:
+		return "9"
+	}
+
+	return ""
+}
+
+/*
+ClampRating keeps a rating between 0 and MaxRating. Rejected photos,
+which Lightroom rates -1, are treated as unrated.
+*/
+func ClampRating(rating int) int {
+	return min(max(rating, 0), MaxRating)
+}
+
+/*
+Star is one of the stars shown for a photo's rating.
+*/
+type Star struct {
+	Value     int
+	IsFilled  bool
+	IsCurrent bool
+}
+
+/*
+Stars returns the stars for this photo's rating, from 1 to MaxRating.
+*/
+func (p *Photo) Stars() []Star {
+	result := make([]Star, 0, MaxRating)
+
+	for value := 1; value <= MaxRating; value++ {
+		result = append(result, Star{
+			Value:     value,
+			IsFilled:  value <= p.Rating,
+			IsCurrent: value == p.Rating,
+		})
+	}
+
+	return result
+}
+
+/*
+LabelChoice is one of the color labels shown for a photo.
+*/
+type LabelChoice struct {
+	Label      ColorLabel
+	IsSelected bool
+}
+
+/*
+LabelChoices returns every color label, marking the photo's own.
+*/
+func (p *Photo) LabelChoices() []LabelChoice {
+	result := make([]LabelChoice, 0, len(ColorLabels))
+
+	for _, label := range ColorLabels {
+		result = append(result, LabelChoice{
+			Label:      label,
+			IsSelected: label == p.Label,
+		})
+	}
+
+	return result
+}
@@ -1,7 +1,10 @@
 package models
 
+import "github.com/adampresley/adamgokit/paging"
+
 type SearchPhotosResult struct {
 	PhotoMatches   []*Photo
+	PhotoPaging    paging.Paging
 	FolderMatches  []*Folder
 	KeywordMatches []*KeywordSearchResult
 	PeopleMatches  []*Person
@@ -9,5 +12,6 @@ type SearchPhotosResult struct {
 
 type KeywordSearchResult struct {
 	Keyword    string
+	Parent     string
 	NumMatches int
 }
@@ -1,9 +1,42 @@
 package models
 
 type Settings struct {
-	ID                uint
-	CollectorSchedule string
-	MaxWorkers        int
-	LibraryPath       string
-	ThumbnailSize     int
+	ID                    uint
+	CollectorSchedule     string
+	MaxWorkers            int
+	LibraryPath           string
+	ThumbnailSize         int
+	DigestSchedule        string
+	DigestOutputPath      string
+	DigestEmailTo         string
+	MapTileURL            string
+	MapTileAttribution    string
+	MetadataWriteTarget   MetadataWriteTarget
+	FaceDetectionSchedule string
+}
+
+/*
+MetadataWriteTarget is where edits to a photo's metadata are written.
+*/
+type MetadataWriteTarget string
+
+const (
+	// WriteToSidecar writes edits to an XMP sidecar next to the photo, leaving the JPEG untouched
+	WriteToSidecar MetadataWriteTarget = "sidecar"
+
+	// WriteToFile writes edits into the JPEG's own IPTC and XMP data
+	WriteToFile MetadataWriteTarget = "file"
+)
+
+/*
+NewMetadataWriteTarget converts a string into a MetadataWriteTarget.
+Anything that isn't "file" writes to a sidecar, as that never changes
+the original photo.
+*/
+func NewMetadataWriteTarget(value string) MetadataWriteTarget {
+	if MetadataWriteTarget(value) == WriteToFile {
+		return WriteToFile
+	}
+
+	return WriteToSidecar
 }
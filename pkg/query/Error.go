@@ -0,0 +1,49 @@
+package query
+
+import (
+	"fmt"
+)
+
+/*
+Error describes a problem with a search query. Position is the byte
+offset in the original query where the problem was found, so it can
+be pointed out to the user.
+*/
+type Error struct {
+	Query    string
+	Position int
+	Message  string
+}
+
+/*
+NewError creates a new query error for a term.
+*/
+func NewError(q string, position int, format string, args ...any) *Error {
+	return &Error{
+		Query:    q,
+		Position: position,
+		Message:  fmt.Sprintf(format, args...),
+	}
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s (at character %d)", e.Message, e.Position+1)
+}
+
+/*
+Before returns the part of the query before the error position.
+*/
+func (e *Error) Before() string {
+	return e.Query[:e.clampedPosition()]
+}
+
+/*
+After returns the part of the query from the error position onward.
+*/
+func (e *Error) After() string {
+	return e.Query[e.clampedPosition():]
+}
+
+func (e *Error) clampedPosition() int {
+	return max(0, min(e.Position, len(e.Query)))
+}
@@ -0,0 +1,228 @@
+package query
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+/*
+Parse turns a search string into a Query. The syntax is a list of
+terms separated by spaces, all of which must match:
+
+	beach sunset               free text
+	"sunset beach"             free text phrase
+	keyword:christmas          field filter
+	person:"Aunt Mary"         quoted field value
+	-keyword:screenshot        negated term
+	year:2015..2018            inclusive range
+	year:2015.. / year:..2018  open ended range
+	year:>2015                 comparison (>, >=, <, <=)
+
+Field names are case insensitive. Parse only checks syntax; it is up
+to the consumer to decide which fields and operators are valid.
+*/
+func Parse(input string) (Query, error) {
+	p := &parser{input: input}
+	return p.parse()
+}
+
+type parser struct {
+	input string
+	pos   int
+}
+
+func (p *parser) parse() (Query, error) {
+	result := Query{Input: p.input, Terms: []Term{}}
+
+	for {
+		p.skipWhitespace()
+
+		if p.atEnd() {
+			return result, nil
+		}
+
+		term, err := p.parseTerm()
+
+		if err != nil {
+			return Query{}, err
+		}
+
+		result.Terms = append(result.Terms, term)
+	}
+}
+
+func (p *parser) parseTerm() (Term, error) {
+	var (
+		err  error
+		term = Term{Position: p.pos, Operator: OpEquals}
+	)
+
+	if p.peek() == '-' {
+		term.Negated = true
+		p.pos++
+
+		if p.atEnd() || p.atWhitespace() {
+			return term, NewError(p.input, term.Position, "expected something to exclude after '-'")
+		}
+	}
+
+	if field, ok := p.tryReadField(); ok {
+		term.Field = strings.ToLower(field)
+
+		if err = p.parseFieldValue(&term); err != nil {
+			return term, err
+		}
+
+		return term, nil
+	}
+
+	if term.Value, term.Quoted, err = p.readValue(); err != nil {
+		return term, err
+	}
+
+	return term, nil
+}
+
+/*
+tryReadField reads a field name followed by a colon. If the upcoming
+text isn't a field, the position is left unchanged.
+*/
+func (p *parser) tryReadField() (string, bool) {
+	end := p.pos
+
+	for end < len(p.input) {
+		r, size := utf8.DecodeRuneInString(p.input[end:])
+
+		if !unicode.IsLetter(r) && r != '_' {
+			break
+		}
+
+		end += size
+	}
+
+	if end == p.pos || end >= len(p.input) || p.input[end] != ':' {
+		return "", false
+	}
+
+	field := p.input[p.pos:end]
+	p.pos = end + 1
+
+	return field, true
+}
+
+func (p *parser) parseFieldValue(term *Term) error {
+	var (
+		err error
+	)
+
+	if p.atEnd() || p.atWhitespace() {
+		return NewError(p.input, term.Position, "missing a value for '%s:'", term.Field)
+	}
+
+	operatorPosition := p.pos
+
+	for _, op := range []Operator{OpGreaterEqual, OpLessEqual, OpGreater, OpLess, OpEquals} {
+		if strings.HasPrefix(p.input[p.pos:], string(op)) {
+			term.Operator = op
+			p.pos += len(op)
+			break
+		}
+	}
+
+	hasOperator := p.pos != operatorPosition
+
+	if hasOperator && (p.atEnd() || p.atWhitespace()) {
+		return NewError(p.input, operatorPosition, "missing a value after '%s' for '%s:'", term.Operator, term.Field)
+	}
+
+	if term.Value, term.Quoted, err = p.readValue(); err != nil {
+		return err
+	}
+
+	/*
+	 * Ranges are only recognized on bare values with no comparison
+	 * operator, so quoted values can still contain "..".
+	 */
+	if term.Quoted || hasOperator || !strings.Contains(term.Value, "..") {
+		return nil
+	}
+
+	from, to, _ := strings.Cut(term.Value, "..")
+
+	switch {
+	case from == "" && to == "":
+		return NewError(p.input, operatorPosition, "a range for '%s:' needs a start, an end, or both", term.Field)
+
+	case from == "":
+		term.Operator = OpLessEqual
+		term.Value = to
+
+	case to == "":
+		term.Operator = OpGreaterEqual
+		term.Value = from
+
+	default:
+		term.Operator = OpRange
+		term.Value = from
+		term.To = to
+	}
+
+	return nil
+}
+
+/*
+readValue reads a quoted string or a bare word up to the next space.
+*/
+func (p *parser) readValue() (string, bool, error) {
+	start := p.pos
+
+	if p.peek() != '"' {
+		for !p.atEnd() && !p.atWhitespace() {
+			_, size := utf8.DecodeRuneInString(p.input[p.pos:])
+			p.pos += size
+		}
+
+		return p.input[start:p.pos], false, nil
+	}
+
+	p.pos++
+	end := strings.IndexByte(p.input[p.pos:], '"')
+
+	if end == -1 {
+		return "", true, NewError(p.input, start, "missing a closing quote")
+	}
+
+	value := p.input[p.pos : p.pos+end]
+	p.pos += end + 1
+
+	if strings.TrimSpace(value) == "" {
+		return "", true, NewError(p.input, start, "quotes cannot be empty")
+	}
+
+	return value, true, nil
+}
+
+func (p *parser) skipWhitespace() {
+	for !p.atEnd() && p.atWhitespace() {
+		_, size := utf8.DecodeRuneInString(p.input[p.pos:])
+		p.pos += size
+	}
+}
+
+func (p *parser) atEnd() bool {
+	return p.pos >= len(p.input)
+}
+
+func (p *parser) atWhitespace() bool {
+	r, _ := utf8.DecodeRuneInString(p.input[p.pos:])
+	return unicode.IsSpace(r)
+}
+
+func (p *parser) peek() byte {
+	if p.atEnd() {
+		return 0
+	}
+
+	return p.input[p.pos]
+}
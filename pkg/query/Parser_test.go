@@ -0,0 +1,183 @@
+package query
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []Term
+	}{
+		{
+			name:  "empty",
+			input: "   ",
+			want:  []Term{},
+		},
+		{
+			name:  "free text",
+			input: "beach  sunset",
+			want: []Term{
+				{Operator: OpEquals, Value: "beach", Position: 0},
+				{Operator: OpEquals, Value: "sunset", Position: 7},
+			},
+		},
+		{
+			name:  "quoted phrase",
+			input: `"sunset beach"`,
+			want: []Term{
+				{Operator: OpEquals, Value: "sunset beach", Quoted: true},
+			},
+		},
+		{
+			name:  "field names are lowercased",
+			input: "Keyword:Christmas",
+			want: []Term{
+				{Field: "keyword", Operator: OpEquals, Value: "Christmas"},
+			},
+		},
+		{
+			name:  "quoted field value",
+			input: `person:"Aunt Mary"`,
+			want: []Term{
+				{Field: "person", Operator: OpEquals, Value: "Aunt Mary", Quoted: true},
+			},
+		},
+		{
+			name:  "negated field",
+			input: "-keyword:screenshot",
+			want: []Term{
+				{Field: "keyword", Negated: true, Operator: OpEquals, Value: "screenshot"},
+			},
+		},
+		{
+			name:  "negated text",
+			input: "-blurry",
+			want: []Term{
+				{Negated: true, Operator: OpEquals, Value: "blurry"},
+			},
+		},
+		{
+			name:  "range",
+			input: "year:2015..2018",
+			want: []Term{
+				{Field: "year", Operator: OpRange, Value: "2015", To: "2018"},
+			},
+		},
+		{
+			name:  "open start range",
+			input: "year:..2018",
+			want: []Term{
+				{Field: "year", Operator: OpLessEqual, Value: "2018"},
+			},
+		},
+		{
+			name:  "open end range",
+			input: "year:2015..",
+			want: []Term{
+				{Field: "year", Operator: OpGreaterEqual, Value: "2015"},
+			},
+		},
+		{
+			name:  "comparisons",
+			input: "year:>2015 rating:>=3 iso:<800 year:<=2020",
+			want: []Term{
+				{Field: "year", Operator: OpGreater, Value: "2015", Position: 0},
+				{Field: "rating", Operator: OpGreaterEqual, Value: "3", Position: 11},
+				{Field: "iso", Operator: OpLess, Value: "800", Position: 22},
+				{Field: "year", Operator: OpLessEqual, Value: "2020", Position: 31},
+			},
+		},
+		{
+			name:  "quoted values keep dots",
+			input: `title:"a..b"`,
+			want: []Term{
+				{Field: "title", Operator: OpEquals, Value: "a..b", Quoted: true},
+			},
+		},
+		{
+			name:  "colon after a non-letter is text",
+			input: "12:30",
+			want: []Term{
+				{Operator: OpEquals, Value: "12:30"},
+			},
+		},
+		{
+			name:  "hex color value",
+			input: "color:#f5c518",
+			want: []Term{
+				{Field: "color", Operator: OpEquals, Value: "#f5c518"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.input)
+
+			if err != nil {
+				t.Fatalf("Parse(%q) returned error %v", tt.input, err)
+			}
+
+			if !reflect.DeepEqual(got.Terms, tt.want) {
+				t.Errorf("Parse(%q) terms =\n%+v\nwant\n%+v", tt.input, got.Terms, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		position int
+	}{
+		{input: "-", position: 0},
+		{input: "beach -", position: 6},
+		{input: "keyword:", position: 0},
+		{input: "keyword: beach", position: 0},
+		{input: "year:>", position: 5},
+		{input: "year:..", position: 5},
+		{input: `"sunset`, position: 0},
+		{input: `person:"Aunt`, position: 7},
+		{input: `""`, position: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			var queryErr *Error
+
+			_, err := Parse(tt.input)
+
+			if !errors.As(err, &queryErr) {
+				t.Fatalf("Parse(%q) error = %v, want a *Error", tt.input, err)
+			}
+
+			if queryErr.Position != tt.position {
+				t.Errorf("Parse(%q) error position = %d, want %d", tt.input, queryErr.Position, tt.position)
+			}
+
+			if queryErr.Before()+queryErr.After() != tt.input {
+				t.Errorf("Parse(%q) error splits the query into %q and %q", tt.input, queryErr.Before(), queryErr.After())
+			}
+		})
+	}
+}
+
+func TestQueryText(t *testing.T) {
+	q, err := Parse(`beach "sunset glow" -blurry keyword:holiday`)
+
+	if err != nil {
+		t.Fatalf("Parse returned error %v", err)
+	}
+
+	if got, want := q.Text(), `beach "sunset glow"`; got != want {
+		t.Errorf("Text() = %q, want %q", got, want)
+	}
+
+	if got := len(q.Filters()); got != 1 {
+		t.Errorf("len(Filters()) = %d, want 1", got)
+	}
+}
@@ -0,0 +1,151 @@
+package query
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+)
+
+/*
+Operator describes how a term's value is compared.
+*/
+type Operator string
+
+const (
+	OpEquals       Operator = "="
+	OpGreater      Operator = ">"
+	OpGreaterEqual Operator = ">="
+	OpLess         Operator = "<"
+	OpLessEqual    Operator = "<="
+	OpRange        Operator = ".."
+)
+
+/*
+Query is the parsed form of a search string. All terms must match
+for a photo to be included in results.
+*/
+type Query struct {
+	Input string
+	Terms []Term
+}
+
+/*
+Term is a single part of a query. Terms without a field are free
+text. For example, `-keyword:screenshot` is a negated term for the
+field "keyword" with the value "screenshot".
+*/
+type Term struct {
+	Field    string
+	Negated  bool
+	Operator Operator
+	Value    string
+	To       string
+	Quoted   bool
+	Position int
+}
+
+/*
+IsText returns true if this term is free text instead of a field filter.
+*/
+func (t Term) IsText() bool {
+	return t.Field == ""
+}
+
+/*
+Filters returns all terms that are field filters.
+*/
+func (q Query) Filters() []Term {
+	result := []Term{}
+
+	for _, t := range q.Terms {
+		if !t.IsText() {
+			result = append(result, t)
+		}
+	}
+
+	return result
+}
+
+/*
+Text returns the free text portion of the query which isn't negated.
+Quoted phrases keep their quotes.
+*/
+func (q Query) Text() string {
+	parts := []string{}
+
+	for _, t := range q.Terms {
+		if !t.IsText() || t.Negated {
+			continue
+		}
+
+		if t.Quoted {
+			parts = append(parts, `"`+t.Value+`"`)
+		} else {
+			parts = append(parts, t.Value)
+		}
+	}
+
+	return strings.Join(parts, " ")
+}
+
+/*
+IsEmpty returns true when the query has no terms at all.
+*/
+func (q Query) IsEmpty() bool {
+	return len(q.Terms) == 0
+}
+
+/*
+ReplaceValue returns the query's input with the value of every term
+for one of fields that matches from, ignoring case, changed to to.
+Only values matched as they are, rather than compared or ranged, are
+changed. The rest of the input is kept as it was typed.
+*/
+func (q Query) ReplaceValue(from, to string, fields ...string) string {
+	result := strings.Builder{}
+	last := 0
+
+	for _, t := range q.Terms {
+		if t.Operator != OpEquals || !slices.Contains(fields, t.Field) || !strings.EqualFold(t.Value, from) {
+			continue
+		}
+
+		/*
+		 * The term is read again to find where it ends. The value
+		 * starts after the field's colon, and an "=" if there is one.
+		 */
+		p := &parser{input: q.Input, pos: t.Position}
+
+		if _, err := p.parseTerm(); err != nil {
+			continue
+		}
+
+		valueStart := t.Position + strings.IndexByte(q.Input[t.Position:p.pos], ':') + 1
+
+		if strings.HasPrefix(q.Input[valueStart:p.pos], string(OpEquals)) {
+			valueStart += len(OpEquals)
+		}
+
+		result.WriteString(q.Input[last:valueStart])
+		result.WriteString(quoteValue(to, t.Quoted))
+		last = p.pos
+	}
+
+	result.WriteString(q.Input[last:])
+	return result.String()
+}
+
+/*
+quoteValue returns a value as it's written in a query, quoted when it
+was before or would otherwise be read as something else.
+*/
+func quoteValue(value string, quoted bool) string {
+	if quoted ||
+		strings.IndexFunc(value, unicode.IsSpace) >= 0 ||
+		strings.Contains(value, "..") ||
+		strings.ContainsAny(value[:min(1, len(value))], `"<>=`) {
+		return `"` + value + `"`
+	}
+
+	return value
+}
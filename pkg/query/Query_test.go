@@ -0,0 +1,64 @@
+package query
+
+import "testing"
+
+func replacePerson(t *testing.T, input, from, to string) string {
+	t.Helper()
+
+	q, err := Parse(input)
+
+	if err != nil {
+		t.Fatalf("Parse(%q) returned error %v", input, err)
+	}
+
+	return q.ReplaceValue(from, to, "person", "people")
+}
+
+func TestReplaceValueKeepsTheRestOfTheQuery(t *testing.T) {
+	got := replacePerson(t, `beach  person:Mary   keyword:"Mary"  -people:mary`, "Mary", "Aunt Mary")
+
+	if want := `beach  person:"Aunt Mary"   keyword:"Mary"  -people:"Aunt Mary"`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestReplaceValueQuoting(t *testing.T) {
+	if got := replacePerson(t, `person:"Mary"`, "mary", "Bob"); got != `person:"Bob"` {
+		t.Errorf("a quoted value became %q, want it to stay quoted", got)
+	}
+
+	if got := replacePerson(t, `person:=Mary`, "Mary", "Bob"); got != `person:=Bob` {
+		t.Errorf("a value after = became %q", got)
+	}
+
+	for _, to := range []string{"A..B", "<3", "=Bob"} {
+		got := replacePerson(t, "person:Mary", "Mary", to)
+
+		q, err := Parse(got)
+
+		if err != nil {
+			t.Errorf("replacing with %q gave %q, which doesn't parse: %v", to, got, err)
+			continue
+		}
+
+		if term := q.Terms[0]; term.Operator != OpEquals || term.Value != to {
+			t.Errorf("replacing with %q gave %q, which reads back as %s %q", to, got, term.Operator, term.Value)
+		}
+	}
+}
+
+func TestReplaceValueLeavesOtherTermsAlone(t *testing.T) {
+	inputs := []string{
+		"Mary",
+		"person:Maryann",
+		"keyword:Mary",
+		"person:>Mary",
+		"person:Mary..Zoe",
+	}
+
+	for _, input := range inputs {
+		if got := replacePerson(t, input, "Mary", "Bob"); got != input {
+			t.Errorf("%q became %q", input, got)
+		}
+	}
+}
@@ -0,0 +1,881 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/adampresley/ownmyphotos/pkg/models"
+	"github.com/rfberaldo/sqlz"
+)
+
+var (
+	ErrAlbumNameRequired   = fmt.Errorf("album name is required")
+	ErrAlbumSearchRequired = fmt.Errorf("smart album search is required")
+	ErrSmartAlbum          = fmt.Errorf("photos can't be added to a smart album")
+)
+
+type AlbumServicer interface {
+	/*
+	 * Retrieves every album, in the order they were arranged, with
+	 * the number of photos in each. Smart albums are counted by
+	 * running their search.
+	 */
+	All() ([]*models.Album, error)
+
+	/*
+	 * Retrieves a single album by ID. An album with an ID of 0 is
+	 * returned if it doesn't exist.
+	 */
+	Get(id int64) (*models.Album, error)
+
+	/*
+	 * Retrieves the albums a photo is in, including smart albums
+	 * whose search it matches.
+	 */
+	GetAlbumsForPhoto(photoID string) ([]*models.Album, error)
+
+	/*
+	 * Creates an empty album after the existing ones.
+	 */
+	Create(name string) (*models.Album, error)
+
+	/*
+	 * Creates a smart album after the existing ones. If the search
+	 * can't be parsed, a *query.Error is returned.
+	 */
+	CreateSmart(name string, criteria models.PhotoSearch) (*models.Album, error)
+
+	/*
+	 * Changes the search of a smart album. If the search can't be
+	 * parsed, a *query.Error is returned.
+	 */
+	UpdateSearch(id int64, criteria models.PhotoSearch) error
+
+	/*
+	 * Turns a smart album into a regular album holding the photos
+	 * its search matches right now. Returns the number of photos.
+	 */
+	ConvertToStatic(id int64) (int, error)
+
+	/*
+	 * Changes the name of an album.
+	 */
+	Rename(id int64, name string) error
+
+	/*
+	 * Deletes an album. The photos in it are not touched.
+	 */
+	Delete(id int64) error
+
+	/*
+	 * Moves an album up (negative offset) or down (positive offset)
+	 * in the list of albums.
+	 */
+	Move(id int64, offset int) error
+
+	/*
+	 * Adds photos to the end of an album. Photos already in the
+	 * album, or not in the library, are skipped. Returns the number
+	 * of photos added.
+	 */
+	AddPhotos(id int64, photoIDs []string) (int, error)
+
+	/*
+	 * Removes photos from an album. If the cover photo is removed,
+	 * the album goes back to using its first photo.
+	 */
+	RemovePhotos(id int64, photoIDs []string) error
+
+	/*
+	 * Makes a photo in the album its cover.
+	 */
+	SetCover(id int64, photoID string) error
+}
+
+type AlbumServiceConfig struct {
+	DB *sqlz.DB
+}
+
+type AlbumService struct {
+	db *sqlz.DB
+}
+
+func NewAlbumService(config AlbumServiceConfig) AlbumService {
+	return AlbumService{
+		db: config.DB,
+	}
+}
+
+/*
+albumColumns is the column list used by queries that return albums.
+*/
+const albumColumns = `
+	a.id
+	, a.name
+	, a.sort_order
+	, COALESCE(a.cover_photo_id, '') AS cover_photo_id
+	, a.is_smart
+	, a.search_term
+	, a.search_keywords
+	, a.search_people
+	, a.search_match
+	, (
+		SELECT COUNT(*)
+		FROM albums_photos ap
+		JOIN photos p ON p.id = ap.photo_id
+		WHERE ap.album_id = a.id
+			AND p.deleted_at IS NULL
+	) AS num_photos
+	, COALESCE(a.cover_photo_id, (
+		SELECT ap.photo_id
+		FROM albums_photos ap
+		JOIN photos p ON p.id = ap.photo_id
+		WHERE ap.album_id = a.id
+			AND p.deleted_at IS NULL
+		ORDER BY ap.sort_order ASC, ap.added_at ASC
+		LIMIT 1
+	), '') AS key_photo_id`
+
+/*
+Retrieves every album, in the order they were arranged.
+*/
+func (s AlbumService) All() ([]*models.Album, error) {
+	var (
+		err    error
+		result = []*models.Album{}
+	)
+
+	statement := `
+SELECT ` + albumColumns + `
+FROM albums a
+ORDER BY a.sort_order ASC, a.id ASC
+`
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	if err = s.db.Query(ctx, &result, statement); err != nil {
+		return result, fmt.Errorf("error querying for albums: %w", err)
+	}
+
+	for _, album := range result {
+		s.countSmartAlbum(album)
+	}
+
+	return result, nil
+}
+
+/*
+Retrieves a single album by ID.
+*/
+func (s AlbumService) Get(id int64) (*models.Album, error) {
+	var (
+		err    error
+		result = &models.Album{}
+	)
+
+	statement := `
+SELECT ` + albumColumns + `
+FROM albums a
+WHERE a.id = ?
+`
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	if err = s.db.QueryRow(ctx, result, statement, id); err != nil {
+		if sqlz.IsNotFound(err) {
+			return &models.Album{}, nil
+		}
+
+		return result, fmt.Errorf("error querying for album %d: %w", id, err)
+	}
+
+	s.countSmartAlbum(result)
+	return result, nil
+}
+
+/*
+Retrieves the albums a photo is in, in the order they were arranged.
+*/
+func (s AlbumService) GetAlbumsForPhoto(photoID string) ([]*models.Album, error) {
+	var (
+		err    error
+		albums = []*models.Album{}
+		result = []*models.Album{}
+	)
+
+	statement := `
+SELECT ` + albumColumns + `
+FROM albums a
+WHERE a.is_smart = 1
+	OR a.id IN (
+		SELECT album_id
+		FROM albums_photos
+		WHERE photo_id = ?
+	)
+ORDER BY a.sort_order ASC, a.id ASC
+`
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	if err = s.db.Query(ctx, &albums, statement, photoID); err != nil {
+		return result, fmt.Errorf("error querying for albums with photo %s: %w", photoID, err)
+	}
+
+	for _, album := range albums {
+		if !album.IsSmart || s.smartAlbumHasPhoto(album, photoID) {
+			result = append(result, album)
+		}
+	}
+
+	return result, nil
+}
+
+/*
+Creates an empty album after the existing ones.
+*/
+func (s AlbumService) Create(name string) (*models.Album, error) {
+	var (
+		err error
+		r   sql.Result
+		id  int64
+	)
+
+	if name = strings.TrimSpace(name); name == "" {
+		return &models.Album{}, ErrAlbumNameRequired
+	}
+
+	statement := `
+INSERT INTO albums (
+	created_at
+	, updated_at
+	, name
+	, sort_order
+) VALUES (
+	?
+	, ?
+	, ?
+	, (SELECT COALESCE(MAX(sort_order), 0) + 1 FROM albums)
+)
+`
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	now := time.Now().UTC()
+
+	if r, err = s.db.Exec(ctx, statement, now, now, name); err != nil {
+		return &models.Album{}, fmt.Errorf("error creating album '%s': %w", name, err)
+	}
+
+	if id, err = r.LastInsertId(); err != nil {
+		return &models.Album{}, fmt.Errorf("error getting the ID of album '%s': %w", name, err)
+	}
+
+	return s.Get(id)
+}
+
+/*
+Creates a smart album after the existing ones. The search is checked
+before it is saved.
+*/
+func (s AlbumService) CreateSmart(name string, criteria models.PhotoSearch) (*models.Album, error) {
+	var (
+		err      error
+		r        sql.Result
+		id       int64
+		keywords []byte
+		people   []byte
+	)
+
+	if name = strings.TrimSpace(name); name == "" {
+		return &models.Album{}, ErrAlbumNameRequired
+	}
+
+	if keywords, people, err = validateAlbumSearch(&criteria); err != nil {
+		return &models.Album{}, err
+	}
+
+	statement := `
+INSERT INTO albums (
+	created_at
+	, updated_at
+	, name
+	, sort_order
+	, is_smart
+	, search_term
+	, search_keywords
+	, search_people
+	, search_match
+) VALUES (
+	?
+	, ?
+	, ?
+	, (SELECT COALESCE(MAX(sort_order), 0) + 1 FROM albums)
+	, 1
+	, ?
+	, ?
+	, ?
+	, ?
+)
+`
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	now := time.Now().UTC()
+	args := []any{now, now, name, criteria.SearchTerm, string(keywords), string(people), string(criteria.Match)}
+
+	if r, err = s.db.Exec(ctx, statement, args...); err != nil {
+		return &models.Album{}, fmt.Errorf("error creating smart album '%s': %w", name, err)
+	}
+
+	if id, err = r.LastInsertId(); err != nil {
+		return &models.Album{}, fmt.Errorf("error getting the ID of smart album '%s': %w", name, err)
+	}
+
+	return s.Get(id)
+}
+
+/*
+Changes the search of a smart album.
+*/
+func (s AlbumService) UpdateSearch(id int64, criteria models.PhotoSearch) error {
+	var (
+		err      error
+		keywords []byte
+		people   []byte
+	)
+
+	if keywords, people, err = validateAlbumSearch(&criteria); err != nil {
+		return err
+	}
+
+	statement := `
+UPDATE albums SET
+	search_term = ?
+	, search_keywords = ?
+	, search_people = ?
+	, search_match = ?
+	, updated_at = ?
+WHERE id = ?
+	AND is_smart = 1
+`
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	args := []any{criteria.SearchTerm, string(keywords), string(people), string(criteria.Match), time.Now().UTC(), id}
+
+	if _, err = s.db.Exec(ctx, statement, args...); err != nil {
+		return fmt.Errorf("error updating the search of album %d: %w", id, err)
+	}
+
+	return nil
+}
+
+/*
+Turns a smart album into a regular album. The photos its search
+matches are added in the order the search returns them.
+*/
+func (s AlbumService) ConvertToStatic(id int64) (int, error) {
+	var (
+		err        error
+		r          sql.Result
+		album      *models.Album
+		from       string
+		orderBy    string
+		parameters []any
+		success    = false
+	)
+
+	if album, err = s.Get(id); err != nil {
+		return 0, err
+	}
+
+	if !album.IsSmart {
+		return 0, fmt.Errorf("album %d is not a smart album", id)
+	}
+
+	if from, orderBy, parameters, err = buildSearchFrom(album.Criteria(1)); err != nil {
+		return 0, fmt.Errorf("error building the search of album %d: %w", id, err)
+	}
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	tx, err := s.db.Begin(ctx)
+
+	if err != nil {
+		return 0, fmt.Errorf("error starting transaction when converting album %d: %w", id, err)
+	}
+
+	defer func() {
+		if success {
+			_ = tx.Commit()
+		} else {
+			_ = tx.Rollback()
+		}
+	}()
+
+	now := time.Now().UTC()
+
+	statement := `
+INSERT INTO albums_photos (
+	album_id
+	, photo_id
+	, sort_order
+	, added_at
+)
+SELECT ?, p.id, ROW_NUMBER() OVER (ORDER BY ` + orderBy + `), ?
+` + from + `
+ORDER BY ` + orderBy + `
+ON CONFLICT (album_id, photo_id) DO NOTHING
+`
+
+	if r, err = tx.Exec(ctx, statement, append([]any{id, now}, parameters...)...); err != nil {
+		return 0, fmt.Errorf("error adding the photos of smart album %d: %w", id, err)
+	}
+
+	statement = `
+UPDATE albums SET
+	is_smart = 0
+	, search_term = ''
+	, search_keywords = '[]'
+	, search_people = '[]'
+	, search_match = 'all'
+	, updated_at = ?
+WHERE id = ?
+`
+
+	if _, err = tx.Exec(ctx, statement, now, id); err != nil {
+		return 0, fmt.Errorf("error converting album %d: %w", id, err)
+	}
+
+	added, _ := r.RowsAffected()
+
+	success = true
+	return int(added), nil
+}
+
+/*
+Changes the name of an album.
+*/
+func (s AlbumService) Rename(id int64, name string) error {
+	var (
+		err error
+	)
+
+	if name = strings.TrimSpace(name); name == "" {
+		return ErrAlbumNameRequired
+	}
+
+	statement := `UPDATE albums SET name = ?, updated_at = ? WHERE id = ?`
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	if _, err = s.db.Exec(ctx, statement, name, time.Now().UTC(), id); err != nil {
+		return fmt.Errorf("error renaming album %d: %w", id, err)
+	}
+
+	return nil
+}
+
+/*
+Deletes an album and its list of photos.
+*/
+func (s AlbumService) Delete(id int64) error {
+	var (
+		err     error
+		success = false
+	)
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	tx, err := s.db.Begin(ctx)
+
+	if err != nil {
+		return fmt.Errorf("error starting transaction when deleting album %d: %w", id, err)
+	}
+
+	defer func() {
+		if success {
+			_ = tx.Commit()
+		} else {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.Exec(ctx, `DELETE FROM albums_photos WHERE album_id=?`, id); err != nil {
+		return fmt.Errorf("error deleting photos in album %d: %w", id, err)
+	}
+
+	if _, err = tx.Exec(ctx, `DELETE FROM albums WHERE id=?`, id); err != nil {
+		return fmt.Errorf("error deleting album %d: %w", id, err)
+	}
+
+	success = true
+	return nil
+}
+
+/*
+Moves an album up or down in the list. The whole list is renumbered,
+so gaps left by deleted albums are closed as well.
+*/
+func (s AlbumService) Move(id int64, offset int) error {
+	var (
+		err     error
+		ids     = []int64{}
+		success = false
+	)
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	tx, err := s.db.Begin(ctx)
+
+	if err != nil {
+		return fmt.Errorf("error starting transaction when moving album %d: %w", id, err)
+	}
+
+	defer func() {
+		if success {
+			_ = tx.Commit()
+		} else {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if err = tx.Query(ctx, &ids, `SELECT id FROM albums ORDER BY sort_order ASC, id ASC`); err != nil {
+		return fmt.Errorf("error querying for album order: %w", err)
+	}
+
+	from := -1
+
+	for i, albumID := range ids {
+		if albumID == id {
+			from = i
+			break
+		}
+	}
+
+	if from < 0 {
+		return fmt.Errorf("album %d not found", id)
+	}
+
+	to := min(max(from+offset, 0), len(ids)-1)
+	ids = append(ids[:from], ids[from+1:]...)
+	ids = append(ids[:to], append([]int64{id}, ids[to:]...)...)
+
+	for i, albumID := range ids {
+		if _, err = tx.Exec(ctx, `UPDATE albums SET sort_order = ? WHERE id = ?`, i+1, albumID); err != nil {
+			return fmt.Errorf("error updating the position of album %d: %w", albumID, err)
+		}
+	}
+
+	success = true
+	return nil
+}
+
+/*
+Adds photos to the end of an album, in the order given.
+*/
+func (s AlbumService) AddPhotos(id int64, photoIDs []string) (int, error) {
+	var (
+		err       error
+		r         sql.Result
+		sortOrder int
+		added     int
+		isSmart   bool
+		success   = false
+	)
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	tx, err := s.db.Begin(ctx)
+
+	if err != nil {
+		return 0, fmt.Errorf("error starting transaction when adding photos to album %d: %w", id, err)
+	}
+
+	defer func() {
+		if success {
+			_ = tx.Commit()
+		} else {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if err = tx.QueryRow(ctx, &isSmart, `SELECT is_smart FROM albums WHERE id = ?`, id); err != nil {
+		return 0, fmt.Errorf("error querying for album %d: %w", id, err)
+	}
+
+	if isSmart {
+		return 0, ErrSmartAlbum
+	}
+
+	if err = tx.QueryRow(ctx, &sortOrder, `SELECT COALESCE(MAX(sort_order), 0) FROM albums_photos WHERE album_id = ?`, id); err != nil {
+		return 0, fmt.Errorf("error querying for the end of album %d: %w", id, err)
+	}
+
+	statement := `
+INSERT INTO albums_photos (
+	album_id
+	, photo_id
+	, sort_order
+	, added_at
+)
+SELECT ?, p.id, ?, ?
+FROM photos p
+WHERE p.id = ?
+	AND p.deleted_at IS NULL
+ON CONFLICT (album_id, photo_id) DO NOTHING
+`
+
+	now := time.Now().UTC()
+
+	for _, photoID := range photoIDs {
+		if r, err = tx.Exec(ctx, statement, id, sortOrder+1, now, photoID); err != nil {
+			return 0, fmt.Errorf("error adding photo %s to album %d: %w", photoID, id, err)
+		}
+
+		if rows, _ := r.RowsAffected(); rows > 0 {
+			sortOrder++
+			added++
+		}
+	}
+
+	if _, err = tx.Exec(ctx, `UPDATE albums SET updated_at = ? WHERE id = ?`, now, id); err != nil {
+		return 0, fmt.Errorf("error updating album %d: %w", id, err)
+	}
+
+	success = true
+	return added, nil
+}
+
+/*
+Removes photos from an album, clearing the cover if it was one of
+them.
+*/
+func (s AlbumService) RemovePhotos(id int64, photoIDs []string) error {
+	var (
+		err     error
+		success = false
+	)
+
+	if len(photoIDs) == 0 {
+		return nil
+	}
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	tx, err := s.db.Begin(ctx)
+
+	if err != nil {
+		return fmt.Errorf("error starting transaction when removing photos from album %d: %w", id, err)
+	}
+
+	defer func() {
+		if success {
+			_ = tx.Commit()
+		} else {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.Exec(ctx, `DELETE FROM albums_photos WHERE album_id = ? AND photo_id IN (?)`, id, photoIDs); err != nil {
+		return fmt.Errorf("error removing photos from album %d: %w", id, err)
+	}
+
+	statement := `
+UPDATE albums SET
+	cover_photo_id = CASE WHEN cover_photo_id IN (?) THEN NULL ELSE cover_photo_id END
+	, updated_at = ?
+WHERE id = ?
+`
+
+	if _, err = tx.Exec(ctx, statement, photoIDs, time.Now().UTC(), id); err != nil {
+		return fmt.Errorf("error updating album %d: %w", id, err)
+	}
+
+	success = true
+	return nil
+}
+
+/*
+Makes a photo in the album its cover.
+*/
+func (s AlbumService) SetCover(id int64, photoID string) error {
+	var (
+		err error
+		r   sql.Result
+	)
+
+	statement := `
+UPDATE albums SET
+	cover_photo_id = ?
+	, updated_at = ?
+WHERE id = ?
+	AND EXISTS (
+		SELECT 1
+		FROM albums_photos
+		WHERE album_id = ?
+			AND photo_id = ?
+	)
+`
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	if r, err = s.db.Exec(ctx, statement, photoID, time.Now().UTC(), id, id, photoID); err != nil {
+		return fmt.Errorf("error setting the cover of album %d: %w", id, err)
+	}
+
+	if rows, _ := r.RowsAffected(); rows == 0 {
+		return fmt.Errorf("photo %s is not in album %d", photoID, id)
+	}
+
+	return nil
+}
+
+/*
+countSmartAlbum fills in the number of photos and the key photo of a
+smart album by running its search. A chosen cover is kept as the key
+photo. A search that no longer works, such as one using a filter that
+has been removed, is logged and counted as empty.
+*/
+func (s AlbumService) countSmartAlbum(album *models.Album) {
+	var (
+		err        error
+		from       string
+		orderBy    string
+		parameters []any
+		summary    struct {
+			NumPhotos  int
+			KeyPhotoID string
+		}
+	)
+
+	if !album.IsSmart {
+		return
+	}
+
+	if from, orderBy, parameters, err = buildSearchFrom(album.Criteria(1)); err != nil {
+		slog.Error("error building the search of a smart album", "error", err, "id", album.ID)
+		return
+	}
+
+	statement := `
+SELECT COUNT(*) OVER () AS num_photos, p.id AS key_photo_id
+` + from + `
+ORDER BY ` + orderBy + `
+LIMIT 1
+`
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	if err = s.db.QueryRow(ctx, &summary, statement, parameters...); err != nil {
+		if !sqlz.IsNotFound(err) {
+			slog.Error("error counting the photos in a smart album", "error", err, "id", album.ID)
+		}
+
+		return
+	}
+
+	album.NumPhotos = summary.NumPhotos
+
+	if album.CoverPhotoID == "" {
+		album.KeyPhotoID = summary.KeyPhotoID
+	}
+}
+
+/*
+smartAlbumHasPhoto returns true if a photo matches the search of a
+smart album.
+*/
+func (s AlbumService) smartAlbumHasPhoto(album *models.Album, photoID string) bool {
+	var (
+		err        error
+		from       string
+		parameters []any
+		found      bool
+	)
+
+	if from, _, parameters, err = buildSearchFrom(album.Criteria(1)); err != nil {
+		return false
+	}
+
+	statement := `SELECT EXISTS (SELECT 1 ` + from + ` AND p.id = ?)`
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	if err = s.db.QueryRow(ctx, &found, statement, append(parameters, photoID)...); err != nil {
+		slog.Error("error checking for a photo in a smart album", "error", err, "id", album.ID, "photoID", photoID)
+		return false
+	}
+
+	return found
+}
+
+/*
+validateAlbumSearch checks that a smart album's search can be run, and
+returns its keywords and people encoded for storage.
+*/
+func validateAlbumSearch(criteria *models.PhotoSearch) ([]byte, []byte, error) {
+	var (
+		err      error
+		keywords []byte
+		people   []byte
+	)
+
+	criteria.SearchTerm = strings.TrimSpace(criteria.SearchTerm)
+	criteria.Keywords = trimSearchValues(criteria.Keywords)
+	criteria.People = trimSearchValues(criteria.People)
+	criteria.Match = models.NewSearchMatch(string(criteria.Match))
+
+	if criteria.IsEmpty() {
+		return nil, nil, ErrAlbumSearchRequired
+	}
+
+	if _, _, _, err = buildSearchFrom(*criteria); err != nil {
+		return nil, nil, err
+	}
+
+	if keywords, err = json.Marshal(criteria.Keywords); err != nil {
+		return nil, nil, fmt.Errorf("error encoding smart album keywords: %w", err)
+	}
+
+	if people, err = json.Marshal(criteria.People); err != nil {
+		return nil, nil, fmt.Errorf("error encoding smart album people: %w", err)
+	}
+
+	return keywords, people, nil
+}
+
+/*
+trimSearchValues removes blank keywords or people from a search,
+keeping the case they were entered in.
+*/
+func trimSearchValues(values []string) []string {
+	result := []string{}
+
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			result = append(result, value)
+		}
+	}
+
+	return result
+}
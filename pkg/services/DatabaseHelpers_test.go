@@ -0,0 +1,70 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	_ "github.com/glebarez/sqlite"
+	"github.com/rfberaldo/sqlz"
+	"github.com/rfberaldo/sqlz/binds"
+)
+
+var registerBinds sync.Once
+
+/*
+newTestDB returns a new database with every migration run, the same
+way the app migrates its own when it starts.
+*/
+func newTestDB(t *testing.T) *sqlz.DB {
+	t.Helper()
+
+	registerBinds.Do(func() {
+		binds.Register("sqlite", binds.BindByDriver("sqlite3"))
+	})
+
+	db, err := sqlz.Connect("sqlite", filepath.Join(t.TempDir(), "test.db"))
+
+	if err != nil {
+		t.Fatalf("error opening the test database: %v", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join("..", "..", "sql-migrations", "commit*.sql"))
+
+	if err != nil || len(files) == 0 {
+		t.Fatalf("error finding migrations: %v", err)
+	}
+
+	sort.Strings(files)
+
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err = db.Exec(context.Background(), string(b)); err != nil && !strings.Contains(err.Error(), "duplicate column") {
+			t.Fatalf("error running migration %s: %v", filepath.Base(file), err)
+		}
+	}
+
+	return db
+}
+
+/*
+execTestSQL runs statements that set up a test, failing it if any do.
+*/
+func execTestSQL(t *testing.T, db *sqlz.DB, statements ...string) {
+	t.Helper()
+
+	for _, statement := range statements {
+		if _, err := db.Exec(context.Background(), statement); err != nil {
+			t.Fatalf("error running %q: %v", statement, err)
+		}
+	}
+}
@@ -0,0 +1,482 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/adampresley/ownmyphotos/pkg/events"
+	"github.com/adampresley/ownmyphotos/pkg/models"
+	"github.com/rfberaldo/sqlz"
+)
+
+var (
+	ErrEventNotFound = errors.New("event not found")
+)
+
+type EventServicer interface {
+	/*
+	 * Groups photos into events by the breaks between them, in time
+	 * and place, and stores the events. Returns the number of
+	 * events.
+	 */
+	Rebuild() (int, error)
+
+	/*
+	 * Returns counts of the photos in events, grouped by the year
+	 * their event started.
+	 */
+	GetYears() ([]*models.DateBucket, error)
+
+	/*
+	 * Retrieves the events that started in a year, in the order they
+	 * happened.
+	 */
+	GetEvents(year int) ([]*models.Event, error)
+
+	/*
+	 * Retrieves a single event by ID. An event with an ID of 0 is
+	 * returned if it doesn't exist.
+	 */
+	Get(id int64) (*models.Event, error)
+
+	/*
+	 * Names an event. An empty name goes back to calling it after
+	 * where it happened.
+	 */
+	Rename(id int64, name string) error
+}
+
+type EventServiceConfig struct {
+	DB *sqlz.DB
+}
+
+type EventService struct {
+	db *sqlz.DB
+}
+
+/*
+eventPhoto is what grouping photos into events needs to know about
+each photo.
+*/
+type eventPhoto struct {
+	ID               string
+	CreationDateTime time.Time
+	Latitude         float64
+	Longitude        float64
+	PlaceID          int64
+	Rating           int
+	EventID          int64
+}
+
+func NewEventService(config EventServiceConfig) EventService {
+	return EventService{
+		db: config.DB,
+	}
+}
+
+/*
+eventColumns is the column list used by queries that return events.
+*/
+const eventColumns = `
+	e.id
+	, e.name
+	, e.start_date_time
+	, e.end_date_time
+	, e.num_photos
+	, e.place_id
+	, COALESCE(c.name, '') AS place_name
+	, e.latitude
+	, e.longitude
+	, COALESCE(e.cover_photo_id, '') AS cover_photo_id
+	, COALESCE((
+		SELECT p.id
+		FROM photos p
+		WHERE p.id = e.cover_photo_id
+			AND p.deleted_at IS NULL
+	), (
+		SELECT p.id
+		FROM photos p
+		WHERE p.event_id = e.id
+			AND p.deleted_at IS NULL
+		ORDER BY p.creation_date_time ASC, p.id ASC
+		LIMIT 1
+	), '') AS key_photo_id`
+
+/*
+Groups photos into events with events.Split. Photos without a date,
+or with an estimated one, are left out, as their dates can't be
+trusted. Each run of photos keeps the ID, and so the name, of the
+event most of its photos were already in, so naming an event survives
+photos being added to it. Events none of whose photos are left are
+removed.
+*/
+func (s EventService) Rebuild() (int, error) {
+	var (
+		err     error
+		tx      *sqlz.Tx
+		result  sql.Result
+		photos  = []*eventPhoto{}
+		claimed = map[int64]bool{}
+		success bool
+	)
+
+	/*
+	 * A large library has hundreds of thousands of photos to update,
+	 * which takes longer than the usual query timeout.
+	 */
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*5)
+	defer cancel()
+
+	statement := `
+SELECT
+	p.id
+	, p.creation_date_time
+	, COALESCE(p.latitude, 0) AS latitude
+	, COALESCE(p.longitude, 0) AS longitude
+	, COALESCE(p.place_id, 0) AS place_id
+	, p.rating
+	, COALESCE(p.event_id, 0) AS event_id
+FROM photos p
+WHERE ` + eventPhotoConditions + `
+ORDER BY p.creation_date_time ASC, p.id ASC
+`
+
+	if err = s.db.Query(ctx, &photos, statement); err != nil {
+		return 0, fmt.Errorf("error querying for photos to group into events: %w", err)
+	}
+
+	moments := make([]events.Moment, len(photos))
+
+	for index, photo := range photos {
+		moments[index] = events.Moment{
+			Time:      photo.CreationDateTime,
+			Latitude:  photo.Latitude,
+			Longitude: photo.Longitude,
+		}
+	}
+
+	groups := events.Split(moments)
+
+	if tx, err = s.db.Begin(ctx); err != nil {
+		return 0, fmt.Errorf("error starting transaction when grouping photos into events: %w", err)
+	}
+
+	defer func() {
+		if !success {
+			_ = tx.Rollback()
+		}
+	}()
+
+	now := time.Now().UTC()
+
+	for _, group := range groups {
+		members := make([]*eventPhoto, len(group))
+
+		for index, photoIndex := range group {
+			members[index] = photos[photoIndex]
+		}
+
+		event := summarizeEvent(members)
+		event.ID = previousEventID(members, claimed)
+
+		if event.ID == 0 {
+			statement = `
+INSERT INTO events (
+	created_at
+	, updated_at
+	, start_date_time
+	, end_date_time
+	, num_photos
+	, place_id
+	, latitude
+	, longitude
+	, cover_photo_id
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+`
+
+			if result, err = tx.Exec(ctx, statement, now, now, event.StartDateTime, event.EndDateTime, event.NumPhotos, event.PlaceID, event.Latitude, event.Longitude, event.CoverPhotoID); err != nil {
+				return 0, fmt.Errorf("error inserting event: %w", err)
+			}
+
+			if event.ID, err = result.LastInsertId(); err != nil {
+				return 0, fmt.Errorf("error getting the ID of a new event: %w", err)
+			}
+		} else {
+			statement = `
+UPDATE events SET
+	updated_at = ?
+	, start_date_time = ?
+	, end_date_time = ?
+	, num_photos = ?
+	, place_id = ?
+	, latitude = ?
+	, longitude = ?
+	, cover_photo_id = ?
+WHERE id = ?
+`
+
+			if _, err = tx.Exec(ctx, statement, now, event.StartDateTime, event.EndDateTime, event.NumPhotos, event.PlaceID, event.Latitude, event.Longitude, event.CoverPhotoID, event.ID); err != nil {
+				return 0, fmt.Errorf("error updating event %d: %w", event.ID, err)
+			}
+		}
+
+		claimed[event.ID] = true
+
+		for _, photo := range members {
+			if photo.EventID == event.ID {
+				continue
+			}
+
+			if _, err = tx.Exec(ctx, `UPDATE photos SET event_id = ? WHERE id = ?`, event.ID, photo.ID); err != nil {
+				return 0, fmt.Errorf("error setting the event of photo %s: %w", photo.ID, err)
+			}
+		}
+	}
+
+	statement = `
+UPDATE photos SET event_id = NULL
+WHERE event_id IS NOT NULL
+	AND id NOT IN (SELECT p.id FROM photos p WHERE ` + eventPhotoConditions + `)
+`
+
+	if _, err = tx.Exec(ctx, statement); err != nil {
+		return 0, fmt.Errorf("error clearing the events of photos left out of events: %w", err)
+	}
+
+	ids := make([]int64, 0, len(claimed))
+
+	for id := range claimed {
+		ids = append(ids, id)
+	}
+
+	if len(ids) == 0 {
+		_, err = tx.Exec(ctx, `DELETE FROM events`)
+	} else {
+		_, err = tx.Exec(ctx, `DELETE FROM events WHERE id NOT IN (?)`, ids)
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("error deleting empty events: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("error committing events: %w", err)
+	}
+
+	success = true
+	slog.Info("grouped photos into events", "photos", len(photos), "events", len(groups))
+
+	return len(groups), nil
+}
+
+/*
+eventPhotoConditions selects the photos that are grouped into events.
+*/
+const eventPhotoConditions = `p.deleted_at IS NULL
+	AND NOT p.date_is_estimated
+	AND ` + undatedPhotoCondition
+
+/*
+summarizeEvent works out an event's dates, place, and cover from its
+photos, which are in the order they were taken. The place is the city
+most of its photos were taken in, and the cover is its highest rated
+photo, or the one in the middle if none are rated.
+*/
+func summarizeEvent(photos []*eventPhoto) *models.Event {
+	var (
+		latitude, longitude float64
+		numLocated          int
+		bestPlaceCount      int
+	)
+
+	result := &models.Event{
+		StartDateTime: photos[0].CreationDateTime,
+		EndDateTime:   photos[len(photos)-1].CreationDateTime,
+		NumPhotos:     len(photos),
+	}
+
+	cover := photos[len(photos)/2]
+	placeCounts := map[int64]int{}
+
+	for _, photo := range photos {
+		if photo.Rating > cover.Rating {
+			cover = photo
+		}
+
+		if photo.Latitude != 0 || photo.Longitude != 0 {
+			latitude += photo.Latitude
+			longitude += photo.Longitude
+			numLocated++
+		}
+
+		if photo.PlaceID == 0 {
+			continue
+		}
+
+		placeCounts[photo.PlaceID]++
+
+		if placeCounts[photo.PlaceID] > bestPlaceCount {
+			placeID := photo.PlaceID
+			result.PlaceID = &placeID
+			bestPlaceCount = placeCounts[photo.PlaceID]
+		}
+	}
+
+	if numLocated > 0 {
+		latitude, longitude = latitude/float64(numLocated), longitude/float64(numLocated)
+		result.Latitude, result.Longitude = &latitude, &longitude
+	}
+
+	result.CoverPhotoID = cover.ID
+	return result
+}
+
+/*
+previousEventID returns the event most of a run's photos were already
+in, as long as another run hasn't already taken it. Returns 0 when
+the run is a new event.
+*/
+func previousEventID(photos []*eventPhoto, claimed map[int64]bool) int64 {
+	var (
+		result    int64
+		bestCount int
+	)
+
+	counts := map[int64]int{}
+
+	for _, photo := range photos {
+		if photo.EventID == 0 || claimed[photo.EventID] {
+			continue
+		}
+
+		counts[photo.EventID]++
+
+		if counts[photo.EventID] > bestCount {
+			result, bestCount = photo.EventID, counts[photo.EventID]
+		}
+	}
+
+	return result
+}
+
+/*
+Returns counts of the photos in events, grouped by the year their
+event started.
+*/
+func (s EventService) GetYears() ([]*models.DateBucket, error) {
+	var (
+		err     error
+		results = []*models.DateBucket{}
+	)
+
+	statement := `
+SELECT
+	substr(e.start_date_time, 1, 4) AS period
+	, SUM(e.num_photos) AS num_photos
+	, 0 AS num_estimated
+FROM events e
+GROUP BY period
+ORDER BY period
+`
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	if err = s.db.Query(ctx, &results, statement); err != nil {
+		return results, fmt.Errorf("error querying for years of events: %w", err)
+	}
+
+	return results, nil
+}
+
+/*
+Retrieves the events that started in a year, in the order they
+happened.
+*/
+func (s EventService) GetEvents(year int) ([]*models.Event, error) {
+	var (
+		err    error
+		result = []*models.Event{}
+	)
+
+	statement := `
+SELECT ` + eventColumns + `
+FROM events e
+LEFT JOIN geonames_cities c ON c.geoname_id = e.place_id
+WHERE substr(e.start_date_time, 1, 4) = ?
+ORDER BY e.start_date_time ASC, e.id ASC
+`
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	if err = s.db.Query(ctx, &result, statement, fmt.Sprintf("%04d", year)); err != nil {
+		return result, fmt.Errorf("error querying for events in %d: %w", year, err)
+	}
+
+	return result, nil
+}
+
+/*
+Retrieves a single event by ID.
+*/
+func (s EventService) Get(id int64) (*models.Event, error) {
+	var (
+		err    error
+		result = &models.Event{}
+	)
+
+	statement := `
+SELECT ` + eventColumns + `
+FROM events e
+LEFT JOIN geonames_cities c ON c.geoname_id = e.place_id
+WHERE e.id = ?
+`
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	if err = s.db.QueryRow(ctx, result, statement, id); err != nil {
+		if sqlz.IsNotFound(err) {
+			return &models.Event{}, nil
+		}
+
+		return result, fmt.Errorf("error querying for event %d: %w", id, err)
+	}
+
+	return result, nil
+}
+
+/*
+Names an event. An empty name goes back to calling it after where it
+happened.
+*/
+func (s EventService) Rename(id int64, name string) error {
+	var (
+		err          error
+		result       sql.Result
+		rowsAffected int64
+	)
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	if result, err = s.db.Exec(ctx, `UPDATE events SET name = ?, updated_at = ? WHERE id = ?`, strings.TrimSpace(name), time.Now().UTC(), id); err != nil {
+		return fmt.Errorf("error renaming event %d: %w", id, err)
+	}
+
+	if rowsAffected, err = result.RowsAffected(); err != nil {
+		return fmt.Errorf("error checking the rename of event %d: %w", id, err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrEventNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,507 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/adampresley/ownmyphotos/pkg/models"
+	"github.com/rfberaldo/sqlz"
+)
+
+type FaceServicer interface {
+	/*
+	 * Retrieves up to limit photos face detection hasn't looked at
+	 * yet.
+	 */
+	GetPhotosPendingDetection(limit int) ([]*models.Photo, error)
+
+	/*
+	 * Retrieves every face in a photo, including ignored ones, with
+	 * their descriptors.
+	 */
+	GetFacesInPhoto(photoID string) ([]*models.Face, error)
+
+	/*
+	 * Stores the faces detection found in a photo, and the
+	 * descriptors of faces the photo already had, then marks the
+	 * photo as looked at.
+	 */
+	SaveDetectedFaces(photoID string, detected, described []*models.Face) error
+
+	/*
+	 * Retrieves every face that isn't ignored and has a descriptor,
+	 * for clustering.
+	 */
+	GetDescribedFaces() ([]*models.Face, error)
+
+	/*
+	 * Stores the cluster and suggested person of each unnamed face.
+	 */
+	SaveClusters(faces []*models.Face) error
+
+	/*
+	 * Retrieves the clusters of unnamed faces waiting to be named,
+	 * biggest first, with the most recent faces of each.
+	 */
+	GetClusters(limit, facesPerCluster int) ([]*models.FaceCluster, error)
+
+	/*
+	 * Returns how many faces are waiting to be named.
+	 */
+	CountFacesToReview() (int, error)
+
+	/*
+	 * Names faces, adding the person to their photos. The person is
+	 * created if nobody has that name. Returns the person's ID and
+	 * the IDs of the photos the faces are in.
+	 */
+	NameFaces(ids []int64, name string) (int64, []string, error)
+
+	/*
+	 * Takes faces out of the review queue, such as things that
+	 * aren't faces, or people not worth naming.
+	 */
+	IgnoreFaces(ids []int64) error
+}
+
+type FaceServiceConfig struct {
+	DB *sqlz.DB
+}
+
+type FaceService struct {
+	db *sqlz.DB
+}
+
+func NewFaceService(config FaceServiceConfig) FaceService {
+	return FaceService{
+		db: config.DB,
+	}
+}
+
+/*
+Retrieves up to limit photos face detection hasn't looked at yet.
+*/
+func (s FaceService) GetPhotosPendingDetection(limit int) ([]*models.Photo, error) {
+	var (
+		err    error
+		result = []*models.Photo{}
+	)
+
+	statement := `
+SELECT ` + photoColumns + `
+FROM photos p
+WHERE p.faces_detected_at IS NULL
+	AND p.deleted_at IS NULL
+ORDER BY p.id ASC
+LIMIT ?
+`
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	if err = s.db.Query(ctx, &result, statement, limit); err != nil {
+		return result, fmt.Errorf("error querying for photos pending face detection: %w", err)
+	}
+
+	return result, nil
+}
+
+/*
+Retrieves every face in a photo, including ignored ones, with their
+descriptors.
+*/
+func (s FaceService) GetFacesInPhoto(photoID string) ([]*models.Face, error) {
+	var (
+		err    error
+		result = []*models.Face{}
+	)
+
+	statement := `
+SELECT
+	id
+	, photo_id
+	, COALESCE(person_id, 0) AS person_id
+	, name
+	, x
+	, y
+	, width
+	, height
+	, source
+	, descriptor
+FROM photo_faces
+WHERE photo_id = ?
+ORDER BY id ASC
+`
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	if err = s.db.Query(ctx, &result, statement, photoID); err != nil {
+		return result, fmt.Errorf("error querying for faces in photo %s: %w", photoID, err)
+	}
+
+	return result, nil
+}
+
+/*
+Stores the faces detection found in a photo, and the descriptors of
+faces the photo already had, then marks the photo as looked at, all
+or nothing.
+*/
+func (s FaceService) SaveDetectedFaces(photoID string, detected, described []*models.Face) error {
+	var (
+		err     error
+		tx      *sqlz.Tx
+		success bool
+	)
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	if tx, err = s.db.Begin(ctx); err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+
+	defer func() {
+		if !success {
+			_ = tx.Rollback()
+		}
+	}()
+
+	for _, face := range detected {
+		statement := `
+INSERT INTO photo_faces (
+	photo_id
+	, x
+	, y
+	, width
+	, height
+	, source
+	, descriptor
+) VALUES (
+	?
+	, ?
+	, ?
+	, ?
+	, ?
+	, ?
+	, ?
+)
+`
+
+		args := []any{photoID, face.X, face.Y, face.Width, face.Height, models.FaceSourceDetected, face.Descriptor}
+
+		if _, err = tx.Exec(ctx, statement, args...); err != nil {
+			return fmt.Errorf("error inserting detected face in photo %s: %w", photoID, err)
+		}
+	}
+
+	for _, face := range described {
+		if _, err = tx.Exec(ctx, `UPDATE photo_faces SET descriptor = ? WHERE id = ?`, face.Descriptor, face.ID); err != nil {
+			return fmt.Errorf("error storing the descriptor of face %d: %w", face.ID, err)
+		}
+	}
+
+	if _, err = tx.Exec(ctx, `UPDATE photos SET faces_detected_at = ? WHERE id = ?`, time.Now().UTC(), photoID); err != nil {
+		return fmt.Errorf("error marking faces detected in photo %s: %w", photoID, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing detected faces in photo %s: %w", photoID, err)
+	}
+
+	success = true
+	return nil
+}
+
+/*
+Retrieves every face that isn't ignored and has a descriptor, for
+clustering.
+*/
+func (s FaceService) GetDescribedFaces() ([]*models.Face, error) {
+	var (
+		err    error
+		result = []*models.Face{}
+	)
+
+	statement := `
+SELECT
+	id
+	, photo_id
+	, COALESCE(person_id, 0) AS person_id
+	, name
+	, source
+	, descriptor
+FROM photo_faces
+WHERE ignored = 0
+	AND descriptor IS NOT NULL
+ORDER BY id ASC
+`
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	if err = s.db.Query(ctx, &result, statement); err != nil {
+		return result, fmt.Errorf("error querying for described faces: %w", err)
+	}
+
+	return result, nil
+}
+
+/*
+Stores the cluster and suggested person of each unnamed face, all or
+nothing. Unnamed faces that aren't given are left out of every
+cluster.
+*/
+func (s FaceService) SaveClusters(faces []*models.Face) error {
+	var (
+		err     error
+		tx      *sqlz.Tx
+		success bool
+	)
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	if tx, err = s.db.Begin(ctx); err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+
+	defer func() {
+		if !success {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.Exec(ctx, `UPDATE photo_faces SET cluster_id = NULL, suggested_person_id = NULL WHERE person_id IS NULL`); err != nil {
+		return fmt.Errorf("error clearing face clusters: %w", err)
+	}
+
+	statement := `
+UPDATE photo_faces SET
+	cluster_id = ?
+	, suggested_person_id = NULLIF(?, 0)
+WHERE id = ?
+	AND person_id IS NULL
+`
+
+	for _, face := range faces {
+		if _, err = tx.Exec(ctx, statement, face.ClusterID, face.SuggestedPersonID, face.ID); err != nil {
+			return fmt.Errorf("error storing the cluster of face %d: %w", face.ID, err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing face clusters: %w", err)
+	}
+
+	success = true
+	return nil
+}
+
+/*
+reviewConditions selects the faces waiting to be named. Faces with a
+name from metadata that doesn't match anyone are left out, as they
+are linked once the name is added as an alias.
+*/
+const reviewConditions = `
+	f.person_id IS NULL
+	AND f.name = ''
+	AND f.ignored = 0
+	AND f.cluster_id IS NOT NULL
+	AND p.deleted_at IS NULL`
+
+/*
+Retrieves up to limit clusters of unnamed faces waiting to be named,
+biggest first. Each has up to facesPerCluster of its faces, from the
+most recent photos.
+*/
+func (s FaceService) GetClusters(limit, facesPerCluster int) ([]*models.FaceCluster, error) {
+	var (
+		err    error
+		result = []*models.FaceCluster{}
+	)
+
+	statement := `
+SELECT
+	f.cluster_id AS id
+	, COUNT(*) AS num_faces
+	, COALESCE(MAX(f.suggested_person_id), 0) AS suggested_person_id
+	, COALESCE(MAX(pe.name), '') AS suggested_name
+FROM photo_faces f
+JOIN photos p ON p.id = f.photo_id
+LEFT JOIN people pe ON pe.id = f.suggested_person_id
+WHERE ` + reviewConditions + `
+GROUP BY f.cluster_id
+ORDER BY num_faces DESC, f.cluster_id ASC
+LIMIT ?
+`
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	if err = s.db.Query(ctx, &result, statement, limit); err != nil {
+		return result, fmt.Errorf("error querying for face clusters: %w", err)
+	}
+
+	statement = `
+SELECT ` + faceColumns + `
+FROM photo_faces f
+JOIN photos p ON p.id = f.photo_id
+LEFT JOIN people pe ON pe.id = f.person_id
+WHERE ` + reviewConditions + `
+	AND f.cluster_id = ?
+ORDER BY p.creation_date_time DESC, f.id ASC
+LIMIT ?
+`
+
+	for _, cluster := range result {
+		cluster.Faces = []*models.Face{}
+
+		if err = s.db.Query(ctx, &cluster.Faces, statement, cluster.ID, facesPerCluster); err != nil {
+			return result, fmt.Errorf("error querying for the faces in cluster %d: %w", cluster.ID, err)
+		}
+	}
+
+	return result, nil
+}
+
+/*
+Returns how many faces are waiting to be named.
+*/
+func (s FaceService) CountFacesToReview() (int, error) {
+	var (
+		err    error
+		result int
+	)
+
+	statement := `
+SELECT COUNT(*)
+FROM photo_faces f
+JOIN photos p ON p.id = f.photo_id
+WHERE ` + reviewConditions + `
+`
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	if err = s.db.QueryRow(ctx, &result, statement); err != nil {
+		return result, fmt.Errorf("error counting faces to review: %w", err)
+	}
+
+	return result, nil
+}
+
+/*
+Names faces, adding the person to the photos they're in. The name is
+matched to a person the same way names in photos' metadata are, and
+the person is created if nobody has it. Returns the person's ID and
+the IDs of the photos the faces are in.
+*/
+func (s FaceService) NameFaces(ids []int64, name string) (int64, []string, error) {
+	var (
+		err      error
+		tx       *sqlz.Tx
+		r        sql.Result
+		personID int64
+		photoIDs = []string{}
+		success  bool
+	)
+
+	if name = strings.TrimSpace(name); name == "" {
+		return 0, photoIDs, ErrPersonNameRequired
+	}
+
+	if len(ids) == 0 {
+		return 0, photoIDs, nil
+	}
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	if tx, err = s.db.Begin(ctx); err != nil {
+		return 0, photoIDs, fmt.Errorf("error starting transaction: %w", err)
+	}
+
+	defer func() {
+		if !success {
+			_ = tx.Rollback()
+		}
+	}()
+
+	/*
+	 * Names typed in the review queue are matched without regard to
+	 * case, so "bob" finds Bob rather than creating someone new.
+	 */
+	if err = tx.QueryRow(ctx, &personID, findPersonStatement, name, name); sqlz.IsNotFound(err) {
+		err = tx.QueryRow(ctx, &personID, `SELECT id FROM people WHERE name = ? COLLATE NOCASE ORDER BY id LIMIT 1`, name)
+	}
+
+	if err != nil && !sqlz.IsNotFound(err) {
+		return 0, photoIDs, fmt.Errorf("error querying for person %s: %w", name, err)
+	}
+
+	if sqlz.IsNotFound(err) {
+		statement := `INSERT INTO people (created_at, updated_at, name) VALUES (?, ?, ?)`
+
+		if r, err = tx.Exec(ctx, statement, time.Now().UTC(), time.Now().UTC(), name); err != nil {
+			return 0, photoIDs, fmt.Errorf("error inserting person %s: %w", name, err)
+		}
+
+		if personID, err = r.LastInsertId(); err != nil {
+			return 0, photoIDs, fmt.Errorf("error getting the ID of person %s: %w", name, err)
+		}
+	}
+
+	if err = tx.Query(ctx, &photoIDs, `SELECT DISTINCT photo_id FROM photo_faces WHERE id IN (?) ORDER BY photo_id`, ids); err != nil && !sqlz.IsNotFound(err) {
+		return 0, photoIDs, fmt.Errorf("error querying for the photos of faces: %w", err)
+	}
+
+	statements := []string{
+		`UPDATE photo_faces SET person_id = ?, cluster_id = NULL, suggested_person_id = NULL, ignored = 0 WHERE id IN (?)`,
+		`INSERT INTO photos_people (photo_id, person_id) SELECT DISTINCT photo_id, ? FROM photo_faces WHERE id IN (?) ON CONFLICT (photo_id, person_id) DO NOTHING`,
+	}
+
+	for _, statement := range statements {
+		if _, err = tx.Exec(ctx, statement, personID, ids); err != nil {
+			return 0, photoIDs, fmt.Errorf("error naming faces %s: %w", name, err)
+		}
+	}
+
+	if err = reindexPhotos(ctx, tx, photoIDs); err != nil {
+		return 0, photoIDs, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, photoIDs, fmt.Errorf("error committing named faces: %w", err)
+	}
+
+	success = true
+	return personID, photoIDs, nil
+}
+
+/*
+Takes unnamed faces out of the review queue.
+*/
+func (s FaceService) IgnoreFaces(ids []int64) error {
+	var (
+		err error
+	)
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	statement := `UPDATE photo_faces SET ignored = 1, cluster_id = NULL, suggested_person_id = NULL WHERE id IN (?) AND person_id IS NULL`
+
+	if _, err = s.db.Exec(ctx, statement, ids); err != nil {
+		return fmt.Errorf("error ignoring faces: %w", err)
+	}
+
+	return nil
+}
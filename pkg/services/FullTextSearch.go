@@ -0,0 +1,163 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/rfberaldo/sqlz"
+)
+
+/*
+Statements to maintain the photos_fts full text index for a single
+photo. The indexed document is built from the photo, its keywords,
+people, its path relative to the library, and the names of the
+place it was taken.
+*/
+const (
+	deleteFullTextStatement = `DELETE FROM photos_fts WHERE photo_id=?`
+
+	insertFullTextStatement = `
+INSERT INTO photos_fts (
+	photo_id
+	, file_name
+	, title
+	, caption
+	, keywords
+	, people
+	, path
+	, year
+	, place
+)
+SELECT
+	p.id
+	, COALESCE(p.file_name, '')
+	, COALESCE(p.title, '')
+	, COALESCE(p.caption, '')
+	, COALESCE((SELECT group_concat(pk.keyword, ' ') FROM photos_keywords pk WHERE pk.photo_id = p.id), '')
+	, COALESCE((SELECT group_concat(pe.name, ' ') FROM photos_people pp JOIN people pe ON pe.id = pp.person_id WHERE pp.photo_id = p.id), '')
+	, COALESCE((SELECT LTRIM(f.parent_path || '/' || f.folder_name, '/') FROM folders f WHERE f.full_path = p.full_path), p.full_path)
+	, COALESCE(p.year, '')
+	, COALESCE((
+		SELECT c.name || ' ' || COALESCE(a.name, '') || ' ' || COALESCE(co.name, '')
+		FROM geonames_cities c
+		LEFT JOIN geonames_admin1 a ON a.country_code = c.country_code AND a.admin1_code = c.admin1_code
+		LEFT JOIN geonames_countries co ON co.country_code = c.country_code
+		WHERE c.geoname_id = p.place_id
+	), '')
+FROM photos p
+WHERE p.id=?
+`
+
+	/*
+	 * Column weights for ranking, in the order the columns are declared
+	 * on photos_fts. Titles, keywords, and people count the most.
+	 */
+	fullTextRank = `bm25(photos_fts, 0.0, 2.0, 5.0, 3.0, 4.0, 4.0, 1.0, 2.0, 3.0)`
+)
+
+/*
+buildFullTextQuery turns a user's search term into an FTS5 query. Words
+are matched by prefix and must all be present. Text in double quotes
+is matched as a phrase. Every token is quoted, so characters that have
+meaning in FTS5 syntax are treated as plain text. An empty string is
+returned if the term contains nothing searchable.
+*/
+func buildFullTextQuery(term string) string {
+	var (
+		parts []string
+	)
+
+	for _, token := range tokenizeSearchTerm(term) {
+		if token.phrase {
+			parts = append(parts, quoteFullTextString(token.text))
+			continue
+		}
+
+		word := strings.TrimRight(token.text, "*")
+
+		if !hasSearchableCharacters(word) {
+			continue
+		}
+
+		parts = append(parts, quoteFullTextString(word)+"*")
+	}
+
+	return strings.Join(parts, " ")
+}
+
+type searchTermToken struct {
+	text   string
+	phrase bool
+}
+
+/*
+tokenizeSearchTerm splits a term on whitespace, keeping text in
+double quotes together. An unterminated quote runs to the end.
+*/
+func tokenizeSearchTerm(term string) []searchTermToken {
+	var (
+		result  []searchTermToken
+		current strings.Builder
+		inQuote bool
+	)
+
+	flush := func(phrase bool) {
+		text := strings.TrimSpace(current.String())
+		current.Reset()
+
+		if hasSearchableCharacters(text) {
+			result = append(result, searchTermToken{text: text, phrase: phrase})
+		}
+	}
+
+	for _, ch := range term {
+		switch {
+		case ch == '"':
+			flush(inQuote)
+			inQuote = !inQuote
+
+		case unicode.IsSpace(ch) && !inQuote:
+			flush(false)
+
+		default:
+			current.WriteRune(ch)
+		}
+	}
+
+	flush(inQuote)
+	return result
+}
+
+func quoteFullTextString(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+func hasSearchableCharacters(s string) bool {
+	return strings.IndexFunc(s, func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsNumber(r)
+	}) > -1
+}
+
+/*
+reindexPhotos updates the full text index for photos whose keywords
+or people were changed directly, rather than by saving each photo.
+*/
+func reindexPhotos(ctx context.Context, tx *sqlz.Tx, ids []string) error {
+	var (
+		err error
+	)
+
+	for _, id := range ids {
+		if _, err = tx.Exec(ctx, deleteFullTextStatement, id); err != nil {
+			return fmt.Errorf("error deleting full text index on photo %s: %w", id, err)
+		}
+
+		if _, err = tx.Exec(ctx, insertFullTextStatement, id); err != nil {
+			return fmt.Errorf("error indexing photo %s for full text search: %w", id, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/adampresley/ownmyphotos/pkg/models"
+)
+
+func TestBuildFullTextQuery(t *testing.T) {
+	for term, want := range map[string]string{
+		"sunset beach":         `"sunset"* "beach"*`,
+		`"sunset beach" dog`:   `"sunset beach" "dog"*`,
+		`"unfinished phrase`:   `"unfinished phrase"`,
+		"dog* NEAR(cat) AND":   `"dog"* "NEAR(cat)"* "AND"*`,
+		`say "he said ""hi"""`: `"say"* "he said" "hi"`,
+		`O"Brien`:              `"O"* "Brien"`,
+		"- * ( )":              "",
+		"   ":                  "",
+	} {
+		if got := buildFullTextQuery(term); got != want {
+			t.Errorf("buildFullTextQuery(%q) = %s, want %s", term, got, want)
+		}
+	}
+}
+
+/*
+TestFullTextSearchWithSyntax makes sure text that means something to
+FTS5 is searched for rather than breaking the query.
+*/
+func TestFullTextSearchWithSyntax(t *testing.T) {
+	db := newTestDB(t)
+	service := NewPhotoService(PhotoServiceConfig{DB: db})
+
+	titles := map[string]string{
+		"1": "Sunset at the beach",
+		"2": "NEAR the lake, AND a boat",
+		"3": "Sunrise",
+	}
+
+	for id, title := range titles {
+		photo := &models.Photo{ID: id, FileName: id, Ext: ".jpg", FullPath: "/library", Title: title}
+
+		if err := service.Save(photo); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	found := func(term string) []string {
+		t.Helper()
+
+		result, err := service.Search(models.PhotoSearch{SearchTerm: term})
+
+		if err != nil {
+			t.Fatalf("searching for %q returned error %v", term, err)
+		}
+
+		ids := []string{}
+
+		for _, photo := range result.PhotoMatches {
+			ids = append(ids, photo.ID)
+		}
+
+		return ids
+	}
+
+	if got := found("sun"); len(got) != 2 {
+		t.Errorf("a prefix found %v, want both sunsets and sunrises", got)
+	}
+
+	if got := found("sun beach"); len(got) != 1 || got[0] != "1" {
+		t.Errorf("two words found %v, want the photo with both", got)
+	}
+
+	if got := found(`NEAR( AND lake*`); len(got) != 1 || got[0] != "2" {
+		t.Errorf("FTS5 operators found %v, want the photo titled with them", got)
+	}
+
+	if got := found("sun -beach"); len(got) != 1 || got[0] != "3" {
+		t.Errorf("a negated word found %v, want the photo without it", got)
+	}
+}
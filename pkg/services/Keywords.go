@@ -0,0 +1,315 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/adampresley/ownmyphotos/pkg/models"
+	"github.com/rfberaldo/sqlz"
+)
+
+var (
+	ErrKeywordNameRequired = errors.New("a keyword name is required")
+)
+
+/*
+keywordCountsStatement selects keywords, with their parents and the
+number of photos that have each. Callers add the WHERE and GROUP BY
+clauses.
+*/
+const keywordCountsStatement = `
+SELECT
+	k.keyword
+	, COALESCE(k.parent, '') AS parent
+	, COUNT(pk.photo_id) AS num_matches
+FROM keywords k
+LEFT JOIN photos_keywords pk ON k.keyword = pk.keyword
+`
+
+type KeywordServicer interface {
+	/*
+	 * Retrieves every keyword, with its parent and the number of
+	 * photos that have it.
+	 */
+	All() ([]*models.KeywordSearchResult, error)
+
+	/*
+	 * Renames a keyword on every photo that has it. Returns the IDs
+	 * of the photos that changed.
+	 */
+	Rename(keyword, newName string) ([]string, error)
+
+	/*
+	 * Replaces several keywords with one, on every photo that has
+	 * any of them. Returns the IDs of the photos that changed.
+	 */
+	Merge(keywords []string, into string) ([]string, error)
+
+	/*
+	 * Removes a keyword from every photo that has it. Returns the
+	 * IDs of the photos that changed.
+	 */
+	Delete(keyword string) ([]string, error)
+}
+
+type KeywordServiceConfig struct {
+	DB *sqlz.DB
+}
+
+type KeywordService struct {
+	db *sqlz.DB
+}
+
+func NewKeywordService(config KeywordServiceConfig) KeywordService {
+	return KeywordService{
+		db: config.DB,
+	}
+}
+
+/*
+Retrieves every keyword, with its parent and the number of photos
+that have it.
+*/
+func (s KeywordService) All() ([]*models.KeywordSearchResult, error) {
+	var (
+		err     error
+		results = []*models.KeywordSearchResult{}
+	)
+
+	statement := keywordCountsStatement + `
+GROUP BY k.keyword
+ORDER BY LOWER(k.keyword) ASC, k.keyword ASC
+`
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	if err = s.db.Query(ctx, &results, statement); err != nil && !sqlz.IsNotFound(err) {
+		return results, fmt.Errorf("error querying for keywords: %w", err)
+	}
+
+	return results, nil
+}
+
+/*
+Renames a keyword on every photo that has it. Renaming to a keyword
+that already exists merges the two. The new name can be hierarchical,
+such as "Places|Italy|Rome", to move the keyword in the keyword tree.
+Returns the IDs of the photos that changed.
+*/
+func (s KeywordService) Rename(keyword, newName string) ([]string, error) {
+	return s.Merge([]string{keyword}, newName)
+}
+
+/*
+Replaces several keywords with one, on every photo that has any of
+them. Keywords under the merged ones move under the one they're
+merged into, which takes the first merged keyword's place in the
+tree if it didn't exist yet. Smart albums searching for a merged
+keyword search for the new one instead. Returns the IDs of the photos
+that changed.
+*/
+func (s KeywordService) Merge(keywords []string, into string) ([]string, error) {
+	var (
+		err     error
+		tx      *sqlz.Tx
+		ids     []string
+		success = false
+	)
+
+	path := models.ParseKeywordPath(into)
+
+	if len(path) == 0 {
+		return nil, ErrKeywordNameRequired
+	}
+
+	target := path[len(path)-1].Keyword
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	if tx, err = s.db.Begin(ctx); err != nil {
+		return nil, fmt.Errorf("error starting transaction when merging keywords into '%s': %w", target, err)
+	}
+
+	defer func() {
+		if success {
+			_ = tx.Commit()
+		} else {
+			_ = tx.Rollback()
+		}
+	}()
+
+	/*
+	 * Photos that already have the keyword gain the levels above it
+	 * when it's placed in the tree, so they change too.
+	 */
+	affected := keywords
+
+	if len(path) > 1 {
+		affected = append(slices.Clone(keywords), target)
+	}
+
+	if ids, err = getPhotosWithKeywords(ctx, tx, affected); err != nil {
+		return nil, err
+	}
+
+	statements := []string{
+		`INSERT INTO keywords (keyword, parent) SELECT ?1, parent FROM keywords WHERE keyword = ?2 ON CONFLICT (keyword) DO NOTHING`,
+		`INSERT INTO photos_keywords (photo_id, keyword) SELECT photo_id, ?1 FROM photos_keywords WHERE keyword = ?2 ON CONFLICT (photo_id, keyword) DO NOTHING`,
+		`DELETE FROM photos_keywords WHERE keyword = ?2`,
+		`UPDATE keywords SET parent = ?1 WHERE parent = ?2`,
+		`DELETE FROM keywords WHERE keyword = ?2`,
+		`
+UPDATE albums
+SET search_keywords = (
+	SELECT json_group_array(CASE WHEN value = ?2 THEN ?1 ELSE value END)
+	FROM json_each(albums.search_keywords)
+)
+WHERE EXISTS (SELECT 1 FROM json_each(albums.search_keywords) WHERE value = ?2)
+`,
+	}
+
+	for _, keyword := range keywords {
+		if keyword == target {
+			continue
+		}
+
+		for _, statement := range statements {
+			if _, err = tx.Exec(ctx, statement, target, keyword); err != nil {
+				return nil, fmt.Errorf("error merging keyword '%s' into '%s': %w", keyword, target, err)
+			}
+		}
+	}
+
+	/*
+	 * A hierarchical name places the keyword, and the levels above it,
+	 * in the tree.
+	 */
+	if len(path) > 1 {
+		for _, level := range path {
+			statement := `
+INSERT INTO keywords (
+	keyword
+	, parent
+) VALUES (
+	?
+	, NULLIF(?, '')
+) ON CONFLICT (keyword) DO UPDATE SET
+	parent=excluded.parent
+`
+
+			if _, err = tx.Exec(ctx, statement, level.Keyword, level.Parent); err != nil {
+				return nil, fmt.Errorf("error placing keyword '%s' in the keyword tree: %w", level.Keyword, err)
+			}
+		}
+
+		/*
+		 * Photos carry every level of a hierarchical keyword, as they
+		 * do when the collector reads one from a file.
+		 */
+		for _, level := range path[:len(path)-1] {
+			statement := `
+INSERT INTO photos_keywords (photo_id, keyword)
+SELECT photo_id, ? FROM photos_keywords WHERE keyword = ?
+ON CONFLICT (photo_id, keyword) DO NOTHING
+`
+
+			if _, err = tx.Exec(ctx, statement, level.Keyword, target); err != nil {
+				return nil, fmt.Errorf("error adding keyword '%s' to photos with '%s': %w", level.Keyword, target, err)
+			}
+		}
+	}
+
+	/*
+	 * Merging a keyword into one below it would leave that keyword
+	 * as its own parent.
+	 */
+	if _, err = tx.Exec(ctx, `UPDATE keywords SET parent = NULL WHERE parent = keyword`); err != nil {
+		return nil, fmt.Errorf("error updating the keyword tree: %w", err)
+	}
+
+	if err = reindexPhotos(ctx, tx, ids); err != nil {
+		return nil, err
+	}
+
+	success = true
+	return ids, nil
+}
+
+/*
+Removes a keyword from every photo that has it. Keywords under it move
+up to its parent. Returns the IDs of the photos that changed.
+*/
+func (s KeywordService) Delete(keyword string) ([]string, error) {
+	var (
+		err     error
+		tx      *sqlz.Tx
+		ids     []string
+		success = false
+	)
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	if tx, err = s.db.Begin(ctx); err != nil {
+		return nil, fmt.Errorf("error starting transaction when deleting keyword '%s': %w", keyword, err)
+	}
+
+	defer func() {
+		if success {
+			_ = tx.Commit()
+		} else {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if ids, err = getPhotosWithKeywords(ctx, tx, []string{keyword}); err != nil {
+		return nil, err
+	}
+
+	statements := []string{
+		`DELETE FROM photos_keywords WHERE keyword = ?1`,
+		`UPDATE keywords SET parent = (SELECT parent FROM keywords WHERE keyword = ?1) WHERE parent = ?1`,
+		`DELETE FROM keywords WHERE keyword = ?1`,
+	}
+
+	for _, statement := range statements {
+		if _, err = tx.Exec(ctx, statement, keyword); err != nil {
+			return nil, fmt.Errorf("error deleting keyword '%s': %w", keyword, err)
+		}
+	}
+
+	if err = reindexPhotos(ctx, tx, ids); err != nil {
+		return nil, err
+	}
+
+	success = true
+	return ids, nil
+}
+
+/*
+getPhotosWithKeywords returns the IDs of the photos that have any of
+the keywords.
+*/
+func getPhotosWithKeywords(ctx context.Context, tx *sqlz.Tx, keywords []string) ([]string, error) {
+	var (
+		err error
+		ids = []string{}
+	)
+
+	if len(keywords) == 0 {
+		return ids, nil
+	}
+
+	statement := `SELECT DISTINCT photo_id FROM photos_keywords WHERE keyword IN (?) ORDER BY photo_id`
+
+	if err = tx.Query(ctx, &ids, statement, keywords); err != nil && !sqlz.IsNotFound(err) {
+		return ids, fmt.Errorf("error querying for photos with keywords '%s': %w", strings.Join(keywords, "', '"), err)
+	}
+
+	return ids, nil
+}
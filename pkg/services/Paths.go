@@ -55,3 +55,16 @@ func GetPhotoPath(libraryPath, albumPath, fileName, ext string) string {
 	return fullPath
 
 }
+
+/*
+TrashFolderName is the folder, at the root of the library, photos are
+moved to when they are put in the trash. The collector skips it.
+*/
+const TrashFolderName = ".trash"
+
+/*
+GetTrashPath returns the full path to the library's trash folder.
+*/
+func GetTrashPath(libraryPath string) string {
+	return filepath.Join(libraryPath, TrashFolderName)
+}
@@ -0,0 +1,631 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/adampresley/ownmyphotos/pkg/models"
+	"github.com/adampresley/ownmyphotos/pkg/query"
+	"github.com/rfberaldo/sqlz"
+)
+
+var (
+	ErrPersonNameRequired = errors.New("a person's name is required")
+	ErrPersonNotFound     = errors.New("person not found")
+	ErrAliasIsPerson      = errors.New("the alias is the name of another person")
+)
+
+type PeopleServicer interface {
+	/*
+	 * Retrieves every person, sorted by name, with the number of
+	 * photos of them, their cover photo, and their aliases.
+	 */
+	All() ([]*models.Person, error)
+
+	/*
+	 * Retrieves a single person by ID. A person with an ID of 0 is
+	 * returned if they don't exist.
+	 */
+	Get(id int64) (*models.Person, error)
+
+	/*
+	 * Returns counts of a person's photos grouped by the year they
+	 * were taken.
+	 */
+	GetYears(id int64) ([]*models.DateBucket, error)
+
+	/*
+	 * Changes a person's name. Renaming someone to the name of
+	 * another person merges them into that person. Returns the ID of
+	 * the person they are now, and the IDs of their photos.
+	 */
+	Rename(id int64, name string) (int64, []string, error)
+
+	/*
+	 * Merges several people into one. Returns the IDs of the photos
+	 * of the person they were merged into.
+	 */
+	Merge(ids []int64, into int64) ([]string, error)
+
+	/*
+	 * Adds another name a person is known by. Photos with that name
+	 * in their metadata are given this person when they're scanned.
+	 */
+	AddAlias(id int64, alias string) error
+
+	/*
+	 * Removes one of a person's aliases.
+	 */
+	RemoveAlias(id int64, alias string) error
+
+	/*
+	 * Makes one of a person's photos their cover.
+	 */
+	SetCover(id int64, photoID string) error
+}
+
+type PeopleServiceConfig struct {
+	DB *sqlz.DB
+}
+
+type PeopleService struct {
+	db *sqlz.DB
+}
+
+func NewPeopleService(config PeopleServiceConfig) PeopleService {
+	return PeopleService{
+		db: config.DB,
+	}
+}
+
+/*
+personColumns is the column list used by queries that return people.
+*/
+const personColumns = `
+	pe.id
+	, pe.name
+	, COALESCE(pe.cover_photo_id, '') AS cover_photo_id
+	, (
+		SELECT COUNT(*)
+		FROM photos_people pp
+		JOIN photos p ON p.id = pp.photo_id
+		WHERE pp.person_id = pe.id
+			AND p.deleted_at IS NULL
+	) AS num_photos
+	, COALESCE(pe.cover_photo_id, (
+		SELECT p.id
+		FROM photos_people pp
+		JOIN photos p ON p.id = pp.photo_id
+		WHERE pp.person_id = pe.id
+			AND p.deleted_at IS NULL
+		ORDER BY p.creation_date_time DESC
+		LIMIT 1
+	), '') AS key_photo_id
+	, COALESCE((
+		SELECT f.id
+		FROM photo_faces f
+		JOIN photos p ON p.id = f.photo_id
+		WHERE f.person_id = pe.id
+			AND p.deleted_at IS NULL
+			AND (pe.cover_photo_id IS NULL OR f.photo_id = pe.cover_photo_id)
+		ORDER BY p.creation_date_time DESC, f.id ASC
+		LIMIT 1
+	), 0) AS key_face_id
+	, (
+		SELECT json_group_array(pa.alias)
+		FROM person_aliases pa
+		WHERE pa.person_id = pe.id
+	) AS aliases`
+
+/*
+Retrieves every person, sorted by name.
+*/
+func (s PeopleService) All() ([]*models.Person, error) {
+	var (
+		err    error
+		result = []*models.Person{}
+	)
+
+	statement := `
+SELECT ` + personColumns + `
+FROM people pe
+WHERE pe.deleted_at IS NULL
+ORDER BY LOWER(pe.name) ASC, pe.id ASC
+`
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	if err = s.db.Query(ctx, &result, statement); err != nil {
+		return result, fmt.Errorf("error querying for people: %w", err)
+	}
+
+	return result, nil
+}
+
+/*
+Retrieves a single person by ID.
+*/
+func (s PeopleService) Get(id int64) (*models.Person, error) {
+	var (
+		err    error
+		result = &models.Person{}
+	)
+
+	statement := `
+SELECT ` + personColumns + `
+FROM people pe
+WHERE pe.id = ?
+	AND pe.deleted_at IS NULL
+`
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	if err = s.db.QueryRow(ctx, result, statement, id); err != nil {
+		if sqlz.IsNotFound(err) {
+			return &models.Person{}, nil
+		}
+
+		return result, fmt.Errorf("error querying for person %d: %w", id, err)
+	}
+
+	return result, nil
+}
+
+/*
+Returns counts of a person's photos grouped by the year they were
+taken. Photos without a date are left out.
+*/
+func (s PeopleService) GetYears(id int64) ([]*models.DateBucket, error) {
+	var (
+		err     error
+		results = []*models.DateBucket{}
+	)
+
+	statement := `
+SELECT
+	substr(p.creation_date_time, 1, 4) AS period
+	, COUNT(*) AS num_photos
+	, SUM(CASE WHEN p.date_is_estimated THEN 1 ELSE 0 END) AS num_estimated
+FROM photos_people pp
+JOIN photos p ON p.id = pp.photo_id
+WHERE pp.person_id = ?
+	AND p.deleted_at IS NULL
+	AND ` + undatedPhotoCondition + `
+GROUP BY period
+ORDER BY period
+`
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	if err = s.db.Query(ctx, &results, statement, id); err != nil {
+		return results, fmt.Errorf("error querying for years of photos of person %d: %w", id, err)
+	}
+
+	return results, nil
+}
+
+/*
+Changes a person's name. Their old name becomes an alias, so photos
+that still have it in their metadata keep this person when they're
+scanned again. Smart albums searching for the old name search for the
+new one instead. When the new name belongs to another person, or is
+another person's alias, this person is merged into them instead, and
+they keep their name. Returns the ID of the person they are now, and
+the IDs of their photos.
+*/
+func (s PeopleService) Rename(id int64, name string) (int64, []string, error) {
+	var (
+		err     error
+		tx      *sqlz.Tx
+		otherID int64
+		ids     []string
+		success = false
+	)
+
+	if name = strings.TrimSpace(name); name == "" {
+		return 0, nil, ErrPersonNameRequired
+	}
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	if tx, err = s.db.Begin(ctx); err != nil {
+		return 0, nil, fmt.Errorf("error starting transaction when renaming person %d: %w", id, err)
+	}
+
+	defer func() {
+		if success {
+			_ = tx.Commit()
+		} else {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if _, err = getPersonName(ctx, tx, id); err != nil {
+		return 0, nil, err
+	}
+
+	statement := `
+SELECT id FROM (
+	SELECT id, 0 AS priority FROM people WHERE name = ?1 COLLATE NOCASE AND id <> ?2
+	UNION ALL
+	SELECT person_id, 1 AS priority FROM person_aliases WHERE alias = ?1 AND person_id <> ?2
+)
+ORDER BY priority
+LIMIT 1
+`
+
+	if err = tx.QueryRow(ctx, &otherID, statement, name, id); err != nil && !sqlz.IsNotFound(err) {
+		return 0, nil, fmt.Errorf("error querying for people named '%s': %w", name, err)
+	}
+
+	if otherID != 0 {
+		if err = mergePerson(ctx, tx, id, otherID); err != nil {
+			return 0, nil, err
+		}
+
+		id = otherID
+	} else if err = renamePerson(ctx, tx, id, name); err != nil {
+		return 0, nil, err
+	}
+
+	if ids, err = getPhotosOfPerson(ctx, tx, id); err != nil {
+		return 0, nil, err
+	}
+
+	if err = reindexPhotos(ctx, tx, ids); err != nil {
+		return 0, nil, err
+	}
+
+	success = true
+	return id, ids, nil
+}
+
+/*
+Merges several people into one. Their photos, names, and aliases
+move to the person they're merged into, whose name is kept. The
+merged names become aliases, so later scans find the same person.
+Returns the IDs of the photos of the person they were merged into.
+*/
+func (s PeopleService) Merge(ids []int64, into int64) ([]string, error) {
+	var (
+		err      error
+		tx       *sqlz.Tx
+		photoIDs []string
+		success  = false
+	)
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	if tx, err = s.db.Begin(ctx); err != nil {
+		return nil, fmt.Errorf("error starting transaction when merging people into %d: %w", into, err)
+	}
+
+	defer func() {
+		if success {
+			_ = tx.Commit()
+		} else {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if _, err = getPersonName(ctx, tx, into); err != nil {
+		return nil, err
+	}
+
+	for _, id := range ids {
+		if id == into {
+			continue
+		}
+
+		if err = mergePerson(ctx, tx, id, into); err != nil {
+			return nil, err
+		}
+	}
+
+	if photoIDs, err = getPhotosOfPerson(ctx, tx, into); err != nil {
+		return nil, err
+	}
+
+	if err = reindexPhotos(ctx, tx, photoIDs); err != nil {
+		return nil, err
+	}
+
+	success = true
+	return photoIDs, nil
+}
+
+/*
+Adds another name a person is known by. An alias can't be the name
+of another person, who should be merged instead. An alias already
+used by someone else moves to this person.
+*/
+func (s PeopleService) AddAlias(id int64, alias string) error {
+	var (
+		err     error
+		name    string
+		otherID int64
+	)
+
+	if alias = strings.TrimSpace(alias); alias == "" {
+		return ErrPersonNameRequired
+	}
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	if err = s.db.QueryRow(ctx, &name, `SELECT name FROM people WHERE id = ?`, id); err != nil {
+		if sqlz.IsNotFound(err) {
+			return ErrPersonNotFound
+		}
+
+		return fmt.Errorf("error querying for person %d: %w", id, err)
+	}
+
+	if alias == name {
+		return nil
+	}
+
+	statement := `SELECT id FROM people WHERE name = ? COLLATE NOCASE AND id <> ? LIMIT 1`
+
+	if err = s.db.QueryRow(ctx, &otherID, statement, alias, id); err != nil && !sqlz.IsNotFound(err) {
+		return fmt.Errorf("error querying for people named '%s': %w", alias, err)
+	}
+
+	if otherID != 0 {
+		return ErrAliasIsPerson
+	}
+
+	statement = `
+INSERT INTO person_aliases (
+	alias
+	, person_id
+) VALUES (
+	?
+	, ?
+) ON CONFLICT (alias) DO UPDATE SET
+	person_id=excluded.person_id
+`
+
+	if _, err = s.db.Exec(ctx, statement, alias, id); err != nil {
+		return fmt.Errorf("error adding alias '%s' to person %d: %w", alias, id, err)
+	}
+
+	/*
+	 * Faces named with the alias that didn't match anyone are theirs
+	 * now, too.
+	 */
+	statement = `UPDATE photo_faces SET person_id = ? WHERE person_id IS NULL AND name = ? COLLATE NOCASE`
+
+	if _, err = s.db.Exec(ctx, statement, id, alias); err != nil {
+		return fmt.Errorf("error linking faces named '%s' to person %d: %w", alias, id, err)
+	}
+
+	return nil
+}
+
+/*
+Removes one of a person's aliases.
+*/
+func (s PeopleService) RemoveAlias(id int64, alias string) error {
+	var (
+		err error
+	)
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	statement := `DELETE FROM person_aliases WHERE person_id = ? AND alias = ? COLLATE BINARY`
+
+	if _, err = s.db.Exec(ctx, statement, id, alias); err != nil {
+		return fmt.Errorf("error removing alias '%s' from person %d: %w", alias, id, err)
+	}
+
+	return nil
+}
+
+/*
+Makes one of a person's photos their cover.
+*/
+func (s PeopleService) SetCover(id int64, photoID string) error {
+	var (
+		err error
+	)
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	statement := `
+UPDATE people SET
+	cover_photo_id = ?
+	, updated_at = ?
+WHERE id = ?
+	AND EXISTS (SELECT 1 FROM photos_people WHERE person_id = ? AND photo_id = ?)
+`
+
+	if _, err = s.db.Exec(ctx, statement, photoID, time.Now().UTC(), id, id, photoID); err != nil {
+		return fmt.Errorf("error setting the cover of person %d: %w", id, err)
+	}
+
+	return nil
+}
+
+/*
+getPersonName returns a person's name, or ErrPersonNotFound.
+*/
+func getPersonName(ctx context.Context, tx *sqlz.Tx, id int64) (string, error) {
+	var (
+		err  error
+		name string
+	)
+
+	if err = tx.QueryRow(ctx, &name, `SELECT name FROM people WHERE id = ?`, id); err != nil {
+		if sqlz.IsNotFound(err) {
+			return "", ErrPersonNotFound
+		}
+
+		return "", fmt.Errorf("error querying for person %d: %w", id, err)
+	}
+
+	return name, nil
+}
+
+/*
+renamePerson changes a person's name, keeping the old one as an
+alias. An alias matching the new name exactly is removed, as the name
+finds them now. Aliases differing only by case are kept, as names are
+matched to people exactly before aliases are tried.
+*/
+func renamePerson(ctx context.Context, tx *sqlz.Tx, id int64, name string) error {
+	var (
+		err     error
+		oldName string
+	)
+
+	if oldName, err = getPersonName(ctx, tx, id); err != nil {
+		return err
+	}
+
+	if oldName == name {
+		return nil
+	}
+
+	statements := []string{
+		`INSERT INTO person_aliases (alias, person_id) VALUES (?2, ?1) ON CONFLICT (alias) DO UPDATE SET person_id=excluded.person_id`,
+		`UPDATE people SET name = ?3, updated_at = ?4 WHERE id = ?1`,
+		`DELETE FROM person_aliases WHERE person_id = ?1 AND alias = ?3 COLLATE BINARY`,
+		replaceAlbumPersonStatement,
+	}
+
+	for _, statement := range statements {
+		if _, err = tx.Exec(ctx, statement, id, oldName, name, time.Now().UTC()); err != nil {
+			return fmt.Errorf("error renaming person %d to '%s': %w", id, name, err)
+		}
+	}
+
+	return replaceAlbumSearchPerson(ctx, tx, oldName, name)
+}
+
+/*
+mergePerson moves one person's photos, faces, name, and aliases to
+another, then deletes them. Faces suggested as them are suggested as
+the other person, and smart albums searching for them search for the
+other person. Their cover becomes the other person's cover if they
+didn't have one.
+*/
+func mergePerson(ctx context.Context, tx *sqlz.Tx, from, into int64) error {
+	var (
+		err      error
+		fromName string
+		intoName string
+	)
+
+	if fromName, err = getPersonName(ctx, tx, from); err != nil {
+		return err
+	}
+
+	if intoName, err = getPersonName(ctx, tx, into); err != nil {
+		return err
+	}
+
+	statements := []string{
+		`UPDATE person_aliases SET person_id = ?1 WHERE person_id = ?2`,
+		`INSERT INTO person_aliases (alias, person_id) VALUES (?3, ?1) ON CONFLICT (alias) DO UPDATE SET person_id=excluded.person_id`,
+		`DELETE FROM person_aliases WHERE person_id = ?1 AND alias = ?4 COLLATE BINARY`,
+		`INSERT INTO photos_people (photo_id, person_id) SELECT photo_id, ?1 FROM photos_people WHERE person_id = ?2 ON CONFLICT (photo_id, person_id) DO NOTHING`,
+		`DELETE FROM photos_people WHERE person_id = ?2`,
+		`UPDATE people SET cover_photo_id = COALESCE(cover_photo_id, (SELECT cover_photo_id FROM people WHERE id = ?2)) WHERE id = ?1`,
+		`UPDATE photo_faces SET person_id = ?1 WHERE person_id = ?2`,
+		`UPDATE photo_faces SET suggested_person_id = ?1 WHERE suggested_person_id = ?2`,
+		`DELETE FROM people WHERE id = ?2`,
+	}
+
+	for _, statement := range statements {
+		if _, err = tx.Exec(ctx, statement, into, from, fromName, intoName); err != nil {
+			return fmt.Errorf("error merging person %d into %d: %w", from, into, err)
+		}
+	}
+
+	if _, err = tx.Exec(ctx, replaceAlbumPersonStatement, into, fromName, intoName); err != nil {
+		return fmt.Errorf("error updating smart albums searching for '%s': %w", fromName, err)
+	}
+
+	return replaceAlbumSearchPerson(ctx, tx, fromName, intoName)
+}
+
+/*
+replaceAlbumPersonStatement changes the person smart albums search for
+from one name (?2) to another (?3).
+*/
+const replaceAlbumPersonStatement = `
+UPDATE albums
+SET search_people = (
+	SELECT json_group_array(CASE WHEN value = ?2 THEN ?3 ELSE value END)
+	FROM json_each(albums.search_people)
+)
+WHERE EXISTS (SELECT 1 FROM json_each(albums.search_people) WHERE value = ?2)
+`
+
+/*
+replaceAlbumSearchPerson changes the person smart albums search for in
+their search terms, such as person:"Aunt Mary", from one name to
+another. Search terms that can't be parsed are left alone, as they
+can't find anyone.
+*/
+func replaceAlbumSearchPerson(ctx context.Context, tx *sqlz.Tx, from, to string) error {
+	var (
+		err    error
+		albums = []*models.Album{}
+	)
+
+	statement := `SELECT id, search_term FROM albums WHERE is_smart = 1 AND search_term LIKE ?`
+
+	if err = tx.Query(ctx, &albums, statement, "%"+from+"%"); err != nil && !sqlz.IsNotFound(err) {
+		return fmt.Errorf("error querying for smart albums searching for '%s': %w", from, err)
+	}
+
+	for _, album := range albums {
+		q, err := query.Parse(album.SearchTerm)
+
+		if err != nil {
+			continue
+		}
+
+		searchTerm := q.ReplaceValue(from, to, "person", "people")
+
+		if searchTerm == album.SearchTerm {
+			continue
+		}
+
+		if _, err = tx.Exec(ctx, `UPDATE albums SET search_term = ? WHERE id = ?`, searchTerm, album.ID); err != nil {
+			return fmt.Errorf("error updating the search of album %d: %w", album.ID, err)
+		}
+	}
+
+	return nil
+}
+
+/*
+getPhotosOfPerson returns the IDs of a person's photos.
+*/
+func getPhotosOfPerson(ctx context.Context, tx *sqlz.Tx, id int64) ([]string, error) {
+	var (
+		err error
+		ids = []string{}
+	)
+
+	statement := `SELECT photo_id FROM photos_people WHERE person_id = ? ORDER BY photo_id`
+
+	if err = tx.Query(ctx, &ids, statement, id); err != nil && !sqlz.IsNotFound(err) {
+		return ids, fmt.Errorf("error querying for photos of person %d: %w", id, err)
+	}
+
+	return ids, nil
+}
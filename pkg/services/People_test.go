@@ -0,0 +1,160 @@
+package services
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/adampresley/ownmyphotos/pkg/models"
+)
+
+/*
+newPeopleTest returns services sharing a new database, with a photo of
+each person given.
+*/
+func newPeopleTest(t *testing.T, names ...string) (PeopleService, AlbumService, PhotoService) {
+	t.Helper()
+
+	db := newTestDB(t)
+	photoService := NewPhotoService(PhotoServiceConfig{DB: db})
+
+	for index, name := range names {
+		photo := &models.Photo{
+			ID:       name,
+			FileName: name,
+			Ext:      ".jpg",
+			FullPath: "/library",
+			People:   []*models.Person{{Name: name}},
+			Faces:    []*models.Face{{Name: name, X: 0.1 * float64(index), Y: 0.1, Width: 0.1, Height: 0.1}},
+		}
+
+		if err := photoService.Save(photo); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return NewPeopleService(PeopleServiceConfig{DB: db}), NewAlbumService(AlbumServiceConfig{DB: db}), photoService
+}
+
+func personID(t *testing.T, service PeopleService, name string) int64 {
+	t.Helper()
+
+	people, err := service.All()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, person := range people {
+		if person.Name == name {
+			return int64(person.ID)
+		}
+	}
+
+	t.Fatalf("no person named %q", name)
+	return 0
+}
+
+func TestMergePerson(t *testing.T) {
+	people, albums, photos := newPeopleTest(t, "Mary", "Aunt Mary", "Bob")
+	ctx := context.Background()
+
+	mary, auntMary, bob := personID(t, people, "Mary"), personID(t, people, "Aunt Mary"), personID(t, people, "Bob")
+
+	/*
+	 * Bob's face is waiting in the review queue, looking like Mary.
+	 */
+	if _, err := people.db.Exec(ctx, `UPDATE photo_faces SET person_id = NULL, suggested_person_id = ? WHERE name = 'Bob'`, mary); err != nil {
+		t.Fatal(err)
+	}
+
+	album, err := albums.CreateSmart("Mary at the beach", models.PhotoSearch{
+		SearchTerm: `beach person:Mary -person:"Mary" people:"mary" person:Bob`,
+		People:     []string{"Mary"},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	photoIDs, err := people.Merge([]int64{mary, bob}, auntMary)
+
+	if err != nil {
+		t.Fatalf("Merge returned error %v", err)
+	}
+
+	if !slices.Equal(photoIDs, []string{"Aunt Mary", "Bob", "Mary"}) {
+		t.Errorf("Merge returned photos %v", photoIDs)
+	}
+
+	if person, _ := people.Get(mary); person.ID != 0 {
+		t.Errorf("Mary still exists after being merged")
+	}
+
+	merged, err := people.Get(auntMary)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Contains(merged.Aliases, "Mary") || !slices.Contains(merged.Aliases, "Bob") {
+		t.Errorf("aliases = %v, want Mary and Bob", merged.Aliases)
+	}
+
+	var stale int
+
+	if err = people.db.QueryRow(ctx, &stale, `SELECT COUNT(*) FROM photo_faces WHERE person_id IN (?, ?) OR suggested_person_id IN (?, ?)`, mary, bob, mary, bob); err != nil {
+		t.Fatal(err)
+	}
+
+	if stale != 0 {
+		t.Errorf("%d faces still point at merged people", stale)
+	}
+
+	if album, err = albums.Get(album.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := `beach person:"Aunt Mary" -person:"Aunt Mary" people:"Aunt Mary" person:"Aunt Mary"`; album.SearchTerm != want {
+		t.Errorf("smart album searches for %q, want %q", album.SearchTerm, want)
+	}
+
+	if !slices.Equal(album.SearchPeople, []string{"Aunt Mary"}) {
+		t.Errorf("smart album searches for people %v, want Aunt Mary", album.SearchPeople)
+	}
+
+	/*
+	 * Every photo of the merged people is found under the new name.
+	 */
+	results, err := photos.Search(models.PhotoSearch{SearchTerm: "person:\"Aunt Mary\""})
+
+	if err != nil {
+		t.Fatalf("error searching for the merged person: %v", err)
+	}
+
+	if len(results.PhotoMatches) != 3 {
+		t.Errorf("found %d photos of Aunt Mary, want all 3", len(results.PhotoMatches))
+	}
+}
+
+func TestRenamePersonUpdatesSmartAlbums(t *testing.T) {
+	people, albums, _ := newPeopleTest(t, "Mary")
+
+	album, err := albums.CreateSmart("Mary", models.PhotoSearch{SearchTerm: "person:mary year:2015..2018"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err = people.Rename(personID(t, people, "Mary"), "Mary Smith"); err != nil {
+		t.Fatalf("Rename returned error %v", err)
+	}
+
+	if album, err = albums.Get(album.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := `person:"Mary Smith" year:2015..2018`; album.SearchTerm != want {
+		t.Errorf("smart album searches for %q, want %q", album.SearchTerm, want)
+	}
+}
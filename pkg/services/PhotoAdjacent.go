@@ -0,0 +1,92 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/adampresley/ownmyphotos/pkg/models"
+	"github.com/rfberaldo/sqlz"
+)
+
+/*
+Returns the photos before and after a photo in a folder, in the order
+the folder's photos are shown.
+*/
+func (s PhotoService) GetAdjacentPhotosInFolder(id, folderPath string) (models.AdjacentPhotos, error) {
+	from := `
+FROM photos p
+WHERE p.deleted_at IS NULL
+	AND p.full_path = ?
+`
+
+	result, err := s.getAdjacentPhotos(id, from, "p.file_name ASC, p.id ASC", []any{folderPath})
+
+	if err != nil {
+		return result, fmt.Errorf("error finding photos next to %s in folder %s: %w", id, folderPath, err)
+	}
+
+	return result, nil
+}
+
+/*
+Returns the photos before and after a photo in the results of a
+search, in the order the results are shown. If the search can't be
+parsed, a *query.Error is returned.
+*/
+func (s PhotoService) GetAdjacentSearchResults(id string, criteria models.PhotoSearch) (models.AdjacentPhotos, error) {
+	from, orderBy, args, err := buildSearchFrom(criteria)
+
+	if err != nil {
+		return models.AdjacentPhotos{}, err
+	}
+
+	result, err := s.getAdjacentPhotos(id, from, orderBy, args)
+
+	if err != nil {
+		return result, fmt.Errorf("error finding photos next to %s in search results: %w", id, err)
+	}
+
+	return result, nil
+}
+
+/*
+getAdjacentPhotos finds a photo among the photos selected by a FROM
+and WHERE clause, in the given order, and returns its neighbors.
+*/
+func (s PhotoService) getAdjacentPhotos(id, from, orderBy string, args []any) (models.AdjacentPhotos, error) {
+	var (
+		err    error
+		result = models.AdjacentPhotos{}
+	)
+
+	statement := `
+SELECT
+	id
+	, COALESCE(previous_id, '') AS previous_id
+	, COALESCE(next_id, '') AS next_id
+	, position
+	, total
+FROM (
+	SELECT
+		p.id
+		, LAG(p.id) OVER list AS previous_id
+		, LEAD(p.id) OVER list AS next_id
+		, ROW_NUMBER() OVER list AS position
+		, COUNT(*) OVER () AS total` + from + `
+	WINDOW list AS (ORDER BY ` + orderBy + `)
+)
+WHERE id = ?
+`
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	if err = s.db.QueryRow(ctx, &result, statement, append(args, id)...); err != nil {
+		if sqlz.IsNotFound(err) {
+			return models.AdjacentPhotos{ID: id}, nil
+		}
+
+		return result, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,37 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/adampresley/adamgokit/paging"
+	"github.com/adampresley/ownmyphotos/pkg/models"
+)
+
+/*
+Returns a page of photos in an album, in the order they were added.
+*/
+func (s PhotoService) GetPhotosInAlbum(albumID int64, page int) ([]*models.Photo, paging.Paging, error) {
+	var (
+		err    error
+		result = []*models.Photo{}
+	)
+
+	statement := `
+SELECT ` + photoColumns + totalCountColumn + `
+FROM albums_photos ap
+JOIN photos p ON p.id = ap.photo_id
+WHERE ap.album_id = ?
+	AND p.deleted_at IS NULL
+ORDER BY ap.sort_order ASC, ap.added_at ASC, p.id ASC
+LIMIT ? OFFSET ?
+`
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	if err = s.db.Query(ctx, &result, statement, albumID, PhotosPerPage, paging.Offset(page, PhotosPerPage)); err != nil {
+		return result, paging.Calculate(page, 0, PhotosPerPage), fmt.Errorf("error querying for photos in album %d: %w", albumID, err)
+	}
+
+	return result, calculatePhotoPaging(result, page), nil
+}
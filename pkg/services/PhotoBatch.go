@@ -0,0 +1,237 @@
+package services
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/adampresley/ownmyphotos/pkg/metadata"
+	"github.com/adampresley/ownmyphotos/pkg/models"
+	"github.com/rfberaldo/sqlz"
+)
+
+/*
+movedFile is a file moved to the trash, remembered so the move can be
+undone if the rest of the batch fails.
+*/
+type movedFile struct {
+	from string
+	to   string
+}
+
+/*
+Retrieves the photos with the given IDs, in file name order. IDs of
+photos that don't exist are skipped.
+*/
+func (s PhotoService) GetPhotosByIDs(ids []string) ([]*models.Photo, error) {
+	var (
+		err    error
+		result = []*models.Photo{}
+	)
+
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	statement := `
+SELECT ` + photoColumns + `
+FROM photos p
+WHERE p.deleted_at IS NULL
+	AND p.id IN (?)
+ORDER BY p.full_path ASC, p.file_name ASC, p.id ASC
+`
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	if err = s.db.Query(ctx, &result, statement, ids); err != nil && !sqlz.IsNotFound(err) {
+		return result, fmt.Errorf("error querying for photos %v: %w", ids, err)
+	}
+
+	return result, nil
+}
+
+/*
+Moves photos, and their XMP sidecars, to the trash folder at the root
+of the library, keeping the folders they were in. The photos are then
+removed from the database. Either every photo is moved or, if one
+can't be, those already moved are put back and nothing changes.
+Returns the photos that were moved.
+*/
+func (s PhotoService) MoveToTrash(libraryPath string, ids []string) ([]*models.Photo, error) {
+	var (
+		err     error
+		photos  []*models.Photo
+		moved   []movedFile
+		tx      *sqlz.Tx
+		success = false
+	)
+
+	if photos, err = s.GetPhotosByIDs(ids); err != nil {
+		return nil, err
+	}
+
+	if len(photos) != len(ids) {
+		return nil, fmt.Errorf("only %d of the %d photos to move to the trash were found", len(photos), len(ids))
+	}
+
+	defer func() {
+		if !success {
+			undoMoves(moved)
+		}
+	}()
+
+	trashPath := GetTrashPath(libraryPath)
+
+	for _, photo := range photos {
+		var files []movedFile
+
+		if files, err = trashFiles(libraryPath, trashPath, photo); err != nil {
+			return nil, err
+		}
+
+		moved = append(moved, files...)
+	}
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	if tx, err = s.db.Begin(ctx); err != nil {
+		return nil, fmt.Errorf("error starting transaction when moving photos to the trash: %w", err)
+	}
+
+	for _, photo := range photos {
+		if err = deletePhoto(ctx, tx, photo.ID); err != nil {
+			_ = tx.Rollback()
+			return nil, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing photos moved to the trash: %w", err)
+	}
+
+	success = true
+	return photos, nil
+}
+
+/*
+Writes a zip archive of photos to w, with each photo stored under its
+path in the library. Photos are already compressed, so they are
+stored as they are.
+*/
+func (s PhotoService) WriteArchive(w io.Writer, libraryPath string, photos []*models.Photo) error {
+	var (
+		err    error
+		f      *os.File
+		info   os.FileInfo
+		header *zip.FileHeader
+		entry  io.Writer
+	)
+
+	archive := zip.NewWriter(w)
+
+	for _, photo := range photos {
+		fullPath := photo.GetFullPath()
+
+		if f, err = os.Open(fullPath); err != nil {
+			return fmt.Errorf("error opening photo '%s' to archive: %w", fullPath, err)
+		}
+
+		if info, err = f.Stat(); err != nil {
+			f.Close()
+			return fmt.Errorf("error reading photo '%s' to archive: %w", fullPath, err)
+		}
+
+		if header, err = zip.FileInfoHeader(info); err != nil {
+			f.Close()
+			return fmt.Errorf("error creating the archive entry for '%s': %w", fullPath, err)
+		}
+
+		header.Name = filepath.ToSlash(filepath.Join(GetPhotoRelativePath(libraryPath, photo), photo.FileName+photo.Ext))
+		header.Method = zip.Store
+
+		if entry, err = archive.CreateHeader(header); err != nil {
+			f.Close()
+			return fmt.Errorf("error adding '%s' to the archive: %w", fullPath, err)
+		}
+
+		_, err = io.Copy(entry, f)
+		f.Close()
+
+		if err != nil {
+			return fmt.Errorf("error writing '%s' to the archive: %w", fullPath, err)
+		}
+	}
+
+	return archive.Close()
+}
+
+/*
+trashFiles moves a photo and its sidecar, if it has one, into the
+trash. A photo already in the trash with the same name is kept, and
+the new one is numbered instead.
+*/
+func trashFiles(libraryPath, trashPath string, photo *models.Photo) ([]movedFile, error) {
+	var (
+		err    error
+		result = []movedFile{}
+	)
+
+	fullPath := photo.GetFullPath()
+	targetDir := filepath.Join(trashPath, GetPhotoRelativePath(libraryPath, photo))
+
+	if err = os.MkdirAll(targetDir, 0755); err != nil {
+		return result, fmt.Errorf("error creating trash folder '%s': %w", targetDir, err)
+	}
+
+	targetName := photo.FileName
+
+	for n := 2; ; n++ {
+		if _, err = os.Stat(filepath.Join(targetDir, targetName+photo.Ext)); errors.Is(err, os.ErrNotExist) {
+			break
+		}
+
+		targetName = fmt.Sprintf("%s (%d)", photo.FileName, n)
+	}
+
+	target := filepath.Join(targetDir, targetName+photo.Ext)
+
+	if err = os.Rename(fullPath, target); err != nil {
+		return result, fmt.Errorf("error moving '%s' to the trash: %w", fullPath, err)
+	}
+
+	result = append(result, movedFile{from: fullPath, to: target})
+
+	/*
+	 * The sidecar keeps its naming style, whether it replaces the
+	 * photo's extension or is added after it.
+	 */
+	if sidecarPath := metadata.FindSidecar(fullPath); sidecarPath != "" {
+		sidecarName := filepath.Base(sidecarPath)
+		sidecarTarget := filepath.Join(targetDir, targetName+strings.TrimPrefix(sidecarName, photo.FileName))
+
+		if err = os.Rename(sidecarPath, sidecarTarget); err != nil {
+			undoMoves(result)
+			return nil, fmt.Errorf("error moving '%s' to the trash: %w", sidecarPath, err)
+		}
+
+		result = append(result, movedFile{from: sidecarPath, to: sidecarTarget})
+	}
+
+	return result, nil
+}
+
+/*
+undoMoves puts files moved to the trash back where they were, most
+recent first.
+*/
+func undoMoves(moved []movedFile) {
+	for i := len(moved) - 1; i >= 0; i-- {
+		_ = os.Rename(moved[i].to, moved[i].from)
+	}
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/adampresley/adamgokit/paging"
+	"github.com/adampresley/ownmyphotos/pkg/models"
+)
+
+/*
+equipmentColumns describes how photos are grouped for each kind of
+equipment. Cameras are identified by their make and model, ignoring
+case, and lenses by their normalized lens ID.
+*/
+var equipmentColumns = map[models.EquipmentKind]struct {
+	id      string
+	make    string
+	model   string
+	present string
+}{
+	models.EquipmentCamera: {
+		id:      `LOWER(TRIM(COALESCE(p.make, ''))) || '|' || LOWER(TRIM(COALESCE(p.model, '')))`,
+		make:    `p.make`,
+		model:   `p.model`,
+		present: `COALESCE(p.model, '') <> ''`,
+	},
+	models.EquipmentLens: {
+		id:      `p.lens_id`,
+		make:    `p.lens_make`,
+		model:   `p.lens_model`,
+		present: `COALESCE(p.lens_id, '') <> ''`,
+	},
+}
+
+type equipmentRow struct {
+	ID         string
+	Make       string
+	Model      string
+	NumPhotos  int
+	FirstTaken string
+	LastTaken  string
+	KeyPhotoID string
+}
+
+/*
+Returns every camera or lens photos were taken with, along with the
+number of photos and the dates of the first and last of them. The
+most used equipment comes first.
+*/
+func (s PhotoService) GetEquipment(kind models.EquipmentKind) ([]*models.Equipment, error) {
+	var (
+		err    error
+		rows   = []*equipmentRow{}
+		result = []*models.Equipment{}
+	)
+
+	columns, ok := equipmentColumns[kind]
+
+	if !ok {
+		return result, fmt.Errorf("invalid equipment kind '%s'", kind)
+	}
+
+	statement := `
+SELECT
+	` + columns.id + ` AS id
+	, COALESCE(MAX(` + columns.make + `), '') AS make
+	, COALESCE(MAX(` + columns.model + `), '') AS model
+	, COUNT(*) AS num_photos
+	, COALESCE(substr(MIN(CASE WHEN ` + undatedPhotoCondition + ` THEN p.creation_date_time END), 1, 10), '') AS first_taken
+	, COALESCE(substr(MAX(CASE WHEN ` + undatedPhotoCondition + ` THEN p.creation_date_time END), 1, 10), '') AS last_taken
+	, MIN(p.id) AS key_photo_id
+FROM photos p
+WHERE p.deleted_at IS NULL
+	AND ` + columns.present + `
+GROUP BY 1
+ORDER BY num_photos DESC, id ASC
+`
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	if err = s.db.Query(ctx, &rows, statement); err != nil {
+		return result, fmt.Errorf("error querying for %s equipment: %w", kind, err)
+	}
+
+	for _, row := range rows {
+		result = append(result, &models.Equipment{
+			Kind:       kind,
+			ID:         row.ID,
+			Name:       models.EquipmentName(row.Make, row.Model),
+			NumPhotos:  row.NumPhotos,
+			FirstTaken: row.FirstTaken,
+			LastTaken:  row.LastTaken,
+			KeyPhotoID: row.KeyPhotoID,
+		})
+	}
+
+	return result, nil
+}
+
+/*
+Returns a page of photos taken with a camera or lens, newest first.
+*/
+func (s PhotoService) GetPhotosWithEquipment(kind models.EquipmentKind, id string, page int) ([]*models.Photo, paging.Paging, error) {
+	var (
+		err    error
+		result = []*models.Photo{}
+	)
+
+	columns, ok := equipmentColumns[kind]
+
+	if !ok {
+		return result, paging.Calculate(page, 0, PhotosPerPage), fmt.Errorf("invalid equipment kind '%s'", kind)
+	}
+
+	statement := `
+SELECT ` + photoColumns + totalCountColumn + `
+FROM photos p
+WHERE p.deleted_at IS NULL
+	AND ` + columns.present + `
+	AND ` + columns.id + ` = ?
+ORDER BY p.creation_date_time DESC, p.id ASC
+LIMIT ? OFFSET ?
+`
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	if err = s.db.Query(ctx, &result, statement, id, PhotosPerPage, paging.Offset(page, PhotosPerPage)); err != nil {
+		return result, paging.Calculate(page, 0, PhotosPerPage), fmt.Errorf("error querying for photos taken with %s '%s': %w", kind, id, err)
+	}
+
+	return result, calculatePhotoPaging(result, page), nil
+}
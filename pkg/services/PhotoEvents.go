@@ -0,0 +1,37 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/adampresley/adamgokit/paging"
+	"github.com/adampresley/ownmyphotos/pkg/models"
+)
+
+/*
+Returns a page of the photos in an event, in the order they were
+taken.
+*/
+func (s PhotoService) GetPhotosInEvent(eventID int64, page int) ([]*models.Photo, paging.Paging, error) {
+	var (
+		err    error
+		result = []*models.Photo{}
+	)
+
+	statement := `
+SELECT ` + photoColumns + totalCountColumn + `
+FROM photos p
+WHERE p.event_id = ?
+	AND p.deleted_at IS NULL
+ORDER BY p.creation_date_time ASC, p.id ASC
+LIMIT ? OFFSET ?
+`
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	if err = s.db.Query(ctx, &result, statement, eventID, PhotosPerPage, paging.Offset(page, PhotosPerPage)); err != nil {
+		return result, paging.Calculate(page, 0, PhotosPerPage), fmt.Errorf("error querying for photos in event %d: %w", eventID, err)
+	}
+
+	return result, calculatePhotoPaging(result, page), nil
+}
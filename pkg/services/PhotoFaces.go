@@ -0,0 +1,213 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/adampresley/ownmyphotos/pkg/models"
+	"github.com/rfberaldo/sqlz"
+)
+
+/*
+findPersonStatement finds the person with a name, taking the name and
+then the same name again. A name that isn't a person's may be another
+name for one, such as the name they had before being renamed or
+merged.
+*/
+const findPersonStatement = `
+SELECT id FROM (
+	SELECT id, 0 AS priority FROM people WHERE name=?
+	UNION ALL
+	SELECT person_id, 1 AS priority FROM person_aliases WHERE alias=?
+)
+ORDER BY priority
+LIMIT 1
+`
+
+/*
+faceColumns is the column list used by queries that return faces.
+Faces linked to a person are named after them.
+*/
+const faceColumns = `
+	f.id
+	, f.photo_id
+	, COALESCE(f.person_id, 0) AS person_id
+	, COALESCE(pe.name, f.name) AS name
+	, f.x
+	, f.y
+	, f.width
+	, f.height
+	, f.source`
+
+/*
+Retrieves the faces in a photo, from left to right. Faces ignored in
+the review queue are left out.
+*/
+func (s PhotoService) GetFaces(photoID string) ([]*models.Face, error) {
+	var (
+		err    error
+		result = []*models.Face{}
+	)
+
+	statement := `
+SELECT ` + faceColumns + `
+FROM photo_faces f
+LEFT JOIN people pe ON pe.id = f.person_id
+WHERE f.photo_id = ?
+	AND f.ignored = 0
+ORDER BY f.x ASC, f.y ASC
+`
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	if err = s.db.Query(ctx, &result, statement, photoID); err != nil {
+		return result, fmt.Errorf("error querying for faces in photo %s: %w", photoID, err)
+	}
+
+	return result, nil
+}
+
+/*
+Retrieves a single face by ID. The face's ID is 0 if it wasn't found.
+*/
+func (s PhotoService) GetFace(id int64) (*models.Face, error) {
+	var (
+		err    error
+		result = &models.Face{}
+	)
+
+	statement := `
+SELECT ` + faceColumns + `
+FROM photo_faces f
+LEFT JOIN people pe ON pe.id = f.person_id
+WHERE f.id = ?
+`
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	if err = s.db.QueryRow(ctx, result, statement, id); err != nil {
+		if sqlz.IsNotFound(err) {
+			return result, nil
+		}
+
+		return result, fmt.Errorf("error querying for face %d: %w", id, err)
+	}
+
+	return result, nil
+}
+
+/*
+saveFaces replaces the faces read from a photo's metadata with the
+photo's own. Faces that are still there, with the same name and
+region, are updated in place, so they keep their IDs and descriptors.
+Faces are linked to the person with their name, or an alias of it.
+Names that don't match anyone are kept on the face, and are linked if
+the name is later added as an alias. Detected faces are kept, unless
+one of the photo's faces now covers them. New faces have no
+descriptor, so the photo is marked for face detection again.
+*/
+func saveFaces(ctx context.Context, tx *sqlz.Tx, photo *models.Photo) error {
+	var (
+		err      error
+		existing []*models.Face
+		detected []*models.Face
+		inserted bool
+	)
+
+	statement := `SELECT id, name, x, y, width, height FROM photo_faces WHERE photo_id=? AND source=?`
+
+	if err = tx.Query(ctx, &existing, statement, photo.ID, models.FaceSourceMetadata); err != nil && !sqlz.IsNotFound(err) {
+		return fmt.Errorf("error querying for faces: %w", err)
+	}
+
+	if err = tx.Query(ctx, &detected, statement, photo.ID, models.FaceSourceDetected); err != nil && !sqlz.IsNotFound(err) {
+		return fmt.Errorf("error querying for detected faces: %w", err)
+	}
+
+	for _, face := range detected {
+		if !slices.ContainsFunc(photo.Faces, face.Overlaps) {
+			continue
+		}
+
+		if _, err = tx.Exec(ctx, `DELETE FROM photo_faces WHERE id=?`, face.ID); err != nil {
+			return fmt.Errorf("error deleting detected face %d: %w", face.ID, err)
+		}
+	}
+
+	for _, face := range photo.Faces {
+		var personID int64
+
+		if face.Name != "" {
+			args := []any{face.Name, face.Name}
+
+			if err = tx.QueryRow(ctx, &personID, findPersonStatement, args...); err != nil && !sqlz.IsNotFound(err) {
+				return fmt.Errorf("error querying for person %s: %w", face.Name, err)
+			}
+		}
+
+		face.PhotoID, face.PersonID, face.Source = photo.ID, uint(personID), models.FaceSourceMetadata
+
+		/*
+		 * The person is linked again, as the name may have become an
+		 * alias since the face was saved.
+		 */
+		if index := slices.IndexFunc(existing, face.SameRegion); index >= 0 {
+			face.ID = existing[index].ID
+			existing = slices.Delete(existing, index, index+1)
+
+			if _, err = tx.Exec(ctx, `UPDATE photo_faces SET person_id=NULLIF(?, 0) WHERE id=?`, personID, face.ID); err != nil {
+				return fmt.Errorf("error updating face %d: %w", face.ID, err)
+			}
+
+			continue
+		}
+
+		statement = `
+INSERT INTO photo_faces (
+	photo_id
+	, person_id
+	, name
+	, x
+	, y
+	, width
+	, height
+) VALUES (
+	?
+	, NULLIF(?, 0)
+	, ?
+	, ?
+	, ?
+	, ?
+	, ?
+)
+`
+
+		args := []any{photo.ID, personID, face.Name, face.X, face.Y, face.Width, face.Height}
+
+		if _, err = tx.Exec(ctx, statement, args...); err != nil {
+			return fmt.Errorf("error inserting face: %w", err)
+		}
+
+		inserted = true
+	}
+
+	/*
+	 * Whatever is left is no longer in the photo's metadata.
+	 */
+	for _, face := range existing {
+		if _, err = tx.Exec(ctx, `DELETE FROM photo_faces WHERE id=?`, face.ID); err != nil {
+			return fmt.Errorf("error deleting face %d: %w", face.ID, err)
+		}
+	}
+
+	if inserted {
+		if _, err = tx.Exec(ctx, `UPDATE photos SET faces_detected_at=NULL WHERE id=?`, photo.ID); err != nil {
+			return fmt.Errorf("error marking the photo for face detection: %w", err)
+		}
+	}
+
+	return nil
+}
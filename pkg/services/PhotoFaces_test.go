@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/adampresley/ownmyphotos/pkg/models"
+)
+
+func TestSaveKeepsUnchangedFaces(t *testing.T) {
+	db := newTestDB(t)
+	service := NewPhotoService(PhotoServiceConfig{DB: db})
+	ctx := context.Background()
+
+	aunt := func() *models.Face {
+		return &models.Face{Name: "Aunt Mary", X: 0.1, Y: 0.1, Width: 0.2, Height: 0.3}
+	}
+
+	photo := &models.Photo{ID: "1", FileName: "picnic", Ext: ".jpg", FullPath: "/library", BlurHash: "LEHV6n", Faces: []*models.Face{aunt()}}
+
+	if err := service.Save(photo); err != nil {
+		t.Fatal(err)
+	}
+
+	execTestSQL(t, db,
+		`UPDATE photo_faces SET descriptor = x'0102'`,
+		`UPDATE photos SET faces_detected_at = CURRENT_TIMESTAMP`,
+	)
+
+	faceID := func() (id uint, descriptor []byte) {
+		t.Helper()
+
+		face := &models.Face{}
+
+		if err := db.QueryRow(ctx, face, `SELECT id, descriptor FROM photo_faces WHERE name = 'Aunt Mary'`); err != nil {
+			t.Fatal(err)
+		}
+
+		return face.ID, face.Descriptor
+	}
+
+	detectedAt := func() sql.NullTime {
+		t.Helper()
+
+		var result sql.NullTime
+
+		if err := db.QueryRow(ctx, &result, `SELECT faces_detected_at FROM photos WHERE id = '1'`); err != nil {
+			t.Fatal(err)
+		}
+
+		return result
+	}
+
+	originalID, _ := faceID()
+
+	/*
+	 * A rescan that only changes the rating reads the same faces.
+	 */
+	photo.Rating, photo.Faces = 4, []*models.Face{aunt()}
+
+	if err := service.Save(photo); err != nil {
+		t.Fatal(err)
+	}
+
+	if id, descriptor := faceID(); id != originalID || len(descriptor) == 0 {
+		t.Errorf("face is %d with descriptor %x after a rescan, want %d with its descriptor", id, descriptor, originalID)
+	}
+
+	if !detectedAt().Valid {
+		t.Errorf("faces_detected_at was cleared, though no face changed")
+	}
+
+	/*
+	 * A new face needs describing, so face detection runs again.
+	 */
+	photo.Faces = []*models.Face{aunt(), {Name: "Uncle Bob", X: 0.6, Y: 0.1, Width: 0.2, Height: 0.3}}
+
+	if err := service.Save(photo); err != nil {
+		t.Fatal(err)
+	}
+
+	if id, _ := faceID(); id != originalID {
+		t.Errorf("face is %d after adding another, want %d", id, originalID)
+	}
+
+	if detectedAt().Valid {
+		t.Errorf("faces_detected_at is still set after a face was added")
+	}
+}
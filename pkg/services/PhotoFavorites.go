@@ -0,0 +1,172 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/adampresley/adamgokit/paging"
+	"github.com/adampresley/ownmyphotos/pkg/models"
+	"github.com/rfberaldo/sqlz"
+)
+
+/*
+Returns a page of favorite photos, most recently favorited first.
+*/
+func (s PhotoService) GetFavoritePhotos(page int) ([]*models.Photo, paging.Paging, error) {
+	var (
+		err    error
+		result = []*models.Photo{}
+	)
+
+	statement := `
+SELECT ` + photoColumns + totalCountColumn + `
+FROM favorites f
+JOIN photos p ON p.full_path = f.full_path
+	AND p.file_name = f.file_name
+	AND p.ext = f.ext
+WHERE p.deleted_at IS NULL
+ORDER BY f.created_at DESC, p.id ASC
+LIMIT ? OFFSET ?
+`
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	if err = s.db.Query(ctx, &result, statement, PhotosPerPage, paging.Offset(page, PhotosPerPage)); err != nil {
+		return result, paging.Calculate(page, 0, PhotosPerPage), fmt.Errorf("error querying for favorite photos: %w", err)
+	}
+
+	return result, calculatePhotoPaging(result, page), nil
+}
+
+/*
+Favorites a photo, or un-favorites it if it already is one. Returns
+true if the photo is now a favorite.
+*/
+func (s PhotoService) ToggleFavorite(id string) (bool, error) {
+	var (
+		err   error
+		photo *models.Photo
+	)
+
+	if photo, err = s.GetPhotoByID(id); err != nil {
+		return false, err
+	}
+
+	if photo.ID == "" {
+		return false, fmt.Errorf("photo %s not found", id)
+	}
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	if photo.IsFavorite {
+		statement := `DELETE FROM favorites WHERE full_path = ? AND file_name = ? AND ext = ?`
+
+		if _, err = s.db.Exec(ctx, statement, photo.FullPath, photo.FileName, photo.Ext); err != nil {
+			return true, fmt.Errorf("error removing photo %s from favorites: %w", id, err)
+		}
+
+		return false, nil
+	}
+
+	statement := `
+INSERT INTO favorites (
+	full_path
+	, file_name
+	, ext
+	, created_at
+) VALUES (
+	?
+	, ?
+	, ?
+	, ?
+) ON CONFLICT (full_path, file_name, ext) DO NOTHING
+`
+
+	if _, err = s.db.Exec(ctx, statement, photo.FullPath, photo.FileName, photo.Ext, time.Now().UTC()); err != nil {
+		return false, fmt.Errorf("error adding photo %s to favorites: %w", id, err)
+	}
+
+	return true, nil
+}
+
+/*
+Favorites or un-favorites several photos at once. Either all of them
+change or none do. Returns how many photos changed, which leaves out
+those that already were, or weren't, favorites.
+*/
+func (s PhotoService) SetFavorites(ids []string, favorite bool) (int, error) {
+	var (
+		err     error
+		r       sql.Result
+		changed int
+		tx      *sqlz.Tx
+		success = false
+	)
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	if tx, err = s.db.Begin(ctx); err != nil {
+		return 0, fmt.Errorf("error starting transaction when changing favorites: %w", err)
+	}
+
+	defer func() {
+		if success {
+			_ = tx.Commit()
+		} else {
+			_ = tx.Rollback()
+		}
+	}()
+
+	statement := `
+DELETE FROM favorites
+WHERE EXISTS (
+	SELECT 1
+	FROM photos p
+	WHERE p.id = ?
+		AND p.full_path = favorites.full_path
+		AND p.file_name = favorites.file_name
+		AND p.ext = favorites.ext
+)
+`
+
+	if favorite {
+		statement = `
+INSERT INTO favorites (
+	full_path
+	, file_name
+	, ext
+	, created_at
+)
+SELECT p.full_path, p.file_name, p.ext, ?
+FROM photos p
+WHERE p.id = ?
+	AND p.deleted_at IS NULL
+ON CONFLICT (full_path, file_name, ext) DO NOTHING
+`
+	}
+
+	now := time.Now().UTC()
+
+	for _, id := range ids {
+		args := []any{id}
+
+		if favorite {
+			args = []any{now, id}
+		}
+
+		if r, err = tx.Exec(ctx, statement, args...); err != nil {
+			return 0, fmt.Errorf("error changing favorite on photo %s: %w", id, err)
+		}
+
+		if rows, _ := r.RowsAffected(); rows > 0 {
+			changed++
+		}
+	}
+
+	success = true
+	return changed, nil
+}
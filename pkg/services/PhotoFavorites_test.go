@@ -0,0 +1,54 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/adampresley/ownmyphotos/pkg/models"
+)
+
+func TestFavorites(t *testing.T) {
+	service := newSearchTest(t, map[string]func(photo *models.Photo){
+		"1": func(photo *models.Photo) { photo.FileName = "lake" },
+		"2": func(photo *models.Photo) { photo.FileName = "beach" },
+	})
+
+	toggle := func(id string, want bool) {
+		t.Helper()
+
+		if favorite, err := service.ToggleFavorite(id); err != nil || favorite != want {
+			t.Fatalf("ToggleFavorite(%s) = %v, %v, want %v", id, favorite, err, want)
+		}
+	}
+
+	toggle("1", true)
+	toggle("2", true)
+	toggle("2", false)
+
+	expectSearch(t, service, models.PhotoSearch{SearchTerm: "favorite:yes"}, "1")
+	expectSearch(t, service, models.PhotoSearch{SearchTerm: "favorite:FALSE"}, "2")
+	expectSearch(t, service, models.PhotoSearch{SearchTerm: "-favorite:true"}, "2")
+
+	if _, err := service.Search(models.PhotoSearch{SearchTerm: "favorite:sometimes"}); err == nil {
+		t.Errorf("favorite:sometimes didn't return an error")
+	}
+
+	/*
+	 * Replacing the file gives the photo a new ID, but it's still in
+	 * the same place, so it's still a favorite.
+	 */
+	execTestSQL(t, service.db, `UPDATE photos SET id = '3' WHERE id = '1'`)
+
+	favorites, _, err := service.GetFavoritePhotos(1)
+
+	if err != nil {
+		t.Fatalf("GetFavoritePhotos returned error %v", err)
+	}
+
+	if len(favorites) != 1 || favorites[0].ID != "3" || !favorites[0].IsFavorite {
+		t.Errorf("favorites after the file was replaced = %+v, want photo 3", favorites)
+	}
+
+	if _, err = service.ToggleFavorite("1"); err == nil {
+		t.Errorf("ToggleFavorite on a photo that's gone didn't return an error")
+	}
+}
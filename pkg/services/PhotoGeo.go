@@ -0,0 +1,234 @@
+package services
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/adampresley/adamgokit/paging"
+	"github.com/adampresley/ownmyphotos/pkg/models"
+)
+
+/*
+Photos without GPS data are stored with a latitude and longitude of
+zero, so those are left out of all geographic queries.
+*/
+const geotaggedPhotoCondition = `p.latitude IS NOT NULL
+	AND p.longitude IS NOT NULL
+	AND NOT (p.latitude = 0 AND p.longitude = 0)`
+
+const (
+	/*
+	 * Clusters are made by dividing the world into a grid. At each zoom
+	 * level a 256 pixel map tile is split into this many cells across.
+	 */
+	clusterCellsPerTile = 4
+
+	maxClusterZoom = 20
+
+	kilometersPerDegree = 111.32
+)
+
+type geoClusterRow struct {
+	CellX      int
+	CellY      int
+	Latitude   float64
+	Longitude  float64
+	NumPhotos  int
+	KeyPhotoID string
+	West       float64
+	South      float64
+	East       float64
+	North      float64
+}
+
+/*
+Returns a page of geotagged photos inside a bounding box, newest first.
+*/
+func (s PhotoService) GetPhotosInBounds(bounds models.GeoBounds, page int) ([]*models.Photo, paging.Paging, error) {
+	var (
+		err    error
+		result = []*models.Photo{}
+	)
+
+	where, args := geoBoundsConditions(bounds)
+
+	statement := `
+SELECT ` + photoColumns + totalCountColumn + `
+FROM photos p
+WHERE p.deleted_at IS NULL
+	AND ` + geotaggedPhotoCondition + where + `
+ORDER BY p.creation_date_time DESC, p.id ASC
+LIMIT ? OFFSET ?
+`
+
+	args = append(args, PhotosPerPage, paging.Offset(page, PhotosPerPage))
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	if err = s.db.Query(ctx, &result, statement, args...); err != nil {
+		return result, paging.Calculate(page, 0, PhotosPerPage), fmt.Errorf("error querying for photos in bounds %s: %w", bounds, err)
+	}
+
+	return result, calculatePhotoPaging(result, page), nil
+}
+
+/*
+Returns a page of geotagged photos within radiusKm kilometers of a
+point, closest first. Distances use an equirectangular approximation,
+which is accurate enough for the distances people search within.
+*/
+func (s PhotoService) GetPhotosNear(latitude, longitude, radiusKm float64, page int) ([]*models.Photo, paging.Paging, error) {
+	var (
+		err    error
+		result = []*models.Photo{}
+	)
+
+	latitudeDelta := radiusKm / kilometersPerDegree
+	longitudeScale := math.Max(math.Cos(latitude*math.Pi/180), 0.01)
+
+	/*
+	 * A bounding box around the circle narrows the rows before the
+	 * distance is calculated.
+	 */
+	bounds := models.GeoBounds{
+		West:  longitude - latitudeDelta/longitudeScale,
+		South: latitude - latitudeDelta,
+		East:  longitude + latitudeDelta/longitudeScale,
+		North: latitude + latitudeDelta,
+	}
+
+	where, args := geoBoundsConditions(bounds)
+
+	distance := `((p.latitude - ?) * (p.latitude - ?) + (p.longitude - ?) * (p.longitude - ?) * ?)`
+	distanceArgs := []any{latitude, latitude, longitude, longitude, longitudeScale * longitudeScale}
+
+	statement := `
+SELECT ` + photoColumns + totalCountColumn + `
+FROM photos p
+WHERE p.deleted_at IS NULL
+	AND ` + geotaggedPhotoCondition + where + `
+	AND ` + distance + ` <= ?
+ORDER BY ` + distance + ` ASC, p.id ASC
+LIMIT ? OFFSET ?
+`
+
+	args = append(args, distanceArgs...)
+	args = append(args, latitudeDelta*latitudeDelta)
+	args = append(args, distanceArgs...)
+	args = append(args, PhotosPerPage, paging.Offset(page, PhotosPerPage))
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	if err = s.db.Query(ctx, &result, statement, args...); err != nil {
+		return result, paging.Calculate(page, 0, PhotosPerPage), fmt.Errorf("error querying for photos within %fkm of %f,%f: %w", radiusKm, latitude, longitude, err)
+	}
+
+	return result, calculatePhotoPaging(result, page), nil
+}
+
+/*
+Returns clusters of geotagged photos inside a bounding box for a map
+zoom level. Photos are grouped by the grid cell they fall in, so the
+clusters get smaller as the map zooms in.
+*/
+func (s PhotoService) GetGeoClusters(bounds models.GeoBounds, zoom int) ([]*models.GeoCluster, error) {
+	var (
+		err    error
+		rows   = []*geoClusterRow{}
+		result = []*models.GeoCluster{}
+	)
+
+	zoom = max(0, min(zoom, maxClusterZoom))
+	cellSize := 360.0 / (math.Pow(2, float64(zoom)) * clusterCellsPerTile)
+
+	where, args := geoBoundsConditions(bounds)
+
+	statement := `
+SELECT
+	CAST((p.longitude + 180) / ? AS INTEGER) AS cell_x
+	, CAST((p.latitude + 90) / ? AS INTEGER) AS cell_y
+	, AVG(p.latitude) AS latitude
+	, AVG(p.longitude) AS longitude
+	, COUNT(*) AS num_photos
+	, MIN(p.id) AS key_photo_id
+	, MIN(p.longitude) AS west
+	, MIN(p.latitude) AS south
+	, MAX(p.longitude) AS east
+	, MAX(p.latitude) AS north
+FROM photos p
+WHERE p.deleted_at IS NULL
+	AND ` + geotaggedPhotoCondition + where + `
+GROUP BY cell_x, cell_y
+`
+
+	args = append([]any{cellSize, cellSize}, args...)
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	if err = s.db.Query(ctx, &rows, statement, args...); err != nil {
+		return result, fmt.Errorf("error querying for photo clusters in bounds %s: %w", bounds, err)
+	}
+
+	for _, row := range rows {
+		result = append(result, &models.GeoCluster{
+			Latitude:   row.Latitude,
+			Longitude:  row.Longitude,
+			NumPhotos:  row.NumPhotos,
+			KeyPhotoID: row.KeyPhotoID,
+			Bounds: models.GeoBounds{
+				West:  row.West,
+				South: row.South,
+				East:  row.East,
+				North: row.North,
+			},
+		})
+	}
+
+	return result, nil
+}
+
+/*
+geoBoundsConditions returns conditions limiting photos to a bounding
+box. Boxes that cross the antimeridian are split in two, and boxes
+wider than the world don't limit longitude at all.
+*/
+func geoBoundsConditions(bounds models.GeoBounds) (string, []any) {
+	where := "\n\tAND p.latitude BETWEEN ? AND ?"
+	args := []any{bounds.South, bounds.North}
+
+	switch {
+	case bounds.East-bounds.West >= 360:
+		return where, args
+
+	case bounds.West <= bounds.East && bounds.West >= -180 && bounds.East <= 180:
+		where += "\n\tAND p.longitude BETWEEN ? AND ?"
+		args = append(args, bounds.West, bounds.East)
+
+	default:
+		west := normalizeLongitude(bounds.West)
+		east := normalizeLongitude(bounds.East)
+
+		if west <= east {
+			where += "\n\tAND p.longitude BETWEEN ? AND ?"
+		} else {
+			where += "\n\tAND (p.longitude >= ? OR p.longitude <= ?)"
+		}
+
+		args = append(args, west, east)
+	}
+
+	return where, args
+}
+
+func normalizeLongitude(longitude float64) float64 {
+	result := math.Mod(longitude+180, 360)
+
+	if result < 0 {
+		result += 360
+	}
+
+	return result - 180
+}
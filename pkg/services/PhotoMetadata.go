@@ -0,0 +1,273 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/adampresley/adamgokit/slices"
+	"github.com/adampresley/ownmyphotos/pkg/metadata"
+	"github.com/adampresley/ownmyphotos/pkg/models"
+	"github.com/rfberaldo/sqlz"
+)
+
+/*
+Updates a photo's title, caption, keywords, and people. The change is
+written to the photo's file or its XMP sidecar first, depending on
+target, then saved to the database with a new metadata hash, which
+matches what the collector reads back from the file. Returns the
+updated photo, whose ID changes when the file is rewritten.
+*/
+func (s PhotoService) UpdateMetadata(id string, edit models.PhotoEdit, target models.MetadataWriteTarget) (*models.Photo, error) {
+	var (
+		err   error
+		photo *models.Photo
+	)
+
+	if photo, err = s.getExistingPhoto(id); err != nil {
+		return nil, err
+	}
+
+	if err = s.updateMetadata(photo, edit, target); err != nil {
+		return nil, err
+	}
+
+	return photo, nil
+}
+
+/*
+Applies the same change to several photos. Photos are updated one at
+a time, as each is its own file, so a failure doesn't stop the rest.
+Returns how many photos were updated, and the errors for those that
+weren't.
+*/
+func (s PhotoService) BatchUpdateMetadata(ids []string, edit models.BatchPhotoEdit, target models.MetadataWriteTarget) (int, error) {
+	var (
+		err     error
+		errs    []error
+		photo   *models.Photo
+		updated int
+	)
+
+	for _, id := range ids {
+		if photo, err = s.getExistingPhoto(id); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		if err = s.updateMetadata(photo, edit.Apply(models.NewPhotoEdit(photo)), target); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		updated++
+	}
+
+	return updated, errors.Join(errs...)
+}
+
+/*
+Writes the metadata in the database back to several photos' files,
+without changing it. This is used after keywords are renamed, merged,
+or deleted, which changes photos in the database only. Returns how
+many photos were written, and the errors for those that weren't.
+*/
+func (s PhotoService) RewriteMetadata(ids []string, target models.MetadataWriteTarget) (int, error) {
+	return s.BatchUpdateMetadata(ids, models.BatchPhotoEdit{}, target)
+}
+
+/*
+updateMetadata writes an edit to a photo's file, then saves it. The
+edit is made to a copy of the photo, which only replaces it once both
+have worked, so a photo that fails keeps its old values and metadata
+hash. The collector then sees any file that was written as changed.
+*/
+func (s PhotoService) updateMetadata(photo *models.Photo, edit models.PhotoEdit, target models.MetadataWriteTarget) error {
+	var (
+		err    error
+		fileID string
+	)
+
+	edited := *photo
+	edited.ApplyEdit(edit)
+	fullPath := edited.GetFullPath()
+
+	hierarchy, err := s.getKeywordHierarchy(edited.Keywords)
+
+	if err != nil {
+		return fmt.Errorf("error getting the keyword hierarchy for photo %s: %w", photo.ID, err)
+	}
+
+	description := metadata.Description{
+		Title:   edited.Title,
+		Caption: edited.Caption,
+		Keywords: slices.Map(edited.Keywords, func(k *models.Keyword, index int) string {
+			return k.Keyword
+		}),
+		People: slices.Map(edited.People, func(p *models.Person, index int) string {
+			return p.Name
+		}),
+		Hierarchy: hierarchy,
+	}
+
+	if target == models.WriteToFile {
+		err = metadata.WriteDescriptionToJPEG(fullPath, description)
+	} else {
+		err = metadata.WriteDescriptionToSidecar(fullPath, description)
+	}
+
+	if err != nil {
+		return fmt.Errorf("error writing metadata for photo %s: %w", photo.ID, err)
+	}
+
+	/*
+	 * Photo IDs are file IDs, and rewriting the file gives it a new
+	 * one. Everything pointing at the photo moves to the new ID, the
+	 * same one the collector will find.
+	 */
+	if fileID, err = s.GetFileID(fullPath); err != nil {
+		return fmt.Errorf("error getting the file ID for photo %s: %w", photo.ID, err)
+	}
+
+	if fileID != photo.ID {
+		if err = s.changePhotoID(photo.ID, fileID); err != nil {
+			return err
+		}
+
+		edited.ID = fileID
+	}
+
+	edited.UpdatedAt = time.Now().UTC()
+
+	if err = s.Save(&edited); err != nil {
+		return fmt.Errorf("error saving metadata for photo %s: %w", edited.ID, err)
+	}
+
+	*photo = edited
+	return nil
+}
+
+/*
+changePhotoID moves a photo, and everything that refers to it, to a
+new ID. A record already using the new ID belonged to a file that no
+longer exists, as file IDs are only reused once a file is gone, so it
+is removed first. Both happen in one transaction, so a failure leaves
+the stale record and the photo as they were.
+*/
+func (s PhotoService) changePhotoID(oldID, newID string) error {
+	var (
+		err     error
+		tx      *sqlz.Tx
+		success = false
+	)
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	if tx, err = s.db.Begin(ctx); err != nil {
+		return fmt.Errorf("error starting transaction when changing the ID of photo %s: %w", oldID, err)
+	}
+
+	defer func() {
+		if !success {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if err = deletePhoto(ctx, tx, newID); err != nil {
+		return fmt.Errorf("error removing the stale photo %s: %w", newID, err)
+	}
+
+	statements := []string{
+		`UPDATE photos SET id=? WHERE id=?`,
+		`UPDATE photos_keywords SET photo_id=? WHERE photo_id=?`,
+		`UPDATE photos_people SET photo_id=? WHERE photo_id=?`,
+		`UPDATE photo_faces SET photo_id=? WHERE photo_id=?`,
+		`UPDATE albums_photos SET photo_id=? WHERE photo_id=?`,
+		`UPDATE albums SET cover_photo_id=? WHERE cover_photo_id=?`,
+		`UPDATE folders SET key_photo_id=? WHERE key_photo_id=?`,
+		`UPDATE people SET cover_photo_id=? WHERE cover_photo_id=?`,
+		`UPDATE events SET cover_photo_id=? WHERE cover_photo_id=?`,
+	}
+
+	for _, statement := range statements {
+		if _, err = tx.Exec(ctx, statement, newID, oldID); err != nil {
+			return fmt.Errorf("error changing the ID of photo %s to %s: %w", oldID, newID, err)
+		}
+	}
+
+	/*
+	 * Saving the photo indexes it again under its new ID.
+	 */
+	if _, err = tx.Exec(ctx, deleteFullTextStatement, oldID); err != nil {
+		return fmt.Errorf("error deleting full text index on photo %s: %w", oldID, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing the ID change of photo %s to %s: %w", oldID, newID, err)
+	}
+
+	success = true
+	return nil
+}
+
+/*
+getKeywordHierarchy returns the hierarchical form of a photo's
+keywords, such as "Places|Italy|Rome", as Lightroom writes them. Only
+the lowest level of each path is included, as it names the levels
+above it. Parents come from the keyword tree, unless the photo's
+keywords say otherwise.
+*/
+func (s PhotoService) getKeywordHierarchy(keywords []*models.Keyword) ([]string, error) {
+	var (
+		err    error
+		rows   = []*models.Keyword{}
+		result = []string{}
+	)
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	statement := `SELECT keyword, parent FROM keywords WHERE parent IS NOT NULL`
+
+	if err = s.db.Query(ctx, &rows, statement); err != nil && !sqlz.IsNotFound(err) {
+		return result, fmt.Errorf("error querying for the keyword tree: %w", err)
+	}
+
+	parents := map[string]string{}
+
+	for _, row := range rows {
+		parents[row.Keyword] = row.Parent
+	}
+
+	isParent := map[string]bool{}
+
+	for _, keyword := range keywords {
+		if keyword.Parent != "" {
+			parents[keyword.Keyword] = keyword.Parent
+		}
+	}
+
+	for _, keyword := range keywords {
+		isParent[parents[keyword.Keyword]] = true
+	}
+
+	for _, keyword := range keywords {
+		if isParent[keyword.Keyword] || parents[keyword.Keyword] == "" {
+			continue
+		}
+
+		path := []string{keyword.Keyword}
+		seen := map[string]bool{keyword.Keyword: true}
+
+		for parent := parents[keyword.Keyword]; parent != "" && !seen[parent]; parent = parents[parent] {
+			path = append([]string{parent}, path...)
+			seen[parent] = true
+		}
+
+		result = append(result, strings.Join(path, models.KeywordPathSeparator))
+	}
+
+	return result, nil
+}
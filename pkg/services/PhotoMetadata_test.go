@@ -0,0 +1,101 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/adampresley/ownmyphotos/pkg/models"
+)
+
+func TestBatchUpdateLeavesFailedPhotosAsTheyWere(t *testing.T) {
+	db := newTestDB(t)
+	service := NewPhotoService(PhotoServiceConfig{DB: db})
+	library := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(library, "lake.jpg"), []byte{0xFF, 0xD8, 0xFF, 0xD9}, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	lakeID, err := service.GetFileID(filepath.Join(library, "lake.jpg"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	/*
+	 * The second photo's folder is gone, so its sidecar can't be
+	 * written.
+	 */
+	photos := []*models.Photo{
+		{ID: lakeID, FileName: "lake", Ext: ".jpg", FullPath: library, Title: "Lake"},
+		{ID: "missing", FileName: "beach", Ext: ".jpg", FullPath: filepath.Join(library, "gone"), Title: "Beach"},
+	}
+
+	for _, photo := range photos {
+		photo.MetadataHash = photo.GenerateMetadataHash()
+
+		if err = service.Save(photo); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	updated, err := service.BatchUpdateMetadata([]string{lakeID, "missing"}, models.BatchPhotoEdit{AddKeywords: []string{"Summer"}}, models.WriteToSidecar)
+
+	if updated != 1 {
+		t.Errorf("updated %d photos, want 1", updated)
+	}
+
+	if err == nil || !strings.Contains(err.Error(), "photo missing") {
+		t.Errorf("error %v doesn't name the photo that failed", err)
+	}
+
+	lake, _ := service.GetPhotoByID(lakeID)
+	beach, _ := service.GetPhotoByID("missing")
+
+	if lake.MetadataHash == photos[0].MetadataHash {
+		t.Errorf("the updated photo kept its old metadata hash")
+	}
+
+	if beach.MetadataHash != photos[1].MetadataHash || len(beach.Keywords) != 0 {
+		t.Errorf("the photo that failed was saved with hash %s and keywords %v, want %s and none", beach.MetadataHash, beach.KeywordNames(), photos[1].MetadataHash)
+	}
+}
+
+func TestGetKeywordHierarchy(t *testing.T) {
+	db := newTestDB(t)
+	service := NewPhotoService(PhotoServiceConfig{DB: db})
+
+	execTestSQL(t, db,
+		`INSERT INTO keywords (keyword, parent) VALUES
+			('Places', NULL), ('Italy', 'Places'), ('Rome', 'Italy'),
+			('Beach', NULL), ('Cat', 'Animals'),
+			('Chicken', 'Egg'), ('Egg', 'Chicken')`,
+	)
+
+	keywords := []*models.Keyword{
+		{Keyword: "Italy"},
+		{Keyword: "Rome"},
+		{Keyword: "Beach"},
+		{Keyword: "Cat", Parent: "Pets"},
+		{Keyword: "Chicken"},
+	}
+
+	got, err := service.getKeywordHierarchy(keywords)
+
+	if err != nil {
+		t.Fatalf("getKeywordHierarchy returned error %v", err)
+	}
+
+	/*
+	 * Italy is left out, as Rome's path names it. Beach has no parent,
+	 * so it has no path. The photo puts Cat under Pets, which wins over
+	 * the keyword tree, and a loop in the tree stops where it repeats.
+	 */
+	want := []string{"Places|Italy|Rome", "Pets|Cat", "Egg|Chicken"}
+
+	if strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Errorf("getKeywordHierarchy() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,45 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/adampresley/adamgokit/paging"
+	"github.com/adampresley/ownmyphotos/pkg/models"
+)
+
+/*
+Returns a page of photos of a person, newest first. Only photos taken
+on or after start, and before end, are returned. A zero start or end
+leaves that side of the range open.
+*/
+func (s PhotoService) GetPhotosOfPerson(personID int64, start, end time.Time, page int) ([]*models.Photo, paging.Paging, error) {
+	var (
+		err    error
+		result = []*models.Photo{}
+	)
+
+	where, args := dateRangeConditions(start, end)
+
+	statement := `
+SELECT ` + photoColumns + totalCountColumn + `
+FROM photos_people pp
+JOIN photos p ON p.id = pp.photo_id
+WHERE pp.person_id = ?
+	AND p.deleted_at IS NULL` + where + `
+ORDER BY p.creation_date_time DESC, p.id ASC
+LIMIT ? OFFSET ?
+`
+
+	args = append([]any{personID}, args...)
+	args = append(args, PhotosPerPage, paging.Offset(page, PhotosPerPage))
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	if err = s.db.Query(ctx, &result, statement, args...); err != nil {
+		return result, paging.Calculate(page, 0, PhotosPerPage), fmt.Errorf("error querying for photos of person %d: %w", personID, err)
+	}
+
+	return result, calculatePhotoPaging(result, page), nil
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/adampresley/adamgokit/paging"
+	"github.com/adampresley/ownmyphotos/pkg/models"
+)
+
+/*
+placeRegionCode is a city's region code, with cities outside any
+first level division grouped together.
+*/
+const placeRegionCode = `COALESCE(NULLIF(c.admin1_code, ''), '` + unknownRegionCode + `')`
+
+/*
+Returns the places one level below parent with the number of photos
+taken in each, in name order. Below the whole world are countries,
+then regions, then cities.
+*/
+func (s PhotoService) GetPlaces(parent models.PlacePath) ([]*models.Place, error) {
+	var (
+		err     error
+		columns string
+		joins   string
+		groupBy string
+		rows    = []*placeRow{}
+		result  = []*models.Place{}
+	)
+
+	switch parent.Kind() {
+	case "":
+		columns = `c.country_code, '' AS region_code, '' AS city_id, COALESCE(co.name, c.country_code) AS name`
+		joins = `LEFT JOIN geonames_countries co ON co.country_code = c.country_code`
+		groupBy = `c.country_code`
+
+	case models.PlaceCountry:
+		columns = `c.country_code, ` + placeRegionCode + ` AS region_code, '' AS city_id, COALESCE(a.name, 'Other') AS name`
+		joins = `LEFT JOIN geonames_admin1 a ON a.country_code = c.country_code AND a.admin1_code = c.admin1_code`
+		groupBy = `c.country_code, region_code`
+
+	case models.PlaceRegion:
+		columns = `c.country_code, ` + placeRegionCode + ` AS region_code, CAST(c.geoname_id AS TEXT) AS city_id, c.name`
+		groupBy = `c.geoname_id`
+
+	default:
+		return result, nil
+	}
+
+	where, args := placeConditions(parent)
+
+	statement := `
+SELECT
+	` + columns + `
+	, COUNT(*) AS num_photos
+	, MIN(p.id) AS key_photo_id
+FROM photos p
+JOIN geonames_cities c ON c.geoname_id = p.place_id
+` + joins + `
+WHERE p.deleted_at IS NULL` + where + `
+GROUP BY ` + groupBy + `
+ORDER BY name
+`
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	if err = s.db.Query(ctx, &rows, statement, args...); err != nil {
+		return result, fmt.Errorf("error querying for places in '%s': %w", parent, err)
+	}
+
+	for _, row := range rows {
+		result = append(result, row.toPlace())
+	}
+
+	return result, nil
+}
+
+/*
+Returns a page of photos taken in a country, region, or city, newest
+first.
+*/
+func (s PhotoService) GetPhotosInPlace(path models.PlacePath, page int) ([]*models.Photo, paging.Paging, error) {
+	var (
+		err    error
+		result = []*models.Photo{}
+	)
+
+	where, args := placeConditions(path)
+
+	statement := `
+SELECT ` + photoColumns + totalCountColumn + `
+FROM photos p
+JOIN geonames_cities c ON c.geoname_id = p.place_id
+WHERE p.deleted_at IS NULL` + where + `
+ORDER BY p.creation_date_time DESC, p.id ASC
+LIMIT ? OFFSET ?
+`
+
+	args = append(args, PhotosPerPage, paging.Offset(page, PhotosPerPage))
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	if err = s.db.Query(ctx, &result, statement, args...); err != nil {
+		return result, paging.Calculate(page, 0, PhotosPerPage), fmt.Errorf("error querying for photos in '%s': %w", path, err)
+	}
+
+	return result, calculatePhotoPaging(result, page), nil
+}
+
+/*
+placeConditions returns conditions limiting photos, joined to their
+gazetteer city as "c", to a place.
+*/
+func placeConditions(path models.PlacePath) (string, []any) {
+	var (
+		where string
+		args  = []any{}
+	)
+
+	if path.CountryCode != "" {
+		where += "\n\tAND c.country_code = ?"
+		args = append(args, path.CountryCode)
+	}
+
+	if path.RegionCode != "" {
+		where += "\n\tAND " + placeRegionCode + " = ?"
+		args = append(args, path.RegionCode)
+	}
+
+	if path.CityID != "" {
+		where += "\n\tAND c.geoname_id = ?"
+		args = append(args, path.CityID)
+	}
+
+	return where, args
+}
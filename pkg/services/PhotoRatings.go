@@ -0,0 +1,104 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/adampresley/ownmyphotos/pkg/metadata"
+	"github.com/adampresley/ownmyphotos/pkg/models"
+)
+
+/*
+Sets a photo's star rating, from 0 to 5. The rating is written to the
+photo's XMP sidecar first, so other tools see it, then saved to the
+database. Returns the updated photo.
+*/
+func (s PhotoService) SetRating(id string, rating int) (*models.Photo, error) {
+	var (
+		err   error
+		photo *models.Photo
+	)
+
+	if rating < 0 || rating > models.MaxRating {
+		return nil, fmt.Errorf("rating must be between 0 and %d", models.MaxRating)
+	}
+
+	if photo, err = s.getExistingPhoto(id); err != nil {
+		return nil, err
+	}
+
+	photo.Rating = rating
+
+	if err = s.saveRating(photo); err != nil {
+		return nil, err
+	}
+
+	return photo, nil
+}
+
+/*
+Sets a photo's color label. Setting the label a photo already has
+clears it, so the same shortcut turns a label on and off. The label
+is written to the photo's XMP sidecar first, then saved to the
+database. Returns the updated photo.
+*/
+func (s PhotoService) SetLabel(id string, label models.ColorLabel) (*models.Photo, error) {
+	var (
+		err   error
+		photo *models.Photo
+	)
+
+	if photo, err = s.getExistingPhoto(id); err != nil {
+		return nil, err
+	}
+
+	if photo.Label == label {
+		label = models.LabelNone
+	}
+
+	photo.Label = label
+
+	if err = s.saveRating(photo); err != nil {
+		return nil, err
+	}
+
+	return photo, nil
+}
+
+func (s PhotoService) getExistingPhoto(id string) (*models.Photo, error) {
+	var (
+		err   error
+		photo *models.Photo
+	)
+
+	if photo, err = s.GetPhotoByID(id); err != nil {
+		return nil, err
+	}
+
+	if photo.ID == "" {
+		return nil, fmt.Errorf("photo %s not found", id)
+	}
+
+	return photo, nil
+}
+
+func (s PhotoService) saveRating(photo *models.Photo) error {
+	var (
+		err error
+	)
+
+	if err = metadata.WriteRating(photo.GetFullPath(), photo.Rating, string(photo.Label)); err != nil {
+		return fmt.Errorf("error writing rating for photo %s: %w", photo.ID, err)
+	}
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	statement := `UPDATE photos SET rating = ?, label = ?, updated_at = ? WHERE id = ?`
+
+	if _, err = s.db.Exec(ctx, statement, photo.Rating, string(photo.Label), time.Now().UTC(), photo.ID); err != nil {
+		return fmt.Errorf("error saving rating for photo %s: %w", photo.ID, err)
+	}
+
+	return nil
+}
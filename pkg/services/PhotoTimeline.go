@@ -0,0 +1,151 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/adampresley/adamgokit/paging"
+	"github.com/adampresley/ownmyphotos/pkg/models"
+)
+
+/*
+Photos are bucketed by the leading characters of their stored creation
+date, which is the wall clock time the photo was taken. Photos without
+any date at all are stored in year 1 and left off the timeline.
+*/
+var dateGroupingLengths = map[models.DateGrouping]int{
+	models.GroupByYear:  4,
+	models.GroupByMonth: 7,
+	models.GroupByDay:   10,
+}
+
+const undatedPhotoCondition = `substr(p.creation_date_time, 1, 4) <> '0001'`
+
+/*
+Returns counts of photos grouped by year, month, or day. Only photos
+taken on or after start, and before end, are counted. A zero start
+or end leaves that side of the range open.
+*/
+func (s PhotoService) GetDateBuckets(grouping models.DateGrouping, start, end time.Time) ([]*models.DateBucket, error) {
+	var (
+		err     error
+		ok      bool
+		length  int
+		results = []*models.DateBucket{}
+	)
+
+	if length, ok = dateGroupingLengths[grouping]; !ok {
+		return results, fmt.Errorf("invalid date grouping '%s'", grouping)
+	}
+
+	where, args := dateRangeConditions(start, end)
+
+	statement := fmt.Sprintf(`
+SELECT
+	substr(p.creation_date_time, 1, %d) AS period
+	, COUNT(*) AS num_photos
+	, SUM(CASE WHEN p.date_is_estimated THEN 1 ELSE 0 END) AS num_estimated
+FROM photos p
+WHERE p.deleted_at IS NULL
+	AND %s%s
+GROUP BY period
+ORDER BY period
+`, length, undatedPhotoCondition, where)
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	if err = s.db.Query(ctx, &results, statement, args...); err != nil {
+		return results, fmt.Errorf("error querying for %s date buckets: %w", grouping, err)
+	}
+
+	return results, nil
+}
+
+/*
+Returns a page of photos taken on or after start, and before end, in
+the order they were taken.
+*/
+func (s PhotoService) GetPhotosInDateRange(start, end time.Time, page int) ([]*models.Photo, paging.Paging, error) {
+	var (
+		err    error
+		result = []*models.Photo{}
+	)
+
+	where, args := dateRangeConditions(start, end)
+
+	statement := `
+SELECT ` + photoColumns + totalCountColumn + `
+FROM photos p
+WHERE p.deleted_at IS NULL
+	AND ` + undatedPhotoCondition + where + `
+ORDER BY p.creation_date_time ASC, p.id ASC
+LIMIT ? OFFSET ?
+`
+
+	args = append(args, PhotosPerPage, paging.Offset(page, PhotosPerPage))
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	if err = s.db.Query(ctx, &result, statement, args...); err != nil {
+		return result, paging.Calculate(page, 0, PhotosPerPage), fmt.Errorf("error querying for photos between %s and %s: %w", start, end, err)
+	}
+
+	return result, calculatePhotoPaging(result, page), nil
+}
+
+/*
+dateRangeConditions returns conditions limiting photos to a date
+range. Dates are compared as text, the same way they are stored.
+*/
+func dateRangeConditions(start, end time.Time) (string, []any) {
+	var (
+		where string
+		args  = []any{}
+	)
+
+	if !start.IsZero() {
+		where += "\n\tAND p.creation_date_time >= ?"
+		args = append(args, start.Format(time.DateOnly))
+	}
+
+	if !end.IsZero() {
+		where += "\n\tAND p.creation_date_time < ?"
+		args = append(args, end.Format(time.DateOnly))
+	}
+
+	return where, args
+}
+
+/*
+Returns photos taken on the same month and day as day, in any earlier
+year, newest first. Photos with estimated dates are left out, as a
+file's modification date rarely says when the photo was taken.
+*/
+func (s PhotoService) GetPhotosOnThisDay(day time.Time) ([]*models.Photo, error) {
+	var (
+		err    error
+		result = []*models.Photo{}
+	)
+
+	statement := `
+SELECT ` + photoColumns + `
+FROM photos p
+WHERE p.deleted_at IS NULL
+	AND ` + undatedPhotoCondition + `
+	AND NOT p.date_is_estimated
+	AND substr(p.creation_date_time, 6, 5) = ?
+	AND substr(p.creation_date_time, 1, 4) < ?
+ORDER BY p.creation_date_time DESC, p.id ASC
+`
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	if err = s.db.Query(ctx, &result, statement, day.Format("01-02"), day.Format("2006")); err != nil {
+		return result, fmt.Errorf("error querying for photos taken on %s: %w", day.Format("01-02"), err)
+	}
+
+	return result, nil
+}
@@ -1,16 +1,22 @@
 package services
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
+	"github.com/adampresley/adamgokit/paging"
 	"github.com/adampresley/ownmyphotos/pkg/models"
+	"github.com/adampresley/ownmyphotos/pkg/query"
 	"github.com/alitto/pond/v2"
 	"github.com/rfberaldo/sqlz"
 )
@@ -39,9 +45,178 @@ type PhotoServicer interface {
 	GetPhotoByID(id string) (*models.Photo, error)
 
 	/*
-	 * Retrieves all photos in a specific folder.
+	 * Retrieves the photos with the given IDs, in file name order.
 	 */
-	GetPhotosInFolder(folderPath string) ([]*models.Photo, error)
+	GetPhotosByIDs(ids []string) ([]*models.Photo, error)
+
+	/*
+	 * Retrieves a page of photos in a specific folder, in the given
+	 * order, along with paging information.
+	 */
+	GetPhotosInFolder(folderPath string, sort models.PhotoSort, page int) ([]*models.Photo, paging.Paging, error)
+
+	/*
+	 * Retrieves the IDs of the photos before and after a photo in
+	 * a folder, in file name order.
+	 */
+	GetAdjacentPhotosInFolder(id, folderPath string) (models.AdjacentPhotos, error)
+
+	/*
+	 * Retrieves the IDs of the photos before and after a photo in
+	 * the results of a search, in the order Search returns them.
+	 */
+	GetAdjacentSearchResults(id string, criteria models.PhotoSearch) (models.AdjacentPhotos, error)
+
+	/*
+	 * Retrieves counts of photos grouped by year, month, or day,
+	 * for photos taken between start (inclusive) and end (exclusive).
+	 * Zero times leave that side of the range open.
+	 */
+	GetDateBuckets(grouping models.DateGrouping, start, end time.Time) ([]*models.DateBucket, error)
+
+	/*
+	 * Retrieves a page of photos taken between start (inclusive)
+	 * and end (exclusive), in the order they were taken.
+	 */
+	GetPhotosInDateRange(start, end time.Time, page int) ([]*models.Photo, paging.Paging, error)
+
+	/*
+	 * Retrieves a page of the photos in an event, in the order they
+	 * were taken.
+	 */
+	GetPhotosInEvent(eventID int64, page int) ([]*models.Photo, paging.Paging, error)
+
+	/*
+	 * Retrieves photos taken on the same month and day as day,
+	 * in earlier years, newest first.
+	 */
+	GetPhotosOnThisDay(day time.Time) ([]*models.Photo, error)
+
+	/*
+	 * Retrieves a page of geotagged photos inside a bounding box.
+	 */
+	GetPhotosInBounds(bounds models.GeoBounds, page int) ([]*models.Photo, paging.Paging, error)
+
+	/*
+	 * Retrieves a page of geotagged photos within radiusKm
+	 * kilometers of a point, closest first.
+	 */
+	GetPhotosNear(latitude, longitude, radiusKm float64, page int) ([]*models.Photo, paging.Paging, error)
+
+	/*
+	 * Retrieves clusters of geotagged photos inside a bounding box,
+	 * sized for a map zoom level.
+	 */
+	GetGeoClusters(bounds models.GeoBounds, zoom int) ([]*models.GeoCluster, error)
+
+	/*
+	 * Retrieves every camera or lens photos were taken with, with
+	 * the number of photos and when they were taken.
+	 */
+	GetEquipment(kind models.EquipmentKind) ([]*models.Equipment, error)
+
+	/*
+	 * Retrieves a page of photos taken with a camera or lens, by the
+	 * ID returned from GetEquipment.
+	 */
+	GetPhotosWithEquipment(kind models.EquipmentKind, id string, page int) ([]*models.Photo, paging.Paging, error)
+
+	/*
+	 * Retrieves the places one level below parent, with the number
+	 * of photos taken in each. An empty parent returns countries.
+	 */
+	GetPlaces(parent models.PlacePath) ([]*models.Place, error)
+
+	/*
+	 * Retrieves a page of photos taken in a country, region, or city.
+	 */
+	GetPhotosInPlace(path models.PlacePath, page int) ([]*models.Photo, paging.Paging, error)
+
+	/*
+	 * Retrieves a page of photos in an album, in the album's order.
+	 */
+	GetPhotosInAlbum(albumID int64, page int) ([]*models.Photo, paging.Paging, error)
+
+	/*
+	 * Retrieves a page of photos of a person, newest first, taken
+	 * between start and end. Zero times leave the range open.
+	 */
+	GetPhotosOfPerson(personID int64, start, end time.Time, page int) ([]*models.Photo, paging.Paging, error)
+
+	/*
+	 * Retrieves the named faces in a photo, from left to right.
+	 */
+	GetFaces(photoID string) ([]*models.Face, error)
+
+	/*
+	 * Retrieves a single face by ID. The face's ID is 0 if it
+	 * wasn't found.
+	 */
+	GetFace(id int64) (*models.Face, error)
+
+	/*
+	 * Retrieves a page of favorite photos, most recently favorited
+	 * first.
+	 */
+	GetFavoritePhotos(page int) ([]*models.Photo, paging.Paging, error)
+
+	/*
+	 * Favorites a photo, or un-favorites it if it already is one.
+	 * Returns true if the photo is now a favorite.
+	 */
+	ToggleFavorite(id string) (bool, error)
+
+	/*
+	 * Favorites or un-favorites several photos at once, all or none
+	 * of them. Returns how many photos changed.
+	 */
+	SetFavorites(ids []string, favorite bool) (int, error)
+
+	/*
+	 * Moves photos and their sidecars to the library's trash folder,
+	 * and removes them from the database. Either all of them are
+	 * moved or none are. Returns the photos that were moved.
+	 */
+	MoveToTrash(libraryPath string, ids []string) ([]*models.Photo, error)
+
+	/*
+	 * Writes a zip archive of photos, stored under their paths in
+	 * the library.
+	 */
+	WriteArchive(w io.Writer, libraryPath string, photos []*models.Photo) error
+
+	/*
+	 * Sets a photo's star rating, writing it to the photo's XMP
+	 * sidecar as well. Returns the updated photo.
+	 */
+	SetRating(id string, rating int) (*models.Photo, error)
+
+	/*
+	 * Sets a photo's color label, or clears it if the photo already
+	 * has that label. The label is written to the photo's XMP
+	 * sidecar as well. Returns the updated photo.
+	 */
+	SetLabel(id string, label models.ColorLabel) (*models.Photo, error)
+
+	/*
+	 * Updates a photo's title, caption, keywords, and people, writing
+	 * them to the photo's file or sidecar. Returns the updated photo,
+	 * whose ID changes if the file was rewritten.
+	 */
+	UpdateMetadata(id string, edit models.PhotoEdit, target models.MetadataWriteTarget) (*models.Photo, error)
+
+	/*
+	 * Applies the same metadata change to several photos. Returns how
+	 * many were updated, and the errors for those that weren't.
+	 */
+	BatchUpdateMetadata(ids []string, edit models.BatchPhotoEdit, target models.MetadataWriteTarget) (int, error)
+
+	/*
+	 * Writes the metadata in the database back to several photos'
+	 * files. Returns how many were written, and the errors for those
+	 * that weren't.
+	 */
+	RewriteMetadata(ids []string, target models.MetadataWriteTarget) (int, error)
 
 	/*
 	 * Saves a photo to the database.
@@ -50,11 +225,98 @@ type PhotoServicer interface {
 
 	/*
 	 * Searches for photos based on various criteria. This will
-	 * return matches of photos, keywords, and people.
+	 * return matches of photos, keywords, and people. The search
+	 * term uses the syntax from the query package. If it can't
+	 * be parsed, a *query.Error is returned.
 	 */
 	Search(criteria models.PhotoSearch) (models.SearchPhotosResult, error)
 }
 
+/*
+photoColumns is the column list used by queries that return complete
+photo records. Keywords and people are aggregated as JSON arrays so
+they can be scanned in a single query.
+*/
+const photoColumns = `
+    p.id,
+    p.created_at,
+    p.updated_at,
+    p.deleted_at,
+    p.file_name,
+    p.ext,
+    p.full_path,
+    p.metadata_hash,
+    p.lens_make,
+    p.lens_model,
+    p.lens_id,
+    p.make,
+    p.model,
+    p.caption,
+    p.title,
+    p.creation_date_time,
+    p.width,
+    p.height,
+    p.latitude,
+    p.longitude,
+    p.iptc_digest,
+    p.year,
+    p.blur_hash,
+    p.colors,
+    p.thumbnail_created_at,
+    COALESCE(p.thumbnail_size, 0) AS thumbnail_size,
+    COALESCE(p.faces_hash, '') AS faces_hash,
+    p.date_is_estimated,
+    p.place_id,
+    p.fnumber,
+    p.exposure_time,
+    p.iso,
+    p.focal_length,
+    p.focal_length35mm,
+    p.flash,
+    p.exposure_program,
+    p.rating,
+    p.label,
+    EXISTS (
+        SELECT 1
+        FROM favorites f
+        WHERE f.full_path = p.full_path
+            AND f.file_name = p.file_name
+            AND f.ext = p.ext
+    ) AS is_favorite,
+    (
+        SELECT json_group_array(pk.keyword)
+        FROM photos_keywords pk
+        WHERE pk.photo_id = p.id
+    ) AS keywords,
+    (
+        SELECT json_group_array(json_object('id', pe.id, 'name', pe.name))
+        FROM photos_people pp
+        JOIN people pe ON pp.person_id = pe.id
+        WHERE pp.photo_id = p.id
+    ) AS people`
+
+/*
+totalCountColumn is added to paged queries so the total number of
+matching photos comes back with each row in Photo.TotalCount.
+*/
+const totalCountColumn = `,
+    COUNT(*) OVER() AS total_count`
+
+/*
+folderSortOrders are the ORDER BY clauses for each way photos in a
+folder can be sorted.
+*/
+var folderSortOrders = map[models.PhotoSort]string{
+	models.SortByName:   "p.file_name ASC, p.id ASC",
+	models.SortByRating: "p.rating DESC, p.file_name ASC, p.id ASC",
+}
+
+/*
+PhotosPerPage is the number of photos returned per page by paged
+queries, such as photos in a folder and search results.
+*/
+const PhotosPerPage = 100
+
 type searchResultSet struct {
 	Type    string
 	Results []*models.Photo
@@ -109,6 +371,22 @@ SELECT
 	, longitude
 	, iptc_digest
 	, year
+	, blur_hash
+	, colors
+	, thumbnail_created_at
+	, COALESCE(thumbnail_size, 0) AS thumbnail_size
+	, COALESCE(faces_hash, '') AS faces_hash
+	, date_is_estimated
+	, place_id
+	, fnumber
+	, exposure_time
+	, iso
+	, focal_length
+	, focal_length35mm
+	, flash
+	, exposure_program
+	, rating
+	, label
 FROM photos 
 WHERE 1=1 
 	AND deleted_at IS NULL
@@ -141,7 +419,7 @@ WHERE 1=1
 
 		err = func() error {
 			people := []*models.Person{}
-			sql := `SELECT p.name FROM photos_people AS pp INNER JOIN people AS p ON p.id=pp.person_id WHERE pp.photo_id=?`
+			sql := `SELECT p.id, p.name FROM photos_people AS pp INNER JOIN people AS p ON p.id=pp.person_id WHERE pp.photo_id=?`
 
 			ctx, cancel := DBContext()
 			defer cancel()
@@ -188,6 +466,23 @@ func (s PhotoService) Delete(id string) error {
 		}
 	}()
 
+	if err = deletePhoto(ctx, tx, id); err != nil {
+		return err
+	}
+
+	success = true
+	return nil
+}
+
+/*
+deletePhoto removes a photo and everything that refers to it inside
+a transaction, so several photos can be deleted together.
+*/
+func deletePhoto(ctx context.Context, tx *sqlz.Tx, id string) error {
+	var (
+		err error
+	)
+
 	// Delete keyword associations
 	sqlStatement := `DELETE FROM photos_keywords WHERE photo_id=?`
 
@@ -202,13 +497,41 @@ func (s PhotoService) Delete(id string) error {
 		return fmt.Errorf("error deleting people on photo %s: %w", id, err)
 	}
 
+	sqlStatement = `DELETE FROM photo_faces WHERE photo_id=?`
+
+	if _, err = tx.Exec(ctx, sqlStatement, id); err != nil {
+		return fmt.Errorf("error deleting faces in photo %s: %w", id, err)
+	}
+
+	// Remove from albums
+	sqlStatement = `DELETE FROM albums_photos WHERE photo_id=?`
+
+	if _, err = tx.Exec(ctx, sqlStatement, id); err != nil {
+		return fmt.Errorf("error removing photo %s from albums: %w", id, err)
+	}
+
+	sqlStatement = `UPDATE albums SET cover_photo_id=NULL WHERE cover_photo_id=?`
+
+	if _, err = tx.Exec(ctx, sqlStatement, id); err != nil {
+		return fmt.Errorf("error clearing album covers using photo %s: %w", id, err)
+	}
+
+	sqlStatement = `UPDATE people SET cover_photo_id=NULL WHERE cover_photo_id=?`
+
+	if _, err = tx.Exec(ctx, sqlStatement, id); err != nil {
+		return fmt.Errorf("error clearing people covers using photo %s: %w", id, err)
+	}
+
+	if _, err = tx.Exec(ctx, deleteFullTextStatement, id); err != nil {
+		return fmt.Errorf("error deleting full text index on photo %s: %w", id, err)
+	}
+
 	sqlStatement = `DELETE FROM photos WHERE id=?`
 
 	if _, err = tx.Exec(ctx, sqlStatement, id); err != nil {
 		return fmt.Errorf("error deleting photo %s: %w", id, err)
 	}
 
-	success = true
 	return nil
 }
 
@@ -244,40 +567,7 @@ func (s PhotoService) GetPhotoByID(id string) (*models.Photo, error) {
 	 * Using JSON aggregation to get keywords and people in a single query
 	 */
 	sqlStatement := `
-SELECT 
-    p.id,
-    p.created_at,
-    p.updated_at,
-    p.deleted_at,
-    p.file_name,
-    p.ext,
-    p.full_path,
-    p.metadata_hash,
-    p.lens_make,
-    p.lens_model,
-    p.lens_id,
-    p.make,
-    p.model,
-    p.caption,
-    p.title,
-    p.creation_date_time,
-    p.width,
-    p.height,
-    p.latitude,
-    p.longitude,
-    p.iptc_digest,
-    p.year,
-    (
-        SELECT json_group_array(pk.keyword)
-        FROM photos_keywords pk
-        WHERE pk.photo_id = p.id
-    ) AS keywords,
-    (
-        SELECT json_group_array(json_object('id', pe.id, 'name', pe.name))
-        FROM photos_people pp
-        JOIN people pe ON pp.person_id = pe.id
-        WHERE pp.photo_id = p.id
-    ) AS people
+SELECT ` + photoColumns + `
 FROM photos p
 WHERE 1=1
 	AND p.deleted_at IS NULL
@@ -306,57 +596,25 @@ WHERE 1=1
 }
 
 /*
-Retrieves all photos in a specific folder.
+Retrieves a page of photos in a specific folder. Pages start at 1.
 */
-func (s PhotoService) GetPhotosInFolder(folderPath string) ([]*models.Photo, error) {
+func (s PhotoService) GetPhotosInFolder(folderPath string, sort models.PhotoSort, page int) ([]*models.Photo, paging.Paging, error) {
 	var (
 		err    error
 		result = []*models.Photo{}
 	)
 
 	/*
-	 * Query to get all photos in a specific folder with their metadata
+	 * Query to get photos in a specific folder with their metadata
 	 * Using JSON aggregation to get keywords and people in a single query
 	 */
 	sqlStatement := `
-SELECT 
-    p.id,
-    p.created_at,
-    p.updated_at,
-    p.deleted_at,
-    p.file_name,
-    p.ext,
-    p.full_path,
-    p.metadata_hash,
-    p.lens_make,
-    p.lens_model,
-    p.lens_id,
-    p.make,
-    p.model,
-    p.caption,
-    p.title,
-    p.creation_date_time,
-    p.width,
-    p.height,
-    p.latitude,
-    p.longitude,
-    p.iptc_digest,
-    p.year,
-    (
-        SELECT json_group_array(pk.keyword)
-        FROM photos_keywords pk
-        WHERE pk.photo_id = p.id
-    ) AS keywords,
-    (
-        SELECT json_group_array(json_object('id', pe.id, 'name', pe.name))
-        FROM photos_people pp
-        JOIN people pe ON pp.person_id = pe.id
-        WHERE pp.photo_id = p.id
-    ) AS people
+SELECT ` + photoColumns + totalCountColumn + `
 FROM photos p
 WHERE p.deleted_at IS NULL
 AND p.full_path = ?
-ORDER BY p.file_name ASC
+ORDER BY ` + folderSortOrders[models.NewPhotoSort(string(sort))] + `
+LIMIT ? OFFSET ?
 `
 
 	ctx, cancel := DBContext()
@@ -365,11 +623,11 @@ ORDER BY p.file_name ASC
 	/*
 	 * Execute the query
 	 */
-	if err = s.db.Query(ctx, &result, sqlStatement, folderPath); err != nil {
-		return result, fmt.Errorf("error querying for photos in folder %s: %w", folderPath, err)
+	if err = s.db.Query(ctx, &result, sqlStatement, folderPath, PhotosPerPage, paging.Offset(page, PhotosPerPage)); err != nil {
+		return result, paging.Calculate(page, 0, PhotosPerPage), fmt.Errorf("error querying for photos in folder %s: %w", folderPath, err)
 	}
 
-	return result, nil
+	return result, calculatePhotoPaging(result, page), nil
 }
 
 /*
@@ -377,9 +635,10 @@ Saves a photo to the database.
 */
 func (s PhotoService) Save(photo *models.Photo) error {
 	var (
-		err error
-		tx  *sqlz.Tx
-		r   sql.Result
+		err    error
+		tx     *sqlz.Tx
+		r      sql.Result
+		colors driver.Value
 	)
 
 	statement := ""
@@ -391,16 +650,23 @@ func (s PhotoService) Save(photo *models.Photo) error {
 		return fmt.Errorf("error starting transaction: %w", err)
 	}
 
+	/*
+	 * A keyword's parent is only known when it was read from a
+	 * hierarchical keyword, so one already known isn't cleared.
+	 */
 	for _, keyword := range photo.Keywords {
 		statement = `
 			INSERT INTO keywords (
 				keyword
+				, parent
 			) VALUES (
 				?
-			) ON CONFLICT (keyword) DO NOTHING;
+				, NULLIF(?, '')
+			) ON CONFLICT (keyword) DO UPDATE SET
+				parent=COALESCE(excluded.parent, keywords.parent);
 		`
 
-		args := []any{keyword.Keyword, keyword.Keyword}
+		args := []any{keyword.Keyword, keyword.Parent}
 
 		if _, err = tx.Exec(ctx, statement, args...); err != nil {
 			err2 := tx.Rollback()
@@ -409,13 +675,15 @@ func (s PhotoService) Save(photo *models.Photo) error {
 	}
 
 	for _, person := range photo.People {
-		// Does this person already exist?
+		/*
+		 * Does this person already exist? A name that isn't a person's
+		 * may be another name for one, such as the name they had
+		 * before being renamed or merged.
+		 */
 		var personID int64
-		statement = `SELECT id FROM people WHERE name=?`
-
-		args := []any{person.Name}
+		args := []any{person.Name, person.Name}
 
-		if err = tx.QueryRow(ctx, &personID, statement, args); err != nil && !sqlz.IsNotFound(err) {
+		if err = tx.QueryRow(ctx, &personID, findPersonStatement, args...); err != nil && !sqlz.IsNotFound(err) {
 			err2 := tx.Rollback()
 			return fmt.Errorf("error querying for person %s: %w (%s)", person.Name, err, err2.Error())
 		}
@@ -446,6 +714,11 @@ func (s PhotoService) Save(photo *models.Photo) error {
 		person.ID = uint(personID)
 	}
 
+	/*
+	 * A new placeholder hash means the image itself changed, so face
+	 * detection looks at the photo again. Photos without a palette
+	 * keep the one they have.
+	 */
 	statement = `
 		INSERT INTO photos (
 			id
@@ -469,6 +742,22 @@ func (s PhotoService) Save(photo *models.Photo) error {
 			, longitude
 			, iptc_digest
 			, year
+			, blur_hash
+			, colors
+			, thumbnail_created_at
+			, thumbnail_size
+			, faces_hash
+			, date_is_estimated
+			, place_id
+			, fnumber
+			, exposure_time
+			, iso
+			, focal_length
+			, focal_length35mm
+			, flash
+			, exposure_program
+			, rating
+			, label
 		) VALUES (
 			?
 			, ?
@@ -491,6 +780,22 @@ func (s PhotoService) Save(photo *models.Photo) error {
 			, ?
 			, ?
 			, ?
+			, ?
+			, ?
+			, ?
+			, NULLIF(?, 0)
+			, ?
+			, ?
+			, ?
+			, ?
+			, ?
+			, ?
+			, ?
+			, ?
+			, ?
+			, ?
+			, ?
+			, ?
 		) ON CONFLICT (id) DO UPDATE SET
 			updated_at=excluded.updated_at
 			, file_name=excluded.file_name
@@ -511,8 +816,44 @@ func (s PhotoService) Save(photo *models.Photo) error {
 			, longitude=excluded.longitude
 			, iptc_digest=excluded.iptc_digest
 			, year=excluded.year
+			, blur_hash=excluded.blur_hash
+			, colors=COALESCE(excluded.colors, photos.colors)
+			, thumbnail_created_at=COALESCE(excluded.thumbnail_created_at, photos.thumbnail_created_at)
+			, thumbnail_size=COALESCE(excluded.thumbnail_size, photos.thumbnail_size)
+			, faces_hash=COALESCE(excluded.faces_hash, photos.faces_hash)
+			, date_is_estimated=excluded.date_is_estimated
+			, place_id=excluded.place_id
+			, fnumber=excluded.fnumber
+			, exposure_time=excluded.exposure_time
+			, iso=excluded.iso
+			, focal_length=excluded.focal_length
+			, focal_length35mm=excluded.focal_length35mm
+			, flash=excluded.flash
+			, exposure_program=excluded.exposure_program
+			, rating=excluded.rating
+			, label=excluded.label
+			, faces_detected_at=CASE WHEN photos.blur_hash IS excluded.blur_hash THEN photos.faces_detected_at END
 	`
 
+	/*
+	 * The palette is passed as JSON, as slices given as arguments are
+	 * expanded into lists.
+	 */
+	if colors, err = photo.Colors.Value(); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	/*
+	 * Like the faces themselves, their hash is only replaced when the
+	 * photo was read with its faces.
+	 */
+	var facesHash any
+
+	if photo.Faces != nil {
+		facesHash = photo.FacesHash
+	}
+
 	args := []any{
 		photo.ID,
 		photo.CreatedAt,
@@ -535,6 +876,22 @@ func (s PhotoService) Save(photo *models.Photo) error {
 		photo.Longitude,
 		photo.IptcDigest,
 		photo.Year,
+		photo.BlurHash,
+		colors,
+		photo.ThumbnailCreatedAt,
+		photo.ThumbnailSize,
+		facesHash,
+		photo.DateIsEstimated,
+		photo.PlaceID,
+		photo.FNumber,
+		photo.ExposureTime,
+		photo.ISO,
+		photo.FocalLength,
+		photo.FocalLength35mm,
+		photo.Flash,
+		photo.ExposureProgram,
+		photo.Rating,
+		string(photo.Label),
 	}
 
 	if _, err = tx.Exec(ctx, statement, args...); err != nil {
@@ -561,7 +918,7 @@ func (s PhotoService) Save(photo *models.Photo) error {
 	}
 
 	for _, person := range photo.People {
-		statement = `INSERT INTO photos_people (photo_id, person_id) VALUES (?,?);`
+		statement = `INSERT INTO photos_people (photo_id, person_id) VALUES (?,?) ON CONFLICT (photo_id, person_id) DO NOTHING;`
 
 		if _, err = tx.Exec(ctx, statement, photo.ID, person.ID); err != nil {
 			err2 := tx.Rollback()
@@ -578,6 +935,50 @@ func (s PhotoService) Save(photo *models.Photo) error {
 		}
 	}
 
+	/*
+	 * Faces are only replaced when the photo was read with them, so
+	 * saving a photo loaded from the database keeps the ones it has.
+	 */
+	if photo.Faces != nil {
+		if err = saveFaces(ctx, tx, photo); err != nil {
+			err2 := tx.Rollback()
+			return fmt.Errorf("error saving faces in photo: %w (%s)", err, err2.Error())
+		}
+	}
+
+	/*
+	 * People named in the face review queue stay in the photo, even
+	 * though the photo's file doesn't name them.
+	 */
+	statement = `
+		INSERT INTO photos_people (photo_id, person_id)
+		SELECT photo_id, person_id
+		FROM photo_faces
+		WHERE photo_id=?
+			AND source=?
+			AND person_id IS NOT NULL
+		ON CONFLICT (photo_id, person_id) DO NOTHING;
+	`
+
+	if _, err = tx.Exec(ctx, statement, photo.ID, models.FaceSourceDetected); err != nil {
+		err2 := tx.Rollback()
+		return fmt.Errorf("error inserting named faces' people to photo: %w (%s)", err, err2.Error())
+	}
+
+	/*
+	 * Re-index the photo for full text search now that its
+	 * keywords and people are in place.
+	 */
+	if _, err = tx.Exec(ctx, deleteFullTextStatement, photo.ID); err != nil {
+		err2 := tx.Rollback()
+		return fmt.Errorf("error deleting full text index for photo: %w (%s)", err, err2.Error())
+	}
+
+	if _, err = tx.Exec(ctx, insertFullTextStatement, photo.ID); err != nil {
+		err2 := tx.Rollback()
+		return fmt.Errorf("error inserting full text index for photo: %w (%s)", err, err2.Error())
+	}
+
 	/*
 	 * Finally! Commit the transaction.
 	 */
@@ -592,15 +993,43 @@ func (s PhotoService) Search(criteria models.PhotoSearch) (models.SearchPhotosRe
 			FolderMatches:  []*models.Folder{},
 			KeywordMatches: []*models.KeywordSearchResult{},
 			PeopleMatches:  []*models.Person{},
+			PhotoPaging:    paging.Calculate(criteria.Page, 0, PhotosPerPage),
 		}
 	)
 
+	/*
+	 * Validate the query before searching anything, so syntax
+	 * errors can be shown to the user. Keywords, people, and
+	 * folders are matched by the free text portion only.
+	 */
+	parsed, err := query.Parse(criteria.SearchTerm)
+
+	if err != nil {
+		return result, err
+	}
+
+	if _, err = compileSearchQuery(parsed); err != nil {
+		return result, err
+	}
+
+	text := strings.TrimSpace(strings.ReplaceAll(parsed.Text(), `"`, ""))
+
+	/*
+	 * Keyword, people, and folder matches are only shown with the
+	 * first page of photos.
+	 */
+	includeRelated := text != "" && criteria.Page <= 1
+
 	pool := pond.NewResultPool[models.SearchPhotosResult](0)
 	group := pool.NewGroup()
 
 	// Search for just photos
 	_ = group.Submit(func() models.SearchPhotosResult {
 		c := models.PhotoSearch{
+			Keywords:   criteria.Keywords,
+			Match:      criteria.Match,
+			Page:       criteria.Page,
+			People:     criteria.People,
 			SearchTerm: criteria.SearchTerm,
 		}
 
@@ -613,13 +1042,18 @@ func (s PhotoService) Search(criteria models.PhotoSearch) (models.SearchPhotosRe
 
 		return models.SearchPhotosResult{
 			PhotoMatches: results,
+			PhotoPaging:  calculatePhotoPaging(results, criteria.Page),
 		}
 	})
 
 	// Search for photos by keywords
 	_ = group.Submit(func() models.SearchPhotosResult {
+		if !includeRelated {
+			return models.SearchPhotosResult{}
+		}
+
 		c := models.PhotoSearch{
-			SearchTerm: criteria.SearchTerm,
+			SearchTerm: text,
 		}
 
 		keywordSearchResults, err := s.searchKeywords(c)
@@ -634,7 +1068,11 @@ func (s PhotoService) Search(criteria models.PhotoSearch) (models.SearchPhotosRe
 
 	// Search for photos by people
 	_ = group.Submit(func() models.SearchPhotosResult {
-		results, err := s.searchPeople(models.PhotoSearch{SearchTerm: criteria.SearchTerm})
+		if !includeRelated {
+			return models.SearchPhotosResult{}
+		}
+
+		results, err := s.searchPeople(models.PhotoSearch{SearchTerm: text})
 
 		if err != nil {
 			slog.Error("error searching for photos by people", "person", criteria.SearchTerm)
@@ -646,8 +1084,12 @@ func (s PhotoService) Search(criteria models.PhotoSearch) (models.SearchPhotosRe
 
 	// Search for folders
 	_ = group.Submit(func() models.SearchPhotosResult {
+		if !includeRelated {
+			return models.SearchPhotosResult{}
+		}
+
 		c := models.PhotoSearch{
-			SearchTerm: criteria.SearchTerm,
+			SearchTerm: text,
 		}
 
 		results, err := s.searchFolders(c)
@@ -665,6 +1107,7 @@ func (s PhotoService) Search(criteria models.PhotoSearch) (models.SearchPhotosRe
 	for _, resultSet := range poolResults {
 		if resultSet.PhotoMatches != nil && len(resultSet.PhotoMatches) > 0 {
 			result.PhotoMatches = append(result.PhotoMatches, resultSet.PhotoMatches...)
+			result.PhotoPaging = resultSet.PhotoPaging
 		}
 
 		if resultSet.KeywordMatches != nil && len(resultSet.KeywordMatches) > 0 {
@@ -685,98 +1128,104 @@ func (s PhotoService) Search(criteria models.PhotoSearch) (models.SearchPhotosRe
 
 func (s PhotoService) search(criteria models.PhotoSearch) ([]*models.Photo, error) {
 	var (
-		err    error
-		photos = []*models.Photo{}
+		err        error
+		from       string
+		orderBy    string
+		parameters []any
+		photos     = []*models.Photo{}
 	)
 
-	maxResults := 100
-	parameters := []any{}
+	if from, orderBy, parameters, err = buildSearchFrom(criteria); err != nil {
+		return photos, err
+	}
 
 	statement := `
-SELECT 
-    p.id,
-    p.created_at,
-    p.updated_at,
-    p.deleted_at,
-    p.file_name,
-    p.ext,
-    p.full_path,
-    p.metadata_hash,
-    p.lens_make,
-    p.lens_model,
-    p.lens_id,
-    p.make,
-    p.model,
-    p.caption,
-    p.title,
-    p.creation_date_time,
-    p.width,
-    p.height,
-    p.latitude,
-    p.longitude,
-    p.iptc_digest,
-    p.year,
-    (
-        SELECT json_group_array(k.keyword)
-        FROM photos_keywords pk
-        JOIN keywords k ON pk.keyword = k.keyword
-        WHERE pk.photo_id = p.id
-    ) AS keywords,
-    (
-        SELECT json_group_array(json_object('id', pe.id, 'name', pe.name))
-        FROM photos_people pp
-        JOIN people pe ON pp.person_id = pe.id
-        WHERE pp.photo_id = p.id
-    ) AS people
-FROM photos p
-WHERE 1=1
-	AND p.deleted_at IS NULL
-	`
+SELECT ` + photoColumns + totalCountColumn + from + fmt.Sprintf(`
+ORDER BY %s
+LIMIT ? OFFSET ?`, orderBy)
 
-	// General
-	if len(criteria.SearchTerm) > 0 {
-		statement += ` 
-AND (
-	LOWER(p.file_name) LIKE ? 
-	OR LOWER(p.title) LIKE ? 
-	OR LOWER(p.caption) LIKE ? 
-)
-		`
+	parameters = append(parameters, PhotosPerPage, paging.Offset(criteria.Page, PhotosPerPage))
+
+	ctx, cancel := DBContext()
+	defer cancel()
 
-		parameters = append(parameters, fmt.Sprintf("%%%s%%", strings.ToLower(criteria.SearchTerm)))
-		parameters = append(parameters, fmt.Sprintf("%%%s%%", strings.ToLower(criteria.SearchTerm)))
-		parameters = append(parameters, fmt.Sprintf("%%%s%%", strings.ToLower(criteria.SearchTerm)))
+	if err = s.db.Query(ctx, &photos, statement, parameters...); err != nil {
+		return photos, fmt.Errorf("error executing search query: %w", err)
 	}
 
-	// Keywords
-	if len(criteria.Keywords) > 0 {
-		statement += ` 
-AND p.id IN (
-	SELECT photo_id FROM photos_keywords
-	WHERE LOWER(keyword) IN (?)
+	return photos, nil
+}
 
-)
-		`
+/*
+buildSearchFrom returns the FROM and WHERE clauses selecting photos
+that match a search, the order results are shown in, and the clauses'
+parameters.
+*/
+func buildSearchFrom(criteria models.PhotoSearch) (string, string, []any, error) {
+	var (
+		err      error
+		parsed   query.Query
+		compiled compiledSearch
+	)
 
-		for _, keyword := range criteria.Keywords {
-			parameters = append(parameters, strings.ToLower(keyword))
-		}
+	if parsed, err = query.Parse(criteria.SearchTerm); err != nil {
+		return "", "", nil, err
 	}
 
-	statement += fmt.Sprintf(` ORDER BY p.creation_date_time LIMIT %d`, maxResults)
+	if compiled, err = compileSearchQuery(parsed); err != nil {
+		return "", "", nil, err
+	}
 
-	if len(criteria.People) > 0 {
-		fmt.Printf("\n\n%s\n\n", statement)
+	parameters := compiled.args
+
+	statement := `
+FROM photos p` + compiled.joins + `
+WHERE 1=1
+	AND p.deleted_at IS NULL` + compiled.where() + `
+	`
+
+	/*
+	 * Keyword and people filters. With MatchAll a photo needs every
+	 * keyword and person. With MatchAny, one of any of them is enough.
+	 */
+	filters := []string{}
+
+	if keywords := normalizeSearchValues(criteria.Keywords); len(keywords) > 0 {
+		filters = append(filters, `p.id IN (
+		SELECT photo_id
+		FROM photos_keywords
+		WHERE LOWER(keyword) IN (?)
+		GROUP BY photo_id
+		HAVING COUNT(DISTINCT LOWER(keyword)) >= ?
+	)`)
+
+		parameters = append(parameters, keywords, requiredMatches(criteria.Match, keywords))
 	}
 
-	ctx, cancel := DBContext()
-	defer cancel()
+	if people := normalizeSearchValues(criteria.People); len(people) > 0 {
+		filters = append(filters, `p.id IN (
+		SELECT pp.photo_id
+		FROM photos_people pp
+		JOIN people pe ON pe.id = pp.person_id
+		WHERE LOWER(pe.name) IN (?)
+		GROUP BY pp.photo_id
+		HAVING COUNT(DISTINCT LOWER(pe.name)) >= ?
+	)`)
+
+		parameters = append(parameters, people, requiredMatches(criteria.Match, people))
+	}
 
-	if err = s.db.Query(ctx, &photos, statement, parameters...); err != nil {
-		return photos, fmt.Errorf("error executing search query: %w", err)
+	if len(filters) > 0 {
+		operator := " AND "
+
+		if criteria.Match == models.MatchAny {
+			operator = " OR "
+		}
+
+		statement += "\n\tAND (" + strings.Join(filters, operator) + ")"
 	}
 
-	return photos, nil
+	return statement, compiled.orderBy + ", p.id", parameters, nil
 }
 
 func (s PhotoService) searchPeople(criteria models.PhotoSearch) ([]*models.Person, error) {
@@ -803,19 +1252,10 @@ func (s PhotoService) searchKeywords(criteria models.PhotoSearch) ([]*models.Key
 		results = []*models.KeywordSearchResult{}
 	)
 
-	statement := `
-        SELECT 
-            k.keyword,
-            COUNT(pk.photo_id) as num_matches
-        FROM 
-            keywords k
-        LEFT JOIN 
-            photos_keywords pk ON k.keyword = pk.keyword
-        WHERE 
-            LOWER(k.keyword) LIKE ?
-        GROUP BY 
-            k.keyword
-    `
+	statement := keywordCountsStatement + `
+WHERE LOWER(k.keyword) LIKE ?
+GROUP BY k.keyword
+`
 
 	ctx, cancel := DBContext()
 	defer cancel()
@@ -856,3 +1296,49 @@ func (s PhotoService) searchFolders(criteria models.PhotoSearch) ([]*models.Fold
 
 	return results, nil
 }
+
+/*
+normalizeSearchValues lowercases and trims keywords or names for
+comparison, removing blanks and duplicates.
+*/
+func normalizeSearchValues(values []string) []string {
+	result := []string{}
+
+	for _, value := range values {
+		value = strings.ToLower(strings.TrimSpace(value))
+
+		if value != "" && !slices.Contains(result, value) {
+			result = append(result, value)
+		}
+	}
+
+	return result
+}
+
+/*
+requiredMatches returns how many of the values a photo must have
+to be included, based on the match mode.
+*/
+func requiredMatches(match models.SearchMatch, values []string) int {
+	if match == models.MatchAny {
+		return 1
+	}
+
+	return len(values)
+}
+
+/*
+calculatePhotoPaging builds paging information from the total count
+that paged queries return with each photo.
+*/
+func calculatePhotoPaging(photos []*models.Photo, page int) paging.Paging {
+	var (
+		totalCount int64
+	)
+
+	if len(photos) > 0 {
+		totalCount = int64(photos[0].TotalCount)
+	}
+
+	return paging.Calculate(page, totalCount, PhotosPerPage)
+}
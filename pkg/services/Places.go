@@ -0,0 +1,460 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"path/filepath"
+	"time"
+
+	"github.com/adampresley/ownmyphotos/pkg/gazetteer"
+	"github.com/adampresley/ownmyphotos/pkg/models"
+	"github.com/rfberaldo/sqlz"
+)
+
+/*
+Photos are placed in the nearest gazetteer city, as long as it is
+within this many kilometers.
+*/
+const maxPlaceDistanceKm = 50.0
+
+/*
+Cities without a first level division are grouped under this region
+code, which is the code GeoNames uses for them too.
+*/
+const unknownRegionCode = "00"
+
+type PlaceServicer interface {
+	/*
+	 * Returns true if a gazetteer has been imported.
+	 */
+	HasGazetteer() (bool, error)
+
+	/*
+	 * Imports the GeoNames dump files in dir, replacing any gazetteer
+	 * already imported. Returns the number of cities imported.
+	 */
+	ImportGazetteer(dir string) (int, error)
+
+	/*
+	 * Returns the country, region, and city in a place path, in
+	 * that order, with their names. Parts of the path that aren't
+	 * in the gazetteer are left out.
+	 */
+	GetPlaceHierarchy(path models.PlacePath) ([]*models.Place, error)
+
+	/*
+	 * Returns the place path of a gazetteer city, or an empty path if
+	 * the city isn't in the gazetteer.
+	 */
+	GetCityPlacePath(cityID int64) (models.PlacePath, error)
+
+	/*
+	 * Returns the GeoNames ID of the city nearest a point, or 0 if
+	 * there is no city nearby or the point is 0,0.
+	 */
+	ResolvePlaceID(latitude, longitude float64) (int64, error)
+
+	/*
+	 * Looks up the place for every photo that hasn't been looked up
+	 * yet. Returns the number of photos updated.
+	 */
+	ResolveMissingPlaces() (int, error)
+}
+
+type PlaceServiceConfig struct {
+	DB *sqlz.DB
+}
+
+type PlaceService struct {
+	db *sqlz.DB
+}
+
+type placeRow struct {
+	CountryCode string
+	RegionCode  string
+	CityID      string
+	Name        string
+	NumPhotos   int
+	KeyPhotoID  string
+}
+
+func NewPlaceService(config PlaceServiceConfig) PlaceService {
+	return PlaceService{
+		db: config.DB,
+	}
+}
+
+/*
+Returns true if a gazetteer has been imported.
+*/
+func (s PlaceService) HasGazetteer() (bool, error) {
+	var (
+		err   error
+		count int
+	)
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	if err = s.db.QueryRow(ctx, &count, `SELECT COUNT(*) FROM (SELECT 1 FROM geonames_cities LIMIT 1)`); err != nil {
+		return false, fmt.Errorf("error checking for a gazetteer: %w", err)
+	}
+
+	return count > 0, nil
+}
+
+/*
+Imports the GeoNames countries, first level divisions, and cities in
+dir, replacing what was there. Photos placed with the old gazetteer
+are looked up again.
+*/
+func (s PlaceService) ImportGazetteer(dir string) (int, error) {
+	var (
+		err        error
+		tx         *sqlz.Tx
+		citiesPath string
+		numCities  int
+	)
+
+	if citiesPath, err = gazetteer.FindCitiesFile(dir); err != nil {
+		return 0, err
+	}
+
+	/*
+	 * The cities file can have a couple hundred thousand rows, which
+	 * takes longer than the usual query timeout.
+	 */
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*5)
+	defer cancel()
+
+	if tx, err = s.db.Begin(ctx); err != nil {
+		return 0, fmt.Errorf("error starting transaction: %w", err)
+	}
+
+	rollback := func(err error) (int, error) {
+		if err2 := tx.Rollback(); err2 != nil {
+			return 0, fmt.Errorf("%w (%s)", err, err2.Error())
+		}
+
+		return 0, err
+	}
+
+	for _, table := range []string{"geonames_countries", "geonames_admin1", "geonames_cities"} {
+		if _, err = tx.Exec(ctx, "DELETE FROM "+table); err != nil {
+			return rollback(fmt.Errorf("error clearing %s: %w", table, err))
+		}
+	}
+
+	err = gazetteer.ReadCountries(filepath.Join(dir, gazetteer.CountryFileName), func(country gazetteer.Country) error {
+		_, err := tx.Exec(ctx, `INSERT OR REPLACE INTO geonames_countries (country_code, name) VALUES (?, ?)`, country.CountryCode, country.Name)
+		return err
+	})
+
+	if err != nil {
+		return rollback(fmt.Errorf("error importing countries: %w", err))
+	}
+
+	err = gazetteer.ReadRegions(filepath.Join(dir, gazetteer.Admin1FileName), func(region gazetteer.Region) error {
+		_, err := tx.Exec(ctx, `INSERT OR REPLACE INTO geonames_admin1 (country_code, admin1_code, name) VALUES (?, ?, ?)`, region.CountryCode, region.Admin1Code, region.Name)
+		return err
+	})
+
+	if err != nil {
+		return rollback(fmt.Errorf("error importing regions: %w", err))
+	}
+
+	statement := `
+INSERT OR REPLACE INTO geonames_cities (
+	geoname_id
+	, name
+	, latitude
+	, longitude
+	, country_code
+	, admin1_code
+	, population
+) VALUES (
+	?
+	, ?
+	, ?
+	, ?
+	, ?
+	, ?
+	, ?
+)`
+
+	err = gazetteer.ReadCities(citiesPath, func(city gazetteer.City) error {
+		numCities++
+		_, err := tx.Exec(ctx, statement, city.GeonameID, city.Name, city.Latitude, city.Longitude, city.CountryCode, city.Admin1Code, city.Population)
+		return err
+	})
+
+	if err != nil {
+		return rollback(fmt.Errorf("error importing cities: %w", err))
+	}
+
+	if _, err = tx.Exec(ctx, `UPDATE photos SET place_id = NULL`); err != nil {
+		return rollback(fmt.Errorf("error clearing photo places: %w", err))
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("error committing gazetteer import: %w", err)
+	}
+
+	return numCities, nil
+}
+
+/*
+Returns the country, region, and city in a place path with their
+names, for breadcrumbs and headings.
+*/
+func (s PlaceService) GetPlaceHierarchy(path models.PlacePath) ([]*models.Place, error) {
+	var (
+		err    error
+		rows   = []*placeRow{}
+		result = []*models.Place{}
+	)
+
+	if path.IsEmpty() {
+		return result, nil
+	}
+
+	statement := `
+SELECT
+	co.country_code
+	, '' AS region_code
+	, '' AS city_id
+	, co.name
+FROM geonames_countries co
+WHERE co.country_code = ?
+
+UNION ALL
+
+SELECT
+	? AS country_code
+	, ? AS region_code
+	, '' AS city_id
+	, COALESCE(a.name, 'Other')
+FROM (SELECT 1)
+LEFT JOIN geonames_admin1 a ON a.country_code = ? AND a.admin1_code = ?
+WHERE ? <> ''
+
+UNION ALL
+
+SELECT
+	c.country_code
+	, ? AS region_code
+	, CAST(c.geoname_id AS TEXT) AS city_id
+	, c.name
+FROM geonames_cities c
+WHERE c.geoname_id = ?
+`
+
+	args := []any{
+		path.CountryCode,
+		path.CountryCode, path.RegionCode, path.CountryCode, path.RegionCode, path.RegionCode,
+		path.RegionCode, path.CityID,
+	}
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	if err = s.db.Query(ctx, &rows, statement, args...); err != nil {
+		return result, fmt.Errorf("error querying for place %s: %w", path, err)
+	}
+
+	for _, row := range rows {
+		result = append(result, row.toPlace())
+	}
+
+	return result, nil
+}
+
+/*
+Returns the place path of a gazetteer city, such as the place of a
+photo, so its country and region can be shown too.
+*/
+func (s PlaceService) GetCityPlacePath(cityID int64) (models.PlacePath, error) {
+	var (
+		err  error
+		rows = []*placeRow{}
+	)
+
+	if cityID == 0 {
+		return models.PlacePath{}, nil
+	}
+
+	statement := `
+SELECT
+	c.country_code
+	, ` + placeRegionCode + ` AS region_code
+	, CAST(c.geoname_id AS TEXT) AS city_id
+	, c.name
+FROM geonames_cities c
+WHERE c.geoname_id = ?
+`
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	if err = s.db.Query(ctx, &rows, statement, cityID); err != nil {
+		return models.PlacePath{}, fmt.Errorf("error querying for city %d: %w", cityID, err)
+	}
+
+	if len(rows) == 0 {
+		return models.PlacePath{}, nil
+	}
+
+	return rows[0].toPlace().Path, nil
+}
+
+/*
+Returns the GeoNames ID of the city nearest a point, within
+maxPlaceDistanceKm. Distances use an equirectangular approximation.
+*/
+func (s PlaceService) ResolvePlaceID(latitude, longitude float64) (int64, error) {
+	var (
+		err    error
+		result int64
+	)
+
+	if latitude == 0 && longitude == 0 {
+		return 0, nil
+	}
+
+	latitudeDelta := maxPlaceDistanceKm / kilometersPerDegree
+	longitudeScale := math.Max(math.Cos(latitude*math.Pi/180), 0.01)
+	longitudeDelta := latitudeDelta / longitudeScale
+
+	statement := `
+SELECT c.geoname_id
+FROM geonames_cities c
+WHERE c.latitude BETWEEN ? AND ?
+	AND c.longitude BETWEEN ? AND ?
+	AND ((c.latitude - ?) * (c.latitude - ?) + (c.longitude - ?) * (c.longitude - ?) * ?) <= ?
+ORDER BY ((c.latitude - ?) * (c.latitude - ?) + (c.longitude - ?) * (c.longitude - ?) * ?) ASC
+LIMIT 1
+`
+
+	distanceArgs := []any{latitude, latitude, longitude, longitude, longitudeScale * longitudeScale}
+
+	args := []any{latitude - latitudeDelta, latitude + latitudeDelta, longitude - longitudeDelta, longitude + longitudeDelta}
+	args = append(args, distanceArgs...)
+	args = append(args, latitudeDelta*latitudeDelta)
+	args = append(args, distanceArgs...)
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	if err = s.db.QueryRow(ctx, &result, statement, args...); err != nil {
+		if sqlz.IsNotFound(err) {
+			return 0, nil
+		}
+
+		return 0, fmt.Errorf("error finding the place nearest %f,%f: %w", latitude, longitude, err)
+	}
+
+	return result, nil
+}
+
+/*
+Looks up the place for photos that haven't been looked up yet, such
+as photos collected before a gazetteer was imported. Nothing is done
+without a gazetteer.
+*/
+func (s PlaceService) ResolveMissingPlaces() (int, error) {
+	var (
+		err          error
+		hasGazetteer bool
+		placeID      int64
+		photos       = []*models.Photo{}
+		numResolved  int
+	)
+
+	if hasGazetteer, err = s.HasGazetteer(); err != nil || !hasGazetteer {
+		return 0, err
+	}
+
+	statement := `
+SELECT
+	p.id
+	, COALESCE(p.latitude, 0) AS latitude
+	, COALESCE(p.longitude, 0) AS longitude
+FROM photos p
+WHERE p.deleted_at IS NULL
+	AND p.place_id IS NULL
+`
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	if err = s.db.Query(ctx, &photos, statement); err != nil {
+		return 0, fmt.Errorf("error querying for photos without places: %w", err)
+	}
+
+	for _, photo := range photos {
+		if placeID, err = s.ResolvePlaceID(photo.Latitude, photo.Longitude); err != nil {
+			return numResolved, err
+		}
+
+		if err = s.setPlace(photo.ID, placeID); err != nil {
+			return numResolved, err
+		}
+
+		numResolved++
+	}
+
+	if numResolved > 0 {
+		slog.Info("resolved photo places", "count", numResolved)
+	}
+
+	return numResolved, nil
+}
+
+/*
+setPlace stores a photo's place and re-indexes it for full text
+search, so it can be found by the place's name.
+*/
+func (s PlaceService) setPlace(photoID string, placeID int64) error {
+	var (
+		err error
+		tx  *sqlz.Tx
+	)
+
+	ctx, cancel := DBContext()
+	defer cancel()
+
+	if tx, err = s.db.Begin(ctx); err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+
+	for _, step := range []struct {
+		statement string
+		args      []any
+	}{
+		{statement: `UPDATE photos SET place_id=? WHERE id=?`, args: []any{placeID, photoID}},
+		{statement: deleteFullTextStatement, args: []any{photoID}},
+		{statement: insertFullTextStatement, args: []any{photoID}},
+	} {
+		if _, err = tx.Exec(ctx, step.statement, step.args...); err != nil {
+			err2 := tx.Rollback()
+			return fmt.Errorf("error setting the place of photo %s: %w (%s)", photoID, err, err2.Error())
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *placeRow) toPlace() *models.Place {
+	return &models.Place{
+		Path: models.PlacePath{
+			CountryCode: r.CountryCode,
+			RegionCode:  r.RegionCode,
+			CityID:      r.CityID,
+		},
+		Name:       r.Name,
+		NumPhotos:  r.NumPhotos,
+		KeyPhotoID: r.KeyPhotoID,
+	}
+}
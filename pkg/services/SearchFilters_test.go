@@ -0,0 +1,169 @@
+package services
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/adampresley/ownmyphotos/pkg/models"
+)
+
+/*
+newSearchTest returns a photo service holding photos with the given
+IDs, each changed by setup before it's saved.
+*/
+func newSearchTest(t *testing.T, setup map[string]func(photo *models.Photo)) PhotoService {
+	t.Helper()
+
+	service := NewPhotoService(PhotoServiceConfig{DB: newTestDB(t)})
+
+	for id, change := range setup {
+		photo := &models.Photo{ID: id, FileName: id, Ext: ".jpg", FullPath: "/library"}
+		change(photo)
+
+		if err := service.Save(photo); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return service
+}
+
+/*
+expectSearch checks which photos a search finds, in any order.
+*/
+func expectSearch(t *testing.T, service PhotoService, criteria models.PhotoSearch, want ...string) {
+	t.Helper()
+
+	result, err := service.Search(criteria)
+
+	if err != nil {
+		t.Fatalf("%+v returned error %v", criteria, err)
+	}
+
+	got := []string{}
+
+	for _, photo := range result.PhotoMatches {
+		got = append(got, photo.ID)
+	}
+
+	slices.Sort(got)
+
+	if !slices.Equal(got, want) {
+		t.Errorf("%+v found %v, want %v", criteria, got, want)
+	}
+}
+
+func namedPeople(names ...string) []*models.Person {
+	result := []*models.Person{}
+
+	for _, name := range names {
+		result = append(result, &models.Person{Name: name})
+	}
+
+	return result
+}
+
+func TestSearchByKeywordsAndPeople(t *testing.T) {
+	service := newSearchTest(t, map[string]func(photo *models.Photo){
+		"lake": func(photo *models.Photo) {
+			photo.Keywords = models.ExpandKeywords([]string{"Lake", "Summer"})
+			photo.People = namedPeople("Aunt Mary")
+		},
+		"boat": func(photo *models.Photo) {
+			photo.Keywords = models.ExpandKeywords([]string{"lake"})
+			photo.People = namedPeople("Bob")
+		},
+		"beach": func(photo *models.Photo) {
+			photo.Keywords = models.ExpandKeywords([]string{"Beach"})
+			photo.People = namedPeople("Aunt Mary", "Bob")
+		},
+	})
+
+	expectSearch(t, service, models.PhotoSearch{Keywords: []string{"LAKE", " summer "}, Match: models.MatchAll}, "lake")
+	expectSearch(t, service, models.PhotoSearch{Keywords: []string{"Lake", "Beach"}, Match: models.MatchAny}, "beach", "boat", "lake")
+	expectSearch(t, service, models.PhotoSearch{People: []string{"aunt mary", "Bob"}, Match: models.MatchAll}, "beach")
+	expectSearch(t, service, models.PhotoSearch{People: []string{"Bob", "bob"}, Match: models.MatchAll}, "beach", "boat")
+
+	/*
+	 * Keywords and people are combined the same way as the values in
+	 * each.
+	 */
+	expectSearch(t, service, models.PhotoSearch{Keywords: []string{"Lake"}, People: []string{"Bob"}, Match: models.MatchAll}, "boat")
+	expectSearch(t, service, models.PhotoSearch{Keywords: []string{"Summer"}, People: []string{"Bob"}, Match: models.MatchAny}, "beach", "boat", "lake")
+
+	expectSearch(t, service, models.PhotoSearch{SearchTerm: "keyword:LAKE"}, "boat", "lake")
+	expectSearch(t, service, models.PhotoSearch{SearchTerm: "-tag:lake"}, "beach")
+	expectSearch(t, service, models.PhotoSearch{SearchTerm: `person:"aunt mary" -people:bob`}, "lake")
+	expectSearch(t, service, models.PhotoSearch{SearchTerm: "person:Mary"})
+}
+
+func TestSearchByExposure(t *testing.T) {
+	service := newSearchTest(t, map[string]func(photo *models.Photo){
+		"portrait": func(photo *models.Photo) {
+			photo.Exposure = models.Exposure{FNumber: 1.7999, ExposureTime: 0.004, ISO: 100, FocalLength: 85}
+		},
+		"night": func(photo *models.Photo) {
+			photo.Exposure = models.Exposure{FNumber: 2.8, ExposureTime: 2, ISO: 3200, FocalLength: 24, Flash: 0x19}
+		},
+		"scan": func(photo *models.Photo) {},
+	})
+
+	search := func(term string, want ...string) {
+		t.Helper()
+		expectSearch(t, service, models.PhotoSearch{SearchTerm: term}, want...)
+	}
+
+	search("aperture:1.8", "portrait")
+	search("f:f/2.8", "night")
+	search("shutter:1/250", "portrait")
+	search("shutter:>1", "night")
+	search("focal:85mm", "portrait")
+	search("focal:24..70", "night")
+	search("flash:yes", "night")
+	search("flash:off", "portrait", "scan")
+
+	/*
+	 * Photos without exposure settings, such as scans, don't count as
+	 * having the lowest ones.
+	 */
+	search("iso:<400", "portrait")
+	search("f:<4", "night", "portrait")
+	search("-iso:<400", "night", "scan")
+
+	for _, term := range []string{"iso:fast", "shutter:1/0", "f:0", "focal:wide", "flash:maybe", "flash:>1"} {
+		if _, err := service.Search(models.PhotoSearch{SearchTerm: term}); err == nil {
+			t.Errorf("%s didn't return an error", term)
+		}
+	}
+}
+
+func TestSearchByColor(t *testing.T) {
+	palette := func(colors ...*models.Color) func(photo *models.Photo) {
+		return func(photo *models.Photo) { photo.Colors = colors }
+	}
+
+	service := newSearchTest(t, map[string]func(photo *models.Photo){
+		"sunflower": palette(&models.Color{Name: "yellow", Red: 240, Green: 190, Blue: 30, Weight: 0.6}),
+		"mustard":   palette(&models.Color{Name: "brown", Red: 200, Green: 160, Blue: 40, Weight: 0.4}),
+		"sea":       palette(&models.Color{Name: "teal", Red: 0, Green: 128, Blue: 128, Weight: 0.9}),
+		"blank":     palette(),
+	})
+
+	expectSearch(t, service, models.PhotoSearch{SearchTerm: "color:Yellow"}, "sunflower")
+	expectSearch(t, service, models.PhotoSearch{SearchTerm: "colour:cyan"}, "sea")
+	expectSearch(t, service, models.PhotoSearch{SearchTerm: "-color:teal"}, "blank", "mustard", "sunflower")
+
+	/*
+	 * #f5c518 is about 10 away from the sunflower, and just over
+	 * colorMatchDistance from the mustard.
+	 */
+	expectSearch(t, service, models.PhotoSearch{SearchTerm: "color:#f5c518"}, "sunflower")
+	expectSearch(t, service, models.PhotoSearch{SearchTerm: "color:#AA8C32"}, "mustard")
+	expectSearch(t, service, models.PhotoSearch{SearchTerm: "color:#fff"})
+
+	for _, term := range []string{"color:mauve", "color:#12345", "color:>red"} {
+		if _, err := service.Search(models.PhotoSearch{SearchTerm: term}); err == nil {
+			t.Errorf("%s didn't return an error", term)
+		}
+	}
+}
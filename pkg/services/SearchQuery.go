@@ -0,0 +1,614 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adampresley/ownmyphotos/pkg/models"
+	"github.com/adampresley/ownmyphotos/pkg/query"
+)
+
+/*
+compiledSearch is a parsed search query translated into SQL fragments
+for use against the photos table, aliased as "p".
+*/
+type compiledSearch struct {
+	joins      string
+	conditions []string
+	args       []any
+	orderBy    string
+}
+
+/*
+where returns the compiled conditions as an AND'ed SQL fragment that
+can be appended to a WHERE clause.
+*/
+func (c compiledSearch) where() string {
+	result := strings.Builder{}
+
+	for _, condition := range c.conditions {
+		result.WriteString("\n\tAND " + condition)
+	}
+
+	return result.String()
+}
+
+/*
+A searchFilter compiles a single field term into a SQL condition and
+its arguments. Negation is applied by the caller.
+*/
+type searchFilter func(q query.Query, term query.Term) (string, []any, error)
+
+/*
+searchFilters maps query field names, and their aliases, to the
+function that compiles them.
+*/
+var searchFilters = map[string]searchFilter{
+	"aperture": apertureFilter,
+	"f":        apertureFilter,
+	"camera":   cameraFilter,
+	"color":    colorFilter,
+	"colour":   colorFilter,
+	"date":     dateFilter,
+	"favorite": favoriteFilter,
+	"flash":    flashFilter,
+	"focal":    focalFilter,
+	"folder":   folderFilter,
+	"in":       folderFilter,
+	"iso":      isoFilter,
+	"keyword":  keywordFilter,
+	"label":    labelFilter,
+	"tag":      keywordFilter,
+	"lens":     lensFilter,
+	"make":     containsFilter("p.make"),
+	"model":    containsFilter("p.model"),
+	"people":   personFilter,
+	"person":   personFilter,
+	"place":    placeFilter,
+	"rating":   ratingFilter,
+	"shutter":  shutterFilter,
+	"year":     yearFilter,
+}
+
+/*
+compileSearchQuery translates a parsed query into SQL. Positive free
+text is matched against the full text index and used for ranking.
+Field terms are compiled by their search filter, except "sort", which
+changes the order of the results. Unknown fields and invalid values
+return a *query.Error.
+*/
+func compileSearchQuery(q query.Query) (compiledSearch, error) {
+	result := compiledSearch{
+		conditions: []string{},
+		args:       []any{},
+		orderBy:    "p.creation_date_time",
+	}
+
+	/*
+	 * The full text match is ranked in a subquery, as bm25() can't be
+	 * used in a query that also uses window functions for paging.
+	 */
+	if fullTextQuery := buildFullTextQuery(q.Text()); fullTextQuery != "" {
+		result.joins = "\nJOIN (SELECT photo_id, " + fullTextRank + " AS rank FROM photos_fts WHERE photos_fts MATCH ?) fts ON fts.photo_id = p.id"
+		result.args = append(result.args, fullTextQuery)
+		result.orderBy = "fts.rank, p.creation_date_time"
+	}
+
+	for _, term := range q.Terms {
+		var (
+			err       error
+			condition string
+			args      []any
+		)
+
+		if term.IsText() {
+			if !term.Negated {
+				continue
+			}
+
+			fullTextQuery := buildFullTextQuery(quoteIfNeeded(term))
+
+			if fullTextQuery == "" {
+				continue
+			}
+
+			result.conditions = append(result.conditions, "p.id NOT IN (SELECT photo_id FROM photos_fts WHERE photos_fts MATCH ?)")
+			result.args = append(result.args, fullTextQuery)
+			continue
+		}
+
+		if term.Field == "sort" {
+			if err = applySort(&result, q, term); err != nil {
+				return result, err
+			}
+
+			continue
+		}
+
+		filter, ok := searchFilters[term.Field]
+
+		if !ok {
+			return result, query.NewError(q.Input, term.Position, "'%s' is not a field you can search by", term.Field)
+		}
+
+		if condition, args, err = filter(q, term); err != nil {
+			return result, err
+		}
+
+		if term.Negated {
+			condition = "NOT (" + condition + ")"
+		}
+
+		result.conditions = append(result.conditions, condition)
+		result.args = append(result.args, args...)
+	}
+
+	return result, nil
+}
+
+/*
+applySort puts the results of "sort:rating" in order of highest rating
+first, keeping the usual order for photos with the same rating.
+*/
+func applySort(result *compiledSearch, q query.Query, term query.Term) error {
+	if err := requireEquals(q, term); err != nil {
+		return err
+	}
+
+	if term.Negated || strings.ToLower(term.Value) != "rating" {
+		return query.NewError(q.Input, term.Position, "'%s' is not a valid sort. Use sort:rating", term.Value)
+	}
+
+	result.orderBy = "p.rating DESC, " + result.orderBy
+	return nil
+}
+
+func keywordFilter(q query.Query, term query.Term) (string, []any, error) {
+	if err := requireEquals(q, term); err != nil {
+		return "", nil, err
+	}
+
+	condition := `p.id IN (SELECT pk.photo_id FROM photos_keywords pk WHERE LOWER(pk.keyword) = LOWER(?))`
+	return condition, []any{term.Value}, nil
+}
+
+func personFilter(q query.Query, term query.Term) (string, []any, error) {
+	if err := requireEquals(q, term); err != nil {
+		return "", nil, err
+	}
+
+	condition := `p.id IN (
+		SELECT pp.photo_id
+		FROM photos_people pp
+		JOIN people pe ON pe.id = pp.person_id
+		WHERE LOWER(pe.name) = LOWER(?)
+	)`
+
+	return condition, []any{term.Value}, nil
+}
+
+/*
+placeFilter matches photos taken in a city, region, or country with
+the given name. Countries can also be given by their ISO code.
+*/
+func placeFilter(q query.Query, term query.Term) (string, []any, error) {
+	if err := requireEquals(q, term); err != nil {
+		return "", nil, err
+	}
+
+	condition := `EXISTS (
+		SELECT 1
+		FROM geonames_cities c
+		LEFT JOIN geonames_admin1 a ON a.country_code = c.country_code AND a.admin1_code = c.admin1_code
+		LEFT JOIN geonames_countries co ON co.country_code = c.country_code
+		WHERE c.geoname_id = p.place_id
+			AND (
+				LOWER(c.name) = LOWER(?)
+				OR LOWER(a.name) = LOWER(?)
+				OR LOWER(co.name) = LOWER(?)
+				OR c.country_code = UPPER(?)
+			)
+	)`
+
+	return condition, []any{term.Value, term.Value, term.Value, term.Value}, nil
+}
+
+func cameraFilter(q query.Query, term query.Term) (string, []any, error) {
+	return containsFilter("COALESCE(p.make, '') || ' ' || COALESCE(p.model, '')")(q, term)
+}
+
+func lensFilter(q query.Query, term query.Term) (string, []any, error) {
+	return containsFilter("COALESCE(p.lens_make, '') || ' ' || COALESCE(p.lens_model, '')")(q, term)
+}
+
+/*
+containsFilter returns a filter doing a case insensitive substring
+match on a column expression.
+*/
+func containsFilter(column string) searchFilter {
+	return func(q query.Query, term query.Term) (string, []any, error) {
+		if err := requireEquals(q, term); err != nil {
+			return "", nil, err
+		}
+
+		condition := "LOWER(COALESCE(" + column + ", '')) LIKE ?"
+		return condition, []any{"%" + strings.ToLower(term.Value) + "%"}, nil
+	}
+}
+
+/*
+folderFilter matches photos in a folder, given relative to the library
+root, or any of its subfolders.
+*/
+func folderFilter(q query.Query, term query.Term) (string, []any, error) {
+	if err := requireEquals(q, term); err != nil {
+		return "", nil, err
+	}
+
+	folder := strings.Trim(term.Value, "/")
+
+	condition := `p.full_path IN (
+		SELECT f.full_path
+		FROM folders f
+		WHERE LOWER(LTRIM(f.parent_path || '/' || f.folder_name, '/')) = LOWER(?)
+			OR LOWER(LTRIM(f.parent_path || '/' || f.folder_name, '/')) LIKE LOWER(?)
+	)`
+
+	return condition, []any{folder, folder + "/%"}, nil
+}
+
+func yearFilter(q query.Query, term query.Term) (string, []any, error) {
+	parse := func(value string) (any, error) {
+		year, err := strconv.Atoi(value)
+
+		if err != nil || year < 1 || year > 9999 {
+			return nil, query.NewError(q.Input, term.Position, "'%s' is not a valid year", value)
+		}
+
+		return year, nil
+	}
+
+	return compareFilter(q, term, "CAST(p.year AS INTEGER)", parse)
+}
+
+/*
+apertureFilter matches photos by f-number, such as "aperture:2.8" or
+"f:<4". A leading "f/" is allowed.
+*/
+func apertureFilter(q query.Query, term query.Term) (string, []any, error) {
+	parse := func(value string) (any, error) {
+		fNumber, err := strconv.ParseFloat(strings.TrimPrefix(strings.ToLower(value), "f/"), 64)
+
+		if err != nil || fNumber <= 0 {
+			return nil, query.NewError(q.Input, term.Position, "'%s' is not a valid aperture", value)
+		}
+
+		return math.Round(fNumber*10) / 10, nil
+	}
+
+	return exposureFilter(q, term, "p.fnumber", "ROUND(p.fnumber, 1)", parse)
+}
+
+/*
+shutterFilter matches photos by exposure time in seconds, written as a
+fraction or a number, such as "shutter:1/250" or "shutter:>2".
+*/
+func shutterFilter(q query.Query, term query.Term) (string, []any, error) {
+	parse := func(value string) (any, error) {
+		var (
+			err     error
+			seconds float64
+		)
+
+		numerator, denominator, isFraction := strings.Cut(strings.TrimSuffix(value, "s"), "/")
+
+		if seconds, err = strconv.ParseFloat(numerator, 64); err == nil && isFraction {
+			var divisor float64
+
+			if divisor, err = strconv.ParseFloat(denominator, 64); err == nil && divisor != 0 {
+				seconds /= divisor
+			} else {
+				err = fmt.Errorf("invalid fraction")
+			}
+		}
+
+		if err != nil || seconds <= 0 {
+			return nil, query.NewError(q.Input, term.Position, "'%s' is not a valid shutter speed. Use a number of seconds, such as 1/250 or 2", value)
+		}
+
+		return math.Round(seconds*1e6) / 1e6, nil
+	}
+
+	return exposureFilter(q, term, "p.exposure_time", "ROUND(p.exposure_time, 6)", parse)
+}
+
+func isoFilter(q query.Query, term query.Term) (string, []any, error) {
+	parse := func(value string) (any, error) {
+		iso, err := strconv.Atoi(value)
+
+		if err != nil || iso <= 0 {
+			return nil, query.NewError(q.Input, term.Position, "'%s' is not a valid ISO", value)
+		}
+
+		return iso, nil
+	}
+
+	return exposureFilter(q, term, "p.iso", "p.iso", parse)
+}
+
+/*
+focalFilter matches photos by focal length in millimeters, rounded to
+the nearest millimeter, such as "focal:35" or "focal:24..70".
+*/
+func focalFilter(q query.Query, term query.Term) (string, []any, error) {
+	parse := func(value string) (any, error) {
+		focalLength, err := strconv.ParseFloat(strings.TrimSuffix(strings.ToLower(value), "mm"), 64)
+
+		if err != nil || focalLength <= 0 {
+			return nil, query.NewError(q.Input, term.Position, "'%s' is not a valid focal length", value)
+		}
+
+		return math.Round(focalLength), nil
+	}
+
+	return exposureFilter(q, term, "p.focal_length", "ROUND(p.focal_length)", parse)
+}
+
+/*
+flashFilter matches photos where the flash did, or didn't, fire.
+*/
+func flashFilter(q query.Query, term query.Term) (string, []any, error) {
+	if err := requireEquals(q, term); err != nil {
+		return "", nil, err
+	}
+
+	switch strings.ToLower(term.Value) {
+	case "yes", "on", "true", "fired":
+		return "(p.flash & 1) = 1", []any{}, nil
+
+	case "no", "off", "false":
+		return "(p.flash & 1) = 0", []any{}, nil
+	}
+
+	return "", nil, query.NewError(q.Input, term.Position, "'%s' is not a valid flash value. Use yes or no", term.Value)
+}
+
+/*
+favoriteFilter matches photos that are, or aren't, favorites.
+*/
+func favoriteFilter(q query.Query, term query.Term) (string, []any, error) {
+	if err := requireEquals(q, term); err != nil {
+		return "", nil, err
+	}
+
+	condition := `EXISTS (
+		SELECT 1
+		FROM favorites fav
+		WHERE fav.full_path = p.full_path
+			AND fav.file_name = p.file_name
+			AND fav.ext = p.ext
+	)`
+
+	switch strings.ToLower(term.Value) {
+	case "yes", "true":
+		return condition, []any{}, nil
+
+	case "no", "false":
+		return "NOT " + condition, []any{}, nil
+	}
+
+	return "", nil, query.NewError(q.Input, term.Position, "'%s' is not a valid favorite value. Use true or false", term.Value)
+}
+
+/*
+ratingFilter matches photos by star rating, such as "rating:5" or
+"rating:>=3". Unrated photos have a rating of 0.
+*/
+func ratingFilter(q query.Query, term query.Term) (string, []any, error) {
+	parse := func(value string) (any, error) {
+		rating, err := strconv.Atoi(value)
+
+		if err != nil || rating < 0 || rating > models.MaxRating {
+			return nil, query.NewError(q.Input, term.Position, "'%s' is not a valid rating. Use a number from 0 to %d", value, models.MaxRating)
+		}
+
+		return rating, nil
+	}
+
+	return compareFilter(q, term, "p.rating", parse)
+}
+
+/*
+labelFilter matches photos with a color label, such as "label:red".
+"label:none" matches photos without one.
+*/
+func labelFilter(q query.Query, term query.Term) (string, []any, error) {
+	if err := requireEquals(q, term); err != nil {
+		return "", nil, err
+	}
+
+	if strings.ToLower(term.Value) == "none" {
+		return "p.label = ''", []any{}, nil
+	}
+
+	label := models.NewColorLabel(term.Value)
+
+	if label == models.LabelNone {
+		return "", nil, query.NewError(q.Input, term.Position, "'%s' is not a valid label. Use red, yellow, green, blue, purple, or none", term.Value)
+	}
+
+	return "p.label = ?", []any{string(label)}, nil
+}
+
+/*
+colorMatchDistance is how far apart, as a distance between red, green,
+and blue values, a color in a photo's palette can be from a searched
+hex color and still match.
+*/
+const colorMatchDistance = 60
+
+/*
+colorFilter matches photos with a color in their palette, either by
+name, such as "color:yellow", or close to a hex color, such as
+"color:#f5c518".
+*/
+func colorFilter(q query.Query, term query.Term) (string, []any, error) {
+	if err := requireEquals(q, term); err != nil {
+		return "", nil, err
+	}
+
+	if name := models.NewColorName(term.Value); name != "" {
+		condition := `EXISTS (
+		SELECT 1
+		FROM json_each(p.colors) c
+		WHERE json_extract(c.value, '$.name') = ?
+	)`
+
+		return condition, []any{name}, nil
+	}
+
+	color, ok := models.ParseHexColor(term.Value)
+
+	if !ok {
+		return "", nil, query.NewError(q.Input, term.Position, "'%s' is not a valid color. Use a hex color, such as #f5c518, or one of %s", term.Value, strings.Join(models.ColorNames, ", "))
+	}
+
+	condition := `EXISTS (
+		SELECT 1
+		FROM (
+			SELECT
+				json_extract(c.value, '$.red') - ? AS r
+				, json_extract(c.value, '$.green') - ? AS g
+				, json_extract(c.value, '$.blue') - ? AS b
+			FROM json_each(p.colors) c
+		)
+		WHERE r * r + g * g + b * b <= ?
+	)`
+
+	return condition, []any{color.Red, color.Green, color.Blue, colorMatchDistance * colorMatchDistance}, nil
+}
+
+/*
+exposureFilter compares an exposure setting, leaving out photos where
+the setting wasn't recorded so they don't match comparisons like
+"iso:<400".
+*/
+func exposureFilter(q query.Query, term query.Term, column, expression string, parse func(value string) (any, error)) (string, []any, error) {
+	condition, args, err := compareFilter(q, term, expression, parse)
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	return "(" + column + " > 0 AND " + condition + ")", args, nil
+}
+
+/*
+compareFilter compiles a term using its operator against a column.
+parse validates and converts each value.
+*/
+func compareFilter(q query.Query, term query.Term, column string, parse func(value string) (any, error)) (string, []any, error) {
+	from, err := parse(term.Value)
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	if term.Operator != query.OpRange {
+		return fmt.Sprintf("%s %s ?", column, term.Operator), []any{from}, nil
+	}
+
+	to, err := parse(term.To)
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	return fmt.Sprintf("%s BETWEEN ? AND ?", column), []any{from, to}, nil
+}
+
+/*
+dateFilter matches photos by creation date. Values can be a year
+(2019), a month (2019-07), or a day (2019-07-14), and each covers
+the whole period it describes.
+*/
+func dateFilter(q query.Query, term query.Term) (string, []any, error) {
+	column := "p.creation_date_time"
+
+	start, end, err := parseDatePeriod(q, term, term.Value)
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	switch term.Operator {
+	case query.OpGreater:
+		return column + " >= ?", []any{end}, nil
+
+	case query.OpGreaterEqual:
+		return column + " >= ?", []any{start}, nil
+
+	case query.OpLess:
+		return column + " < ?", []any{start}, nil
+
+	case query.OpLessEqual:
+		return column + " < ?", []any{end}, nil
+
+	case query.OpRange:
+		_, rangeEnd, err := parseDatePeriod(q, term, term.To)
+
+		if err != nil {
+			return "", nil, err
+		}
+
+		return "(" + column + " >= ? AND " + column + " < ?)", []any{start, rangeEnd}, nil
+	}
+
+	return "(" + column + " >= ? AND " + column + " < ?)", []any{start, end}, nil
+}
+
+/*
+parseDatePeriod returns the start of a date period, and the start of
+the period after it, formatted for comparison against stored dates.
+*/
+func parseDatePeriod(q query.Query, term query.Term, value string) (string, string, error) {
+	layouts := []struct {
+		layout string
+		next   func(t time.Time) time.Time
+	}{
+		{layout: "2006-01-02", next: func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }},
+		{layout: "2006-01", next: func(t time.Time) time.Time { return t.AddDate(0, 1, 0) }},
+		{layout: "2006", next: func(t time.Time) time.Time { return t.AddDate(1, 0, 0) }},
+	}
+
+	for _, l := range layouts {
+		if len(value) != len(l.layout) {
+			continue
+		}
+
+		if t, err := time.Parse(l.layout, value); err == nil {
+			return t.Format(time.DateOnly), l.next(t).Format(time.DateOnly), nil
+		}
+	}
+
+	return "", "", query.NewError(q.Input, term.Position, "'%s' is not a valid date. Use YYYY, YYYY-MM, or YYYY-MM-DD", value)
+}
+
+func requireEquals(q query.Query, term query.Term) error {
+	if term.Operator != query.OpEquals {
+		return query.NewError(q.Input, term.Position, "'%s:' can only match a value, not compare or use ranges", term.Field)
+	}
+
+	return nil
+}
+
+func quoteIfNeeded(term query.Term) string {
+	if term.Quoted {
+		return `"` + term.Value + `"`
+	}
+
+	return term.Value
+}
@@ -0,0 +1,126 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/adampresley/ownmyphotos/pkg/query"
+)
+
+func compileTestQuery(t *testing.T, input string) compiledSearch {
+	t.Helper()
+
+	q, err := query.Parse(input)
+
+	if err != nil {
+		t.Fatalf("Parse(%q) returned error %v", input, err)
+	}
+
+	compiled, err := compileSearchQuery(q)
+
+	if err != nil {
+		t.Fatalf("compileSearchQuery(%q) returned error %v", input, err)
+	}
+
+	return compiled
+}
+
+/*
+wantQueryError checks that a query doesn't compile, and that the error
+points at the term at fault.
+*/
+func wantQueryError(t *testing.T, input string, position int) {
+	t.Helper()
+
+	q, err := query.Parse(input)
+
+	if err == nil {
+		_, err = compileSearchQuery(q)
+	}
+
+	var queryError *query.Error
+
+	if !errors.As(err, &queryError) {
+		t.Errorf("%q returned error %v, want a *query.Error", input, err)
+		return
+	}
+
+	if queryError.Position != position {
+		t.Errorf("%q returned %q at %d, want it at %d", input, queryError.Message, queryError.Position, position)
+	}
+}
+
+func TestCompileSearchQuery(t *testing.T) {
+	compiled := compileTestQuery(t, `beach -blurry year:>=2015 -folder:/Trips/Italy/`)
+
+	if !strings.Contains(compiled.joins, "photos_fts MATCH ?") {
+		t.Errorf("free text isn't matched against the full text index: %s", compiled.joins)
+	}
+
+	if compiled.orderBy != "fts.rank, p.creation_date_time" {
+		t.Errorf("results are ordered by %s", compiled.orderBy)
+	}
+
+	if len(compiled.conditions) != 3 {
+		t.Fatalf("compiled %d conditions, want 3: %v", len(compiled.conditions), compiled.conditions)
+	}
+
+	if !strings.HasPrefix(compiled.conditions[0], "p.id NOT IN") {
+		t.Errorf("negated text compiled to %s", compiled.conditions[0])
+	}
+
+	if compiled.conditions[1] != "CAST(p.year AS INTEGER) >= ?" {
+		t.Errorf("year:>=2015 compiled to %s", compiled.conditions[1])
+	}
+
+	if !strings.HasPrefix(compiled.conditions[2], "NOT (p.full_path IN") {
+		t.Errorf("-folder compiled to %s", compiled.conditions[2])
+	}
+
+	/*
+	 * Arguments follow the order of the SQL: the ranked match in the
+	 * join, then each condition's.
+	 */
+	wantArgs := []any{`"beach"*`, `"blurry"*`, 2015, "Trips/Italy", "Trips/Italy/%"}
+
+	if !reflect.DeepEqual(compiled.args, wantArgs) {
+		t.Errorf("args = %#v, want %#v", compiled.args, wantArgs)
+	}
+}
+
+func TestCompileEmptySearchQuery(t *testing.T) {
+	compiled := compileTestQuery(t, "")
+
+	if compiled.joins != "" || compiled.where() != "" || compiled.orderBy != "p.creation_date_time" {
+		t.Errorf("an empty query compiled to %+v", compiled)
+	}
+}
+
+func TestCompileDatePeriods(t *testing.T) {
+	dates := map[string][]any{
+		"date:2019":             {"2019-01-01", "2020-01-01"},
+		"date:2019-02":          {"2019-02-01", "2019-03-01"},
+		"date:2019-12-31":       {"2019-12-31", "2020-01-01"},
+		"date:>2019-07":         {"2019-08-01"},
+		"date:<=2019":           {"2020-01-01"},
+		"date:2019-06..2019-08": {"2019-06-01", "2019-09-01"},
+		"date:2018..2019-01-15": {"2018-01-01", "2019-01-16"},
+	}
+
+	for input, want := range dates {
+		if got := compileTestQuery(t, input).args; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s compiled with %v, want %v", input, got, want)
+		}
+	}
+}
+
+func TestCompileSearchQueryErrors(t *testing.T) {
+	wantQueryError(t, "beach weather:sunny", 6)
+	wantQueryError(t, "year:twenty", 0)
+	wantQueryError(t, "beach year:2019..later", 6)
+	wantQueryError(t, "date:2019-13", 0)
+	wantQueryError(t, "date:July", 0)
+	wantQueryError(t, "folder:>Trips", 0)
+}
@@ -32,9 +32,12 @@ func (s SettingsService) Read() (*models.Settings, error) {
 	)
 
 	result := &models.Settings{
-		ThumbnailSize:     300,
-		MaxWorkers:        5,
-		CollectorSchedule: "0 */1 * * *",
+		ThumbnailSize:       300,
+		MaxWorkers:          5,
+		CollectorSchedule:   "0 */1 * * *",
+		MapTileURL:          "https://tile.openstreetmap.org/{z}/{x}/{y}.png",
+		MapTileAttribution:  "© OpenStreetMap contributors",
+		MetadataWriteTarget: models.WriteToSidecar,
 	}
 
 	sql := `
@@ -44,6 +47,13 @@ SELECT
 	, max_workers
    , library_path
 	, thumbnail_size
+	, digest_schedule
+	, digest_output_path
+	, digest_email_to
+	, map_tile_url
+	, map_tile_attribution
+	, metadata_write_target
+	, face_detection_schedule
 FROM settings
 WHERE 1=1
    AND id=1
@@ -71,12 +81,26 @@ INSERT INTO settings (
 	, max_workers
    , library_path
 	, thumbnail_size
+	, digest_schedule
+	, digest_output_path
+	, digest_email_to
+	, map_tile_url
+	, map_tile_attribution
+	, metadata_write_target
+	, face_detection_schedule
 ) VALUES (
    1
 	, ?
 	, ?
    , ?
 	, ?
+	, ?
+	, ?
+	, ?
+	, ?
+	, ?
+	, ?
+	, ?
 )
 ON CONFLICT (id) DO
 UPDATE SET
@@ -84,6 +108,13 @@ UPDATE SET
 	, max_workers=excluded.max_workers
    , library_path=excluded.library_path
 	, thumbnail_size=excluded.thumbnail_size
+	, digest_schedule=excluded.digest_schedule
+	, digest_output_path=excluded.digest_output_path
+	, digest_email_to=excluded.digest_email_to
+	, map_tile_url=excluded.map_tile_url
+	, map_tile_attribution=excluded.map_tile_attribution
+	, metadata_write_target=excluded.metadata_write_target
+	, face_detection_schedule=excluded.face_detection_schedule
    `
 
 	args := []any{
@@ -91,6 +122,13 @@ UPDATE SET
 		settings.MaxWorkers,
 		settings.LibraryPath,
 		settings.ThumbnailSize,
+		settings.DigestSchedule,
+		settings.DigestOutputPath,
+		settings.DigestEmailTo,
+		settings.MapTileURL,
+		settings.MapTileAttribution,
+		string(models.NewMetadataWriteTarget(string(settings.MetadataWriteTarget))),
+		settings.FaceDetectionSchedule,
 	}
 
 	ctx, cancel := DBContext()
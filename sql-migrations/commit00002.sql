@@ -0,0 +1,4 @@
+--
+-- Placeholder hashes rendered while thumbnails load
+--
+ALTER TABLE photos ADD COLUMN blur_hash text default '';
@@ -0,0 +1,41 @@
+--
+-- Full text search over photo metadata. Rows are maintained by the
+-- photo service whenever a photo is saved or deleted.
+--
+CREATE VIRTUAL TABLE IF NOT EXISTS photos_fts USING fts5(
+   photo_id UNINDEXED,
+   file_name,
+   title,
+   caption,
+   keywords,
+   people,
+   path,
+   year,
+   tokenize = 'unicode61 remove_diacritics 2',
+   prefix = '2 3'
+);
+
+--
+-- Index any photos collected before full text search existed
+--
+INSERT INTO photos_fts (
+   photo_id,
+   file_name,
+   title,
+   caption,
+   keywords,
+   people,
+   path,
+   year
+)
+SELECT
+   p.id,
+   COALESCE(p.file_name, ''),
+   COALESCE(p.title, ''),
+   COALESCE(p.caption, ''),
+   COALESCE((SELECT group_concat(pk.keyword, ' ') FROM photos_keywords pk WHERE pk.photo_id = p.id), ''),
+   COALESCE((SELECT group_concat(pe.name, ' ') FROM photos_people pp JOIN people pe ON pe.id = pp.person_id WHERE pp.photo_id = p.id), ''),
+   COALESCE((SELECT LTRIM(f.parent_path || '/' || f.folder_name, '/') FROM folders f WHERE f.full_path = p.full_path), p.full_path),
+   COALESCE(p.year, '')
+FROM photos p
+WHERE p.id NOT IN (SELECT photo_id FROM photos_fts);
@@ -0,0 +1,10 @@
+--
+-- Timeline browsing groups and filters photos by creation date
+--
+CREATE INDEX IF NOT EXISTS idx_photos_creation_date_time ON photos (creation_date_time);
+
+--
+-- Photos without an EXIF date use the file's modification time,
+-- and are flagged so the date can be shown as an estimate
+--
+ALTER TABLE photos ADD COLUMN date_is_estimated boolean default false;
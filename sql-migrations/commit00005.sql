@@ -0,0 +1,6 @@
+--
+-- "On this day" memories digest. An empty schedule turns it off
+--
+ALTER TABLE settings ADD COLUMN digest_schedule text default '';
+ALTER TABLE settings ADD COLUMN digest_output_path text default '';
+ALTER TABLE settings ADD COLUMN digest_email_to text default '';
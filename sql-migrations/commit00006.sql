@@ -0,0 +1,6 @@
+--
+-- Map tiles can come from any server using the {z}/{x}/{y} URL
+-- scheme, including a self-hosted one
+--
+ALTER TABLE settings ADD COLUMN map_tile_url text default 'https://tile.openstreetmap.org/{z}/{x}/{y}.png';
+ALTER TABLE settings ADD COLUMN map_tile_attribution text default '© OpenStreetMap contributors';
@@ -0,0 +1,29 @@
+--
+-- Offline gazetteer imported from the GeoNames dumps, used to turn
+-- photo coordinates into country, region, and city names
+--
+CREATE TABLE IF NOT EXISTS "geonames_countries" (
+   country_code text PRIMARY KEY,
+   name text
+);
+
+CREATE TABLE IF NOT EXISTS "geonames_admin1" (
+   country_code text,
+   admin1_code text,
+   name text,
+
+   PRIMARY KEY(country_code, admin1_code)
+);
+
+CREATE TABLE IF NOT EXISTS "geonames_cities" (
+   geoname_id integer PRIMARY KEY,
+   name text,
+   latitude real,
+   longitude real,
+   country_code text,
+   admin1_code text,
+   population integer
+);
+
+CREATE INDEX IF NOT EXISTS idx_geonames_cities_latitude ON geonames_cities (latitude, longitude);
+CREATE INDEX IF NOT EXISTS idx_geonames_cities_region ON geonames_cities (country_code, admin1_code);
@@ -0,0 +1,55 @@
+--
+-- Photos are linked to the nearest city in the gazetteer. A NULL
+-- place_id means the photo hasn't been looked up yet, and 0 means
+-- there was no city nearby.
+--
+-- Migrations run on every start, and this script stops at the ALTER
+-- once the column exists. Everything after it only runs once, which
+-- is what rebuilding the full text index below relies on.
+--
+ALTER TABLE photos ADD COLUMN place_id integer;
+
+CREATE INDEX IF NOT EXISTS idx_photos_place_id ON photos (place_id);
+
+--
+-- Rebuild the full text index with a column for place names. FTS5
+-- tables can't have columns added, so the table is recreated.
+--
+DROP TABLE IF EXISTS photos_fts;
+
+CREATE VIRTUAL TABLE photos_fts USING fts5(
+   photo_id UNINDEXED,
+   file_name,
+   title,
+   caption,
+   keywords,
+   people,
+   path,
+   year,
+   place,
+   tokenize = 'unicode61 remove_diacritics 2',
+   prefix = '2 3'
+);
+
+INSERT INTO photos_fts (
+   photo_id,
+   file_name,
+   title,
+   caption,
+   keywords,
+   people,
+   path,
+   year,
+   place
+)
+SELECT
+   p.id,
+   COALESCE(p.file_name, ''),
+   COALESCE(p.title, ''),
+   COALESCE(p.caption, ''),
+   COALESCE((SELECT group_concat(pk.keyword, ' ') FROM photos_keywords pk WHERE pk.photo_id = p.id), ''),
+   COALESCE((SELECT group_concat(pe.name, ' ') FROM photos_people pp JOIN people pe ON pe.id = pp.person_id WHERE pp.photo_id = p.id), ''),
+   COALESCE((SELECT LTRIM(f.parent_path || '/' || f.folder_name, '/') FROM folders f WHERE f.full_path = p.full_path), p.full_path),
+   COALESCE(p.year, ''),
+   ''
+FROM photos p;
@@ -0,0 +1,12 @@
+--
+-- Shooting settings read from each photo's EXIF data. Settings the
+-- camera didn't record are 0. exposure_time is in seconds, and flash
+-- and exposure_program hold the raw EXIF values.
+--
+ALTER TABLE photos ADD COLUMN fnumber real NOT NULL DEFAULT 0;
+ALTER TABLE photos ADD COLUMN exposure_time real NOT NULL DEFAULT 0;
+ALTER TABLE photos ADD COLUMN iso integer NOT NULL DEFAULT 0;
+ALTER TABLE photos ADD COLUMN focal_length real NOT NULL DEFAULT 0;
+ALTER TABLE photos ADD COLUMN focal_length35mm integer NOT NULL DEFAULT 0;
+ALTER TABLE photos ADD COLUMN flash integer NOT NULL DEFAULT 0;
+ALTER TABLE photos ADD COLUMN exposure_program integer NOT NULL DEFAULT 0;
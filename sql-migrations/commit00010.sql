@@ -0,0 +1,24 @@
+--
+-- Albums are collections of photos from any folder. Photos are kept
+-- in the order they were added, and an album without a cover photo
+-- uses its first photo instead.
+--
+CREATE TABLE IF NOT EXISTS "albums" (
+   id integer PRIMARY KEY AUTOINCREMENT,
+   created_at datetime,
+   updated_at datetime,
+   name text NOT NULL,
+   sort_order integer NOT NULL DEFAULT 0,
+   cover_photo_id text
+);
+
+CREATE TABLE IF NOT EXISTS "albums_photos" (
+   album_id integer,
+   photo_id text,
+   sort_order integer NOT NULL DEFAULT 0,
+   added_at datetime,
+
+   PRIMARY KEY(album_id, photo_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_albums_photos_photo_id ON albums_photos (photo_id);
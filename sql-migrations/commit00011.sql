@@ -0,0 +1,13 @@
+--
+-- Smart albums hold a saved search instead of a list of photos. The
+-- search is run each time the album is viewed, so new photos that
+-- match show up on their own. Keywords and people are JSON arrays.
+--
+-- Migrations run on every start, and this script stops at the first
+-- ALTER once the column exists.
+--
+ALTER TABLE albums ADD COLUMN is_smart integer NOT NULL DEFAULT 0;
+ALTER TABLE albums ADD COLUMN search_term text NOT NULL DEFAULT '';
+ALTER TABLE albums ADD COLUMN search_keywords text NOT NULL DEFAULT '[]';
+ALTER TABLE albums ADD COLUMN search_people text NOT NULL DEFAULT '[]';
+ALTER TABLE albums ADD COLUMN search_match text NOT NULL DEFAULT 'all';
@@ -0,0 +1,13 @@
+--
+-- Favorite photos. These are keyed by the photo's folder and file
+-- name instead of its ID, as the ID changes when a file is replaced,
+-- and a favorite should survive the library being collected again.
+--
+CREATE TABLE IF NOT EXISTS "favorites" (
+   full_path text,
+   file_name text,
+   ext text,
+   created_at datetime,
+
+   PRIMARY KEY(full_path, file_name, ext)
+);
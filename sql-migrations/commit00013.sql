@@ -0,0 +1,9 @@
+--
+-- Star ratings, from 0 for unrated to 5, and color labels, read from
+-- xmp:Rating and xmp:Label. Labels use Lightroom's names, such as
+-- "Red", and are empty when a photo has none.
+--
+ALTER TABLE photos ADD COLUMN rating integer NOT NULL DEFAULT 0;
+ALTER TABLE photos ADD COLUMN label text NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_photos_rating ON photos (rating);
@@ -0,0 +1,6 @@
+--
+-- Where edits to a photo's title, caption, keywords, and people are
+-- written: "sidecar" for an XMP file next to the photo, or "file" for
+-- the JPEG itself.
+--
+ALTER TABLE settings ADD COLUMN metadata_write_target text default 'sidecar';
@@ -0,0 +1,8 @@
+--
+-- Keywords can sit under a parent keyword, the way Lightroom's
+-- hierarchical keywords, such as "Places|Italy|Rome", do. Top level
+-- keywords have no parent.
+--
+ALTER TABLE keywords ADD COLUMN parent text;
+
+CREATE INDEX IF NOT EXISTS idx_keywords_parent ON keywords (parent);
@@ -0,0 +1,19 @@
+--
+-- Other names a person is known by. People are created from the names
+-- in photos' metadata, so one person can end up as "Bob", "Robert
+-- Smith", and "bob smith". Merging or renaming people keeps the old
+-- names here, and names read from photos are looked up here when they
+-- don't match a person, so later scans find the same person.
+--
+CREATE TABLE IF NOT EXISTS "person_aliases" (
+   alias text PRIMARY KEY COLLATE NOCASE,
+   person_id integer NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_person_aliases_person_id ON person_aliases (person_id);
+
+--
+-- The photo shown for a person in the people directory. When empty,
+-- their most recent photo is used.
+--
+ALTER TABLE people ADD COLUMN cover_photo_id text;
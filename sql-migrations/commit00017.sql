@@ -0,0 +1,19 @@
+--
+-- Named face rectangles read from photos' metadata, such as the
+-- regions Picasa, Lightroom, and digiKam write. The rectangle is a
+-- fraction of the photo's width and height, measured from its top
+-- left corner. person_id is empty when the name isn't a known person.
+--
+CREATE TABLE IF NOT EXISTS "photo_faces" (
+   id integer PRIMARY KEY AUTOINCREMENT,
+   photo_id text NOT NULL,
+   person_id integer,
+   name text NOT NULL DEFAULT '',
+   x real NOT NULL,
+   y real NOT NULL,
+   width real NOT NULL,
+   height real NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_photo_faces_photo_id ON photo_faces (photo_id);
+CREATE INDEX IF NOT EXISTS idx_photo_faces_person_id ON photo_faces (person_id);
@@ -0,0 +1,20 @@
+--
+-- Faces can also be found by the face detection job, which looks for
+-- them in photos' thumbnails. source is "metadata" for faces read from
+-- a photo's file and "detected" for those the job found. descriptor
+-- is what faces are compared by. cluster_id groups unnamed faces that
+-- look like the same person, and suggested_person_id is the named
+-- person they look most like, if anyone. Faces marked ignored, such as
+-- things that aren't faces at all, are left out of the review queue.
+--
+-- photos.faces_detected_at is when the job looked for faces in a photo.
+--
+ALTER TABLE photo_faces ADD COLUMN source text NOT NULL DEFAULT 'metadata';
+ALTER TABLE photo_faces ADD COLUMN descriptor blob;
+ALTER TABLE photo_faces ADD COLUMN cluster_id integer;
+ALTER TABLE photo_faces ADD COLUMN suggested_person_id integer;
+ALTER TABLE photo_faces ADD COLUMN ignored integer NOT NULL DEFAULT 0;
+ALTER TABLE photos ADD COLUMN faces_detected_at datetime;
+ALTER TABLE settings ADD COLUMN face_detection_schedule text default '';
+
+CREATE INDEX IF NOT EXISTS idx_photo_faces_cluster_id ON photo_faces (cluster_id);
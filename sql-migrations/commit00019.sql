@@ -0,0 +1,8 @@
+--
+-- photos.colors is the photo's palette of dominant colors, worked out
+-- from its thumbnail, as a JSON array of colors with their hex value,
+-- name, red, green, and blue values, and weight, which is the fraction
+-- of the photo they cover. It's NULL until the photo's thumbnail is
+-- next made.
+--
+ALTER TABLE photos ADD COLUMN colors text;
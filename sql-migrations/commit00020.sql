@@ -0,0 +1,32 @@
+--
+-- Events are runs of photos taken close together, in time and place,
+-- such as a day out or a week's holiday. They're worked out again
+-- after every scan, keeping the ID, and so the name, of the event
+-- most of a run's photos were already in. place_id is the gazetteer
+-- city most of its photos were taken in, and latitude and longitude
+-- are the middle of its photos' locations. Both are NULL when none
+-- of its photos has a location.
+--
+CREATE TABLE IF NOT EXISTS "events" (
+   id integer PRIMARY KEY AUTOINCREMENT,
+   created_at datetime,
+   updated_at datetime,
+   name text NOT NULL DEFAULT '',
+   start_date_time datetime,
+   end_date_time datetime,
+   num_photos integer NOT NULL DEFAULT 0,
+   place_id integer,
+   latitude real,
+   longitude real,
+   cover_photo_id text
+);
+
+CREATE INDEX IF NOT EXISTS idx_events_start_date_time ON events (start_date_time);
+
+--
+-- The event a photo is in. Photos without a date, or with an
+-- estimated one, aren't in an event.
+--
+ALTER TABLE photos ADD COLUMN event_id integer;
+
+CREATE INDEX IF NOT EXISTS idx_photos_event_id ON photos (event_id);
@@ -0,0 +1,7 @@
+--
+-- When the photo's thumbnail, placeholder hash, and palette were last
+-- made. Photos whose placeholder hash couldn't be worked out keep an
+-- empty hash, and this tells them apart from photos collected before
+-- placeholder hashes existed, so they aren't made again on every scan.
+--
+ALTER TABLE photos ADD COLUMN thumbnail_created_at datetime;
@@ -0,0 +1,6 @@
+--
+-- The size, in pixels along the longest edge, the photo's thumbnail
+-- was made at. It's part of the thumbnail's version, so changing the
+-- thumbnail size gives thumbnails new URLs once they're made again.
+--
+ALTER TABLE photos ADD COLUMN thumbnail_size integer;
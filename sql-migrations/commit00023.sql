@@ -0,0 +1,7 @@
+--
+-- Identifies the named face regions the photo's file had when it was
+-- last collected. Face regions aren't part of the metadata hash, as
+-- photos loaded from the database don't have them, so they are
+-- compared on their own to find photos whose faces changed.
+--
+ALTER TABLE photos ADD COLUMN faces_hash text;